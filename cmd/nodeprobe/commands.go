@@ -0,0 +1,629 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"nodeprobe/internal/app"
+	"nodeprobe/internal/domain"
+	"nodeprobe/internal/pkg/auth"
+	"nodeprobe/internal/pkg/boltdb"
+	"nodeprobe/internal/pkg/config"
+	"nodeprobe/internal/pkg/signing"
+	"nodeprobe/internal/pkg/sqlite"
+	"nodeprobe/internal/pkg/tls"
+)
+
+const usage = `Usage: nodeprobe [command]
+
+Without a command, nodeprobe starts the node.
+
+Commands:
+  ca init [-dir DIR]                     Create a cluster CA (ca.crt, ca.key)
+  ca sign -node-id ID [-dns NAMES] [-ip ADDRS] [-key-type TYPE] [-days N] [-out DIR]
+                                         Issue a node certificate signed by the cluster CA
+  signing pubkey [-key PATH]             Print this node's snapshot signing public key,
+                                         creating the key if needed
+  token create -name NAME -role ROLE     Generate an API token (role viewer, operator or peer)
+                                         and print its auth.json entry
+  db export [-format FORMAT] [-table TABLE] [-since T] [-until T] [-out FILE]
+                                         Export nodes and poll results as ndjson, csv or parquet
+  db import [-format FORMAT] FILE        Merge an export into this node's database
+  db backup [-dir DIR]                   Write a hot backup of the database and node identity
+  db restore [-force] [BACKUP]           Validate a backup (default the newest) and restore it;
+                                         stop the node first
+  silence add [-node ID] [-rule NAME] [-label K=V,...] [-start T] [-duration D] [-comment TEXT]
+                                         Silence matching alert notifications (default for 2h)
+  silence list                           List active and upcoming silences
+  silence rm ID                          Remove a silence
+  slo report [-month YYYY-MM] [-format FORMAT] [-out FILE]
+                                         Monthly SLO report as json or csv (default this month)
+`
+
+// runCommand dispatches a nodeprobe subcommand
+func runCommand(args []string) error {
+	switch args[0] {
+	case "ca":
+		return runCACommand(args[1:])
+	case "signing":
+		return runSigningCommand(args[1:])
+	case "token":
+		return runTokenCommand(args[1:])
+	case "db":
+		return runDBCommand(args[1:])
+	case "silence":
+		return runSilenceCommand(args[1:])
+	case "slo":
+		return runSLOCommand(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func runCACommand(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("missing ca subcommand")
+	}
+
+	switch args[0] {
+	case "init":
+		flags := flag.NewFlagSet("ca init", flag.ContinueOnError)
+		dir := flags.String("dir", certDir, "directory to write ca.crt and ca.key to")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		certPath, keyPath, err := tls.InitCA(*dir)
+		if err != nil {
+			return fmt.Errorf("failed to initialize CA: %w", err)
+		}
+
+		fmt.Printf("Created cluster CA certificate %s and key %s\n", certPath, keyPath)
+		fmt.Println("Copy ca.crt to every node's certificate directory and enable mutual_tls in tls.json.")
+		fmt.Println("Nodes that also have ca.key issue their own certificates; otherwise use 'nodeprobe ca sign'.")
+		return nil
+
+	case "sign":
+		flags := flag.NewFlagSet("ca sign", flag.ContinueOnError)
+		dir := flags.String("dir", certDir, "directory containing ca.crt and ca.key")
+		nodeID := flags.String("node-id", "", "UUID of the node, from its node.id file")
+		dnsNames := flags.String("dns", "", "comma-separated DNS names for the certificate")
+		ipList := flags.String("ip", "", "comma-separated IP addresses for the certificate")
+		keyType := flags.String("key-type", domain.KeyTypeECDSAP256, "key type: rsa, ecdsa-p256 or ed25519")
+		days := flags.Int("days", 365, "certificate validity in days")
+		outDir := flags.String("out", ".", "directory to write server.crt and server.key to")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		if *nodeID == "" {
+			return fmt.Errorf("-node-id is required")
+		}
+
+		var ips []net.IP
+		for _, value := range splitList(*ipList) {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return fmt.Errorf("invalid IP address %q", value)
+			}
+			ips = append(ips, ip)
+		}
+
+		ca, err := tls.LoadCA(filepath.Join(*dir, "ca.crt"), filepath.Join(*dir, "ca.key"))
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(*outDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+
+		certPath := filepath.Join(*outDir, "server.crt")
+		keyPath := filepath.Join(*outDir, "server.key")
+		opts := tls.CertOptions{KeyType: *keyType, Validity: time.Duration(*days) * 24 * time.Hour}
+		if err := ca.IssueNodeCert(*nodeID, splitList(*dnsNames), ips, opts, certPath, keyPath); err != nil {
+			return err
+		}
+
+		fmt.Printf("Issued certificate for node %s: %s, %s\n", *nodeID, certPath, keyPath)
+		return nil
+
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown ca subcommand %q", args[0])
+	}
+}
+
+func runSigningCommand(args []string) error {
+	if len(args) == 0 || args[0] != "pubkey" {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown signing subcommand")
+	}
+
+	flags := flag.NewFlagSet("signing pubkey", flag.ContinueOnError)
+	keyPath := flags.String("key", filepath.Join(dataDir, "signing.key"), "path to the Ed25519 signing key")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	privateKey, err := signing.LoadOrGenerateKey(*keyPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(base64.StdEncoding.EncodeToString(privateKey.Public().(ed25519.PublicKey)))
+	return nil
+}
+
+func runTokenCommand(args []string) error {
+	if len(args) == 0 || args[0] != "create" {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown token subcommand")
+	}
+
+	flags := flag.NewFlagSet("token create", flag.ContinueOnError)
+	name := flags.String("name", "", "name identifying the token holder")
+	role := flags.String("role", domain.RoleViewer, "role granted to the token: viewer, operator or peer")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+	if !auth.ValidRole(*role) {
+		return fmt.Errorf("unknown role %q", *role)
+	}
+
+	token, err := auth.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	entry, err := json.MarshalIndent(domain.APIToken{
+		Name:        *name,
+		Role:        *role,
+		TokenSHA256: auth.HashToken(token),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode token entry: %w", err)
+	}
+
+	fmt.Printf("Token (shown once, store it securely): %s\n\n", token)
+	fmt.Println("Add to the \"tokens\" list in auth.json:")
+	fmt.Println(string(entry))
+	return nil
+}
+
+func runDBCommand(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("missing db subcommand")
+	}
+
+	switch args[0] {
+	case "export":
+		flags := flag.NewFlagSet("db export", flag.ContinueOnError)
+		format := flags.String("format", domain.ExportFormatNDJSON, "output format: ndjson, csv or parquet")
+		table := flags.String("table", "", "export only nodes or poll_results (required for csv and parquet)")
+		since := flags.String("since", "", "start of the poll result range, RFC 3339 or a duration ago (default 24h before -until)")
+		until := flags.String("until", "", "end of the poll result range, RFC 3339 or a duration ago (default now)")
+		out := flags.String("out", "", "output file (default stdout)")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		opts := domain.ExportOptions{Format: *format, Table: *table}
+		var err error
+		if opts.Since, err = app.ParseExportTime(*since, now); err != nil {
+			return err
+		}
+		if opts.Until, err = app.ParseExportTime(*until, now); err != nil {
+			return err
+		}
+
+		configSvc, _, store, err := openDataStore()
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		exportService := app.NewExportService(store, store, configSvc)
+
+		if *out == "" {
+			return exportService.Export(context.Background(), os.Stdout, opts)
+		}
+
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		if err := exportService.Export(context.Background(), f, opts); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+
+	case "import":
+		flags := flag.NewFlagSet("db import", flag.ContinueOnError)
+		format := flags.String("format", "", "input format: ndjson, csv or parquet (default from the file extension)")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return fmt.Errorf("db import takes one export file, or - for stdin")
+		}
+
+		path := flags.Arg(0)
+		if *format == "" {
+			switch ext := filepath.Ext(path); {
+			case strings.EqualFold(ext, ".csv"):
+				*format = domain.ExportFormatCSV
+			case strings.EqualFold(ext, ".parquet"):
+				*format = domain.ExportFormatParquet
+			default:
+				*format = domain.ExportFormatNDJSON
+			}
+		}
+
+		r := os.Stdin
+		if path != "-" {
+			f, err := os.Open(path)
+			if err != nil {
+				return fmt.Errorf("failed to open export file: %w", err)
+			}
+			defer f.Close()
+			r = f
+		}
+
+		configSvc, _, store, err := openDataStore()
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		exportService := app.NewExportService(store, store, configSvc)
+
+		stats, err := exportService.Import(context.Background(), r, *format)
+		if stats != nil {
+			fmt.Printf("Nodes: %d added, %d updated, %d skipped\n", stats.NodesAdded, stats.NodesUpdated, stats.NodesSkipped)
+			fmt.Printf("Poll results: %d added, %d duplicates skipped\n", stats.PollResults, stats.DuplicatePolls)
+		}
+		return err
+
+	case "backup":
+		flags := flag.NewFlagSet("db backup", flag.ContinueOnError)
+		dir := flags.String("dir", "", "backup directory (default from backup.json)")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		configSvc, storageConfig, store, err := openDataStore()
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		backupConfig, err := configSvc.LoadBackupConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load backup config: %w", err)
+		}
+		if *dir != "" {
+			backupConfig.Dir = *dir
+		}
+
+		backupService := app.NewBackupService(store, configSvc, dataDir, storeFileName(storageConfig.Backend))
+		backup, err := backupService.Backup(context.Background(), backupConfig)
+		if err != nil {
+			return err
+		}
+		fmt.Println(backup.Path)
+		return nil
+
+	case "restore":
+		flags := flag.NewFlagSet("db restore", flag.ContinueOnError)
+		force := flags.Bool("force", false, "restore even if the database has a write-ahead log")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() > 1 {
+			return fmt.Errorf("db restore takes at most one backup directory")
+		}
+		return restoreBackup(flags.Arg(0), *force)
+
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown db subcommand %q", args[0])
+	}
+}
+
+func runSilenceCommand(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("missing silence subcommand")
+	}
+
+	var silence domain.Silence
+	var silenceID int64
+
+	switch args[0] {
+	case "add":
+		flags := flag.NewFlagSet("silence add", flag.ContinueOnError)
+		node := flags.String("node", "", "silence alerts for this node ID")
+		rule := flags.String("rule", "", "silence alerts of this rule")
+		labels := flags.String("label", "", "silence alerts carrying these labels, as name=value pairs separated by commas")
+		start := flags.String("start", "", "start time, RFC 3339 (default now)")
+		duration := flags.Duration("duration", 2*time.Hour, "how long the silence lasts")
+		comment := flags.String("comment", "", "why the alerts are silenced")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		silence = domain.Silence{NodeID: *node, Rule: *rule, Comment: *comment, CreatedBy: "cli"}
+		if user := os.Getenv("USER"); user != "" {
+			silence.CreatedBy = "cli:" + user
+		}
+		for _, pair := range splitList(*labels) {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok || name == "" {
+				return fmt.Errorf("label %q is not name=value", pair)
+			}
+			if silence.Labels == nil {
+				silence.Labels = make(map[string]string)
+			}
+			silence.Labels[name] = value
+		}
+
+		silence.StartsAt = time.Now()
+		if *start != "" {
+			startsAt, err := time.Parse(time.RFC3339, *start)
+			if err != nil {
+				return fmt.Errorf("invalid -start: %w", err)
+			}
+			silence.StartsAt = startsAt
+		}
+		silence.EndsAt = silence.StartsAt.Add(*duration)
+
+	case "list":
+
+	case "rm":
+		if len(args) != 2 {
+			return fmt.Errorf("silence rm takes one silence ID")
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid silence ID %q", args[1])
+		}
+		silenceID = id
+
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown silence subcommand %q", args[0])
+	}
+
+	configSvc, _, store, err := openDataStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	silenceService := app.NewSilenceService(store, configSvc)
+	ctx := context.Background()
+
+	switch args[0] {
+	case "add":
+		if err := silenceService.CreateSilence(ctx, &silence); err != nil {
+			return err
+		}
+		fmt.Printf("Silence %d active from %s until %s\n", silence.ID,
+			silence.StartsAt.Format(time.RFC3339), silence.EndsAt.Format(time.RFC3339))
+
+	case "list":
+		silences, err := silenceService.GetSilences(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTARTS\tENDS\tMATCHES\tCREATED BY\tCOMMENT")
+		for _, silence := range silences {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", silence.ID,
+				silence.StartsAt.Format(time.RFC3339), silence.EndsAt.Format(time.RFC3339),
+				describeSilence(&silence), silence.CreatedBy, silence.Comment)
+		}
+		return w.Flush()
+
+	case "rm":
+		if err := silenceService.DeleteSilence(ctx, silenceID); err != nil {
+			return err
+		}
+		fmt.Printf("Silence %d removed\n", silenceID)
+	}
+
+	return nil
+}
+
+// runSLOCommand writes the SLO report for a month from the local database
+// as JSON or CSV
+func runSLOCommand(args []string) error {
+	if len(args) == 0 || args[0] != "report" {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("missing or unknown slo subcommand")
+	}
+
+	flags := flag.NewFlagSet("slo report", flag.ContinueOnError)
+	month := flags.String("month", "", "month to report on as YYYY-MM (default the current month)")
+	format := flags.String("format", "json", "output format: json or csv")
+	out := flags.String("out", "", "output file (default stdout)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *format != "json" && *format != domain.ExportFormatCSV {
+		return fmt.Errorf("unknown format %q, use json or csv", *format)
+	}
+
+	reportMonth, err := app.ParseSLOMonth(*month, time.Now())
+	if err != nil {
+		return err
+	}
+
+	configSvc, _, store, err := openDataStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	sloService := app.NewSLOService(store, store, configSvc, nil)
+	if err := sloService.Initialize(); err != nil {
+		return err
+	}
+	report, err := sloService.GetSLOReport(context.Background(), reportMonth)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+	}
+	if *format == domain.ExportFormatCSV {
+		err = app.WriteSLOReportCSV(w, report)
+	} else {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	}
+	if *out != "" {
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// describeSilence lists a silence's matchers as name=value pairs
+func describeSilence(silence *domain.Silence) string {
+	var matchers []string
+	if silence.NodeID != "" {
+		matchers = append(matchers, "node="+silence.NodeID)
+	}
+	if silence.Rule != "" {
+		matchers = append(matchers, "rule="+silence.Rule)
+	}
+	names := make([]string, 0, len(silence.Labels))
+	for name := range silence.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		matchers = append(matchers, name+"="+silence.Labels[name])
+	}
+	return strings.Join(matchers, ",")
+}
+
+// restoreBackup validates a backup, or the newest one in the configured
+// backup directory, and swaps it into the data directory
+func restoreBackup(backupDir string, force bool) error {
+	configSvc, err := config.NewService(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize config service: %w", err)
+	}
+
+	storageConfig, err := configSvc.LoadStorageConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load storage config: %w", err)
+	}
+
+	if backupDir == "" {
+		backupConfig, err := configSvc.LoadBackupConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load backup config: %w", err)
+		}
+		backups, err := app.ListBackups(backupConfig.Dir)
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			return fmt.Errorf("no backups in %s", backupConfig.Dir)
+		}
+		backupDir = backups[len(backups)-1].Path
+	}
+
+	dbName := storeFileName(storageConfig.Backend)
+	path := filepath.Join(backupDir, dbName)
+	switch storageConfig.Backend {
+	case domain.StorageBackendSQLite:
+		version, err := sqlite.ValidateBackup(path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Backup schema version %d (this build: %d)\n", version, sqlite.SchemaVersion())
+	case domain.StorageBackendBolt:
+		if err := boltdb.ValidateBackup(path); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("the %s backend has nothing to restore", storageConfig.Backend)
+	}
+
+	if err := app.RestoreBackup(backupDir, dataDir, dbName, force); err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %s into %s; replaced files were kept with a .pre-restore suffix\n", backupDir, dataDir)
+	return nil
+}
+
+// openDataStore opens this node's configured store for the db, silence and
+// slo commands
+func openDataStore() (*config.Service, *domain.StorageConfig, domain.Store, error) {
+	configSvc, err := config.NewService(dataDir)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to initialize config service: %w", err)
+	}
+
+	storageConfig, err := configSvc.LoadStorageConfig()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load storage config: %w", err)
+	}
+	if storageConfig.Backend == domain.StorageBackendMemory {
+		return nil, nil, nil, fmt.Errorf("the memory backend keeps nothing on disk; use the API of the running node")
+	}
+
+	store, err := openStore(configSvc, dataDir)
+	if err != nil {
+		if storageConfig.Backend == domain.StorageBackendBolt {
+			return nil, nil, nil, fmt.Errorf("%w (bbolt databases are locked while the node runs; stop it or use the API)", err)
+		}
+		return nil, nil, nil, err
+	}
+
+	return configSvc, storageConfig, store, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
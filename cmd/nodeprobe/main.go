@@ -11,8 +11,14 @@ import (
 	"time"
 
 	"nodeprobe/internal/app"
+	"nodeprobe/internal/domain"
+	"nodeprobe/internal/pkg/auth"
+	"nodeprobe/internal/pkg/boltdb"
 	"nodeprobe/internal/pkg/config"
 	"nodeprobe/internal/pkg/http"
+	"nodeprobe/internal/pkg/memory"
+	"nodeprobe/internal/pkg/notify"
+	"nodeprobe/internal/pkg/signing"
 	"nodeprobe/internal/pkg/sqlite"
 	"nodeprobe/internal/pkg/tls"
 )
@@ -23,6 +29,14 @@ const (
 )
 
 func main() {
+	// Subcommands such as "ca init" run and exit without starting the node
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+
 	log.Println("Starting NodeProbe...")
 
 	// Set up signal handling for graceful shutdown
@@ -71,8 +85,7 @@ func run(ctx context.Context) error {
 	}
 
 	// Initialize database
-	dbPath := filepath.Join(dataDir, "nodeprobe.db")
-	repo, err := sqlite.NewRepository(dbPath)
+	repo, err := openStore(configSvc, dataDir)
 	if err != nil {
 		return fmt.Errorf("failed to create repository: %w", err)
 	}
@@ -82,31 +95,127 @@ func run(ctx context.Context) error {
 		}
 	}()
 
+	// Initialize TLS service
+	tlsConfig, err := configSvc.LoadTLSConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load TLS config: %w", err)
+	}
+	nodeID, err := configSvc.GetNodeID()
+	if err != nil {
+		return fmt.Errorf("failed to get node ID: %w", err)
+	}
+	tlsService := tls.NewService(certDir, nodeID, tlsConfig)
+	if tlsConfig.MutualTLS {
+		log.Println("Mutual TLS enabled: peers must present certificates issued by the cluster CA")
+	}
+
+	// Initialize snapshot signing
+	signingConfig, err := configSvc.LoadSigningConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load signing config: %w", err)
+	}
+	signingService, err := signing.NewService(dataDir, nodeID, signingConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create signing service: %w", err)
+	}
+	if signingService.Enabled() {
+		log.Printf("Snapshot signing enabled (%s): unsigned or untrusted snapshots are rejected", signingConfig.Mode)
+		if publicKey := signingService.PublicKey(); publicKey != "" {
+			log.Printf("Snapshot signing public key: %s", publicKey)
+		}
+	}
+
+	// Initialize API token authentication
+	authConfig, err := configSvc.LoadAuthConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load auth config: %w", err)
+	}
+	authService, err := auth.NewService(authConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create auth service: %w", err)
+	}
+	if authService.Enabled() {
+		log.Printf("API token authentication enabled with %d token(s)", len(authConfig.Tokens))
+	}
+
 	// Initialize HTTP client
-	httpClient := http.NewClient()
+	httpClient, err := http.NewClient(tlsService.ClientTLSConfig, signingService, authService.PeerToken())
+	if err != nil {
+		return fmt.Errorf("failed to create client TLS configuration: %w", err)
+	}
 	defer func() {
 		if err := httpClient.Close(); err != nil {
 			log.Printf("Failed to close HTTP client: %v", err)
 		}
 	}()
 
-	// Initialize TLS service
-	tlsService := tls.NewService(certDir)
-
 	// Initialize node service
 	nodeService := app.NewNodeService(repo, configSvc)
 	if err := nodeService.Initialize(ctx); err != nil {
 		return fmt.Errorf("failed to initialize node service: %w", err)
 	}
 
+	// Initialize coordinate service
+	coordinateService := app.NewCoordinateService(nodeService, configSvc)
+	if err := coordinateService.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize coordinate service: %w", err)
+	}
+
 	// Initialize polling service
-	pollingService := app.NewPollingService(nodeService, repo, httpClient, configSvc)
+	pollingService := app.NewPollingService(nodeService, repo, httpClient, coordinateService, configSvc)
+
+	// Load dashboard templates unless running headless
+	dashboardConfig, err := configSvc.LoadDashboardConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load dashboard config: %w", err)
+	}
+
+	var renderer *app.TemplateRenderer
+	if dashboardConfig.JSONOnly {
+		log.Println("Dashboard running in JSON-only mode")
+	} else {
+		renderer, err = app.NewTemplateRenderer(dashboardConfig.TemplateDir)
+		if err != nil {
+			return fmt.Errorf("failed to load dashboard templates: %w", err)
+		}
+	}
+
+	// Initialize retention service
+	retentionService := app.NewRetentionService(repo, configSvc)
+
+	// Initialize anomaly service
+	anomalyService := app.NewAnomalyService(repo, configSvc)
+	if err := anomalyService.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize anomaly service: %w", err)
+	}
 
 	// Initialize reporting service
-	reportingService := app.NewReportingService(nodeService, httpClient, configSvc, repo)
+	reportingService := app.NewReportingService(nodeService, httpClient, configSvc, repo, repo, anomalyService, renderer)
+
+	// Initialize backup service
+	storageConfig, err := configSvc.LoadStorageConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load storage config: %w", err)
+	}
+	backupService := app.NewBackupService(repo, configSvc, dataDir, storeFileName(storageConfig.Backend))
+
+	// Initialize alert service
+	alertService := app.NewAlertService(nodeService, repo, repo, repo, anomalyService, notify.NewNotifier(), configSvc)
+
+	// Initialize silence service
+	silenceService := app.NewSilenceService(repo, configSvc)
+
+	// Initialize SLO service
+	sloService := app.NewSLOService(repo, repo, configSvc, renderer)
+	if err := sloService.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize SLO service: %w", err)
+	}
+
+	// Initialize export service
+	exportService := app.NewExportService(repo, repo, configSvc)
 
 	// Initialize web server
-	webServer := app.NewWebServer(nodeService, reportingService, configSvc, tlsService)
+	webServer := app.NewWebServer(nodeService, reportingService, exportService, silenceService, sloService, anomalyService, coordinateService, configSvc, tlsService, signingService, authService, renderer)
 
 	// Start all services
 	log.Println("Starting services...")
@@ -129,6 +238,26 @@ func run(ctx context.Context) error {
 		return fmt.Errorf("failed to start reporting service: %w", err)
 	}
 
+	// Start retention service
+	if err := retentionService.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start retention service: %w", err)
+	}
+
+	// Start backup service
+	if err := backupService.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start backup service: %w", err)
+	}
+
+	// Start anomaly service
+	if err := anomalyService.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start anomaly service: %w", err)
+	}
+
+	// Start alert service
+	if err := alertService.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start alert service: %w", err)
+	}
+
 	// Get node information for logging
 	nodeInfo, err := configSvc.GetNodeInfo()
 	if err != nil {
@@ -144,23 +273,6 @@ func run(ctx context.Context) error {
 		log.Printf("Health Check: https://%s:443/health", nodeInfo.FQDN)
 	}
 
-	// Start cleanup routine
-	go func() {
-		ticker := time.NewTicker(1 * time.Hour)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				if err := pollingService.CleanupOldResults(ctx); err != nil {
-					log.Printf("Failed to cleanup old poll results: %v", err)
-				}
-			}
-		}
-	}()
-
 	// Keep the main goroutine alive and handle context cancellation
 	<-ctx.Done()
 
@@ -171,6 +283,22 @@ func run(ctx context.Context) error {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	if err := alertService.Stop(); err != nil {
+		log.Printf("Error stopping alert service: %v", err)
+	}
+
+	if err := anomalyService.Stop(); err != nil {
+		log.Printf("Error stopping anomaly service: %v", err)
+	}
+
+	if err := backupService.Stop(); err != nil {
+		log.Printf("Error stopping backup service: %v", err)
+	}
+
+	if err := retentionService.Stop(); err != nil {
+		log.Printf("Error stopping retention service: %v", err)
+	}
+
 	if err := reportingService.Stop(); err != nil {
 		log.Printf("Error stopping reporting service: %v", err)
 	}
@@ -185,3 +313,36 @@ func run(ctx context.Context) error {
 
 	return nil
 }
+
+// openStore opens the storage backend selected in storage.json
+func openStore(configSvc *config.Service, dataDir string) (domain.Store, error) {
+	storageConfig, err := configSvc.LoadStorageConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load storage config: %w", err)
+	}
+
+	path := filepath.Join(dataDir, storeFileName(storageConfig.Backend))
+	switch storageConfig.Backend {
+	case domain.StorageBackendBolt:
+		log.Println("Using bbolt storage backend")
+		return boltdb.NewStore(path)
+	case domain.StorageBackendMemory:
+		log.Println("Using in-memory storage backend; data is lost on restart")
+		return memory.NewStore(), nil
+	default:
+		return sqlite.NewRepository(path)
+	}
+}
+
+// storeFileName returns the database file name of a storage backend, in the
+// data directory and in backups
+func storeFileName(backend string) string {
+	switch backend {
+	case domain.StorageBackendBolt:
+		return "nodeprobe.bolt"
+	case domain.StorageBackendMemory:
+		return ""
+	default:
+		return "nodeprobe.db"
+	}
+}
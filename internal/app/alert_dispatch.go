@@ -0,0 +1,249 @@
+package app
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sort"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// alertGroup tracks what each channel has been told about one rule's
+// alerts, so a partition taking down many nodes sends one notification and
+// each channel hears about every alert once, plus any repeats
+type alertGroup struct {
+	createdAt time.Time
+	channels  map[string]*channelState
+}
+
+type channelState struct {
+	lastSent time.Time
+	notified map[string]bool // node IDs of firing alerts the channel was told about
+	resolved []domain.Alert  // notified alerts that resolved since the last send
+}
+
+func newAlertGroup(createdAt time.Time) *alertGroup {
+	return &alertGroup{createdAt: createdAt, channels: make(map[string]*channelState)}
+}
+
+func (g *alertGroup) channel(name string) *channelState {
+	state, ok := g.channels[name]
+	if !ok {
+		state = &channelState{notified: make(map[string]bool)}
+		g.channels[name] = state
+	}
+	return state
+}
+
+// idle reports whether no channel awaits news of the group's alerts
+func (g *alertGroup) idle() bool {
+	for _, state := range g.channels {
+		if len(state.notified) > 0 || len(state.resolved) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// restoreNotified records an alert restored after a restart as already
+// notified on all of its rule's channels
+func (as *AlertService) restoreNotified(alert *domain.Alert) {
+	rule := as.rule(alert.Rule)
+	group, ok := as.groups[rule.Name]
+	if !ok {
+		group = newAlertGroup(time.Time{})
+		as.groups[rule.Name] = group
+	}
+
+	for _, channel := range as.channelsFor(rule) {
+		state := group.channel(channel.Name)
+		state.notified[alert.NodeID] = true
+		if alert.FiredAt.After(state.lastSent) {
+			state.lastSent = alert.FiredAt
+		}
+	}
+}
+
+// queueResolved queues a resolved alert for the channels that were told it
+// was firing
+func (as *AlertService) queueResolved(alert *domain.Alert) {
+	group, ok := as.groups[alert.Rule]
+	if !ok {
+		return
+	}
+
+	for _, state := range group.channels {
+		if state.notified[alert.NodeID] {
+			delete(state.notified, alert.NodeID)
+			state.resolved = append(state.resolved, *alert)
+		}
+	}
+}
+
+// channelsFor returns the channels a rule notifies: those it names, or all
+func (as *AlertService) channelsFor(rule *domain.AlertRule) []*domain.AlertChannel {
+	var channels []*domain.AlertChannel
+	for i := range as.config.Channels {
+		channel := &as.config.Channels[i]
+		if len(rule.Channels) == 0 || slices.Contains(rule.Channels, channel.Name) {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// alertLabels returns the labels silences and maintenance windows match:
+// the node's labels, overridden by the rule's labels and its severity
+func alertLabels(rule *domain.AlertRule, nodeLabels map[string]string) map[string]string {
+	labels := make(map[string]string, len(nodeLabels)+len(rule.Labels)+1)
+	for name, value := range nodeLabels {
+		labels[name] = value
+	}
+	for name, value := range rule.Labels {
+		labels[name] = value
+	}
+	labels["severity"] = rule.Severity
+	return labels
+}
+
+// silenced reports whether a silence or maintenance window covers the
+// alert at now
+func (as *AlertService) silenced(rule *domain.AlertRule, alert *domain.Alert, nodeLabels map[string]string, silences []domain.Silence, now time.Time) bool {
+	labels := alertLabels(rule, nodeLabels)
+
+	for i := range as.config.MaintenanceWindows {
+		window := &as.config.MaintenanceWindows[i]
+		if window.Matches(alert.NodeID, rule.Name, labels) && window.ActiveAt(now) {
+			return true
+		}
+	}
+	for i := range silences {
+		silence := &silences[i]
+		if silence.Matches(alert.NodeID, rule.Name, labels) && silence.ActiveAt(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch notifies each rule's channels of alerts they have not heard
+// about: newly firing alerts that are not silenced, and resolved alerts
+// they were told were firing. A new group waits GroupWaitSeconds so alerts
+// firing together are sent together; channels with a repeat interval are
+// reminded of alerts that keep firing. News of flapping nodes is held back
+// until they settle. Failed sends are retried at the next evaluation.
+func (as *AlertService) dispatch(ctx context.Context, now time.Time, silences []domain.Silence, flapping map[string]bool, nodeLabels map[string]map[string]string) {
+	groupWait := time.Duration(as.config.GroupWaitSeconds) * time.Second
+
+	for i := range as.config.Rules {
+		rule := &as.config.Rules[i]
+
+		var firing []domain.Alert
+		for key, alert := range as.active {
+			if key.rule == rule.Name && alert.State == domain.AlertStateFiring && !as.silenced(rule, alert, nodeLabels[alert.NodeID], silences, now) {
+				firing = append(firing, *alert)
+			}
+		}
+		sort.Slice(firing, func(i, j int) bool { return firing[i].NodeID < firing[j].NodeID })
+
+		group, ok := as.groups[rule.Name]
+		if !ok {
+			if len(firing) == 0 {
+				continue
+			}
+			group = newAlertGroup(now)
+			as.groups[rule.Name] = group
+		}
+		if now.Sub(group.createdAt) < groupWait {
+			continue
+		}
+
+		for _, channel := range as.channelsFor(rule) {
+			as.notifyChannel(ctx, rule, channel, group.channel(channel.Name), firing, flapping, now)
+		}
+
+		if len(firing) == 0 && group.idle() {
+			delete(as.groups, rule.Name)
+		}
+	}
+}
+
+// notifyChannel sends channel the firing and resolved alerts of rule it has
+// not heard about, if any, or a reminder when its repeat interval is up
+func (as *AlertService) notifyChannel(
+	ctx context.Context,
+	rule *domain.AlertRule,
+	channel *domain.AlertChannel,
+	state *channelState,
+	firing []domain.Alert,
+	flapping map[string]bool,
+	now time.Time,
+) {
+	// A flapping node's alert firing again before its resolution went out
+	// is the same outage, not news
+	for _, alert := range firing {
+		if !flapping[alert.NodeID] || state.notified[alert.NodeID] {
+			continue
+		}
+		i := slices.IndexFunc(state.resolved, func(resolved domain.Alert) bool { return resolved.NodeID == alert.NodeID })
+		if i >= 0 {
+			state.resolved = slices.Delete(state.resolved, i, i+1)
+			state.notified[alert.NodeID] = true
+		}
+	}
+
+	var sendFiring, sendResolved, held []domain.Alert
+	due := false
+	for _, alert := range firing {
+		switch {
+		case state.notified[alert.NodeID]:
+			sendFiring = append(sendFiring, alert)
+		case !flapping[alert.NodeID]:
+			sendFiring = append(sendFiring, alert)
+			due = true
+		}
+	}
+	for _, alert := range state.resolved {
+		if flapping[alert.NodeID] {
+			held = append(held, alert)
+		} else {
+			sendResolved = append(sendResolved, alert)
+			due = true
+		}
+	}
+
+	repeat := time.Duration(channel.RepeatIntervalMinutes) * time.Minute
+	if len(sendFiring) > 0 && repeat > 0 && now.Sub(state.lastSent) >= repeat {
+		due = true
+	}
+	if !due {
+		return
+	}
+
+	notification := &domain.AlertNotification{
+		ReportingNode: as.nodeID,
+		Rule:          rule.Name,
+		Severity:      rule.Severity,
+		State:         domain.AlertStateResolved,
+		Alerts:        append(sendFiring, sendResolved...),
+	}
+	if len(sendFiring) > 0 {
+		notification.State = domain.AlertStateFiring
+	}
+
+	notifyCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	err := as.notifier.Notify(notifyCtx, channel, notification)
+	cancel()
+	if err != nil {
+		log.Printf("Failed to notify %s of alert %s: %v", channel.Name, rule.Name, err)
+		return
+	}
+
+	state.lastSent = now
+	for _, alert := range sendFiring {
+		state.notified[alert.NodeID] = true
+	}
+	state.resolved = held
+}
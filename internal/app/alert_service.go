@@ -0,0 +1,434 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// AlertService evaluates the rules in alerts.json against node state and
+// poll results, moves alerts through pending, firing and resolved, stores
+// them when they fire and notifies the rule's channels, holding back news of
+// silenced and flapping nodes
+type AlertService struct {
+	nodeService domain.NodeService
+	pollRepo    domain.PollRepository
+	alertRepo   domain.AlertRepository
+	silenceRepo domain.SilenceRepository
+	anomalies   domain.AnomalyService
+	notifier    domain.AlertNotifier
+	configSvc   domain.ConfigService
+
+	config *domain.AlertConfig
+	nodeID string
+	active map[alertKey]*domain.Alert // pending and firing alerts
+	groups map[string]*alertGroup     // by rule name
+
+	running  bool
+	stopChan chan struct{}
+	mu       sync.RWMutex
+}
+
+// alertKey identifies the alert a rule raises for one node
+type alertKey struct {
+	rule   string
+	nodeID string
+}
+
+// notifyTimeout bounds each delivery so a dead channel cannot stall evaluation
+const notifyTimeout = 10 * time.Second
+
+func NewAlertService(
+	nodeService domain.NodeService,
+	pollRepo domain.PollRepository,
+	alertRepo domain.AlertRepository,
+	silenceRepo domain.SilenceRepository,
+	anomalies domain.AnomalyService,
+	notifier domain.AlertNotifier,
+	configSvc domain.ConfigService,
+) *AlertService {
+	return &AlertService{
+		nodeService: nodeService,
+		pollRepo:    pollRepo,
+		alertRepo:   alertRepo,
+		silenceRepo: silenceRepo,
+		anomalies:   anomalies,
+		notifier:    notifier,
+		configSvc:   configSvc,
+		active:      make(map[alertKey]*domain.Alert),
+		groups:      make(map[string]*alertGroup),
+		stopChan:    make(chan struct{}),
+	}
+}
+
+func (as *AlertService) Start(ctx context.Context) error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	if as.running {
+		return fmt.Errorf("alert service is already running")
+	}
+
+	if err := as.load(ctx); err != nil {
+		return err
+	}
+	if len(as.config.Rules) == 0 {
+		log.Println("Alerting is disabled (no rules in alerts.json)")
+		return nil
+	}
+
+	as.running = true
+	go as.alertLoop(ctx)
+
+	log.Printf("Alert service started (%d rules, %d channels, every %ds)",
+		len(as.config.Rules), len(as.config.Channels), as.config.EvaluationIntervalSeconds)
+	return nil
+}
+
+func (as *AlertService) Stop() error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	if !as.running {
+		return nil
+	}
+
+	close(as.stopChan)
+	as.running = false
+
+	log.Println("Alert service stopped")
+	return nil
+}
+
+// load reads the config and picks up the alerts still firing from before a
+// restart, so they resolve instead of firing again. They are assumed to have
+// been notified. Alerts whose rule was removed from the config are resolved
+// now.
+func (as *AlertService) load(ctx context.Context) error {
+	config, err := as.configSvc.LoadAlertConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load alert config: %w", err)
+	}
+	nodeID, err := as.configSvc.GetNodeID()
+	if err != nil {
+		return fmt.Errorf("failed to get node ID: %w", err)
+	}
+	as.config = config
+	as.nodeID = nodeID
+
+	firing, err := as.alertRepo.GetFiringAlerts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get firing alerts: %w", err)
+	}
+
+	now := time.Now()
+	for i := range firing {
+		alert := &firing[i]
+		if as.rule(alert.Rule) == nil {
+			alert.State = domain.AlertStateResolved
+			alert.ResolvedAt = &now
+			if err := as.alertRepo.SaveAlert(ctx, alert); err != nil {
+				log.Printf("Failed to resolve alert for removed rule %s: %v", alert.Rule, err)
+			}
+			continue
+		}
+		as.active[alertKey{alert.Rule, alert.NodeID}] = alert
+		as.restoreNotified(alert)
+	}
+
+	return nil
+}
+
+func (as *AlertService) rule(name string) *domain.AlertRule {
+	for i := range as.config.Rules {
+		if as.config.Rules[i].Name == name {
+			return &as.config.Rules[i]
+		}
+	}
+	return nil
+}
+
+func (as *AlertService) alertLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(as.config.EvaluationIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-as.stopChan:
+			return
+		case now := <-ticker.C:
+			if err := as.evaluate(ctx, now); err != nil {
+				log.Printf("Error evaluating alert rules: %v", err)
+			}
+		}
+	}
+}
+
+// condition is the outcome of one rule for one node
+type condition struct {
+	holds   bool
+	value   float64
+	message string
+}
+
+// evaluate checks every rule against every node it covers and applies the
+// resulting state transitions
+func (as *AlertService) evaluate(ctx context.Context, now time.Time) error {
+	nodes, err := as.nodeService.GetKnownNodes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get known nodes: %w", err)
+	}
+
+	maxWindow := 0
+	checkAnomalies := false
+	for _, rule := range as.config.Rules {
+		if rule.Type != domain.AlertRuleNewNode && rule.WindowMinutes > maxWindow {
+			maxWindow = rule.WindowMinutes
+		}
+		if rule.Type == domain.AlertRuleLatencyAnomaly {
+			checkAnomalies = true
+		}
+	}
+
+	// Only this node's own polls count; imported results from other
+	// observers describe other paths
+	pollsByNode := make(map[string][]domain.PollResult)
+	if maxWindow > 0 {
+		results, err := as.pollRepo.GetRecentPollResults(ctx, now.Add(-time.Duration(maxWindow)*time.Minute))
+		if err != nil {
+			return fmt.Errorf("failed to get recent poll results: %w", err)
+		}
+		for _, result := range results {
+			if result.Observer == "" {
+				pollsByNode[result.NodeID] = append(pollsByNode[result.NodeID], result)
+			}
+		}
+		for _, polls := range pollsByNode {
+			sort.SliceStable(polls, func(i, j int) bool { return polls[i].PollTime.Before(polls[j].PollTime) })
+		}
+	}
+
+	// Ongoing anomaly events by node; ended ones come after them
+	anomalies := make(map[string]*domain.AnomalyEvent)
+	if checkAnomalies {
+		events, err := as.anomalies.GetAnomalies(ctx, now)
+		if err != nil {
+			return fmt.Errorf("failed to get latency anomalies: %w", err)
+		}
+		for i := range events {
+			if events[i].EndedAt == nil {
+				anomalies[events[i].NodeID] = &events[i]
+			}
+		}
+	}
+
+	seen := make(map[alertKey]bool)
+	for i := range as.config.Rules {
+		rule := &as.config.Rules[i]
+		for j := range nodes {
+			node := &nodes[j]
+			if len(rule.Nodes) > 0 && !slices.Contains(rule.Nodes, node.ID) {
+				continue
+			}
+			if !domain.LabelsMatch(rule.NodeLabels, node.Labels) {
+				continue
+			}
+
+			key := alertKey{rule.Name, node.ID}
+			seen[key] = true
+
+			var cond condition
+			if rule.Type == domain.AlertRuleLatencyAnomaly {
+				cond = checkAnomaly(node, anomalies[node.ID])
+			} else {
+				cond = checkRule(rule, node, pollsByNode[node.ID], now)
+			}
+			as.transition(ctx, rule, key, cond, now)
+		}
+	}
+
+	// Nodes that were forgotten, or dropped from a rule, no longer raise alerts
+	for key, alert := range as.active {
+		if seen[key] {
+			continue
+		}
+		if alert.State == domain.AlertStateFiring {
+			as.resolve(ctx, alert, now)
+		}
+		delete(as.active, key)
+	}
+
+	// Notify without silences rather than not at all
+	silences, err := as.silenceRepo.GetSilences(ctx, now)
+	if err != nil {
+		log.Printf("Failed to get silences: %v", err)
+	}
+	flapStates, err := as.nodeService.GetFlapStates(ctx)
+	if err != nil {
+		log.Printf("Failed to get flap states: %v", err)
+	}
+	flapping := make(map[string]bool)
+	for _, state := range flapStates {
+		flapping[state.NodeID] = state.Flapping
+	}
+	nodeLabels := make(map[string]map[string]string, len(nodes))
+	for _, node := range nodes {
+		nodeLabels[node.ID] = node.Labels
+	}
+	as.dispatch(ctx, now, silences, flapping, nodeLabels)
+
+	return nil
+}
+
+// transition applies one evaluation to the alert for key
+func (as *AlertService) transition(ctx context.Context, rule *domain.AlertRule, key alertKey, cond condition, now time.Time) {
+	alert := as.active[key]
+
+	if !cond.holds {
+		if alert != nil {
+			if alert.State == domain.AlertStateFiring {
+				as.resolve(ctx, alert, now)
+			}
+			delete(as.active, key)
+		}
+		return
+	}
+
+	if alert == nil {
+		alert = &domain.Alert{
+			Rule:      rule.Name,
+			NodeID:    key.nodeID,
+			Severity:  rule.Severity,
+			State:     domain.AlertStatePending,
+			StartedAt: now,
+		}
+		as.active[key] = alert
+	}
+	alert.Value = cond.value
+	alert.Message = cond.message
+
+	if alert.State == domain.AlertStatePending && now.Sub(alert.StartedAt) >= time.Duration(rule.ForMinutes)*time.Minute {
+		alert.State = domain.AlertStateFiring
+		alert.FiredAt = now
+		if err := as.alertRepo.SaveAlert(ctx, alert); err != nil {
+			log.Printf("Failed to store alert %s for %s: %v", rule.Name, key.nodeID, err)
+		}
+		log.Printf("Alert firing: %s", alert.Message)
+	}
+}
+
+func (as *AlertService) resolve(ctx context.Context, alert *domain.Alert, now time.Time) {
+	alert.State = domain.AlertStateResolved
+	alert.ResolvedAt = &now
+	if err := as.alertRepo.SaveAlert(ctx, alert); err != nil {
+		log.Printf("Failed to store resolved alert %s for %s: %v", alert.Rule, alert.NodeID, err)
+	}
+	log.Printf("Alert resolved: %s on %s", alert.Rule, alert.NodeID)
+	as.queueResolved(alert)
+}
+
+// checkRule evaluates rule for node; polls are the node's own poll results
+// in time order, covering at least the rule's window
+func checkRule(rule *domain.AlertRule, node *domain.Node, polls []domain.PollResult, now time.Time) condition {
+	window := time.Duration(rule.WindowMinutes) * time.Minute
+	since := now.Add(-window)
+	start := sort.Search(len(polls), func(i int) bool { return !polls[i].PollTime.Before(since) })
+	polls = polls[start:]
+
+	switch rule.Type {
+	case domain.AlertRuleNodeDown:
+		return condition{
+			holds:   !node.IsActive,
+			message: fmt.Sprintf("%s is down, last seen %s", node.ID, node.LastSeen.Format(time.RFC3339)),
+		}
+
+	case domain.AlertRuleLatencyP95:
+		var latencies []int64
+		for _, poll := range polls {
+			if poll.Success {
+				latencies = append(latencies, poll.ResponseMs)
+			}
+		}
+		if len(latencies) == 0 {
+			return condition{}
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		p95 := percentile(latencies, 95)
+		return condition{
+			holds: p95 > rule.Threshold,
+			value: p95,
+			message: fmt.Sprintf("p95 latency to %s is %.0fms over the last %dm (threshold %.0fms)",
+				node.ID, p95, rule.WindowMinutes, rule.Threshold),
+		}
+
+	case domain.AlertRuleSuccessRate:
+		if len(polls) == 0 {
+			return condition{}
+		}
+		successes := 0
+		for _, poll := range polls {
+			if poll.Success {
+				successes++
+			}
+		}
+		rate := float64(successes) / float64(len(polls)) * 100
+		return condition{
+			holds: rate < rule.Threshold,
+			value: rate,
+			message: fmt.Sprintf("success rate polling %s is %.1f%% over the last %dm (threshold %.1f%%)",
+				node.ID, rate, rule.WindowMinutes, rule.Threshold),
+		}
+
+	case domain.AlertRuleMTUDrop:
+		highest, latest := 0, 0
+		for _, poll := range polls {
+			if poll.PathMTU <= 0 {
+				continue
+			}
+			if latest > highest {
+				highest = latest
+			}
+			latest = poll.PathMTU
+		}
+		return condition{
+			holds:   latest > 0 && latest < highest,
+			value:   float64(latest),
+			message: fmt.Sprintf("path MTU to %s dropped from %d to %d", node.ID, highest, latest),
+		}
+
+	case domain.AlertRuleNewNode:
+		// Seeds are configured by hand; only peers introduce unknown nodes
+		discovered := node.DiscoveredBy != "" && node.DiscoveredBy != "seed"
+		return condition{
+			holds: discovered && !node.FirstSeen.Before(since),
+			message: fmt.Sprintf("new node %s (%s) was discovered by %s",
+				node.ID, node.IP, node.DiscoveredBy),
+		}
+	}
+
+	return condition{}
+}
+
+// checkAnomaly holds while the link to node has an ongoing latency anomaly;
+// the value is the standard deviations of its peak from the baseline
+func checkAnomaly(node *domain.Node, event *domain.AnomalyEvent) condition {
+	if event == nil {
+		return condition{}
+	}
+	return condition{
+		holds: true,
+		value: event.Sigma,
+		message: fmt.Sprintf("latency to %s is %.0fms, %.1f sigma %s its baseline of %.0fms ± %.0fms since %s",
+			node.ID, event.LatestMs, math.Abs(event.Sigma), event.Direction, event.ExpectedMs, event.StdDevMs,
+			event.StartedAt.Format(time.RFC3339)),
+	}
+}
@@ -0,0 +1,588 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+	"nodeprobe/internal/pkg/memory"
+)
+
+// fakeNotifier records the notifications sent to each channel, failing
+// for channels listed in failing
+type fakeNotifier struct {
+	mu      sync.Mutex
+	sent    map[string][]domain.AlertNotification
+	failing map[string]bool
+}
+
+func newFakeNotifier() *fakeNotifier {
+	return &fakeNotifier{sent: make(map[string][]domain.AlertNotification), failing: make(map[string]bool)}
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, channel *domain.AlertChannel, notification *domain.AlertNotification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.failing[channel.Name] {
+		return errors.New("connection refused")
+	}
+	n.sent[channel.Name] = append(n.sent[channel.Name], *notification)
+	return nil
+}
+
+func (n *fakeNotifier) states(channel string) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var states []string
+	for _, notification := range n.sent[channel] {
+		states = append(states, notification.State)
+	}
+	return states
+}
+
+// last returns the latest notification sent to channel
+func (n *fakeNotifier) last(channel string) domain.AlertNotification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	sent := n.sent[channel]
+	if len(sent) == 0 {
+		return domain.AlertNotification{}
+	}
+	return sent[len(sent)-1]
+}
+
+// newTestAlertService returns an alert service with the given rules, two
+// channels and one known peer, loaded but not started
+func newTestAlertService(t *testing.T, rules ...domain.AlertRule) (*AlertService, *NodeService, *memory.Store, *fakeNotifier) {
+	t.Helper()
+
+	config := newFakeConfig("self")
+	config.alerts = domain.AlertConfig{
+		EvaluationIntervalSeconds: 30,
+		FlapDetection:             config.alerts.FlapDetection,
+		AnomalyDetection:          config.alerts.AnomalyDetection,
+		Rules:                     rules,
+		Channels: []domain.AlertChannel{
+			{Name: "hook", Type: domain.AlertChannelWebhook},
+			{Name: "mail", Type: domain.AlertChannelSMTP},
+		},
+	}
+	return newTestAlertServiceWithConfig(t, config, "peer")
+}
+
+// newTestAlertServiceWithConfig returns an alert service with the given
+// config and known nodes, loaded but not started
+func newTestAlertServiceWithConfig(t *testing.T, config *fakeConfig, nodeIDs ...string) (*AlertService, *NodeService, *memory.Store, *fakeNotifier) {
+	t.Helper()
+
+	ns, store := newTestNodeService(t, config)
+	for i, id := range nodeIDs {
+		node := testNode(id, fmt.Sprintf("10.0.0.%d", i+2))
+		if err := ns.addOrUpdateNode(context.Background(), &node); err != nil {
+			t.Fatalf("addOrUpdateNode: %v", err)
+		}
+	}
+
+	notifier := newFakeNotifier()
+	anomalies := newTestAnomalyService(t, store, config)
+	as := NewAlertService(ns, store, store, store, anomalies, notifier, config)
+	if err := as.load(context.Background()); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	return as, ns, store, notifier
+}
+
+func equalStates(got []string, want ...string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAlertLifecycle(t *testing.T) {
+	ctx := context.Background()
+	as, ns, store, notifier := newTestAlertService(t, domain.AlertRule{
+		Name: "peer-down", Type: domain.AlertRuleNodeDown, ForMinutes: 2, Severity: "critical", Channels: []string{"hook"},
+	})
+	now := time.Now()
+
+	if err := ns.UpdateNodeStatus(ctx, "peer", false); err != nil {
+		t.Fatalf("UpdateNodeStatus: %v", err)
+	}
+
+	if err := as.evaluate(ctx, now); err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if alert := as.active[alertKey{"peer-down", "peer"}]; alert == nil || alert.State != domain.AlertStatePending {
+		t.Fatalf("after first evaluation alert = %+v, want pending", alert)
+	}
+	if firing, _ := store.GetFiringAlerts(ctx); len(firing) != 0 {
+		t.Errorf("pending alert was stored: %+v", firing)
+	}
+
+	as.evaluate(ctx, now.Add(time.Minute))
+	if len(notifier.states("hook")) != 0 {
+		t.Errorf("notified before for_minutes elapsed")
+	}
+
+	as.evaluate(ctx, now.Add(2*time.Minute))
+	firing, _ := store.GetFiringAlerts(ctx)
+	if len(firing) != 1 || firing[0].Severity != "critical" || !firing[0].StartedAt.Equal(now) {
+		t.Fatalf("firing alerts = %+v, want one critical alert started at the first evaluation", firing)
+	}
+	if got := notifier.states("hook"); !equalStates(got, domain.AlertStateFiring) {
+		t.Errorf("hook notifications = %v, want firing", got)
+	}
+	if got := notifier.states("mail"); len(got) != 0 {
+		t.Errorf("mail notified %v, but the rule only names hook", got)
+	}
+
+	// Still down: no repeat notification
+	as.evaluate(ctx, now.Add(3*time.Minute))
+	if got := notifier.states("hook"); len(got) != 1 {
+		t.Errorf("hook notifications = %v, want a single firing", got)
+	}
+
+	if err := ns.UpdateNodeStatus(ctx, "peer", true); err != nil {
+		t.Fatalf("UpdateNodeStatus: %v", err)
+	}
+	as.evaluate(ctx, now.Add(4*time.Minute))
+
+	if got := notifier.states("hook"); !equalStates(got, domain.AlertStateFiring, domain.AlertStateResolved) {
+		t.Errorf("hook notifications = %v, want firing then resolved", got)
+	}
+	history, _ := store.GetAlertHistory(ctx, now, 10)
+	if len(history) != 1 || history[0].State != domain.AlertStateResolved || history[0].ResolvedAt == nil {
+		t.Errorf("alert history = %+v, want one resolved alert", history)
+	}
+	if len(as.active) != 0 {
+		t.Errorf("active alerts = %v, want none", as.active)
+	}
+}
+
+func TestPendingAlertClearsSilently(t *testing.T) {
+	ctx := context.Background()
+	as, ns, store, notifier := newTestAlertService(t, domain.AlertRule{
+		Name: "peer-down", Type: domain.AlertRuleNodeDown, ForMinutes: 5,
+	})
+	now := time.Now()
+
+	ns.UpdateNodeStatus(ctx, "peer", false)
+	as.evaluate(ctx, now)
+	ns.UpdateNodeStatus(ctx, "peer", true)
+	as.evaluate(ctx, now.Add(time.Minute))
+
+	if len(as.active) != 0 || len(notifier.states("hook")) != 0 || len(notifier.states("mail")) != 0 {
+		t.Errorf("a pending alert that cleared left state or notified")
+	}
+	if history, _ := store.GetAlertHistory(ctx, now.Add(-time.Hour), 10); len(history) != 0 {
+		t.Errorf("alert history = %+v, want none", history)
+	}
+}
+
+func TestAlertRestoredAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	as, ns, store, notifier := newTestAlertService(t, domain.AlertRule{Name: "peer-down", Type: domain.AlertRuleNodeDown})
+
+	ns.UpdateNodeStatus(ctx, "peer", false)
+	as.evaluate(ctx, now)
+
+	removed := domain.Alert{Rule: "old-rule", NodeID: "peer", State: domain.AlertStateFiring, StartedAt: now, FiredAt: now}
+	if err := store.SaveAlert(ctx, &removed); err != nil {
+		t.Fatalf("SaveAlert: %v", err)
+	}
+
+	// A new service over the same store picks up the firing alert
+	restarted := NewAlertService(ns, store, store, store, as.anomalies, notifier, as.configSvc)
+	if err := restarted.load(ctx); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if alert := restarted.active[alertKey{"peer-down", "peer"}]; alert == nil || alert.State != domain.AlertStateFiring {
+		t.Fatalf("restored alert = %+v, want firing", alert)
+	}
+	firing, _ := store.GetFiringAlerts(ctx)
+	if len(firing) != 1 || firing[0].Rule != "peer-down" {
+		t.Errorf("firing alerts = %+v, want the old rule's alert resolved", firing)
+	}
+
+	// Still down after the restart: it stays firing without a second notification
+	restarted.evaluate(ctx, now.Add(time.Minute))
+	if got := notifier.states("hook"); !equalStates(got, domain.AlertStateFiring) {
+		t.Errorf("hook notifications = %v, want the single firing from before the restart", got)
+	}
+}
+
+func TestCheckRule(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	node := testNode("peer", "10.0.0.2")
+	node.FirstSeen = now.Add(-time.Hour)
+
+	ago := func(minutes int) time.Time { return now.Add(-time.Duration(minutes) * time.Minute) }
+	polls := []domain.PollResult{
+		{NodeID: "peer", PollTime: ago(30), Success: true, ResponseMs: 10, PathMTU: 1500}, // outside 5m windows
+		{NodeID: "peer", PollTime: ago(4), Success: true, ResponseMs: 20, PathMTU: 1500},
+		{NodeID: "peer", PollTime: ago(3), Success: false},
+		{NodeID: "peer", PollTime: ago(2), Success: true, ResponseMs: 300, PathMTU: 1400},
+		{NodeID: "peer", PollTime: ago(1), Success: true, ResponseMs: 40},
+	}
+
+	tests := []struct {
+		name  string
+		rule  domain.AlertRule
+		node  func(*domain.Node)
+		holds bool
+		value float64
+	}{
+		{"latency above threshold", domain.AlertRule{Type: domain.AlertRuleLatencyP95, Threshold: 200, WindowMinutes: 5}, nil, true, 274},
+		{"latency below threshold", domain.AlertRule{Type: domain.AlertRuleLatencyP95, Threshold: 500, WindowMinutes: 5}, nil, false, 274},
+		{"success rate below threshold", domain.AlertRule{Type: domain.AlertRuleSuccessRate, Threshold: 90, WindowMinutes: 5}, nil, true, 75},
+		{"success rate above threshold", domain.AlertRule{Type: domain.AlertRuleSuccessRate, Threshold: 50, WindowMinutes: 5}, nil, false, 75},
+		{"mtu dropped", domain.AlertRule{Type: domain.AlertRuleMTUDrop, WindowMinutes: 60}, nil, true, 1400},
+		{"mtu steady in window", domain.AlertRule{Type: domain.AlertRuleMTUDrop, WindowMinutes: 2}, nil, false, 1400},
+		{"node up", domain.AlertRule{Type: domain.AlertRuleNodeDown}, nil, false, 0},
+		{"node down", domain.AlertRule{Type: domain.AlertRuleNodeDown}, func(n *domain.Node) { n.IsActive = false }, true, 0},
+		{"new node from peer", domain.AlertRule{Type: domain.AlertRuleNewNode, WindowMinutes: 90},
+			func(n *domain.Node) { n.DiscoveredBy = "other" }, true, 0},
+		{"new node from seed", domain.AlertRule{Type: domain.AlertRuleNewNode, WindowMinutes: 90},
+			func(n *domain.Node) { n.DiscoveredBy = "seed" }, false, 0},
+		{"node known before window", domain.AlertRule{Type: domain.AlertRuleNewNode, WindowMinutes: 30},
+			func(n *domain.Node) { n.DiscoveredBy = "other" }, false, 0},
+	}
+
+	for _, tt := range tests {
+		n := node
+		if tt.node != nil {
+			tt.node(&n)
+		}
+		cond := checkRule(&tt.rule, &n, polls, now)
+		if cond.holds != tt.holds || cond.value != tt.value {
+			t.Errorf("%s: holds = %v, value = %v; want %v, %v", tt.name, cond.holds, cond.value, tt.holds, tt.value)
+		}
+	}
+
+	if cond := checkRule(&domain.AlertRule{Type: domain.AlertRuleSuccessRate, Threshold: 90, WindowMinutes: 5}, &node, nil, now); cond.holds {
+		t.Error("success rate without polls holds, want no alert without data")
+	}
+}
+
+// newGroupingConfig returns alert config with a node_down rule labelled
+// site=ams, a webhook repeating every 30 minutes and an SMTP channel
+func newGroupingConfig(groupWait int) *fakeConfig {
+	config := newFakeConfig("self")
+	config.alerts = domain.AlertConfig{
+		EvaluationIntervalSeconds: 30,
+		FlapDetection:             config.alerts.FlapDetection,
+		AnomalyDetection:          config.alerts.AnomalyDetection,
+		GroupWaitSeconds:          groupWait,
+		Rules: []domain.AlertRule{{Name: "peer-down", Type: domain.AlertRuleNodeDown, Severity: "critical",
+			Labels: map[string]string{"site": "ams"}}},
+		Channels: []domain.AlertChannel{
+			{Name: "hook", Type: domain.AlertChannelWebhook, RepeatIntervalMinutes: 30},
+			{Name: "mail", Type: domain.AlertChannelSMTP},
+		},
+	}
+	return config
+}
+
+func TestAlertGrouping(t *testing.T) {
+	ctx := context.Background()
+	as, ns, _, notifier := newTestAlertServiceWithConfig(t, newGroupingConfig(60), "peer-a", "peer-b", "peer-c")
+	now := time.Now()
+
+	// A partition takes down two nodes, then a third within the group wait
+	ns.UpdateNodeStatus(ctx, "peer-a", false)
+	ns.UpdateNodeStatus(ctx, "peer-b", false)
+	as.evaluate(ctx, now)
+	ns.UpdateNodeStatus(ctx, "peer-c", false)
+	as.evaluate(ctx, now.Add(30*time.Second))
+	if got := notifier.states("mail"); len(got) != 0 {
+		t.Fatalf("mail notified %v during the group wait", got)
+	}
+
+	as.evaluate(ctx, now.Add(time.Minute))
+	if got := notifier.states("mail"); !equalStates(got, domain.AlertStateFiring) {
+		t.Fatalf("mail notifications = %v, want one grouped firing", got)
+	}
+	if got := notifier.last("mail"); len(got.Alerts) != 3 || got.Rule != "peer-down" || got.Severity != "critical" {
+		t.Errorf("grouped notification = %+v, want the three down nodes", got)
+	}
+
+	// One recovers: the resolution goes out alone with the still firing alerts
+	ns.UpdateNodeStatus(ctx, "peer-b", true)
+	as.evaluate(ctx, now.Add(2*time.Minute))
+	got := notifier.last("mail")
+	if got.State != domain.AlertStateFiring || len(got.Alerts) != 3 || got.Alerts[2].State != domain.AlertStateResolved {
+		t.Errorf("notification after one recovery = %+v, want two firing and one resolved", got)
+	}
+
+	// The webhook repeats the firing alerts after its interval; mail does not
+	as.evaluate(ctx, now.Add(20*time.Minute))
+	if got := notifier.states("hook"); len(got) != 2 {
+		t.Errorf("hook notifications before the repeat interval = %v, want 2", got)
+	}
+	as.evaluate(ctx, now.Add(33*time.Minute))
+	if got := notifier.states("hook"); len(got) != 3 || len(notifier.last("hook").Alerts) != 2 {
+		t.Errorf("hook notifications = %v, want a repeat of the 2 firing alerts", got)
+	}
+	if got := notifier.states("mail"); len(got) != 2 {
+		t.Errorf("mail notifications = %v, want no repeat", got)
+	}
+
+	ns.UpdateNodeStatus(ctx, "peer-a", true)
+	ns.UpdateNodeStatus(ctx, "peer-c", true)
+	as.evaluate(ctx, now.Add(34*time.Minute))
+	if got := notifier.last("mail"); got.State != domain.AlertStateResolved || len(got.Alerts) != 2 {
+		t.Errorf("final notification = %+v, want the last two resolved", got)
+	}
+	if len(as.groups) != 0 {
+		t.Errorf("groups = %v, want none once every alert resolved", as.groups)
+	}
+}
+
+func TestAlertNotificationRetried(t *testing.T) {
+	ctx := context.Background()
+	as, ns, _, notifier := newTestAlertServiceWithConfig(t, newGroupingConfig(0), "peer")
+	now := time.Now()
+
+	notifier.failing["mail"] = true
+	ns.UpdateNodeStatus(ctx, "peer", false)
+	as.evaluate(ctx, now)
+	if got := notifier.states("hook"); len(got) != 1 {
+		t.Errorf("hook notifications = %v, want firing despite the mail failure", got)
+	}
+
+	notifier.failing["mail"] = false
+	as.evaluate(ctx, now.Add(30*time.Second))
+	if got := notifier.states("mail"); !equalStates(got, domain.AlertStateFiring) {
+		t.Errorf("mail notifications = %v, want the failed firing retried", got)
+	}
+	if got := notifier.states("hook"); len(got) != 1 {
+		t.Errorf("hook notifications = %v, want no duplicate", got)
+	}
+}
+
+func TestAlertSilences(t *testing.T) {
+	ctx := context.Background()
+	as, ns, store, notifier := newTestAlertServiceWithConfig(t, newGroupingConfig(0), "peer-a", "peer-b")
+	now := time.Now()
+
+	for _, silence := range []domain.Silence{
+		{NodeID: "peer-a", StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour)},
+		{Labels: map[string]string{"site": "fra"}, StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour)}, // other site
+		{Rule: "peer-down", StartsAt: now.Add(2 * time.Hour), EndsAt: now.Add(3 * time.Hour)},                   // not yet
+	} {
+		if err := store.CreateSilence(ctx, &silence); err != nil {
+			t.Fatalf("CreateSilence: %v", err)
+		}
+	}
+
+	ns.UpdateNodeStatus(ctx, "peer-a", false)
+	ns.UpdateNodeStatus(ctx, "peer-b", false)
+	as.evaluate(ctx, now)
+
+	got := notifier.last("mail")
+	if len(got.Alerts) != 1 || got.Alerts[0].NodeID != "peer-b" {
+		t.Errorf("notification = %+v, want only the unsilenced peer-b", got)
+	}
+	if firing, _ := store.GetFiringAlerts(ctx); len(firing) != 2 {
+		t.Errorf("stored %d firing alerts, want silenced alerts recorded too", len(firing))
+	}
+
+	// A silenced alert that never notified resolves quietly
+	ns.UpdateNodeStatus(ctx, "peer-a", true)
+	as.evaluate(ctx, now.Add(time.Minute))
+	if got := notifier.states("mail"); len(got) != 1 {
+		t.Errorf("mail notifications = %v, want nothing for the silenced recovery", got)
+	}
+
+	// Inside the scheduled rule silence, the webhook's repeat is held back
+	as.evaluate(ctx, now.Add(150*time.Minute))
+	if got := notifier.states("hook"); len(got) != 1 {
+		t.Errorf("hook notifications = %v, want no repeat while silenced", got)
+	}
+	as.evaluate(ctx, now.Add(190*time.Minute))
+	if got := notifier.states("hook"); len(got) != 2 {
+		t.Errorf("hook notifications = %v, want the repeat once the silence ended", got)
+	}
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	ctx := context.Background()
+	config := newGroupingConfig(0)
+	config.alerts.MaintenanceWindows = []domain.MaintenanceWindow{{
+		Name: "nightly", Days: []string{"sun"}, Start: "23:00", DurationMinutes: 120, Timezone: "UTC",
+		AlertMatcher: domain.AlertMatcher{Labels: map[string]string{"site": "ams", "severity": "critical"}},
+	}}
+	as, ns, _, notifier := newTestAlertServiceWithConfig(t, config, "peer")
+	ns.UpdateNodeStatus(ctx, "peer", false)
+
+	// Monday 00:30 UTC falls in the window opened on Sunday night
+	inside := time.Date(2025, 6, 2, 0, 30, 0, 0, time.UTC)
+	as.evaluate(ctx, inside)
+	if got := notifier.states("mail"); len(got) != 0 {
+		t.Errorf("mail notifications = %v, want none inside the maintenance window", got)
+	}
+
+	as.evaluate(ctx, inside.Add(time.Hour))
+	if got := notifier.states("mail"); !equalStates(got, domain.AlertStateFiring) {
+		t.Errorf("mail notifications = %v, want firing after the window closed", got)
+	}
+
+	window := config.alerts.MaintenanceWindows[0]
+	for at, want := range map[time.Time]bool{
+		time.Date(2025, 6, 1, 22, 59, 0, 0, time.UTC): false, // Sunday, before it opens
+		time.Date(2025, 6, 1, 23, 0, 0, 0, time.UTC):  true,
+		time.Date(2025, 6, 2, 1, 0, 0, 0, time.UTC):   false, // closed after two hours
+		time.Date(2025, 6, 2, 23, 30, 0, 0, time.UTC): false, // Monday night
+	} {
+		if got := window.ActiveAt(at); got != want {
+			t.Errorf("ActiveAt(%v) = %v, want %v", at, got, want)
+		}
+	}
+}
+
+func TestCreateSilence(t *testing.T) {
+	ctx := context.Background()
+	config := newFakeConfig("self")
+	config.alerts.Rules = []domain.AlertRule{{Name: "peer-down", Type: domain.AlertRuleNodeDown}}
+	store := memory.NewStore()
+	ss := NewSilenceService(store, config)
+	now := time.Now()
+
+	valid := domain.Silence{Rule: "peer-down", EndsAt: now.Add(time.Hour), CreatedBy: "ops"}
+	if err := ss.CreateSilence(ctx, &valid); err != nil {
+		t.Fatalf("CreateSilence: %v", err)
+	}
+	if valid.ID == 0 || valid.StartsAt.IsZero() || valid.CreatedAt.IsZero() {
+		t.Errorf("created silence = %+v, want ID, start and creation time set", valid)
+	}
+
+	for name, silence := range map[string]domain.Silence{
+		"no matcher":   {EndsAt: now.Add(time.Hour)},
+		"unknown rule": {Rule: "peer-dwon", EndsAt: now.Add(time.Hour)},
+		"ends first":   {NodeID: "peer", StartsAt: now.Add(time.Hour), EndsAt: now},
+		"already over": {NodeID: "peer", StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)},
+	} {
+		if err := ss.CreateSilence(ctx, &silence); !errors.Is(err, domain.ErrInvalidSilence) {
+			t.Errorf("%s: error = %v, want ErrInvalidSilence", name, err)
+		}
+	}
+
+	silences, _ := ss.GetSilences(ctx)
+	if len(silences) != 1 {
+		t.Fatalf("GetSilences = %+v, want the valid silence", silences)
+	}
+	if err := ss.DeleteSilence(ctx, valid.ID); err != nil {
+		t.Errorf("DeleteSilence: %v", err)
+	}
+	if err := ss.DeleteSilence(ctx, valid.ID); !errors.Is(err, domain.ErrSilenceNotFound) {
+		t.Errorf("second DeleteSilence error = %v, want ErrSilenceNotFound", err)
+	}
+}
+
+func TestAlertFlappingNodeHeldBack(t *testing.T) {
+	ctx := context.Background()
+	as, ns, store, notifier := newTestAlertServiceWithConfig(t, newGroupingConfig(0), "wifi", "wired")
+	now := time.Now()
+
+	// wifi toggles every evaluation; each change notifies until its score
+	// reaches the high threshold
+	at := now
+	toggle := func(times int) {
+		for i := 0; i < times; i++ {
+			at = at.Add(30 * time.Second)
+			ns.UpdateNodeStatus(ctx, "wifi", i%2 != 0)
+			as.evaluate(ctx, at)
+		}
+	}
+	toggle(20)
+	sent := len(notifier.states("mail"))
+	if sent == 0 || sent >= 20 {
+		t.Fatalf("mail got %d notifications before wifi was flapping, want some", sent)
+	}
+
+	toggle(20)
+	if got := notifier.states("mail"); len(got) != sent {
+		t.Errorf("mail notifications while flapping = %v, want none after the first %d", got, sent)
+	}
+	if history, _ := store.GetAlertHistory(ctx, now, 100); len(history) < 20 {
+		t.Errorf("stored %d alerts, want every firing recorded while flapping", len(history))
+	}
+
+	// Other nodes still notify
+	ns.UpdateNodeStatus(ctx, "wired", false)
+	as.evaluate(ctx, at)
+	if got := notifier.last("mail"); len(got.Alerts) == 0 || got.Alerts[len(got.Alerts)-1].NodeID != "wired" {
+		t.Errorf("notification = %+v, want wired firing", got)
+	}
+	ns.UpdateNodeStatus(ctx, "wired", true)
+	as.evaluate(ctx, at)
+
+	// wifi was last notified firing; once it settles up that resolves
+	for i := 0; i < 20; i++ {
+		at = at.Add(30 * time.Second)
+		ns.UpdateNodeStatus(ctx, "wifi", true)
+		as.evaluate(ctx, at)
+	}
+	if states, _ := ns.GetFlapStates(ctx); states[0].NodeID != "wifi" || states[0].Flapping {
+		t.Fatalf("flap states = %+v, want wifi settled", states)
+	}
+	for _, channel := range []string{"mail", "hook"} {
+		got := notifier.last(channel)
+		if got.State != domain.AlertStateResolved || len(got.Alerts) != 1 || got.Alerts[0].NodeID != "wifi" {
+			t.Errorf("last %s notification = %+v, want wifi resolved", channel, got)
+		}
+	}
+	if len(as.groups) != 0 {
+		t.Errorf("groups = %v, want none once settled", as.groups)
+	}
+}
+
+func TestAlertLatencyAnomaly(t *testing.T) {
+	ctx := context.Background()
+	as, _, store, notifier := newTestAlertService(t, domain.AlertRule{Name: "peer-anomaly", Type: domain.AlertRuleLatencyAnomaly})
+	anomalies := as.anomalies.(*AnomalyService)
+	now := time.Now()
+
+	storeLatencies(t, store, "peer", now.Add(-3*time.Hour), jitter(20, 60)...)
+	storeLatencies(t, store, "peer", now.Add(-5*time.Minute), 200, 200, 200)
+	if err := anomalies.Detect(ctx, now); err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	as.evaluate(ctx, now)
+
+	firing, _ := store.GetFiringAlerts(ctx)
+	if len(firing) != 1 || firing[0].Rule != "peer-anomaly" || firing[0].NodeID != "peer" || firing[0].Value <= 3 {
+		t.Fatalf("firing = %+v, want peer-anomaly on peer", firing)
+	}
+	if !strings.Contains(firing[0].Message, "above its baseline of 20ms") {
+		t.Errorf("message = %q, want the baseline", firing[0].Message)
+	}
+
+	// The alert resolves with the first normal poll
+	storeLatencies(t, store, "peer", now, 20)
+	if err := anomalies.Detect(ctx, now.Add(2*time.Minute)); err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	as.evaluate(ctx, now.Add(2*time.Minute))
+	if firing, _ := store.GetFiringAlerts(ctx); len(firing) != 0 {
+		t.Errorf("firing = %+v, want resolved", firing)
+	}
+	if got := notifier.states("hook"); !equalStates(got, domain.AlertStateFiring, domain.AlertStateResolved) {
+		t.Errorf("hook notifications = %v, want firing then resolved", got)
+	}
+}
@@ -0,0 +1,226 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// AnomalyService learns a latency baseline for the link to every node this
+// node polls, overall and by hour of day, and records runs of polls that
+// deviate from it as anomaly events. Baselines are learned from the poll
+// history at startup and then follow new polls as they are stored.
+type AnomalyService struct {
+	pollRepo  domain.PollRepository
+	configSvc domain.ConfigService
+
+	config    domain.AnomalyConfig
+	cursor    time.Time // polls before this have been learned
+	trained   bool
+	detectors map[string]*domain.AnomalyDetector // by node ID
+	events    []domain.AnomalyEvent              // ended, oldest first
+
+	running  bool
+	stopChan chan struct{}
+	mu       sync.RWMutex
+}
+
+const (
+	// anomalyBatch bounds the poll history read per query while learning
+	anomalyBatch = 1 * time.Hour
+
+	// maxAnomalyEvents bounds the ended events kept in memory
+	maxAnomalyEvents = 100
+)
+
+func NewAnomalyService(pollRepo domain.PollRepository, configSvc domain.ConfigService) *AnomalyService {
+	return &AnomalyService{
+		pollRepo:  pollRepo,
+		configSvc: configSvc,
+		detectors: make(map[string]*domain.AnomalyDetector),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Initialize loads the anomaly_detection settings from alerts.json; the
+// baselines are learned from the last training_days of polls on the first
+// call to Detect
+func (as *AnomalyService) Initialize() error {
+	config, err := as.configSvc.LoadAlertConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load alert config: %w", err)
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	as.config = config.AnomalyDetection
+	as.cursor = time.Now().Add(-time.Duration(as.config.TrainingDays) * 24 * time.Hour).Truncate(time.Minute)
+	return nil
+}
+
+func (as *AnomalyService) Start(ctx context.Context) error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	if as.running {
+		return fmt.Errorf("anomaly service is already running")
+	}
+	as.running = true
+	go as.detectLoop(ctx)
+
+	log.Printf("Anomaly service started (%.1f sigma, learning from the last %d days)",
+		as.config.Sigma, as.config.TrainingDays)
+	return nil
+}
+
+func (as *AnomalyService) Stop() error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	if !as.running {
+		return nil
+	}
+
+	close(as.stopChan)
+	as.running = false
+
+	log.Println("Anomaly service stopped")
+	return nil
+}
+
+func (as *AnomalyService) detectLoop(ctx context.Context) {
+	ticker := time.NewTicker(domain.PollInterval)
+	defer ticker.Stop()
+
+	if err := as.Detect(ctx, time.Now()); err != nil {
+		log.Printf("Error learning latency baselines: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-as.stopChan:
+			return
+		case now := <-ticker.C:
+			if err := as.Detect(ctx, now); err != nil {
+				log.Printf("Error detecting latency anomalies: %v", err)
+			}
+		}
+	}
+}
+
+// Detect judges and learns this node's own polls stored since the last
+// call, up to domain.RollupDelay before now so in-flight polls land first.
+// Imported results from other observers describe other paths and are
+// skipped.
+func (as *AnomalyService) Detect(ctx context.Context, now time.Time) error {
+	as.mu.RLock()
+	cursor := as.cursor
+	as.mu.RUnlock()
+
+	until := now.Add(-domain.RollupDelay)
+	learned := 0
+	for cursor.Before(until) {
+		end := cursor.Add(anomalyBatch)
+		if end.After(until) {
+			end = until
+		}
+
+		results, err := as.pollRepo.GetPollResultsBetween(ctx, cursor, end)
+		if err != nil {
+			return fmt.Errorf("failed to get poll results: %w", err)
+		}
+		sort.SliceStable(results, func(i, j int) bool { return results[i].PollTime.Before(results[j].PollTime) })
+
+		as.mu.Lock()
+		for _, result := range results {
+			if result.Observer != "" || !result.Success {
+				continue
+			}
+			as.record(result)
+			learned++
+		}
+		as.cursor = end
+		as.mu.Unlock()
+
+		cursor = end
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if !as.trained {
+		as.trained = true
+		log.Printf("Learned latency baselines for %d links from %d polls", len(as.detectors), learned)
+	}
+	return nil
+}
+
+// record feeds one poll to its link's detector and keeps the events it ends
+func (as *AnomalyService) record(result domain.PollResult) {
+	detector, ok := as.detectors[result.NodeID]
+	if !ok {
+		detector = &domain.AnomalyDetector{NodeID: result.NodeID}
+		as.detectors[result.NodeID] = detector
+	}
+
+	for _, event := range detector.Record(float64(result.ResponseMs), result.PollTime, as.config) {
+		if event.EndedAt == nil {
+			if as.trained {
+				log.Printf("Latency anomaly on %s: %.0fms is %.1f sigma %s its baseline of %.0fms",
+					event.NodeID, event.PeakMs, math.Abs(event.Sigma), event.Direction, event.ExpectedMs)
+			}
+			continue
+		}
+
+		if as.trained {
+			log.Printf("Latency anomaly on %s ended after %d polls", event.NodeID, event.Polls)
+		}
+		as.events = append(as.events, event)
+		if len(as.events) > maxAnomalyEvents {
+			as.events = as.events[len(as.events)-maxAnomalyEvents:]
+		}
+	}
+}
+
+// GetBaselines returns the baseline of every link learned so far, by node ID
+func (as *AnomalyService) GetBaselines(ctx context.Context) ([]domain.LinkBaseline, error) {
+	as.mu.RLock()
+	defer as.mu.RUnlock()
+
+	now := time.Now()
+	baselines := make([]domain.LinkBaseline, 0, len(as.detectors))
+	for _, detector := range as.detectors {
+		baselines = append(baselines, detector.State(now, as.config))
+	}
+	sort.Slice(baselines, func(i, j int) bool { return baselines[i].NodeID < baselines[j].NodeID })
+	return baselines, nil
+}
+
+// GetAnomalies returns the ongoing anomaly events followed by the ended
+// ones that started since the given time, each newest first
+func (as *AnomalyService) GetAnomalies(ctx context.Context, since time.Time) ([]domain.AnomalyEvent, error) {
+	as.mu.RLock()
+	defer as.mu.RUnlock()
+
+	anomalies := []domain.AnomalyEvent{}
+	for _, detector := range as.detectors {
+		if event := detector.Event(); event != nil {
+			anomalies = append(anomalies, *event)
+		}
+	}
+	sort.Slice(anomalies, func(i, j int) bool { return anomalies[i].StartedAt.After(anomalies[j].StartedAt) })
+
+	for i := len(as.events) - 1; i >= 0; i-- {
+		if !as.events[i].StartedAt.Before(since) {
+			anomalies = append(anomalies, as.events[i])
+		}
+	}
+	return anomalies, nil
+}
@@ -0,0 +1,161 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+	"nodeprobe/internal/pkg/memory"
+)
+
+// newTestAnomalyService returns an anomaly service over store, initialized
+// but not started
+func newTestAnomalyService(t *testing.T, store *memory.Store, config *fakeConfig) *AnomalyService {
+	t.Helper()
+
+	as := NewAnomalyService(store, config)
+	if err := as.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return as
+}
+
+// storeLatencies stores this node's successful polls of nodeID, one every
+// 30 seconds from start, answered in the given milliseconds
+func storeLatencies(t *testing.T, store *memory.Store, nodeID string, start time.Time, latencies ...int64) time.Time {
+	t.Helper()
+
+	results := make([]domain.PollResult, len(latencies))
+	for i, ms := range latencies {
+		results[i] = domain.PollResult{NodeID: nodeID, PollTime: start, Success: true, ResponseMs: ms}
+		start = start.Add(30 * time.Second)
+	}
+	if err := store.CreatePollResults(context.Background(), results); err != nil {
+		t.Fatalf("CreatePollResults: %v", err)
+	}
+	return start
+}
+
+// jitter returns n latencies around ms
+func jitter(ms int64, n int) []int64 {
+	latencies := make([]int64, n)
+	for i := range latencies {
+		latencies[i] = ms + int64(i%3) - 1
+	}
+	return latencies
+}
+
+func TestAnomalyDetection(t *testing.T) {
+	ctx := context.Background()
+	config := newFakeConfig("self")
+	store := memory.NewStore()
+	as := newTestAnomalyService(t, store, config)
+
+	now := time.Now()
+	next := storeLatencies(t, store, "peer", now.Add(-3*time.Hour), jitter(20, 60)...)
+	next = storeLatencies(t, store, "peer", next, 200, 210, 205)
+	storeLatencies(t, store, "peer", next, jitter(20, 10)...)
+
+	// Two slow polls in a row are not enough to open an event, nor are slow
+	// polls that stay within min_deviation_ms of a quiet link
+	storeLatencies(t, store, "lan", now.Add(-3*time.Hour), jitter(1, 60)...)
+	storeLatencies(t, store, "lan", now.Add(-2*time.Hour), 4, 4, 4, 4)
+	storeLatencies(t, store, "lan", now.Add(-90*time.Minute), 300, 300, 1)
+
+	// Failed polls and other observers' polls are not learned
+	if err := store.CreatePollResults(ctx, []domain.PollResult{
+		{NodeID: "peer", PollTime: now.Add(-3 * time.Hour), Success: false, ResponseMs: 5000},
+		{NodeID: "far", Observer: "peer", PollTime: now.Add(-3 * time.Hour), Success: true, ResponseMs: 90},
+	}); err != nil {
+		t.Fatalf("CreatePollResults: %v", err)
+	}
+
+	if err := as.Detect(ctx, now); err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+
+	anomalies, err := as.GetAnomalies(ctx, now.Add(-domain.AnomalyWindow))
+	if err != nil {
+		t.Fatalf("GetAnomalies: %v", err)
+	}
+	if len(anomalies) != 1 {
+		t.Fatalf("anomalies = %+v, want one", anomalies)
+	}
+	event := anomalies[0]
+	if event.NodeID != "peer" || event.Direction != domain.AnomalyAbove || event.Polls != 3 ||
+		event.PeakMs != 210 || event.EndedAt == nil || event.Sigma <= config.alerts.AnomalyDetection.Sigma {
+		t.Errorf("event = %+v, want 3 polls above the baseline peaking at 210ms, ended", event)
+	}
+	if event.ExpectedMs < 19 || event.ExpectedMs > 21 {
+		t.Errorf("expected = %.1fms, want about 20ms", event.ExpectedMs)
+	}
+
+	baselines, err := as.GetBaselines(ctx)
+	if err != nil {
+		t.Fatalf("GetBaselines: %v", err)
+	}
+	if len(baselines) != 2 || baselines[0].NodeID != "lan" || baselines[1].NodeID != "peer" {
+		t.Fatalf("baselines = %+v, want lan and peer", baselines)
+	}
+	if peer := baselines[1]; peer.Samples != 73 || peer.Learning || len(peer.Hourly) == 0 || peer.Anomaly != nil {
+		t.Errorf("peer baseline = %+v, want 73 samples, trained, no anomaly", peer)
+	}
+
+	// New polls are picked up where the last call left off
+	storeLatencies(t, store, "peer", now, 400, 400, 400, 400)
+	if err := as.Detect(ctx, now.Add(5*time.Minute)); err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	anomalies, _ = as.GetAnomalies(ctx, now.Add(-domain.AnomalyWindow))
+	if len(anomalies) != 2 || anomalies[0].EndedAt != nil || anomalies[0].Polls != 4 {
+		t.Fatalf("anomalies = %+v, want an ongoing event of 4 polls first", anomalies)
+	}
+	baselines, _ = as.GetBaselines(ctx)
+	if baselines[1].Anomaly == nil || baselines[1].Samples != 77 {
+		t.Errorf("peer baseline = %+v, want the ongoing anomaly", baselines[1])
+	}
+
+	// Older events fall outside a shorter window
+	if anomalies, _ := as.GetAnomalies(ctx, now.Add(-time.Hour)); len(anomalies) != 1 {
+		t.Errorf("anomalies in the last hour = %+v, want only the ongoing one", anomalies)
+	}
+}
+
+func TestAnomalyDetectorHourlyProfile(t *testing.T) {
+	config := newFakeConfig("self").alerts.AnomalyDetection
+	detector := domain.AnomalyDetector{NodeID: "peer"}
+
+	// Once every hour has min_samples polls, a link that is slow every
+	// evening is normal when it is slow in the evening, but not in the morning
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for d := 0; d < 4; d++ {
+		for hour := 0; hour < 24; hour++ {
+			ms := 20.0
+			if hour >= 18 {
+				ms = 80
+			}
+			for i := 0; i < 12; i++ {
+				at := day.AddDate(0, 0, d).Add(time.Duration(hour)*time.Hour + time.Duration(i)*5*time.Minute)
+				if events := detector.Record(ms+float64(i%3), at, config); len(events) != 0 && d == 3 {
+					t.Fatalf("day %d hour %d: events %+v, want none", d, hour, events)
+				}
+			}
+		}
+	}
+
+	morning := day.AddDate(0, 0, 4).Add(9 * time.Hour)
+	var events []domain.AnomalyEvent
+	for i := 0; i < config.ConsecutivePolls; i++ {
+		events = append(events, detector.Record(80, morning.Add(time.Duration(i)*time.Minute), config)...)
+	}
+	if len(events) != 1 || events[0].Direction != domain.AnomalyAbove || events[0].StartedAt != morning {
+		t.Errorf("morning events = %+v, want one opened at %v", events, morning)
+	}
+
+	// Dropping back in the other direction ends it and starts counting anew
+	events = detector.Record(0, morning.Add(time.Hour), config)
+	if len(events) != 1 || events[0].EndedAt == nil {
+		t.Errorf("events = %+v, want the morning event ended", events)
+	}
+}
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// sessionCookie holds the API token of a browser session started at /login
+const sessionCookie = "nodeprobe_token"
+
+const sessionLifetime = 12 * time.Hour
+
+type principalKey struct{}
+
+// requireRole restricts a route to callers holding role when authentication
+// is enabled. Tokens are read from the Authorization header or the session
+// cookie; on peer routes a node certificate verified by mutual TLS also
+// identifies the caller as a peer.
+func (ws *WebServer) requireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ws.authService.Enabled() {
+			next(w, r)
+			return
+		}
+
+		principal, err := ws.authService.Authenticate(requestToken(r))
+		if err != nil && role == domain.RolePeer && ws.tlsService.MutualTLSEnabled() {
+			if peerID, verifyErr := ws.tlsService.VerifyPeer(r.TLS); verifyErr == nil {
+				principal, err = &domain.Principal{Name: peerID, Role: domain.RolePeer}, nil
+			}
+		}
+		if err != nil {
+			if ws.renderer != nil && r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+				http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="nodeprobe"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if err := ws.authService.Authorize(principal, role); err != nil {
+			log.Printf("Denied %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	}
+}
+
+// principalName returns the name of the authenticated caller, if any
+func principalName(ctx context.Context) string {
+	if principal, ok := ctx.Value(principalKey{}).(*domain.Principal); ok {
+		return principal.Name
+	}
+	return "anonymous"
+}
+
+// requestToken extracts the API token from a bearer header or session cookie
+func requestToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
+
+func (ws *WebServer) handleLogin(w http.ResponseWriter, r *http.Request) {
+	next := r.FormValue("next")
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		next = "/dashboard"
+	}
+
+	data := struct {
+		Next  string
+		Error string
+	}{Next: next}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		principal, err := ws.authService.Authenticate(strings.TrimSpace(r.PostFormValue("token")))
+		if err == nil {
+			err = ws.authService.Authorize(principal, domain.RoleViewer)
+		}
+		if err != nil {
+			log.Printf("Failed dashboard login from %s: %v", r.RemoteAddr, err)
+			data.Error = "Invalid token"
+			w.WriteHeader(http.StatusUnauthorized)
+			break
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    strings.TrimSpace(r.PostFormValue("token")),
+			Path:     "/",
+			MaxAge:   int(sessionLifetime.Seconds()),
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+		log.Printf("Dashboard login by %s from %s", principal.Name, r.RemoteAddr)
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	html, err := ws.renderer.Render("login.html", data)
+	if err != nil {
+		log.Printf("Failed to render login page: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if _, err := w.Write([]byte(html)); err != nil {
+		log.Printf("Failed to write HTML response: %v", err)
+	}
+}
+
+func (ws *WebServer) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
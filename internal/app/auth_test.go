@@ -0,0 +1,162 @@
+package app
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nodeprobe/internal/domain"
+	"nodeprobe/internal/pkg/auth"
+)
+
+// fakeTLS is a TLS service without mutual TLS, so peers are identified by
+// their token alone
+type fakeTLS struct {
+	domain.TLSService
+}
+
+func (fakeTLS) MutualTLSEnabled() bool { return false }
+
+func (fakeTLS) VerifyPeer(state *tls.ConnectionState) (string, error) {
+	return "", errors.New("mutual TLS is disabled")
+}
+
+// routeStatus serves req and returns its status, or 0 when the request got
+// past authentication into a handler that panicked on a service this test
+// does not provide
+func routeStatus(mux *http.ServeMux, req *http.Request) (status int) {
+	defer func() {
+		if recover() != nil {
+			status = 0
+		}
+	}()
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRequireRoleOnRouteGroups(t *testing.T) {
+	tokens := map[string]string{
+		domain.RoleViewer:   "viewer-secret",
+		domain.RoleOperator: "operator-secret",
+		domain.RolePeer:     "peer-secret",
+	}
+	config := &domain.AuthConfig{Enabled: true}
+	for role, token := range tokens {
+		config.Tokens = append(config.Tokens, domain.APIToken{Name: role, Role: role, TokenSHA256: auth.HashToken(token)})
+	}
+	authService, err := auth.NewService(config)
+	if err != nil {
+		t.Fatalf("auth.NewService: %v", err)
+	}
+
+	ws := &WebServer{authService: authService, tlsService: fakeTLS{}, configSvc: newFakeConfig("self")}
+	mux := http.NewServeMux()
+	ws.setupRoutes(mux)
+
+	groups := []struct {
+		name     string
+		requests [][2]string // method and path
+		allowed  []string    // roles let through; everyone else gets 403
+	}{
+		{
+			name: "viewer",
+			requests: [][2]string{
+				{http.MethodGet, "/metrics"},
+				{http.MethodGet, "/api/v1/report"},
+				{http.MethodGet, "/api/v1/nodes"},
+				{http.MethodGet, "/api/v1/nodes/peer/rollups"},
+				{http.MethodGet, "/api/v1/alerts"},
+				{http.MethodGet, "/api/v1/silences"},
+				{http.MethodGet, "/api/v1/slo"},
+				{http.MethodGet, "/api/v1/export"},
+				{http.MethodGet, "/api/v1/peers/fingerprints"},
+				{http.MethodGet, "/dashboard"},
+			},
+			allowed: []string{domain.RoleViewer, domain.RoleOperator},
+		},
+		{
+			name: "operator",
+			requests: [][2]string{
+				{http.MethodPost, "/api/v1/silences"},
+				{http.MethodDelete, "/api/v1/silences/1"},
+				{http.MethodPost, "/api/v1/peers/peer/fingerprint/approve"},
+			},
+			allowed: []string{domain.RoleOperator},
+		},
+		{
+			name: "peer",
+			requests: [][2]string{
+				{http.MethodGet, "/nodeinfo"},
+				{http.MethodPost, "/report"},
+			},
+			allowed: []string{domain.RolePeer},
+		},
+	}
+
+	for _, group := range groups {
+		t.Run(group.name, func(t *testing.T) {
+			allowed := make(map[string]bool)
+			for _, role := range group.allowed {
+				allowed[role] = true
+			}
+
+			for _, request := range group.requests {
+				method, path := request[0], request[1]
+
+				if status := routeStatus(mux, httptest.NewRequest(method, path, nil)); status != http.StatusUnauthorized {
+					t.Errorf("%s %s without a token: status %d, want 401", method, path, status)
+				}
+				req := httptest.NewRequest(method, path, nil)
+				req.Header.Set("Authorization", "Bearer wrong")
+				if status := routeStatus(mux, req); status != http.StatusUnauthorized {
+					t.Errorf("%s %s with an unknown token: status %d, want 401", method, path, status)
+				}
+
+				for role, token := range tokens {
+					req := httptest.NewRequest(method, path, nil)
+					req.Header.Set("Authorization", "Bearer "+token)
+					status := routeStatus(mux, req)
+					if allowed[role] && (status == http.StatusUnauthorized || status == http.StatusForbidden) {
+						t.Errorf("%s %s as %s: status %d, want it let through", method, path, role, status)
+					}
+					if !allowed[role] && status != http.StatusForbidden {
+						t.Errorf("%s %s as %s: status %d, want 403", method, path, role, status)
+					}
+				}
+			}
+		})
+	}
+
+	// The health check stays open for load balancers
+	if status := routeStatus(mux, httptest.NewRequest(http.MethodGet, "/health", nil)); status == http.StatusUnauthorized || status == http.StatusForbidden {
+		t.Errorf("GET /health without a token: status %d, want it open", status)
+	}
+}
+
+func TestRequireRoleAcceptsSessionCookie(t *testing.T) {
+	authService, err := auth.NewService(&domain.AuthConfig{
+		Enabled: true,
+		Tokens:  []domain.APIToken{{Name: "viewer", Role: domain.RoleViewer, TokenSHA256: auth.HashToken("viewer-secret")}},
+	})
+	if err != nil {
+		t.Fatalf("auth.NewService: %v", err)
+	}
+	ws := &WebServer{authService: authService, tlsService: fakeTLS{}}
+
+	var principal *domain.Principal
+	handler := ws.requireRole(domain.RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = r.Context().Value(principalKey{}).(*domain.Principal)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/nodes", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: "viewer-secret"})
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusOK || principal == nil || principal.Name != "viewer" {
+		t.Errorf("status %d, principal %+v; want the viewer let through", rec.Code, principal)
+	}
+}
@@ -0,0 +1,318 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// BackupService writes scheduled hot backups of the database, together with
+// the node's identity files, and keeps the newest few
+type BackupService struct {
+	store     domain.Store
+	configSvc domain.ConfigService
+	dataDir   string
+	dbName    string // database file name in dataDir and in each backup
+
+	config *domain.BackupConfig
+
+	running  bool
+	stopChan chan struct{}
+	mu       sync.RWMutex
+}
+
+// Backups are directories named by their UTC creation time, so name order is
+// time order. They are written under a hidden partial name and renamed once
+// complete, so an interrupted backup is never mistaken for a good one.
+const (
+	backupPrefix     = "nodeprobe-"
+	backupTimeFormat = "20060102T150405Z"
+	partialSuffix    = ".partial"
+	preRestoreSuffix = ".pre-restore"
+)
+
+// identityFiles are copied into every backup when present in the data
+// directory; losing them changes the node's ID and signing key
+var identityFiles = []string{"node.id", "signing.key"}
+
+func NewBackupService(store domain.Store, configSvc domain.ConfigService, dataDir string, dbName string) *BackupService {
+	return &BackupService{
+		store:     store,
+		configSvc: configSvc,
+		dataDir:   dataDir,
+		dbName:    dbName,
+		stopChan:  make(chan struct{}),
+	}
+}
+
+func (bs *BackupService) Start(ctx context.Context) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if bs.running {
+		return fmt.Errorf("backup service is already running")
+	}
+
+	config, err := bs.configSvc.LoadBackupConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load backup config: %w", err)
+	}
+	if !config.Enabled {
+		log.Println("Scheduled backups are disabled")
+		return nil
+	}
+	if _, ok := bs.store.(domain.BackupStore); !ok {
+		log.Printf("Scheduled backups are enabled but %v", domain.ErrBackupUnsupported)
+		return nil
+	}
+
+	bs.config = config
+	bs.running = true
+	go bs.backupLoop(ctx)
+
+	log.Printf("Backup service started (every %dh to %s, keeping %d)", config.IntervalHours, config.Dir, config.Keep)
+	return nil
+}
+
+func (bs *BackupService) Stop() error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if !bs.running {
+		return nil
+	}
+
+	close(bs.stopChan)
+	bs.running = false
+
+	log.Println("Backup service stopped")
+	return nil
+}
+
+func (bs *BackupService) backupLoop(ctx context.Context) {
+	interval := time.Duration(bs.config.IntervalHours) * time.Hour
+
+	// Continue the schedule across restarts instead of backing up on every start
+	next := time.Now()
+	if backups, err := ListBackups(bs.config.Dir); err != nil {
+		log.Printf("Failed to list backups: %v", err)
+	} else if len(backups) > 0 {
+		next = backups[len(backups)-1].CreatedAt.Add(interval)
+	}
+
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-bs.stopChan:
+			return
+		case <-timer.C:
+			if backup, err := bs.Backup(ctx, bs.config); err != nil {
+				log.Printf("Scheduled backup failed: %v", err)
+			} else {
+				log.Printf("Backed up database to %s", backup.Path)
+			}
+			timer.Reset(interval)
+		}
+	}
+}
+
+// Backup writes a backup of the database and identity files into a new
+// directory under config.Dir, then removes all but the newest config.Keep
+func (bs *BackupService) Backup(ctx context.Context, config *domain.BackupConfig) (*domain.Backup, error) {
+	backupStore, ok := bs.store.(domain.BackupStore)
+	if !ok {
+		return nil, domain.ErrBackupUnsupported
+	}
+
+	if err := os.MkdirAll(config.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	name := backupPrefix + createdAt.Format(backupTimeFormat)
+	path := filepath.Join(config.Dir, name)
+	partial := filepath.Join(config.Dir, "."+name+partialSuffix)
+
+	if err := bs.writeBackup(ctx, backupStore, partial); err != nil {
+		os.RemoveAll(partial)
+		return nil, err
+	}
+	if err := os.Rename(partial, path); err != nil {
+		os.RemoveAll(partial)
+		return nil, fmt.Errorf("failed to finish backup: %w", err)
+	}
+
+	if err := rotateBackups(config.Dir, config.Keep); err != nil {
+		log.Printf("Failed to remove old backups: %v", err)
+	}
+
+	return &domain.Backup{Path: path, CreatedAt: createdAt}, nil
+}
+
+func (bs *BackupService) writeBackup(ctx context.Context, backupStore domain.BackupStore, dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	if err := backupStore.Backup(ctx, filepath.Join(dir, bs.dbName)); err != nil {
+		return err
+	}
+
+	for _, name := range identityFiles {
+		err := copyFile(filepath.Join(bs.dataDir, name), filepath.Join(dir, name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to back up %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ListBackups returns the complete backups in dir, oldest first
+func ListBackups(dir string) ([]domain.Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	// ReadDir sorts by name, which is time order
+	var backups []domain.Backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !strings.HasPrefix(name, backupPrefix) {
+			continue
+		}
+		createdAt, err := time.Parse(backupTimeFormat, strings.TrimPrefix(name, backupPrefix))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, domain.Backup{Path: filepath.Join(dir, name), CreatedAt: createdAt})
+	}
+	return backups, nil
+}
+
+// rotateBackups removes all but the newest keep backups
+func rotateBackups(dir string, keep int) error {
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return err
+	}
+
+	for len(backups) > keep {
+		if err := os.RemoveAll(backups[0].Path); err != nil {
+			return err
+		}
+		log.Printf("Removed old backup %s", backups[0].Path)
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// RestoreBackup copies a backup's database and identity files into dataDir.
+// The backup must already be validated and the node stopped; an existing
+// write-ahead log suggests it is still running, so it is refused unless
+// force is set. Replaced files are kept with a .pre-restore suffix.
+func RestoreBackup(backupDir string, dataDir string, dbName string, force bool) error {
+	src := filepath.Join(backupDir, dbName)
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("%w: %s holds no %s", domain.ErrInvalidBackup, backupDir, dbName)
+	}
+
+	dst := filepath.Join(dataDir, dbName)
+	if _, err := os.Stat(dst + "-wal"); err == nil && !force {
+		return fmt.Errorf("%s has a write-ahead log, so the node may be running; stop it first, or use -force if it crashed", dst)
+	}
+
+	// Copy next to the destination first so the swap is a rename
+	restoring := dst + ".restoring"
+	os.Remove(restoring)
+	if err := copyFile(src, restoring); err != nil {
+		os.Remove(restoring)
+		return fmt.Errorf("failed to copy backup: %w", err)
+	}
+
+	// The journal files move with the database they belong to; left in place
+	// they would be replayed onto the restored one
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := setAside(dst, suffix); err != nil {
+			os.Remove(restoring)
+			return err
+		}
+	}
+	if err := os.Rename(restoring, dst); err != nil {
+		return fmt.Errorf("failed to swap in restored database: %w", err)
+	}
+
+	for _, name := range identityFiles {
+		src := filepath.Join(backupDir, name)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		dst := filepath.Join(dataDir, name)
+		if err := setAside(dst, ""); err != nil {
+			return err
+		}
+		if err := copyFile(src, dst); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// setAside renames path+suffix to path.pre-restore+suffix, replacing an
+// earlier set-aside copy; a missing file is not an error
+func setAside(path string, suffix string) error {
+	aside := path + preRestoreSuffix + suffix
+	if err := os.Remove(aside); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %w", aside, err)
+	}
+	if err := os.Rename(path+suffix, aside); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to set aside %s: %w", path+suffix, err)
+	}
+	return nil
+}
+
+// copyFile copies src to a new file dst with the same permissions and
+// syncs it to disk
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
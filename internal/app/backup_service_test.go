@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+	"nodeprobe/internal/pkg/memory"
+)
+
+// backupStore is an in-memory store whose backups write a marker file
+type backupStore struct {
+	*memory.Store
+	contents string
+}
+
+func (s *backupStore) Backup(ctx context.Context, path string) error {
+	return os.WriteFile(path, []byte(s.contents), 0600)
+}
+
+func writeFile(t *testing.T, path string, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestBackupWritesDatabaseAndIdentity(t *testing.T) {
+	ctx := context.Background()
+	dataDir, backupDir := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(dataDir, "node.id"), "node-uuid")
+
+	store := &backupStore{Store: memory.NewStore(), contents: "db"}
+	bs := NewBackupService(store, newFakeConfig("self"), dataDir, "nodeprobe.db")
+
+	backup, err := bs.Backup(ctx, &domain.BackupConfig{Dir: backupDir, Keep: 3})
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if got := readFile(t, filepath.Join(backup.Path, "nodeprobe.db")); got != "db" {
+		t.Errorf("backed up database = %q", got)
+	}
+	if got := readFile(t, filepath.Join(backup.Path, "node.id")); got != "node-uuid" {
+		t.Errorf("backed up node.id = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(backup.Path, "signing.key")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing signing key should be skipped, got %v", err)
+	}
+
+	entries, _ := os.ReadDir(backupDir)
+	if len(entries) != 1 {
+		t.Errorf("backup directory holds %d entries, want only the finished backup", len(entries))
+	}
+}
+
+func TestBackupRotation(t *testing.T) {
+	backupDir := t.TempDir()
+
+	// Older backups, a partial one and an unrelated directory
+	for _, name := range []string{
+		"nodeprobe-20250101T000000Z",
+		"nodeprobe-20250102T000000Z",
+		"nodeprobe-20250103T000000Z",
+		".nodeprobe-20250104T000000Z.partial",
+		"notes",
+	} {
+		if err := os.Mkdir(filepath.Join(backupDir, name), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := &backupStore{Store: memory.NewStore()}
+	bs := NewBackupService(store, newFakeConfig("self"), t.TempDir(), "nodeprobe.db")
+	latest, err := bs.Backup(context.Background(), &domain.BackupConfig{Dir: backupDir, Keep: 2})
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	backups, err := ListBackups(backupDir)
+	if err != nil {
+		t.Fatalf("ListBackups: %v", err)
+	}
+	if len(backups) != 2 || filepath.Base(backups[0].Path) != "nodeprobe-20250103T000000Z" || backups[1].Path != latest.Path {
+		t.Errorf("backups after rotation = %+v, want 2025-01-03 and the new one", backups)
+	}
+	if !backups[0].CreatedAt.Equal(time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("created at = %v", backups[0].CreatedAt)
+	}
+	for _, name := range []string{".nodeprobe-20250104T000000Z.partial", "notes"} {
+		if _, err := os.Stat(filepath.Join(backupDir, name)); err != nil {
+			t.Errorf("rotation removed %s", name)
+		}
+	}
+}
+
+func TestBackupUnsupported(t *testing.T) {
+	bs := NewBackupService(memory.NewStore(), newFakeConfig("self"), t.TempDir(), "")
+	if _, err := bs.Backup(context.Background(), &domain.BackupConfig{Dir: t.TempDir(), Keep: 1}); !errors.Is(err, domain.ErrBackupUnsupported) {
+		t.Errorf("error = %v, want ErrBackupUnsupported", err)
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	dataDir, backupDir := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(backupDir, "nodeprobe.db"), "backup db")
+	writeFile(t, filepath.Join(backupDir, "node.id"), "old-uuid")
+
+	writeFile(t, filepath.Join(dataDir, "nodeprobe.db"), "current db")
+	writeFile(t, filepath.Join(dataDir, "nodeprobe.db-wal"), "current wal")
+	writeFile(t, filepath.Join(dataDir, "node.id"), "regenerated-uuid")
+
+	if err := RestoreBackup(backupDir, dataDir, "nodeprobe.db", false); err == nil {
+		t.Fatal("restore over a database with a write-ahead log succeeded without force")
+	}
+	if got := readFile(t, filepath.Join(dataDir, "nodeprobe.db")); got != "current db" {
+		t.Fatalf("refused restore changed the database to %q", got)
+	}
+
+	if err := RestoreBackup(backupDir, dataDir, "nodeprobe.db", true); err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"nodeprobe.db":                 "backup db",
+		"node.id":                      "old-uuid",
+		"nodeprobe.db.pre-restore":     "current db",
+		"nodeprobe.db.pre-restore-wal": "current wal",
+		"node.id.pre-restore":          "regenerated-uuid",
+	} {
+		if got := readFile(t, filepath.Join(dataDir, name)); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "nodeprobe.db-wal")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("the replaced database's write-ahead log was left in place")
+	}
+
+	if err := RestoreBackup(backupDir, dataDir, "nodeprobe.bolt", false); !errors.Is(err, domain.ErrInvalidBackup) {
+		t.Errorf("restoring a backup of another backend: %v, want ErrInvalidBackup", err)
+	}
+}
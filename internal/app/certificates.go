@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// certificateRenewalLoop periodically re-runs EnsureCertificate, which
+// replaces the certificate once it is inside the renewal window; the server
+// picks up the new files on the next handshake
+func (ws *WebServer) certificateRenewalLoop(ctx context.Context) {
+	ticker := time.NewTicker(domain.CertificateCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ws.tlsService.EnsureCertificate(); err != nil {
+				log.Printf("Failed to renew TLS certificate: %v", err)
+			}
+			ws.checkCertificate()
+		}
+	}
+}
+
+// checkCertificate logs the expiry of the certificate being served, as a
+// warning once it is close
+func (ws *WebServer) checkCertificate() {
+	expiry, err := ws.tlsService.CertificateExpiry()
+	if err != nil {
+		log.Printf("Failed to read TLS certificate expiry: %v", err)
+		return
+	}
+
+	remaining := time.Until(expiry)
+	if remaining < domain.CertificateExpiryWarning {
+		log.Printf("WARNING: TLS certificate expires in %s (%s)",
+			remaining.Round(time.Minute), expiry.UTC().Format(time.RFC3339))
+		return
+	}
+
+	log.Printf("TLS certificate valid until %s", expiry.UTC().Format(time.RFC3339))
+}
+
+// certificateStatus describes the served certificate for /health
+func (ws *WebServer) certificateStatus() map[string]interface{} {
+	expiry, err := ws.tlsService.CertificateExpiry()
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+
+	return map[string]interface{}{
+		"not_after":       expiry.UTC().Format(time.RFC3339),
+		"expires_in_days": int(time.Until(expiry).Hours() / 24),
+	}
+}
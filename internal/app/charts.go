@@ -0,0 +1,220 @@
+package app
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// Chart geometry shared by the inline SVG charts
+const (
+	chartWidth   = 960
+	chartHeight  = 240
+	chartPadLeft = 56
+	chartPadTop  = 12
+	chartPadBot  = 28
+	chartPadRite = 12
+)
+
+// chartFrame maps time and value coordinates onto the SVG canvas
+type chartFrame struct {
+	since, until time.Time
+	maxValue     float64
+}
+
+// newChartFrame spans the frame from the first bucket's start to the last bucket's end
+func newChartFrame(buckets []domain.LatencyBucket, maxValue float64) chartFrame {
+	if len(buckets) == 0 {
+		return chartFrame{maxValue: maxValue}
+	}
+	return chartFrame{
+		since:    buckets[0].Start,
+		until:    buckets[len(buckets)-1].Start.Add(bucketSize(buckets)),
+		maxValue: maxValue,
+	}
+}
+
+func (f chartFrame) x(t time.Time) float64 {
+	span := f.until.Sub(f.since).Seconds()
+	if span <= 0 {
+		return chartPadLeft
+	}
+	plotWidth := float64(chartWidth - chartPadLeft - chartPadRite)
+	return chartPadLeft + t.Sub(f.since).Seconds()/span*plotWidth
+}
+
+func (f chartFrame) y(v float64) float64 {
+	plotHeight := float64(chartHeight - chartPadTop - chartPadBot)
+	if f.maxValue <= 0 {
+		return chartPadTop + plotHeight
+	}
+	return chartPadTop + plotHeight - v/f.maxValue*plotHeight
+}
+
+// axes draws the plot border, horizontal gridlines and time labels
+func (f chartFrame) axes(b *strings.Builder, unit string) {
+	bottom := chartHeight - chartPadBot
+	right := chartWidth - chartPadRite
+
+	for i := 0; i <= 4; i++ {
+		v := f.maxValue * float64(i) / 4
+		y := f.y(v)
+		fmt.Fprintf(b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#eee"/>`, chartPadLeft, y, right, y)
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" text-anchor="end">%.0f%s</text>`, chartPadLeft-6, y+4, v, unit)
+	}
+
+	layout := "15:04"
+	if f.until.Sub(f.since) > 48*time.Hour {
+		layout = "01-02 15:04"
+	}
+	for i := 0; i <= 4; i++ {
+		t := f.since.Add(f.until.Sub(f.since) * time.Duration(i) / 4)
+		fmt.Fprintf(b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, f.x(t), chartHeight-8, t.Format(layout))
+	}
+
+	fmt.Fprintf(b, `<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="#ccc"/>`,
+		chartPadLeft, chartPadTop, right-chartPadLeft, bottom-chartPadTop)
+}
+
+// renderLatencyChart draws the median latency line over 5–95th and 25–75th
+// percentile bands; buckets without successful polls break the series
+func renderLatencyChart(buckets []domain.LatencyBucket) template.HTML {
+	maxValue := 0.0
+	for _, bucket := range buckets {
+		maxValue = math.Max(maxValue, bucket.P95Ms)
+	}
+	frame := newChartFrame(buckets, niceCeiling(maxValue*1.1))
+	size := bucketSize(buckets)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" role="img" aria-label="Latency over time">`, chartWidth, chartHeight)
+	frame.axes(&b, "ms")
+
+	for _, run := range successfulRuns(buckets) {
+		b.WriteString(bandPath(frame, run, size, func(x domain.LatencyBucket) (float64, float64) { return x.P5Ms, x.P95Ms }, "#667eea", 0.15))
+		b.WriteString(bandPath(frame, run, size, func(x domain.LatencyBucket) (float64, float64) { return x.P25Ms, x.P75Ms }, "#667eea", 0.3))
+
+		var line strings.Builder
+		for i, bucket := range run {
+			cmd := "L"
+			if i == 0 {
+				cmd = "M"
+			}
+			fmt.Fprintf(&line, "%s%.1f,%.1f ", cmd, frame.x(bucketMid(bucket, size)), frame.y(bucket.P50Ms))
+		}
+		fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="#764ba2" stroke-width="2"/>`, strings.TrimSpace(line.String()))
+
+		if len(run) == 1 {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="#764ba2"/>`,
+				frame.x(bucketMid(run[0], size)), frame.y(run[0].P50Ms))
+		}
+	}
+
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// renderSuccessChart draws one bar per bucket coloured by its success rate
+func renderSuccessChart(buckets []domain.LatencyBucket) template.HTML {
+	frame := newChartFrame(buckets, 100)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" role="img" aria-label="Success rate over time">`, chartWidth, chartHeight)
+	frame.axes(&b, "%")
+
+	width := 0.0
+	if len(buckets) > 0 {
+		width = math.Max(frame.x(frame.since.Add(bucketSize(buckets)))-frame.x(frame.since)-1, 1)
+	}
+
+	for _, bucket := range buckets {
+		if bucket.Polls == 0 {
+			continue
+		}
+		colour := "#28a745"
+		switch {
+		case bucket.SuccessRate < failingSuccessRate:
+			colour = "#dc3545"
+		case bucket.SuccessRate < degradedSuccessRate:
+			colour = "#ffc107"
+		}
+		top := frame.y(bucket.SuccessRate)
+		// Always show a sliver so fully failed buckets remain visible
+		height := math.Max(frame.y(0)-top, 2)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %.1f%% (%d/%d)</title></rect>`,
+			frame.x(bucket.Start), frame.y(0)-height, width, height, colour,
+			bucket.Start.Format("2006-01-02 15:04"), bucket.SuccessRate, bucket.Successes, bucket.Polls)
+	}
+
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// bandPath draws a closed polygon between a lower and upper series
+func bandPath(frame chartFrame, run []domain.LatencyBucket, size time.Duration, bounds func(domain.LatencyBucket) (float64, float64), colour string, opacity float64) string {
+	var path strings.Builder
+	for i, bucket := range run {
+		_, upper := bounds(bucket)
+		cmd := "L"
+		if i == 0 {
+			cmd = "M"
+		}
+		fmt.Fprintf(&path, "%s%.1f,%.1f ", cmd, frame.x(bucketMid(bucket, size)), frame.y(upper))
+	}
+	for i := len(run) - 1; i >= 0; i-- {
+		lower, _ := bounds(run[i])
+		fmt.Fprintf(&path, "L%.1f,%.1f ", frame.x(bucketMid(run[i], size)), frame.y(lower))
+	}
+	return fmt.Sprintf(`<path d="%sZ" fill="%s" fill-opacity="%.2f" stroke="none"/>`, path.String(), colour, opacity)
+}
+
+// successfulRuns splits buckets into consecutive runs that have latency data
+func successfulRuns(buckets []domain.LatencyBucket) [][]domain.LatencyBucket {
+	var runs [][]domain.LatencyBucket
+	var current []domain.LatencyBucket
+	for _, bucket := range buckets {
+		if bucket.Successes == 0 {
+			if len(current) > 0 {
+				runs = append(runs, current)
+				current = nil
+			}
+			continue
+		}
+		current = append(current, bucket)
+	}
+	if len(current) > 0 {
+		runs = append(runs, current)
+	}
+	return runs
+}
+
+// bucketSize infers the bucket width from the first two bucket start times
+func bucketSize(buckets []domain.LatencyBucket) time.Duration {
+	if len(buckets) < 2 {
+		return time.Minute
+	}
+	return buckets[1].Start.Sub(buckets[0].Start)
+}
+
+// bucketMid returns the centre of a bucket so points sit between bucket edges
+func bucketMid(bucket domain.LatencyBucket, size time.Duration) time.Time {
+	return bucket.Start.Add(size / 2)
+}
+
+// niceCeiling rounds a value up to 1, 2 or 5 times a power of ten
+func niceCeiling(v float64) float64 {
+	if v <= 0 {
+		return 10
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if v <= step*magnitude {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
@@ -0,0 +1,234 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// CoordinateService keeps this node's Vivaldi coordinate, moved by the
+// round-trip time of every successful poll, and the coordinates of other
+// nodes: reported by the nodes it polls and heard from them about the rest.
+// Coordinates live in memory and settle again within minutes of a restart.
+type CoordinateService struct {
+	nodeService domain.NodeService
+	configSvc   domain.ConfigService
+
+	nodeID     string
+	staleAfter time.Duration
+	local      domain.Coordinate
+	peers      map[string]*peerCoordinate // by node ID
+	mu         sync.RWMutex
+}
+
+// peerCoordinate is what this node holds about another node
+type peerCoordinate struct {
+	coordinate *domain.Coordinate // nil until the node or a peer reports one
+	source     string
+	updatedAt  time.Time
+	rtts       []float64 // latest poll round trips, newest last
+}
+
+// latencyFilterSize is the number of round trips whose median moves the
+// coordinate, so one slow poll does not throw it off
+const latencyFilterSize = 3
+
+func NewCoordinateService(nodeService domain.NodeService, configSvc domain.ConfigService) *CoordinateService {
+	return &CoordinateService{
+		nodeService: nodeService,
+		configSvc:   configSvc,
+		local:       domain.NewCoordinate(),
+		peers:       make(map[string]*peerCoordinate),
+	}
+}
+
+// Initialize loads coordinates.json and this node's ID
+func (cs *CoordinateService) Initialize() error {
+	config, err := cs.configSvc.LoadCoordinateConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load coordinate config: %w", err)
+	}
+	nodeID, err := cs.configSvc.GetNodeID()
+	if err != nil {
+		return fmt.Errorf("failed to get node ID: %w", err)
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.nodeID = nodeID
+	cs.staleAfter = time.Duration(config.StaleAfterMinutes) * time.Minute
+	return nil
+}
+
+// Observe records a successful poll of nodeInfo.ID that took rttMs: the
+// polled node's coordinate moves this node's own, and the coordinates it
+// reports for other known nodes replace older ones
+func (cs *CoordinateService) Observe(ctx context.Context, nodeInfo *domain.NodeInfo, rttMs float64) {
+	// Only nodes the node service accepted are tracked, bounding what a
+	// peer can make this node hold
+	known := make(map[string]bool, len(nodeInfo.Coordinates))
+	for _, reported := range nodeInfo.Coordinates {
+		if _, err := cs.nodeService.GetNodeByID(ctx, reported.NodeID); err == nil {
+			known[reported.NodeID] = true
+		}
+	}
+
+	now := time.Now()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	peer := cs.peer(nodeInfo.ID)
+	peer.updatedAt = now
+	peer.rtts = append(peer.rtts, rttMs)
+	if len(peer.rtts) > latencyFilterSize {
+		peer.rtts = peer.rtts[len(peer.rtts)-latencyFilterSize:]
+	}
+	if nodeInfo.Coordinate != nil && nodeInfo.Coordinate.Valid() {
+		coordinate := nodeInfo.Coordinate.Clone()
+		cs.local.Update(coordinate, median(peer.rtts))
+		peer.coordinate = &coordinate
+		peer.source = domain.CoordinateDirect
+	}
+
+	for _, reported := range nodeInfo.Coordinates {
+		if !known[reported.NodeID] || reported.NodeID == cs.nodeID || reported.NodeID == nodeInfo.ID {
+			continue
+		}
+		if !reported.Coordinate.Valid() {
+			continue
+		}
+
+		// Reported times come from the peer's clock; one from the future
+		// counts as now
+		updatedAt := reported.UpdatedAt
+		if updatedAt.After(now) {
+			updatedAt = now
+		}
+		if now.Sub(updatedAt) > cs.staleAfter {
+			continue
+		}
+		existing, ok := cs.peers[reported.NodeID]
+		if ok && existing.coordinate != nil && !existing.updatedAt.Before(updatedAt) {
+			continue
+		}
+
+		coordinate := reported.Coordinate.Clone()
+		gossiped := cs.peer(reported.NodeID)
+		gossiped.coordinate = &coordinate
+		gossiped.source = domain.CoordinateGossip
+		gossiped.updatedAt = updatedAt
+	}
+
+	for nodeID, peer := range cs.peers {
+		if now.Sub(peer.updatedAt) > cs.staleAfter {
+			delete(cs.peers, nodeID)
+		}
+	}
+}
+
+// peer returns the entry for nodeID, adding it if needed; callers hold mu
+func (cs *CoordinateService) peer(nodeID string) *peerCoordinate {
+	peer, ok := cs.peers[nodeID]
+	if !ok {
+		peer = &peerCoordinate{}
+		cs.peers[nodeID] = peer
+	}
+	return peer
+}
+
+// LocalCoordinate returns a copy of this node's coordinate
+func (cs *CoordinateService) LocalCoordinate() domain.Coordinate {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.local.Clone()
+}
+
+// GetCoordinates returns this node's coordinate followed by the fresh
+// coordinates held for other nodes, by node ID
+func (cs *CoordinateService) GetCoordinates(ctx context.Context) ([]domain.NodeCoordinate, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	now := time.Now()
+	coordinates := []domain.NodeCoordinate{{
+		NodeID:     cs.nodeID,
+		Coordinate: cs.local.Clone(),
+		Source:     domain.CoordinateSelf,
+		UpdatedAt:  now,
+	}}
+
+	var peers []domain.NodeCoordinate
+	for nodeID, peer := range cs.peers {
+		if peer.coordinate == nil || now.Sub(peer.updatedAt) > cs.staleAfter {
+			continue
+		}
+		peers = append(peers, domain.NodeCoordinate{
+			NodeID:     nodeID,
+			Coordinate: peer.coordinate.Clone(),
+			Source:     peer.source,
+			UpdatedAt:  peer.updatedAt,
+		})
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].NodeID < peers[j].NodeID })
+
+	return append(coordinates, peers...), nil
+}
+
+// EstimateRTT predicts the round-trip time between two nodes from their
+// coordinates, either of which may be this node
+func (cs *CoordinateService) EstimateRTT(ctx context.Context, from, to string) (*domain.RTTEstimate, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	now := time.Now()
+	a, err := cs.coordinate(from, now)
+	if err != nil {
+		return nil, err
+	}
+	b, err := cs.coordinate(to, now)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := &domain.RTTEstimate{
+		From:        from,
+		To:          to,
+		EstimatedMs: a.DistanceTo(b),
+		Error:       (a.Error + b.Error) / 2,
+	}
+	estimate.ErrorMs = estimate.EstimatedMs * estimate.Error
+
+	if from == cs.nodeID {
+		if peer, ok := cs.peers[to]; ok && len(peer.rtts) > 0 && now.Sub(peer.updatedAt) <= cs.staleAfter {
+			estimate.MeasuredMs = median(peer.rtts)
+		}
+	}
+	return estimate, nil
+}
+
+// coordinate returns the fresh coordinate of nodeID; callers hold mu
+func (cs *CoordinateService) coordinate(nodeID string, now time.Time) (domain.Coordinate, error) {
+	if nodeID == cs.nodeID {
+		return cs.local, nil
+	}
+	peer, ok := cs.peers[nodeID]
+	if !ok || peer.coordinate == nil || now.Sub(peer.updatedAt) > cs.staleAfter {
+		return domain.Coordinate{}, fmt.Errorf("%w: %s", domain.ErrCoordinateNotFound, nodeID)
+	}
+	return *peer.coordinate, nil
+}
+
+// median returns the median of values, which must not be empty
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
@@ -0,0 +1,194 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+func newTestCoordinateService(t *testing.T, ns *NodeService, config *fakeConfig) *CoordinateService {
+	t.Helper()
+
+	cs := NewCoordinateService(ns, config)
+	if err := cs.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return cs
+}
+
+func TestVivaldiConverges(t *testing.T) {
+	// Two sites 80ms apart, each with a few nodes a couple of ms apart
+	type site struct{ x, y float64 }
+	sites := []site{{0, 0}, {0, 2}, {2, 0}, {80, 0}, {80, 3}, {82, 1}}
+	rtt := func(i, j int) float64 {
+		return math.Hypot(sites[i].x-sites[j].x, sites[i].y-sites[j].y) + 1 // 0.5ms access link each
+	}
+
+	coordinates := make([]domain.Coordinate, len(sites))
+	for i := range coordinates {
+		coordinates[i] = domain.NewCoordinate()
+	}
+	random := rand.New(rand.NewPCG(1, 2))
+	for round := 0; round < 2000; round++ {
+		i, j := random.IntN(len(sites)), random.IntN(len(sites))
+		if i == j {
+			continue
+		}
+		other := coordinates[j].Clone()
+		coordinates[i].Update(other, rtt(i, j)*(0.95+0.1*random.Float64()))
+	}
+
+	for i := range sites {
+		if !coordinates[i].Valid() {
+			t.Fatalf("coordinate %d = %+v, want valid", i, coordinates[i])
+		}
+		for j := range sites {
+			if i == j {
+				continue
+			}
+			predicted, actual := coordinates[i].DistanceTo(coordinates[j]), rtt(i, j)
+			if math.Abs(predicted-actual) > 0.25*actual+1 {
+				t.Errorf("predicted %d to %d = %.1fms, want about %.1fms", i, j, predicted, actual)
+			}
+		}
+		if coordinates[i].Error > 0.5 {
+			t.Errorf("coordinate %d error = %.2f, want it to have settled", i, coordinates[i].Error)
+		}
+	}
+}
+
+func TestCoordinateServiceObserve(t *testing.T) {
+	ctx := context.Background()
+	config := newFakeConfig("self")
+	ns, _ := newTestNodeService(t, config)
+	for _, node := range []domain.Node{testNode("peer-a", "10.0.0.2"), testNode("peer-b", "10.0.0.3"), testNode("peer-c", "10.0.0.4")} {
+		if err := ns.addOrUpdateNode(ctx, &node); err != nil {
+			t.Fatalf("addOrUpdateNode: %v", err)
+		}
+	}
+	cs := newTestCoordinateService(t, ns, config)
+
+	at := func(x float64) domain.Coordinate {
+		coordinate := domain.NewCoordinate()
+		coordinate.Vec[0] = x
+		coordinate.Error = 0.2
+		return coordinate
+	}
+	now := time.Now()
+	invalid := at(1)
+	invalid.Vec = invalid.Vec[:2]
+	peerA := at(10)
+	info := &domain.NodeInfo{ID: "peer-a", Coordinate: &peerA, Coordinates: []domain.NodeCoordinate{
+		{NodeID: "peer-b", Coordinate: at(50), UpdatedAt: now.Add(-time.Minute)},
+		{NodeID: "peer-c", Coordinate: at(90), UpdatedAt: now.Add(-time.Hour)}, // stale
+		{NodeID: "stranger", Coordinate: at(5), UpdatedAt: now},                // unknown node
+		{NodeID: "self", Coordinate: at(5), UpdatedAt: now},                    // our own
+		{NodeID: "peer-a", Coordinate: invalid, UpdatedAt: now},
+	}}
+	for _, rtt := range []float64{10, 11, 400} {
+		cs.Observe(ctx, info, rtt)
+	}
+
+	coordinates, err := cs.GetCoordinates(ctx)
+	if err != nil {
+		t.Fatalf("GetCoordinates: %v", err)
+	}
+	sources := map[string]string{}
+	for _, coordinate := range coordinates {
+		sources[coordinate.NodeID] = coordinate.Source
+	}
+	want := map[string]string{"self": domain.CoordinateSelf, "peer-a": domain.CoordinateDirect, "peer-b": domain.CoordinateGossip}
+	if len(sources) != len(want) || coordinates[0].NodeID != "self" {
+		t.Fatalf("coordinates = %+v, want self, peer-a and peer-b", coordinates)
+	}
+	for nodeID, source := range want {
+		if sources[nodeID] != source {
+			t.Errorf("%s source = %q, want %q", nodeID, sources[nodeID], source)
+		}
+	}
+
+	// This node moved away from peer-a, which it measured at about 10ms
+	if local := cs.LocalCoordinate(); local.Vec[0] >= 10 || local.Error >= domain.VivaldiErrorMax {
+		t.Errorf("local coordinate = %+v, want it moved and more confident", local)
+	}
+
+	estimate, err := cs.EstimateRTT(ctx, "self", "peer-a")
+	if err != nil {
+		t.Fatalf("EstimateRTT: %v", err)
+	}
+	if estimate.MeasuredMs != 11 || estimate.EstimatedMs <= 0 || estimate.ErrorMs != estimate.EstimatedMs*estimate.Error {
+		t.Errorf("estimate = %+v, want the median measured 11ms alongside the estimate", estimate)
+	}
+
+	// Nodes that never polled each other
+	estimate, err = cs.EstimateRTT(ctx, "peer-a", "peer-b")
+	if err != nil {
+		t.Fatalf("EstimateRTT: %v", err)
+	}
+	if math.Abs(estimate.EstimatedMs-40.02) > 0.01 || estimate.MeasuredMs != 0 {
+		t.Errorf("peer-a to peer-b = %+v, want 40ms apart plus heights, nothing measured", estimate)
+	}
+
+	if _, err := cs.EstimateRTT(ctx, "self", "peer-c"); !errors.Is(err, domain.ErrCoordinateNotFound) {
+		t.Errorf("EstimateRTT to a stale coordinate error = %v, want ErrCoordinateNotFound", err)
+	}
+
+	// A newer report replaces an older one, an older one does not
+	info = &domain.NodeInfo{ID: "peer-c", Coordinates: []domain.NodeCoordinate{
+		{NodeID: "peer-b", Coordinate: at(60), UpdatedAt: now.Add(-2 * time.Minute)},
+	}}
+	cs.Observe(ctx, info, 5)
+	if estimate, _ := cs.EstimateRTT(ctx, "peer-a", "peer-b"); math.Abs(estimate.EstimatedMs-40.02) > 0.01 {
+		t.Errorf("peer-a to peer-b = %.2fms after an older report, want unchanged", estimate.EstimatedMs)
+	}
+	info.Coordinates[0].UpdatedAt = now.Add(time.Hour) // a peer clock running ahead counts as now
+	cs.Observe(ctx, info, 5)
+	if estimate, _ := cs.EstimateRTT(ctx, "peer-a", "peer-b"); math.Abs(estimate.EstimatedMs-50.02) > 0.01 {
+		t.Errorf("peer-a to peer-b = %.2fms after a newer report, want 50ms apart plus heights", estimate.EstimatedMs)
+	}
+}
+
+func TestPollSubset(t *testing.T) {
+	var nodes []domain.Node
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		nodes = append(nodes, testNode(id, "10.0.0.2"))
+	}
+
+	if all := pollSubset("self", nodes, 0); len(all) != len(nodes) {
+		t.Errorf("subset of 0 = %d nodes, want all %d", len(all), len(nodes))
+	}
+
+	subset := pollSubset("self", nodes, 3)
+	if len(subset) != 3 {
+		t.Fatalf("subset = %d nodes, want 3", len(subset))
+	}
+
+	// A node leaving the subset makes room for the next one only
+	var rest []domain.Node
+	for _, node := range nodes {
+		if node.ID != subset[0].ID {
+			rest = append(rest, node)
+		}
+	}
+	next := pollSubset("self", rest, 3)
+	if next[0].ID != subset[1].ID || next[1].ID != subset[2].ID {
+		t.Errorf("subset after %s left = %v, want %s and %s kept", subset[0].ID, next, subset[1].ID, subset[2].ID)
+	}
+
+	// Other nodes poll other subsets
+	differs := false
+	for _, localID := range []string{"n1", "n2", "n3", "n4"} {
+		other := pollSubset(localID, nodes, 3)
+		for i := range other {
+			differs = differs || other[i].ID != subset[i].ID
+		}
+	}
+	if !differs {
+		t.Error("every node polls the same subset")
+	}
+}
@@ -0,0 +1,493 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// ExportService streams the node registry and poll history out as NDJSON,
+// CSV or Parquet, and merges such exports from other nodes into this node's
+// store
+type ExportService struct {
+	nodeRepo  domain.NodeRepository
+	pollRepo  domain.PollRepository
+	configSvc domain.ConfigService
+}
+
+const (
+	// exportBatch is how much poll history is read per query, so large
+	// ranges stream instead of loading at once
+	exportBatch = 1 * time.Hour
+	// importBatch is how many poll results are written per transaction
+	importBatch = 1000
+	// defaultExportWindow applies when an export has no start time
+	defaultExportWindow = 24 * time.Hour
+	// maxExportWindow bounds the poll result range of one export, and with
+	// it the number of batch queries; longer history is exported in parts
+	maxExportWindow = 90 * 24 * time.Hour
+)
+
+// CSV columns; imports match them by header name. Node exports end with a
+// labels column, which older exports lack
+var (
+	nodeColumns       = []string{"id", "fqdn", "ip", "discovered_by", "first_seen", "last_seen", "is_active"}
+	pollResultColumns = []string{"observer", "node_id", "poll_time", "success", "response_ms", "error", "path_mtu"}
+)
+
+func NewExportService(nodeRepo domain.NodeRepository, pollRepo domain.PollRepository, configSvc domain.ConfigService) *ExportService {
+	return &ExportService{
+		nodeRepo:  nodeRepo,
+		pollRepo:  pollRepo,
+		configSvc: configSvc,
+	}
+}
+
+// Export writes the selected tables to w. Poll results this node made are
+// written with this node's ID as observer, so the export is self-describing
+// once merged elsewhere.
+func (es *ExportService) Export(ctx context.Context, w io.Writer, opts domain.ExportOptions) error {
+	if err := normalizeExportOptions(&opts, time.Now()); err != nil {
+		return err
+	}
+
+	nodeID, err := es.configSvc.GetNodeID()
+	if err != nil {
+		return fmt.Errorf("failed to get node ID: %w", err)
+	}
+
+	switch opts.Format {
+	case domain.ExportFormatCSV:
+		return es.exportCSV(ctx, w, opts, nodeID)
+	case domain.ExportFormatParquet:
+		return es.exportParquet(ctx, w, opts, nodeID)
+	}
+	return es.exportNDJSON(ctx, w, opts, nodeID)
+}
+
+// ParseExportTime parses an export range bound given either as an RFC 3339
+// time or as a duration before now, such as "72h"; empty yields the zero time
+func ParseExportTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if ago, err := time.ParseDuration(value); err == nil {
+		return now.Add(-ago), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q is neither an RFC 3339 time nor a duration", domain.ErrInvalidRange, value)
+	}
+	return t, nil
+}
+
+// normalizeExportOptions fills in defaults and rejects unknown formats,
+// tables and empty or overlong time ranges
+func normalizeExportOptions(opts *domain.ExportOptions, now time.Time) error {
+	switch opts.Format {
+	case "":
+		opts.Format = domain.ExportFormatNDJSON
+	case domain.ExportFormatNDJSON, domain.ExportFormatCSV, domain.ExportFormatParquet:
+	default:
+		return fmt.Errorf("%w: unknown format %q", domain.ErrInvalidExport, opts.Format)
+	}
+
+	switch opts.Table {
+	case "":
+		if opts.Format != domain.ExportFormatNDJSON {
+			return fmt.Errorf("%w: %s exports hold one table, choose %s or %s",
+				domain.ErrInvalidExport, opts.Format, domain.ExportTableNodes, domain.ExportTablePollResults)
+		}
+	case domain.ExportTableNodes, domain.ExportTablePollResults:
+	default:
+		return fmt.Errorf("%w: unknown table %q", domain.ErrInvalidExport, opts.Table)
+	}
+
+	if opts.Until.IsZero() {
+		opts.Until = now
+	}
+	if opts.Since.IsZero() {
+		opts.Since = opts.Until.Add(-defaultExportWindow)
+	}
+	if !opts.Since.Before(opts.Until) {
+		return fmt.Errorf("%w: since must be before until", domain.ErrInvalidRange)
+	}
+	if opts.Until.Sub(opts.Since) > maxExportWindow {
+		return fmt.Errorf("%w: an export covers at most %d days", domain.ErrInvalidRange, int(maxExportWindow.Hours()/24))
+	}
+
+	return nil
+}
+
+func (es *ExportService) exportNDJSON(ctx context.Context, w io.Writer, opts domain.ExportOptions, nodeID string) error {
+	// Read before anything is written, so a failure can still be reported
+	var nodes []domain.Node
+	if opts.Table != domain.ExportTablePollResults {
+		var err error
+		if nodes, err = es.nodeRepo.GetAllNodes(ctx); err != nil {
+			return fmt.Errorf("failed to get nodes: %w", err)
+		}
+	}
+
+	enc := json.NewEncoder(w)
+
+	header := &domain.ExportHeader{
+		Version:    domain.ExportVersion,
+		NodeID:     nodeID,
+		Since:      opts.Since,
+		Until:      opts.Until,
+		ExportedAt: time.Now(),
+	}
+	if err := enc.Encode(domain.ExportRecord{Type: domain.ExportRecordHeader, Header: header}); err != nil {
+		return fmt.Errorf("failed to write export header: %w", err)
+	}
+
+	for i := range nodes {
+		if err := enc.Encode(domain.ExportRecord{Type: domain.ExportRecordNode, Node: &nodes[i]}); err != nil {
+			return fmt.Errorf("failed to write node: %w", err)
+		}
+	}
+
+	if opts.Table != domain.ExportTableNodes {
+		return es.streamPollResults(ctx, opts.Since, opts.Until, nodeID, func(result *domain.PollResult) error {
+			return enc.Encode(domain.ExportRecord{Type: domain.ExportRecordPollResult, PollResult: result})
+		})
+	}
+
+	return nil
+}
+
+func (es *ExportService) exportCSV(ctx context.Context, w io.Writer, opts domain.ExportOptions, nodeID string) error {
+	cw := csv.NewWriter(w)
+
+	if opts.Table == domain.ExportTableNodes {
+		if err := cw.Write(append(nodeColumns, "labels")); err != nil {
+			return fmt.Errorf("failed to write CSV header: %w", err)
+		}
+
+		nodes, err := es.nodeRepo.GetAllNodes(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get nodes: %w", err)
+		}
+		for _, node := range nodes {
+			if err := cw.Write([]string{
+				node.ID, node.FQDN, node.IP, node.DiscoveredBy,
+				node.FirstSeen.Format(time.RFC3339Nano), node.LastSeen.Format(time.RFC3339Nano),
+				strconv.FormatBool(node.IsActive), domain.FormatLabels(node.Labels),
+			}); err != nil {
+				return fmt.Errorf("failed to write node: %w", err)
+			}
+		}
+	} else {
+		if err := cw.Write(pollResultColumns); err != nil {
+			return fmt.Errorf("failed to write CSV header: %w", err)
+		}
+
+		err := es.streamPollResults(ctx, opts.Since, opts.Until, nodeID, func(result *domain.PollResult) error {
+			return cw.Write([]string{
+				result.Observer, result.NodeID, result.PollTime.Format(time.RFC3339Nano),
+				strconv.FormatBool(result.Success), strconv.FormatInt(result.ResponseMs, 10),
+				result.Error, strconv.Itoa(result.PathMTU),
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// streamPollResults passes poll results in [since, until) to fn one batch
+// window at a time, filling in nodeID as observer of this node's own polls
+func (es *ExportService) streamPollResults(ctx context.Context, since, until time.Time, nodeID string, fn func(*domain.PollResult) error) error {
+	for start := since; start.Before(until); start = start.Add(exportBatch) {
+		end := start.Add(exportBatch)
+		if end.After(until) {
+			end = until
+		}
+
+		results, err := es.pollRepo.GetPollResultsBetween(ctx, start, end)
+		if err != nil {
+			return fmt.Errorf("failed to get poll results: %w", err)
+		}
+
+		for i := range results {
+			if results[i].Observer == "" {
+				results[i].Observer = nodeID
+			}
+			if err := fn(&results[i]); err != nil {
+				return fmt.Errorf("failed to write poll result: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// importer merges records into the store, batching poll result writes
+type importer struct {
+	es      *ExportService
+	nodeID  string
+	stats   domain.ImportStats
+	pending []domain.PollResult
+}
+
+// Import merges an export into this node's store. Nodes are added or
+// refreshed when the export has seen them more recently; pinned certificate
+// fingerprints are never imported. Poll results already stored for the same
+// observer, node and time are skipped, so overlapping exports can be
+// imported repeatedly.
+func (es *ExportService) Import(ctx context.Context, r io.Reader, format string) (*domain.ImportStats, error) {
+	nodeID, err := es.configSvc.GetNodeID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node ID: %w", err)
+	}
+
+	imp := &importer{es: es, nodeID: nodeID}
+	switch format {
+	case "", domain.ExportFormatNDJSON:
+		err = imp.readNDJSON(ctx, r)
+	case domain.ExportFormatCSV:
+		err = imp.readCSV(ctx, r)
+	case domain.ExportFormatParquet:
+		err = imp.readParquet(ctx, r)
+	default:
+		err = fmt.Errorf("%w: unknown format %q", domain.ErrInvalidExport, format)
+	}
+	if err == nil {
+		err = imp.flush(ctx)
+	}
+
+	// Stats cover what was merged before any error
+	return &imp.stats, err
+}
+
+func (imp *importer) readNDJSON(ctx context.Context, r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var record domain.ExportRecord
+		if err := dec.Decode(&record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%w: record %d: %v", domain.ErrInvalidExport, line, err)
+		}
+
+		var err error
+		switch {
+		case record.Type == domain.ExportRecordHeader && record.Header != nil:
+			if record.Header.Version > domain.ExportVersion {
+				err = fmt.Errorf("%w: export version %d is newer than this build supports (%d)",
+					domain.ErrInvalidExport, record.Header.Version, domain.ExportVersion)
+			}
+		case record.Type == domain.ExportRecordNode && record.Node != nil:
+			err = imp.importNode(ctx, record.Node)
+		case record.Type == domain.ExportRecordPollResult && record.PollResult != nil:
+			err = imp.importPollResult(ctx, record.PollResult)
+		default:
+			err = fmt.Errorf("%w: unknown record type %q", domain.ErrInvalidExport, record.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", line, err)
+		}
+	}
+}
+
+func (imp *importer) readCSV(ctx context.Context, r io.Reader) error {
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("%w: failed to read CSV header: %v", domain.ErrInvalidExport, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+
+	// The header tells which table the file holds
+	var parse func(row []string) error
+	switch {
+	case hasColumns(columns, nodeColumns):
+		parse = func(row []string) error {
+			node, err := parseNodeRow(row, columns)
+			if err != nil {
+				return err
+			}
+			return imp.importNode(ctx, node)
+		}
+	case hasColumns(columns, pollResultColumns):
+		parse = func(row []string) error {
+			result, err := parsePollResultRow(row, columns)
+			if err != nil {
+				return err
+			}
+			return imp.importPollResult(ctx, result)
+		}
+	default:
+		return fmt.Errorf("%w: CSV header matches neither the nodes nor the poll_results table", domain.ErrInvalidExport)
+	}
+
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", domain.ErrInvalidExport, err)
+		}
+		if err := parse(row); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+func hasColumns(columns map[string]int, want []string) bool {
+	for _, name := range want {
+		if _, ok := columns[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func parseNodeRow(row []string, columns map[string]int) (*domain.Node, error) {
+	firstSeen, err1 := time.Parse(time.RFC3339Nano, row[columns["first_seen"]])
+	lastSeen, err2 := time.Parse(time.RFC3339Nano, row[columns["last_seen"]])
+	isActive, err3 := strconv.ParseBool(row[columns["is_active"]])
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidExport, err)
+	}
+
+	// Exports from before node labels have no labels column
+	var labels map[string]string
+	if i, ok := columns["labels"]; ok {
+		parsed, err := domain.ParseLabels(row[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", domain.ErrInvalidExport, err)
+		}
+		labels = parsed
+	}
+
+	return &domain.Node{
+		ID:           row[columns["id"]],
+		FQDN:         row[columns["fqdn"]],
+		IP:           row[columns["ip"]],
+		DiscoveredBy: row[columns["discovered_by"]],
+		FirstSeen:    firstSeen,
+		LastSeen:     lastSeen,
+		IsActive:     isActive,
+		Labels:       labels,
+	}, nil
+}
+
+func parsePollResultRow(row []string, columns map[string]int) (*domain.PollResult, error) {
+	pollTime, err1 := time.Parse(time.RFC3339Nano, row[columns["poll_time"]])
+	success, err2 := strconv.ParseBool(row[columns["success"]])
+	responseMs, err3 := strconv.ParseInt(row[columns["response_ms"]], 10, 64)
+	pathMTU, err4 := strconv.Atoi(row[columns["path_mtu"]])
+	if err := errors.Join(err1, err2, err3, err4); err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidExport, err)
+	}
+
+	return &domain.PollResult{
+		Observer:   row[columns["observer"]],
+		NodeID:     row[columns["node_id"]],
+		PollTime:   pollTime,
+		Success:    success,
+		ResponseMs: responseMs,
+		Error:      row[columns["error"]],
+		PathMTU:    pathMTU,
+	}, nil
+}
+
+// importNode adds an unknown node, or refreshes a known one's address and
+// status when the export saw it more recently
+func (imp *importer) importNode(ctx context.Context, node *domain.Node) error {
+	if node.ID == imp.nodeID || validateNode(node) != nil {
+		imp.stats.NodesSkipped++
+		return nil
+	}
+
+	existing, err := imp.es.nodeRepo.GetNode(ctx, node.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get node %s: %w", node.ID, err)
+	}
+
+	if existing == nil {
+		if err := imp.es.nodeRepo.CreateNode(ctx, node); err != nil {
+			return err
+		}
+		imp.stats.NodesAdded++
+		return nil
+	}
+
+	updated := *existing
+	if node.FirstSeen.Before(updated.FirstSeen) {
+		updated.FirstSeen = node.FirstSeen
+	}
+	if node.LastSeen.After(updated.LastSeen) {
+		updated.FQDN = node.FQDN
+		updated.IP = node.IP
+		updated.LastSeen = node.LastSeen
+		updated.IsActive = node.IsActive
+		if node.Labels != nil {
+			updated.Labels = node.Labels
+		}
+	}
+	if reflect.DeepEqual(updated, *existing) {
+		return nil
+	}
+
+	if err := imp.es.nodeRepo.UpdateNode(ctx, &updated); err != nil {
+		return err
+	}
+	imp.stats.NodesUpdated++
+	return nil
+}
+
+// importPollResult queues a poll result, writing a batch when full. Results
+// this node made itself come back with an empty observer, as stored locally.
+func (imp *importer) importPollResult(ctx context.Context, result *domain.PollResult) error {
+	if result.NodeID == "" || result.Observer == "" || result.PollTime.IsZero() {
+		return fmt.Errorf("%w: poll result needs a node ID, observer and poll time", domain.ErrInvalidExport)
+	}
+
+	imported := *result
+	imported.ID = 0
+	if imported.Observer == imp.nodeID {
+		imported.Observer = ""
+	}
+
+	imp.pending = append(imp.pending, imported)
+	if len(imp.pending) >= importBatch {
+		return imp.flush(ctx)
+	}
+	return nil
+}
+
+func (imp *importer) flush(ctx context.Context) error {
+	if len(imp.pending) == 0 {
+		return nil
+	}
+
+	added, err := imp.es.pollRepo.ImportPollResults(ctx, imp.pending)
+	if err != nil {
+		return fmt.Errorf("failed to import poll results: %w", err)
+	}
+	imp.stats.PollResults += added
+	imp.stats.DuplicatePolls += int64(len(imp.pending)) - added
+	imp.pending = imp.pending[:0]
+	return nil
+}
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"nodeprobe/internal/domain"
+)
+
+// parquetRowGroup is how many rows are buffered before a row group is
+// written, bounding the memory a large export holds
+const parquetRowGroup = 100000
+
+// Parquet rows mirror the CSV columns, with typed values; labels use the
+// same key=value list as CSV
+type parquetNode struct {
+	ID           string    `parquet:"id"`
+	FQDN         string    `parquet:"fqdn"`
+	IP           string    `parquet:"ip"`
+	DiscoveredBy string    `parquet:"discovered_by"`
+	FirstSeen    time.Time `parquet:"first_seen,timestamp(nanosecond)"`
+	LastSeen     time.Time `parquet:"last_seen,timestamp(nanosecond)"`
+	IsActive     bool      `parquet:"is_active"`
+	Labels       string    `parquet:"labels"`
+}
+
+type parquetPollResult struct {
+	Observer   string    `parquet:"observer,dict"`
+	NodeID     string    `parquet:"node_id,dict"`
+	PollTime   time.Time `parquet:"poll_time,timestamp(nanosecond)"`
+	Success    bool      `parquet:"success"`
+	ResponseMs int64     `parquet:"response_ms"`
+	Error      string    `parquet:"error"`
+	PathMTU    int64     `parquet:"path_mtu"`
+}
+
+func (es *ExportService) exportParquet(ctx context.Context, w io.Writer, opts domain.ExportOptions, nodeID string) error {
+	if opts.Table == domain.ExportTableNodes {
+		nodes, err := es.nodeRepo.GetAllNodes(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get nodes: %w", err)
+		}
+
+		rows := make([]parquetNode, len(nodes))
+		for i, node := range nodes {
+			rows[i] = parquetNode{
+				ID:           node.ID,
+				FQDN:         node.FQDN,
+				IP:           node.IP,
+				DiscoveredBy: node.DiscoveredBy,
+				FirstSeen:    node.FirstSeen,
+				LastSeen:     node.LastSeen,
+				IsActive:     node.IsActive,
+				Labels:       domain.FormatLabels(node.Labels),
+			}
+		}
+
+		pw := parquet.NewGenericWriter[parquetNode](w, parquet.Compression(&parquet.Zstd))
+		if _, err := pw.Write(rows); err != nil {
+			return fmt.Errorf("failed to write nodes: %w", err)
+		}
+		if err := pw.Close(); err != nil {
+			return fmt.Errorf("failed to write Parquet: %w", err)
+		}
+		return nil
+	}
+
+	pw := parquet.NewGenericWriter[parquetPollResult](w, parquet.Compression(&parquet.Zstd))
+	buffered := 0
+	err := es.streamPollResults(ctx, opts.Since, opts.Until, nodeID, func(result *domain.PollResult) error {
+		if _, err := pw.Write([]parquetPollResult{{
+			Observer:   result.Observer,
+			NodeID:     result.NodeID,
+			PollTime:   result.PollTime,
+			Success:    result.Success,
+			ResponseMs: result.ResponseMs,
+			Error:      result.Error,
+			PathMTU:    int64(result.PathMTU),
+		}}); err != nil {
+			return err
+		}
+		if buffered++; buffered >= parquetRowGroup {
+			buffered = 0
+			return pw.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := pw.Close(); err != nil {
+		return fmt.Errorf("failed to write Parquet: %w", err)
+	}
+	return nil
+}
+
+func (imp *importer) readParquet(ctx context.Context, r io.Reader) error {
+	input, size, err := parquetInput(r)
+	if err != nil {
+		return fmt.Errorf("failed to read Parquet export: %w", err)
+	}
+	file, err := parquet.OpenFile(input, size)
+	if err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrInvalidExport, err)
+	}
+
+	columns := make(map[string]int)
+	for i, field := range file.Schema().Fields() {
+		columns[field.Name()] = i
+	}
+
+	// The schema tells which table the file holds
+	switch {
+	case hasColumns(columns, nodeColumns):
+		return readParquetRows(file, func(row *parquetNode) error {
+			labels, err := domain.ParseLabels(row.Labels)
+			if err != nil {
+				return fmt.Errorf("%w: %v", domain.ErrInvalidExport, err)
+			}
+			return imp.importNode(ctx, &domain.Node{
+				ID:           row.ID,
+				FQDN:         row.FQDN,
+				IP:           row.IP,
+				DiscoveredBy: row.DiscoveredBy,
+				FirstSeen:    row.FirstSeen,
+				LastSeen:     row.LastSeen,
+				IsActive:     row.IsActive,
+				Labels:       labels,
+			})
+		})
+	case hasColumns(columns, pollResultColumns):
+		return readParquetRows(file, func(row *parquetPollResult) error {
+			return imp.importPollResult(ctx, &domain.PollResult{
+				Observer:   row.Observer,
+				NodeID:     row.NodeID,
+				PollTime:   row.PollTime,
+				Success:    row.Success,
+				ResponseMs: row.ResponseMs,
+				Error:      row.Error,
+				PathMTU:    int(row.PathMTU),
+			})
+		})
+	default:
+		return fmt.Errorf("%w: Parquet schema matches neither the nodes nor the poll_results table", domain.ErrInvalidExport)
+	}
+}
+
+// parquetInput returns r for random access. Parquet metadata sits at the
+// end of the file, so anything but a regular file, such as stdin, is read
+// into memory first.
+func parquetInput(r io.Reader) (io.ReaderAt, int64, error) {
+	if f, ok := r.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			return f, info.Size(), nil
+		}
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(data), int64(len(data)), nil
+}
+
+// readParquetRows passes every row of file to fn, a batch at a time
+func readParquetRows[T any](file *parquet.File, fn func(*T) error) error {
+	reader := parquet.NewGenericReader[T](file)
+	defer reader.Close()
+
+	rows := make([]T, importBatch)
+	for line := 1; ; {
+		n, err := reader.Read(rows)
+		for i := 0; i < n; i, line = i+1, line+1 {
+			if err := fn(&rows[i]); err != nil {
+				return fmt.Errorf("row %d: %w", line, err)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", domain.ErrInvalidExport, err)
+		}
+	}
+}
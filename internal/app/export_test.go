@@ -0,0 +1,390 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+	"nodeprobe/internal/pkg/memory"
+)
+
+// newTestExportService returns an export service for nodeID over an empty in-memory store
+func newTestExportService(nodeID string) (*ExportService, *memory.Store) {
+	store := memory.NewStore()
+	return NewExportService(store, store, newFakeConfig(nodeID)), store
+}
+
+func TestExportImportNDJSON(t *testing.T) {
+	ctx := context.Background()
+	source, sourceStore := newTestExportService("edge-1")
+
+	peer := testNode("peer", "10.0.0.2")
+	if err := sourceStore.CreateNode(ctx, &peer); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+	if err := sourceStore.UpdateNodeFingerprints(ctx, "peer", "pinned", ""); err != nil {
+		t.Fatalf("UpdateNodeFingerprints: %v", err)
+	}
+
+	now := time.Now()
+	results := []domain.PollResult{
+		{NodeID: "peer", PollTime: now.Add(-time.Hour), Success: true, ResponseMs: 10},
+		{NodeID: "peer", PollTime: now.Add(-2 * time.Hour), Success: false, Error: "timeout"},
+		{NodeID: "peer", PollTime: now.Add(-3 * time.Hour), Success: true, ResponseMs: 30, Observer: "edge-2"},
+		{NodeID: "peer", PollTime: now.Add(-48 * time.Hour), Success: true}, // outside the range
+	}
+	if err := sourceStore.CreatePollResults(ctx, results); err != nil {
+		t.Fatalf("CreatePollResults: %v", err)
+	}
+
+	var export bytes.Buffer
+	if err := source.Export(ctx, &export, domain.ExportOptions{Since: now.Add(-24 * time.Hour)}); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(export.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("export has %d records, want header, 1 node and 3 poll results:\n%s", len(lines), export.String())
+	}
+	var header domain.ExportRecord
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil || header.Header == nil || header.Header.NodeID != "edge-1" {
+		t.Errorf("first record = %s, want header from edge-1", lines[0])
+	}
+
+	collector, collectorStore := newTestExportService("collector")
+	data := export.Bytes()
+	stats, err := collector.Import(ctx, bytes.NewReader(data), domain.ExportFormatNDJSON)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if stats.NodesAdded != 1 || stats.PollResults != 3 || stats.DuplicatePolls != 0 {
+		t.Errorf("first import stats = %+v, want 1 node and 3 poll results", stats)
+	}
+
+	stats, err = collector.Import(ctx, bytes.NewReader(data), domain.ExportFormatNDJSON)
+	if err != nil {
+		t.Fatalf("second Import: %v", err)
+	}
+	if stats.NodesAdded != 0 || stats.PollResults != 0 || stats.DuplicatePolls != 3 {
+		t.Errorf("second import stats = %+v, want only duplicates", stats)
+	}
+
+	imported, _ := collectorStore.GetNodePollResultsSince(ctx, "peer", now.Add(-24*time.Hour))
+	observers := map[string]int{}
+	for _, result := range imported {
+		observers[result.Observer]++
+	}
+	if observers["edge-1"] != 2 || observers["edge-2"] != 1 {
+		t.Errorf("imported results by observer = %v, want edge-1: 2, edge-2: 1", observers)
+	}
+
+	node, _ := collectorStore.GetNode(ctx, "peer")
+	if node == nil || node.CertFingerprint != "" {
+		t.Errorf("imported node = %+v, want peer without a pinned fingerprint", node)
+	}
+
+	// Importing an export back into the node that made it adds nothing
+	stats, err = source.Import(ctx, bytes.NewReader(data), domain.ExportFormatNDJSON)
+	if err != nil {
+		t.Fatalf("Import into source: %v", err)
+	}
+	if stats.PollResults != 0 || stats.DuplicatePolls != 3 {
+		t.Errorf("round-trip import stats = %+v, want only duplicates", stats)
+	}
+}
+
+func TestExportImportCSV(t *testing.T) {
+	ctx := context.Background()
+	source, sourceStore := newTestExportService("edge-1")
+
+	peer := testNode("peer", "10.0.0.2")
+	if err := sourceStore.CreateNode(ctx, &peer); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+	now := time.Now()
+	if err := sourceStore.CreatePollResults(ctx, []domain.PollResult{
+		{NodeID: "peer", PollTime: now.Add(-time.Minute), Success: false, Error: "dial tcp: i/o timeout, retrying"},
+		{NodeID: "peer", PollTime: now.Add(-2 * time.Minute), Success: true, ResponseMs: 12, PathMTU: 1500},
+	}); err != nil {
+		t.Fatalf("CreatePollResults: %v", err)
+	}
+
+	collector, collectorStore := newTestExportService("collector")
+	for _, table := range []string{domain.ExportTableNodes, domain.ExportTablePollResults} {
+		var export bytes.Buffer
+		if err := source.Export(ctx, &export, domain.ExportOptions{Format: domain.ExportFormatCSV, Table: table}); err != nil {
+			t.Fatalf("Export %s: %v", table, err)
+		}
+		if _, err := collector.Import(ctx, &export, domain.ExportFormatCSV); err != nil {
+			t.Fatalf("Import %s: %v", table, err)
+		}
+	}
+
+	if node, _ := collectorStore.GetNode(ctx, "peer"); node == nil || node.IP != "10.0.0.2" || !node.FirstSeen.Equal(peer.FirstSeen) {
+		t.Errorf("imported node = %+v, want peer as exported", node)
+	}
+
+	imported, _ := collectorStore.GetNodePollResultsSince(ctx, "peer", now.Add(-time.Hour))
+	if len(imported) != 2 {
+		t.Fatalf("imported %d poll results, want 2", len(imported))
+	}
+	if got := imported[1]; got.Observer != "edge-1" || got.Error != "dial tcp: i/o timeout, retrying" {
+		t.Errorf("imported failure = %+v", got)
+	}
+	if got := imported[0]; !got.Success || got.ResponseMs != 12 || got.PathMTU != 1500 {
+		t.Errorf("imported success = %+v", got)
+	}
+}
+
+func TestExportImportParquet(t *testing.T) {
+	ctx := context.Background()
+	source, sourceStore := newTestExportService("edge-1")
+
+	peer := testNode("peer", "10.0.0.2")
+	peer.Labels = map[string]string{"region": "eu-west"}
+	if err := sourceStore.CreateNode(ctx, &peer); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+	now := time.Now()
+	if err := sourceStore.CreatePollResults(ctx, []domain.PollResult{
+		{NodeID: "peer", PollTime: now.Add(-time.Minute), Success: false, Error: "timeout"},
+		{NodeID: "peer", PollTime: now.Add(-2 * time.Minute), Success: true, ResponseMs: 12, PathMTU: 1500},
+		{NodeID: "peer", PollTime: now.Add(-3 * time.Minute), Success: true, ResponseMs: 40, Observer: "edge-2"},
+	}); err != nil {
+		t.Fatalf("CreatePollResults: %v", err)
+	}
+
+	collector, collectorStore := newTestExportService("collector")
+	for _, table := range []string{domain.ExportTableNodes, domain.ExportTablePollResults} {
+		var export bytes.Buffer
+		if err := source.Export(ctx, &export, domain.ExportOptions{Format: domain.ExportFormatParquet, Table: table}); err != nil {
+			t.Fatalf("Export %s: %v", table, err)
+		}
+		if !bytes.HasPrefix(export.Bytes(), []byte("PAR1")) {
+			t.Fatalf("%s export is not a Parquet file", table)
+		}
+
+		// From a pipe, then from a file, which adds nothing the second time
+		stats, err := collector.Import(ctx, bytes.NewReader(export.Bytes()), domain.ExportFormatParquet)
+		if err != nil {
+			t.Fatalf("Import %s: %v", table, err)
+		}
+		if table == domain.ExportTablePollResults && stats.PollResults != 3 {
+			t.Errorf("poll result import stats = %+v, want 3 poll results", stats)
+		}
+
+		path := filepath.Join(t.TempDir(), table+".parquet")
+		if err := os.WriteFile(path, export.Bytes(), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		stats, err = collector.Import(ctx, f, domain.ExportFormatParquet)
+		f.Close()
+		if err != nil {
+			t.Fatalf("Import %s from file: %v", table, err)
+		}
+		if stats.NodesAdded != 0 || stats.PollResults != 0 {
+			t.Errorf("second %s import stats = %+v, want nothing added", table, stats)
+		}
+	}
+
+	node, _ := collectorStore.GetNode(ctx, "peer")
+	if node == nil || node.IP != "10.0.0.2" || !node.FirstSeen.Equal(peer.FirstSeen) || node.Labels["region"] != "eu-west" {
+		t.Errorf("imported node = %+v, want peer as exported", node)
+	}
+
+	imported, _ := collectorStore.GetNodePollResultsSince(ctx, "peer", now.Add(-time.Hour))
+	if len(imported) != 3 {
+		t.Fatalf("imported %d poll results, want 3", len(imported))
+	}
+	byResponse := map[int64]domain.PollResult{}
+	for _, result := range imported {
+		byResponse[result.ResponseMs] = result
+	}
+	if got := byResponse[0]; got.Observer != "edge-1" || got.Error != "timeout" || !got.PollTime.Equal(now.Add(-time.Minute)) {
+		t.Errorf("imported failure = %+v", got)
+	}
+	if got := byResponse[12]; !got.Success || got.PathMTU != 1500 || got.Observer != "edge-1" {
+		t.Errorf("imported success = %+v", got)
+	}
+	if got := byResponse[40]; got.Observer != "edge-2" {
+		t.Errorf("imported result from edge-2 = %+v", got)
+	}
+
+	if _, err := collector.Import(ctx, strings.NewReader("not parquet"), domain.ExportFormatParquet); !errors.Is(err, domain.ErrInvalidExport) {
+		t.Errorf("Import of garbage error = %v, want ErrInvalidExport", err)
+	}
+}
+
+func TestImportLeavesLocalStatsUnchanged(t *testing.T) {
+	ctx := context.Background()
+	collector, store := newTestExportService("collector")
+	config := newFakeConfig("collector")
+	config.slo.Objectives = []domain.SLObjective{{Name: "available", Type: domain.SLOAvailability, Target: 99, WindowDays: 1}}
+	ss := NewSLOService(store, store, config, nil)
+	if err := ss.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	now := time.Now()
+	bucket := now.Truncate(time.Minute).Add(-10 * time.Minute)
+	if err := store.CreatePollResults(ctx, []domain.PollResult{
+		{NodeID: "peer", PollTime: bucket.Add(time.Second), Success: true, ResponseMs: 10},
+		{NodeID: "peer", PollTime: bucket.Add(2 * time.Second), Success: false},
+	}); err != nil {
+		t.Fatalf("CreatePollResults: %v", err)
+	}
+	before, err := ss.GetSLOStatus(ctx)
+	if err != nil {
+		t.Fatalf("GetSLOStatus: %v", err)
+	}
+
+	// Another observer's polls of the same node in the same minute
+	var export bytes.Buffer
+	enc := json.NewEncoder(&export)
+	for i := 0; i < 3; i++ {
+		result := domain.PollResult{NodeID: "peer", PollTime: bucket.Add(time.Duration(10+i) * time.Second), Success: false, Observer: "edge-1"}
+		enc.Encode(domain.ExportRecord{Type: domain.ExportRecordPollResult, PollResult: &result})
+	}
+	if stats, err := collector.Import(ctx, &export, domain.ExportFormatNDJSON); err != nil || stats.PollResults != 3 {
+		t.Fatalf("Import = %+v, %v, want 3 poll results", stats, err)
+	}
+
+	rs := NewRetentionService(store, config)
+	rs.rawRetention = 24 * time.Hour
+	rs.minuteRetention = 7 * 24 * time.Hour
+	if err := rs.Rollup(ctx, now); err != nil {
+		t.Fatalf("Rollup: %v", err)
+	}
+	rollups, _ := store.GetRollups(ctx, domain.RollupMinute, "peer", bucket, now)
+	if len(rollups) != 1 || rollups[0].Polls != 2 || rollups[0].Successes != 1 {
+		t.Errorf("minute rollups = %+v, want this node's 2 polls", rollups)
+	}
+
+	after, err := ss.GetSLOStatus(ctx)
+	if err != nil {
+		t.Fatalf("GetSLOStatus: %v", err)
+	}
+	if len(before) != 1 || len(after) != 1 || after[0].Polls != before[0].Polls || after[0].Good != before[0].Good {
+		t.Errorf("SLO status after import = %+v, want unchanged from %+v", after, before)
+	}
+}
+
+func TestImportMergesNodes(t *testing.T) {
+	ctx := context.Background()
+	collector, store := newTestExportService("collector")
+
+	now := time.Now()
+	known := testNode("peer", "10.0.0.2")
+	if err := store.CreateNode(ctx, &known); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	older := known
+	older.IP = "10.0.0.99"
+	older.FirstSeen = now.Add(-time.Hour)
+	older.LastSeen = now.Add(-time.Hour)
+
+	newer := known
+	newer.IP = "10.0.0.20"
+	newer.LastSeen = now.Add(time.Minute)
+
+	self := testNode("collector", "10.0.0.254")
+	invalid := testNode("bad id!", "10.0.0.5")
+
+	var export bytes.Buffer
+	enc := json.NewEncoder(&export)
+	for _, node := range []*domain.Node{&older, &self, &invalid} {
+		enc.Encode(domain.ExportRecord{Type: domain.ExportRecordNode, Node: node})
+	}
+
+	stats, err := collector.Import(ctx, &export, domain.ExportFormatNDJSON)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if stats.NodesUpdated != 1 || stats.NodesSkipped != 2 || stats.NodesAdded != 0 {
+		t.Errorf("stats = %+v, want 1 updated, 2 skipped", stats)
+	}
+
+	node, _ := store.GetNode(ctx, "peer")
+	if node.IP != "10.0.0.2" || !node.FirstSeen.Equal(older.FirstSeen) {
+		t.Errorf("after older export node = %+v, want original IP and earlier first seen", node)
+	}
+
+	export.Reset()
+	enc.Encode(domain.ExportRecord{Type: domain.ExportRecordNode, Node: &newer})
+	if _, err := collector.Import(ctx, &export, domain.ExportFormatNDJSON); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if node, _ := store.GetNode(ctx, "peer"); node.IP != "10.0.0.20" {
+		t.Errorf("after newer export IP = %s, want 10.0.0.20", node.IP)
+	}
+}
+
+func TestExportImportErrors(t *testing.T) {
+	ctx := context.Background()
+	es, _ := newTestExportService("self")
+	now := time.Now()
+
+	for name, opts := range map[string]domain.ExportOptions{
+		"unknown format":     {Format: "xml"},
+		"parquet w/o table":  {Format: domain.ExportFormatParquet},
+		"unknown table":      {Table: "rollups"},
+		"csv without table":  {Format: domain.ExportFormatCSV},
+		"empty range":        {Since: now, Until: now},
+		"range ends earlier": {Since: now, Until: now.Add(-time.Hour)},
+		"range too long":     {Since: now.Add(-876000 * time.Hour), Until: now},
+	} {
+		var out bytes.Buffer
+		err := es.Export(ctx, &out, opts)
+		if !errors.Is(err, domain.ErrInvalidExport) && !errors.Is(err, domain.ErrInvalidRange) {
+			t.Errorf("%s: error = %v, want invalid export or range", name, err)
+		}
+		if out.Len() != 0 {
+			t.Errorf("%s: wrote %q before failing", name, out.String())
+		}
+	}
+
+	for name, input := range map[string]string{
+		"newer version":   `{"type":"header","header":{"version":99}}`,
+		"unknown record":  `{"type":"rollup"}`,
+		"no observer":     `{"type":"poll_result","poll_result":{"node_id":"peer","poll_time":"2025-06-01T12:00:00Z"}}`,
+		"malformed":       `{"type":`,
+		"unknown columns": "a,b,c\n1,2,3\n",
+	} {
+		format := domain.ExportFormatNDJSON
+		if strings.Contains(input, ",b,") {
+			format = domain.ExportFormatCSV
+		}
+		if _, err := es.Import(ctx, strings.NewReader(input), format); !errors.Is(err, domain.ErrInvalidExport) {
+			t.Errorf("%s: error = %v, want ErrInvalidExport", name, err)
+		}
+	}
+}
+
+func TestParseExportTime(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	if got, err := ParseExportTime("", now); err != nil || !got.IsZero() {
+		t.Errorf("empty = %v, %v; want zero time", got, err)
+	}
+	if got, err := ParseExportTime("72h", now); err != nil || !got.Equal(now.Add(-72*time.Hour)) {
+		t.Errorf("72h = %v, %v; want three days ago", got, err)
+	}
+	if got, err := ParseExportTime("2025-05-01T00:00:00Z", now); err != nil || !got.Equal(time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("RFC 3339 = %v, %v", got, err)
+	}
+	if _, err := ParseExportTime("yesterday", now); !errors.Is(err, domain.ErrInvalidRange) {
+		t.Errorf("invalid time error = %v, want ErrInvalidRange", err)
+	}
+}
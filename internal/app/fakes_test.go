@@ -0,0 +1,181 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+	"nodeprobe/internal/pkg/memory"
+)
+
+// fakeConfig serves configuration from fields; methods a test doesn't
+// expect panic through the nil embedded interface
+type fakeConfig struct {
+	domain.ConfigService
+
+	nodeID    string
+	labels    map[string]string
+	seeds     *domain.SeedConfig
+	reporting *domain.ReportingConfig
+	limits    domain.LimitsConfig
+	tls       domain.TLSConfig
+	backup    domain.BackupConfig
+	alerts    domain.AlertConfig
+	slo       domain.SLOConfig
+	coords    domain.CoordinateConfig
+}
+
+func newFakeConfig(nodeID string) *fakeConfig {
+	return &fakeConfig{
+		nodeID: nodeID,
+		limits: domain.LimitsConfig{
+			MaxNewNodesPerPeer: domain.DefaultMaxNewNodesPerPeer,
+			NewNodeWindow:      int(domain.DefaultNewNodeWindow.Seconds()),
+		},
+		alerts: domain.AlertConfig{
+			FlapDetection: domain.FlapConfig{
+				LowThreshold:  domain.DefaultFlapLowThreshold,
+				HighThreshold: domain.DefaultFlapHighThreshold,
+			},
+			AnomalyDetection: domain.AnomalyConfig{
+				Sigma:            domain.DefaultAnomalySigma,
+				MinDeviationMs:   domain.DefaultAnomalyMinDeviationMs,
+				Alpha:            domain.DefaultAnomalyAlpha,
+				MinSamples:       domain.DefaultAnomalyMinSamples,
+				ConsecutivePolls: domain.DefaultAnomalyConsecutivePolls,
+				TrainingDays:     domain.DefaultAnomalyTrainingDays,
+			},
+		},
+		coords: domain.CoordinateConfig{StaleAfterMinutes: domain.DefaultCoordinateStaleAfterMinutes},
+	}
+}
+
+func (c *fakeConfig) GetNodeID() (string, error) {
+	return c.nodeID, nil
+}
+
+func (c *fakeConfig) GetNodeInfo() (*domain.NodeInfo, error) {
+	return &domain.NodeInfo{ID: c.nodeID, FQDN: c.nodeID + ".example.com", IP: "10.0.0.254", Labels: c.labels}, nil
+}
+
+func (c *fakeConfig) LoadSeedConfig() (*domain.SeedConfig, error) {
+	return c.seeds, nil
+}
+
+func (c *fakeConfig) LoadReportingConfig() (*domain.ReportingConfig, error) {
+	return c.reporting, nil
+}
+
+func (c *fakeConfig) LoadLimitsConfig() (*domain.LimitsConfig, error) {
+	limits := c.limits
+	return &limits, nil
+}
+
+func (c *fakeConfig) LoadTLSConfig() (*domain.TLSConfig, error) {
+	tls := c.tls
+	return &tls, nil
+}
+
+func (c *fakeConfig) LoadBackupConfig() (*domain.BackupConfig, error) {
+	backup := c.backup
+	return &backup, nil
+}
+
+func (c *fakeConfig) LoadAlertConfig() (*domain.AlertConfig, error) {
+	alerts := c.alerts
+	return &alerts, nil
+}
+
+func (c *fakeConfig) LoadCoordinateConfig() (*domain.CoordinateConfig, error) {
+	coords := c.coords
+	return &coords, nil
+}
+
+func (c *fakeConfig) LoadSLOConfig() (*domain.SLOConfig, error) {
+	slo := c.slo
+	return &slo, nil
+}
+
+// fakeHTTPClient answers node info requests from a map keyed by node URL.
+// Nodes present the identity in identities, if any, to the verifier, and
+// requests records the node URLs that were sent a request.
+type fakeHTTPClient struct {
+	mu         sync.Mutex
+	nodeInfo   map[string]*domain.NodeInfo
+	identities map[string]domain.PeerIdentity
+	requests   []string
+	mtu        int
+	mtuTests   []string
+	snapshots  map[string][]*domain.NetworkSnapshot
+}
+
+func newFakeHTTPClient() *fakeHTTPClient {
+	return &fakeHTTPClient{
+		nodeInfo:   make(map[string]*domain.NodeInfo),
+		identities: make(map[string]domain.PeerIdentity),
+		mtu:        1500,
+		snapshots:  make(map[string][]*domain.NetworkSnapshot),
+	}
+}
+
+func (c *fakeHTTPClient) GetNodeInfo(ctx context.Context, nodeURL string, verify domain.PeerVerifier) (*domain.NodeInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info, ok := c.nodeInfo[nodeURL]
+	if !ok {
+		return nil, errors.New("connection refused")
+	}
+	if verify != nil {
+		if err := verify(c.identities[nodeURL]); err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+	}
+	c.requests = append(c.requests, nodeURL)
+	copied := *info
+	return &copied, nil
+}
+
+func (c *fakeHTTPClient) SendNetworkSnapshot(ctx context.Context, reportingURL string, snapshot *domain.NetworkSnapshot) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.snapshots[reportingURL] = append(c.snapshots[reportingURL], snapshot)
+	return nil
+}
+
+func (c *fakeHTTPClient) TestPathMTU(ctx context.Context, nodeURL string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.mtuTests = append(c.mtuTests, nodeURL)
+	return c.mtu, nil
+}
+
+// newTestNodeService returns an initialized node service over an empty in-memory store
+func newTestNodeService(t *testing.T, config *fakeConfig) (*NodeService, *memory.Store) {
+	t.Helper()
+
+	store := memory.NewStore()
+	ns := NewNodeService(store, config)
+	if err := ns.Initialize(context.Background()); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return ns, store
+}
+
+func testNode(id string, ip string) domain.Node {
+	now := time.Now()
+	return domain.Node{
+		ID:        id,
+		FQDN:      id + ".example.com",
+		IP:        ip,
+		FirstSeen: now,
+		LastSeen:  now,
+		IsActive:  true,
+	}
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// GetFlapStates returns the flap scores of nodes, kept by the node service
+// from status updates, and of links, replayed from the poll results of the
+// last domain.FlapWindow including those imported from other observers
+func (rs *ReportingService) GetFlapStates(ctx context.Context) (*domain.FlapReport, error) {
+	nodes, err := rs.nodeService.GetFlapStates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node flap states: %w", err)
+	}
+
+	config, err := rs.configSvc.LoadAlertConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alert config: %w", err)
+	}
+	localID, err := rs.configSvc.GetNodeID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node ID: %w", err)
+	}
+
+	results, err := rs.pollRepo.GetRecentPollResults(ctx, time.Now().Add(-domain.FlapWindow))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent poll results: %w", err)
+	}
+
+	return &domain.FlapReport{
+		Nodes: nodes,
+		Links: linkFlapStates(localID, results, config.FlapDetection),
+	}, nil
+}
+
+// linkFlapStates scores each observer and polled node pair by replaying its
+// poll results in time order, so hysteresis applies as if tracked live
+func linkFlapStates(localID string, results []domain.PollResult, config domain.FlapConfig) []domain.FlapState {
+	type link struct {
+		observer string
+		nodeID   string
+	}
+
+	polls := make(map[link][]domain.PollResult)
+	for _, result := range results {
+		observer := result.Observer
+		if observer == "" {
+			observer = localID
+		}
+		key := link{observer, result.NodeID}
+		polls[key] = append(polls[key], result)
+	}
+
+	states := make([]domain.FlapState, 0, len(polls))
+	for key, linkPolls := range polls {
+		sort.SliceStable(linkPolls, func(i, j int) bool { return linkPolls[i].PollTime.Before(linkPolls[j].PollTime) })
+
+		var detector domain.FlapDetector
+		for _, poll := range linkPolls {
+			detector.Record(poll.Success, poll.PollTime, config)
+		}
+		states = append(states, detector.State(key.nodeID, key.observer))
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Observer != states[j].Observer {
+			return states[i].Observer < states[j].Observer
+		}
+		return states[i].NodeID < states[j].NodeID
+	})
+	return states
+}
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"sort"
+
+	"nodeprobe/internal/domain"
+)
+
+// filterNodes returns the nodes carrying every one of labels
+func filterNodes(nodes []domain.Node, labels map[string]string) []domain.Node {
+	if len(labels) == 0 {
+		return nodes
+	}
+
+	filtered := make([]domain.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if domain.LabelsMatch(labels, node.Labels) {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
+
+// scopeReport drops the poll results, alerts, anomalies and flap scores of
+// nodes no longer in the report's node list
+func scopeReport(report *domain.Report) {
+	inScope := make(map[string]bool, len(report.Nodes))
+	for _, node := range report.Nodes {
+		inScope[node.ID] = true
+	}
+
+	report.PollResults = keep(report.PollResults, func(result domain.PollResult) bool { return inScope[result.NodeID] })
+	report.Alerts = keep(report.Alerts, func(alert domain.Alert) bool { return inScope[alert.NodeID] })
+	report.Anomalies = keep(report.Anomalies, func(event domain.AnomalyEvent) bool { return inScope[event.NodeID] })
+	if report.Flapping != nil {
+		report.Flapping.Nodes = keep(report.Flapping.Nodes, func(state domain.FlapState) bool { return inScope[state.NodeID] })
+		report.Flapping.Links = keep(report.Flapping.Links, func(state domain.FlapState) bool { return inScope[state.NodeID] })
+	}
+}
+
+// keep returns the items accepted by fn, in order
+func keep[T any](items []T, fn func(T) bool) []T {
+	kept := make([]T, 0, len(items))
+	for _, item := range items {
+		if fn(item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// groupNodes groups nodes by the value of label, sorted by value with nodes
+// lacking the label last
+func groupNodes(nodes []domain.Node, label string) []domain.NodeGroup {
+	byValue := make(map[string]*domain.NodeGroup)
+	for _, node := range nodes {
+		value := node.Labels[label]
+		group, ok := byValue[value]
+		if !ok {
+			group = &domain.NodeGroup{Value: value}
+			byValue[value] = group
+		}
+		group.Nodes = append(group.Nodes, node)
+		if node.IsActive {
+			group.ActiveNodes++
+		}
+	}
+
+	groups := make([]domain.NodeGroup, 0, len(byValue))
+	for _, group := range byValue {
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if (groups[i].Value == "") != (groups[j].Value == "") {
+			return groups[j].Value == ""
+		}
+		return groups[i].Value < groups[j].Value
+	})
+	return groups
+}
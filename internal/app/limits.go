@@ -0,0 +1,93 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// configureLimits loads limits.json into the web server's rate limiter,
+// body size limit and peer allowlist
+func (ws *WebServer) configureLimits() error {
+	limits, err := ws.configSvc.LoadLimitsConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load limits config: %w", err)
+	}
+
+	ws.maxBodyBytes = limits.MaxBodyBytes
+
+	if limits.RateLimit > 0 {
+		ws.limiter = newRateLimiter(limits.RateLimit, limits.RateBurst)
+	}
+
+	for _, cidr := range limits.PeerCIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid peer CIDR %q: %w", cidr, err)
+		}
+		ws.peerNets = append(ws.peerNets, network)
+	}
+	if len(ws.peerNets) > 0 {
+		log.Printf("Inter-node endpoints restricted to %v", limits.PeerCIDRs)
+	}
+
+	return nil
+}
+
+// limitRequests applies the per-source-IP rate limit and caps request bodies
+func (ws *WebServer) limitRequests(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ws.limiter != nil && !ws.limiter.allow(sourceIP(r), time.Now()) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		if ws.maxBodyBytes > 0 && r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, ws.maxBodyBytes)
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// peerRoute guards an inter-node endpoint: the source must be in the peer
+// allowlist, authenticated as a peer and, with mutual TLS, present a node
+// certificate
+func (ws *WebServer) peerRoute(next http.HandlerFunc) http.HandlerFunc {
+	return ws.allowPeerSource(ws.requireRole(domain.RolePeer, ws.requirePeer(next)))
+}
+
+// allowPeerSource rejects requests from outside the configured peer CIDRs
+func (ws *WebServer) allowPeerSource(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(ws.peerNets) == 0 {
+			next(w, r)
+			return
+		}
+
+		ip := net.ParseIP(sourceIP(r))
+		for _, network := range ws.peerNets {
+			if ip != nil && network.Contains(ip) {
+				next(w, r)
+				return
+			}
+		}
+
+		log.Printf("Rejected %s %s from %s: source not in peer allowlist", r.Method, r.URL.Path, r.RemoteAddr)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	}
+}
+
+// sourceIP returns the IP address of the connecting client
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
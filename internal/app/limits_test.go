@@ -0,0 +1,92 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowPeerSource(t *testing.T) {
+	tests := []struct {
+		name       string
+		cidrs      []string
+		remoteAddr string
+		want       int
+	}{
+		{"no allowlist", nil, "203.0.113.9:4000", http.StatusOK},
+		{"inside IPv4 range", []string{"10.0.0.0/8"}, "10.1.2.3:4000", http.StatusOK},
+		{"outside IPv4 range", []string{"10.0.0.0/8"}, "192.168.1.1:4000", http.StatusForbidden},
+		{"second range", []string{"10.0.0.0/8", "192.168.1.0/24"}, "192.168.1.7:4000", http.StatusOK},
+		{"single host", []string{"172.16.0.5/32"}, "172.16.0.6:4000", http.StatusForbidden},
+		{"inside IPv6 range", []string{"2001:db8::/32"}, "[2001:db8::7]:4000", http.StatusOK},
+		{"IPv6 outside IPv4 range", []string{"10.0.0.0/8"}, "[2001:db8::7]:4000", http.StatusForbidden},
+		{"unparsable source", []string{"10.0.0.0/8"}, "pipe", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configSvc := newFakeConfig("self")
+			configSvc.limits.PeerCIDRs = tt.cidrs
+			ws := &WebServer{configSvc: configSvc}
+			if err := ws.configureLimits(); err != nil {
+				t.Fatalf("configureLimits: %v", err)
+			}
+
+			handler := ws.allowPeerSource(func(w http.ResponseWriter, r *http.Request) {})
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/report", nil)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigureLimitsRejectsMalformedCIDR(t *testing.T) {
+	configSvc := newFakeConfig("self")
+	configSvc.limits.PeerCIDRs = []string{"10.0.0.0/33"}
+	ws := &WebServer{configSvc: configSvc}
+	if err := ws.configureLimits(); err == nil {
+		t.Error("configureLimits accepted a malformed CIDR")
+	}
+}
+
+func TestLimitRequests(t *testing.T) {
+	for _, rate := range []float64{0, -1} {
+		configSvc := newFakeConfig("self")
+		configSvc.limits.RateLimit = rate
+		ws := &WebServer{configSvc: configSvc}
+		if err := ws.configureLimits(); err != nil {
+			t.Fatalf("configureLimits: %v", err)
+		}
+		if ws.limiter != nil {
+			t.Errorf("rate %v: rate limiting is enabled, want it disabled", rate)
+		}
+	}
+
+	configSvc := newFakeConfig("self")
+	configSvc.limits.RateLimit = 1
+	configSvc.limits.RateBurst = 2
+	ws := &WebServer{configSvc: configSvc}
+	if err := ws.configureLimits(); err != nil {
+		t.Fatalf("configureLimits: %v", err)
+	}
+	handler := ws.limitRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	codes := make([]int, 3)
+	for i := range codes {
+		req := httptest.NewRequest(http.MethodGet, "/static/app.js", nil)
+		req.RemoteAddr = "10.0.0.1:4000"
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		codes[i] = rec.Code
+		if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Error("429 response has no Retry-After header")
+		}
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("status codes = %v, want two 200s then 429", codes)
+	}
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// GetLocalityMatrix summarizes the poll results since the given time,
+// including those imported from other observers, between the values of
+// label carried by the polling and polled nodes
+func (rs *ReportingService) GetLocalityMatrix(ctx context.Context, label string, since time.Time) (*domain.LocalityMatrix, error) {
+	nodes, err := rs.nodeService.GetKnownNodes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get known nodes: %w", err)
+	}
+	nodeInfo, err := rs.configSvc.GetNodeInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node info: %w", err)
+	}
+
+	results, err := rs.pollRepo.GetRecentPollResults(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent poll results: %w", err)
+	}
+
+	return localityMatrix(label, results, nodeLabelsByID(nodeInfo, nodes), nodeInfo.ID, since, time.Now()), nil
+}
+
+// nodeLabelsByID returns the labels of this node and the known nodes
+func nodeLabelsByID(nodeInfo *domain.NodeInfo, nodes []domain.Node) map[string]map[string]string {
+	labels := make(map[string]map[string]string, len(nodes)+1)
+	for _, node := range nodes {
+		labels[node.ID] = node.Labels
+	}
+	labels[nodeInfo.ID] = nodeInfo.Labels
+	return labels
+}
+
+// localityMatrix summarizes results by the label values of their observer,
+// localID for this node's own polls, and of the polled node. Polls where
+// either end lacks the label are only counted.
+func localityMatrix(label string, results []domain.PollResult, nodeLabels map[string]map[string]string, localID string, since, until time.Time) *domain.LocalityMatrix {
+	type path struct {
+		observer string
+		nodeID   string
+	}
+
+	matrix := &domain.LocalityMatrix{
+		Label:      label,
+		Since:      since,
+		Until:      until,
+		Localities: []string{},
+		Links:      []domain.LocalityLink{},
+	}
+
+	links := make(map[[2]string]*domain.LocalityLink)
+	paths := make(map[path]bool)
+	localities := make(map[string]bool)
+	for _, result := range results {
+		observer := result.Observer
+		if observer == "" {
+			observer = localID
+		}
+		source, target := nodeLabels[observer][label], nodeLabels[result.NodeID][label]
+		if source == "" || target == "" {
+			matrix.UnlabelledPolls++
+			continue
+		}
+
+		key := [2]string{source, target}
+		link, ok := links[key]
+		if !ok {
+			link = &domain.LocalityLink{Source: source, Target: target}
+			links[key] = link
+			localities[source] = true
+			localities[target] = true
+		}
+		link.Add(result)
+		if p := (path{observer, result.NodeID}); !paths[p] {
+			paths[p] = true
+			link.Paths++
+		}
+	}
+
+	for locality := range localities {
+		matrix.Localities = append(matrix.Localities, locality)
+	}
+	sort.Strings(matrix.Localities)
+
+	for _, link := range links {
+		link.Summarize()
+		matrix.Links = append(matrix.Links, *link)
+	}
+	sort.Slice(matrix.Links, func(i, j int) bool {
+		if matrix.Links[i].Source != matrix.Links[j].Source {
+			return matrix.Links[i].Source < matrix.Links[j].Source
+		}
+		return matrix.Links[i].Target < matrix.Links[j].Target
+	})
+	return matrix
+}
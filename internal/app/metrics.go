@@ -0,0 +1,67 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// handleMetrics serves node metrics in the Prometheus text exposition format
+func (ws *WebServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	nodes, err := ws.nodeService.GetKnownNodes(r.Context())
+	if err != nil {
+		log.Printf("Failed to get nodes for metrics: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	active := 0
+	for _, node := range nodes {
+		if node.IsActive {
+			active++
+		}
+	}
+
+	var b strings.Builder
+	writeMetric(&b, "nodeprobe_known_nodes", "gauge", "Nodes in the registry.", float64(len(nodes)))
+	writeMetric(&b, "nodeprobe_active_nodes", "gauge", "Nodes currently marked active.", float64(active))
+
+	if expiry, err := ws.tlsService.CertificateExpiry(); err == nil {
+		writeMetric(&b, "nodeprobe_tls_certificate_expiry_timestamp_seconds", "gauge",
+			"Expiry of the served TLS certificate as a Unix timestamp.", float64(expiry.Unix()))
+	}
+
+	stats := ws.signingService.Stats()
+	b.WriteString("# HELP nodeprobe_snapshot_signatures_total Received network snapshots by signature verification result.\n")
+	b.WriteString("# TYPE nodeprobe_snapshot_signatures_total counter\n")
+	for _, result := range []struct {
+		name  string
+		value uint64
+	}{
+		{"accepted", stats.Accepted},
+		{"unsigned", stats.Unsigned},
+		{"unknown_signer", stats.UnknownSigner},
+		{"invalid_signature", stats.InvalidSignature},
+		{"stale", stats.Stale},
+		{"replayed", stats.Replayed},
+	} {
+		fmt.Fprintf(&b, "nodeprobe_snapshot_signatures_total{result=%q} %d\n", result.name, result.value)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := w.Write([]byte(b.String())); err != nil {
+		log.Printf("Failed to write metrics response: %v", err)
+	}
+}
+
+// writeMetric writes a single unlabelled sample with its HELP and TYPE lines
+func writeMetric(b *strings.Builder, name, kind, help string, value float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, strconv.FormatFloat(value, 'f', -1, 64))
+}
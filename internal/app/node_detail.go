@@ -0,0 +1,245 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"sort"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// GenerateNodeDetail aggregates a node's poll history over the named range
+// into fixed-size latency buckets, an error breakdown and MTU changes
+func (rs *ReportingService) GenerateNodeDetail(ctx context.Context, nodeID string, rangeName string) (*domain.NodeDetail, error) {
+	timeRange, err := lookupRange(rangeName)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := rs.nodeService.GetNodeByID(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	until := time.Now()
+	since := until.Add(-timeRange.Duration).Truncate(timeRange.Bucket)
+
+	results, err := rs.pollRepo.GetNodePollResultsSince(ctx, nodeID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get poll results: %w", err)
+	}
+	results = ownPollResults(results)
+
+	detail := &domain.NodeDetail{
+		Node:       *node,
+		Range:      timeRange.Name,
+		BucketSize: timeRange.Bucket.String(),
+		Since:      since,
+		Until:      until,
+		Buckets:    aggregateBuckets(results, since, until, timeRange.Bucket),
+		Errors:     countErrors(results),
+		MTUHistory: mtuChanges(results),
+	}
+
+	for _, result := range results {
+		detail.TotalPolls++
+		if result.Success {
+			detail.Successes++
+		}
+	}
+	if detail.TotalPolls > 0 {
+		detail.SuccessRate = float64(detail.Successes) / float64(detail.TotalPolls) * 100
+	}
+
+	return detail, nil
+}
+
+// GetNodeRollups returns a node's rollups at the given resolution covering
+// the window ending now, with averages and percentiles filled in
+func (rs *ReportingService) GetNodeRollups(ctx context.Context, nodeID string, resolution string, window time.Duration) ([]domain.PollRollup, error) {
+	size, ok := domain.RollupResolutions[resolution]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown rollup resolution %q", domain.ErrInvalidRange, resolution)
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("%w: window must be positive", domain.ErrInvalidRange)
+	}
+
+	if _, err := rs.nodeService.GetNodeByID(ctx, nodeID); err != nil {
+		return nil, err
+	}
+
+	until := time.Now()
+	rollups, err := rs.pollRepo.GetRollups(ctx, resolution, nodeID, until.Add(-window).Truncate(size), until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rollups: %w", err)
+	}
+
+	for i := range rollups {
+		rollups[i].Summarize()
+	}
+
+	return rollups, nil
+}
+
+// GenerateNodeDetailHTML renders the node detail page with inline SVG charts
+func (rs *ReportingService) GenerateNodeDetailHTML(ctx context.Context, nodeID string, rangeName string) (string, error) {
+	if rs.renderer == nil {
+		return "", domain.ErrHTMLDisabled
+	}
+
+	detail, err := rs.GenerateNodeDetail(ctx, nodeID, rangeName)
+	if err != nil {
+		return "", err
+	}
+
+	pageData := struct {
+		*domain.NodeDetail
+		Ranges       []domain.TimeRange
+		GeneratedAt  time.Time
+		LatencyChart template.HTML
+		SuccessChart template.HTML
+	}{
+		NodeDetail:   detail,
+		Ranges:       domain.DetailRanges,
+		GeneratedAt:  time.Now(),
+		LatencyChart: renderLatencyChart(detail.Buckets),
+		SuccessChart: renderSuccessChart(detail.Buckets),
+	}
+
+	html, err := rs.renderer.Render("node.html", pageData)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate HTML from template: %w", err)
+	}
+
+	return html, nil
+}
+
+// lookupRange resolves a range name, defaulting to 24h when empty
+func lookupRange(name string) (domain.TimeRange, error) {
+	if name == "" {
+		name = "24h"
+	}
+	for _, r := range domain.DetailRanges {
+		if r.Name == name {
+			return r, nil
+		}
+	}
+	return domain.TimeRange{}, fmt.Errorf("%w: %s", domain.ErrInvalidRange, name)
+}
+
+// aggregateBuckets groups results into consecutive buckets covering [since, until)
+func aggregateBuckets(results []domain.PollResult, since, until time.Time, size time.Duration) []domain.LatencyBucket {
+	count := int(until.Sub(since)/size) + 1
+	buckets := make([]domain.LatencyBucket, count)
+	latencies := make([][]int64, count)
+
+	for i := range buckets {
+		buckets[i].Start = since.Add(time.Duration(i) * size)
+	}
+
+	for _, result := range results {
+		i := int(result.PollTime.Sub(since) / size)
+		if i < 0 || i >= count {
+			continue
+		}
+		buckets[i].Polls++
+		if result.Success {
+			buckets[i].Successes++
+			latencies[i] = append(latencies[i], result.ResponseMs)
+		}
+	}
+
+	for i := range buckets {
+		b := &buckets[i]
+		if b.Polls > 0 {
+			b.SuccessRate = float64(b.Successes) / float64(b.Polls) * 100
+		}
+
+		values := latencies[i]
+		if len(values) == 0 {
+			continue
+		}
+		sort.Slice(values, func(a, b int) bool { return values[a] < values[b] })
+		b.MinMs = float64(values[0])
+		b.P5Ms = percentile(values, 5)
+		b.P25Ms = percentile(values, 25)
+		b.P50Ms = percentile(values, 50)
+		b.P75Ms = percentile(values, 75)
+		b.P95Ms = percentile(values, 95)
+		b.MaxMs = float64(values[len(values)-1])
+	}
+
+	return buckets
+}
+
+// percentile returns the p-th percentile of sorted values using linear interpolation
+func percentile(sorted []int64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	if len(sorted) == 1 {
+		return float64(sorted[0])
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(rank)
+	if lower >= len(sorted)-1 {
+		return float64(sorted[len(sorted)-1])
+	}
+	frac := rank - float64(lower)
+	return float64(sorted[lower]) + frac*float64(sorted[lower+1]-sorted[lower])
+}
+
+// countErrors groups failed polls by error message, most frequent first
+func countErrors(results []domain.PollResult) []domain.ErrorCount {
+	byMessage := make(map[string]*domain.ErrorCount)
+	for _, result := range results {
+		if result.Success {
+			continue
+		}
+		message := result.Error
+		if message == "" {
+			message = "unknown error"
+		}
+
+		ec, ok := byMessage[message]
+		if !ok {
+			ec = &domain.ErrorCount{Error: message}
+			byMessage[message] = ec
+		}
+		ec.Count++
+		if result.PollTime.After(ec.LastSeen) {
+			ec.LastSeen = result.PollTime
+		}
+	}
+
+	counts := make([]domain.ErrorCount, 0, len(byMessage))
+	for _, ec := range byMessage {
+		counts = append(counts, *ec)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Error < counts[j].Error
+	})
+
+	return counts
+}
+
+// mtuChanges returns each path MTU measurement that differs from the one before it
+func mtuChanges(results []domain.PollResult) []domain.MTUChange {
+	changes := make([]domain.MTUChange, 0)
+	last := 0
+	for _, result := range results {
+		if result.PathMTU == 0 || result.PathMTU == last {
+			continue
+		}
+		changes = append(changes, domain.MTUChange{Time: result.PollTime, PathMTU: result.PathMTU})
+		last = result.PathMTU
+	}
+	return changes
+}
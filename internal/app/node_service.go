@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,21 +13,49 @@ import (
 )
 
 type NodeService struct {
-	nodeRepo   domain.NodeRepository
-	configSvc  domain.ConfigService
-	mu         sync.RWMutex
-	knownNodes map[string]*domain.Node
+	nodeRepo      domain.NodeRepository
+	configSvc     domain.ConfigService
+	mu            sync.RWMutex
+	knownNodes    map[string]*domain.Node
+	nodeLocks     map[string]*sync.Mutex         // serialize writes to each node, see lockNode
+	introductions map[string]*introductionWindow // new nodes introduced per peer
+	maxNewNodes   int                            // per peer and window, 0 for no limit
+	newNodeWindow time.Duration
+	flaps         map[string]*domain.FlapDetector // status history per node
+	flapConfig    domain.FlapConfig
+}
+
+// introductionWindow counts the new nodes a peer introduced since start
+type introductionWindow struct {
+	start time.Time
+	count int
 }
 
 func NewNodeService(nodeRepo domain.NodeRepository, configSvc domain.ConfigService) *NodeService {
 	return &NodeService{
-		nodeRepo:   nodeRepo,
-		configSvc:  configSvc,
-		knownNodes: make(map[string]*domain.Node),
+		nodeRepo:      nodeRepo,
+		configSvc:     configSvc,
+		knownNodes:    make(map[string]*domain.Node),
+		nodeLocks:     make(map[string]*sync.Mutex),
+		introductions: make(map[string]*introductionWindow),
+		flaps:         make(map[string]*domain.FlapDetector),
 	}
 }
 
 func (ns *NodeService) Initialize(ctx context.Context) error {
+	limits, err := ns.configSvc.LoadLimitsConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load limits config: %w", err)
+	}
+	ns.maxNewNodes = limits.MaxNewNodesPerPeer
+	ns.newNodeWindow = time.Duration(limits.NewNodeWindow) * time.Second
+
+	alerts, err := ns.configSvc.LoadAlertConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load alert config: %w", err)
+	}
+	ns.flapConfig = alerts.FlapDetection
+
 	// Load existing nodes from database
 	nodes, err := ns.nodeRepo.GetAllNodes(ctx)
 	if err != nil {
@@ -50,20 +80,23 @@ func (ns *NodeService) DiscoverNodes(ctx context.Context) error {
 	// This method is called periodically to refresh node information
 	// For now, it just updates the last seen timestamp for active nodes
 
-	ns.mu.RLock()
-	var activeNodes []domain.Node
-	for _, node := range ns.knownNodes {
-		if node.IsActive {
-			activeNodes = append(activeNodes, *node)
-		}
+	activeNodes, err := ns.GetActiveNodes(ctx)
+	if err != nil {
+		return err
 	}
-	ns.mu.RUnlock()
 
 	// Update last seen for active nodes in database
 	now := time.Now()
 	for _, node := range activeNodes {
-		node.LastSeen = now
-		if err := ns.nodeRepo.UpdateNode(ctx, &node); err != nil {
+		err := ns.updateNode(ctx, node.ID, func(node *domain.Node) bool {
+			// The node may have gone inactive since it was listed
+			if !node.IsActive {
+				return false
+			}
+			node.LastSeen = now
+			return true
+		})
+		if err != nil {
 			log.Printf("Failed to update node %s last seen: %v", node.ID, err)
 		}
 	}
@@ -83,34 +116,54 @@ func (ns *NodeService) MergeNodeInfo(ctx context.Context, nodeInfo *domain.NodeI
 
 	now := time.Now()
 
+	// Reject the whole update if the source itself is malformed
+	source := &domain.Node{
+		ID:           nodeInfo.ID,
+		FQDN:         nodeInfo.FQDN,
+		IP:           nodeInfo.IP,
+		DiscoveredBy: discoveredBy,
+		FirstSeen:    now,
+		LastSeen:     now,
+		IsActive:     true,
+		Labels:       nodeInfo.Labels, // a node's own labels replace any heard from peers
+	}
+	if err := validateNode(source); err != nil {
+		return fmt.Errorf("rejected node info from %s: %w", discoveredBy, err)
+	}
+
 	// Add the source node itself if it's not already known
 	if nodeInfo.ID != myNodeID {
-		if err := ns.addOrUpdateNode(ctx, &domain.Node{
-			ID:           nodeInfo.ID,
-			FQDN:         nodeInfo.FQDN,
-			IP:           nodeInfo.IP,
-			DiscoveredBy: discoveredBy,
-			FirstSeen:    now,
-			LastSeen:     now,
-			IsActive:     true,
-		}); err != nil {
+		if err := ns.mergeSourceNode(ctx, source); err != nil {
 			log.Printf("Failed to add/update source node %s: %v", nodeInfo.ID, err)
 		}
 	}
 
 	// Process all nodes in the nodeInfo
+	rejected, limited := 0, 0
 	for _, node := range nodeInfo.Nodes {
 		// Skip our own node
 		if node.ID == myNodeID {
 			continue
 		}
 
+		if err := validateNode(&node); err != nil {
+			log.Printf("Ignoring node reported by %s: %v", nodeInfo.ID, err)
+			rejected++
+			continue
+		}
+
 		// Check if we already know about this node
 		ns.mu.RLock()
-		existingNode, exists := ns.knownNodes[node.ID]
+		_, exists := ns.knownNodes[node.ID]
 		ns.mu.RUnlock()
 
 		if !exists {
+			// A single peer may only introduce a limited number of nodes per window
+			if !ns.allowIntroduction(nodeInfo.ID, now) {
+				limited++
+				continue
+			}
+
 			// This is a new node, add it
 			newNode := &domain.Node{
 				ID:           node.ID,
@@ -120,6 +173,7 @@ func (ns *NodeService) MergeNodeInfo(ctx context.Context, nodeInfo *domain.NodeI
 				FirstSeen:    now,
 				LastSeen:     now,
 				IsActive:     true,
+				Labels:       node.Labels,
 			}
 
 			if err := ns.addOrUpdateNode(ctx, newNode); err != nil {
@@ -129,34 +183,58 @@ func (ns *NodeService) MergeNodeInfo(ctx context.Context, nodeInfo *domain.NodeI
 
 			log.Printf("Discovered new node %s (%s) via %s", node.ID, node.FQDN, nodeInfo.ID)
 		} else {
-			// Update existing node information if needed
-			updated := false
-
-			if existingNode.FQDN != node.FQDN {
-				existingNode.FQDN = node.FQDN
-				updated = true
-			}
-
-			if existingNode.IP != node.IP {
-				existingNode.IP = node.IP
-				updated = true
-			}
-
-			// Always update last seen
-			existingNode.LastSeen = now
-			updated = true
-
-			if updated {
-				if err := ns.addOrUpdateNode(ctx, existingNode); err != nil {
-					log.Printf("Failed to update existing node %s: %v", node.ID, err)
+			err := ns.updateNode(ctx, node.ID, func(updatedNode *domain.Node) bool {
+				updatedNode.FQDN = node.FQDN
+				updatedNode.IP = node.IP
+				updatedNode.LastSeen = now
+
+				// Peers' copies of labels may be stale; they only stand in
+				// until the node is polled and reports its own
+				if updatedNode.Labels == nil {
+					updatedNode.Labels = node.Labels
 				}
+				return true
+			})
+			if err != nil {
+				log.Printf("Failed to update existing node %s: %v", node.ID, err)
 			}
 		}
 	}
 
+	if limited > 0 {
+		log.Printf("Node %s exceeded the limit of %d new nodes per %s; ignored %d new node(s)",
+			nodeInfo.ID, ns.maxNewNodes, ns.newNodeWindow, limited)
+	}
+	if rejected > 0 {
+		log.Printf("Ignored %d invalid node(s) reported by %s", rejected, nodeInfo.ID)
+	}
+
 	return nil
 }
 
+// allowIntroduction counts a new node introduced by peerID and reports
+// whether the peer is still within its limit for the current window
+func (ns *NodeService) allowIntroduction(peerID string, now time.Time) bool {
+	if ns.maxNewNodes <= 0 {
+		return true
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	window, ok := ns.introductions[peerID]
+	if !ok || now.Sub(window.start) >= ns.newNodeWindow {
+		window = &introductionWindow{start: now}
+		ns.introductions[peerID] = window
+	}
+
+	if window.count >= ns.maxNewNodes {
+		return false
+	}
+	window.count++
+	return true
+}
+
 func (ns *NodeService) GetKnownNodes(ctx context.Context) ([]domain.Node, error) {
 	ns.mu.RLock()
 	defer ns.mu.RUnlock()
@@ -170,52 +248,248 @@ func (ns *NodeService) GetKnownNodes(ctx context.Context) ([]domain.Node, error)
 }
 
 func (ns *NodeService) UpdateNodeStatus(ctx context.Context, nodeID string, isActive bool) error {
-	ns.mu.Lock()
-	defer ns.mu.Unlock()
+	unlock := ns.lockNode(nodeID)
+	defer unlock()
 
+	ns.mu.Lock()
 	node, exists := ns.knownNodes[nodeID]
 	if !exists {
+		ns.mu.Unlock()
 		return fmt.Errorf("node %s not found", nodeID)
 	}
 
 	node.IsActive = isActive
+	ns.recordStatus(nodeID, isActive, time.Now())
+	nodeCopy := *node
+	ns.mu.Unlock()
 
-	if err := ns.nodeRepo.UpdateNode(ctx, node); err != nil {
+	if err := ns.nodeRepo.UpdateNode(ctx, &nodeCopy); err != nil {
 		return fmt.Errorf("failed to update node status in database: %w", err)
 	}
 
 	return nil
 }
 
-func (ns *NodeService) addOrUpdateNode(ctx context.Context, node *domain.Node) error {
+// VerifyPeerFingerprint implements trust-on-first-use pinning: the first
+// key fingerprint seen for a node is pinned, and a different one is refused
+// and held as pending until an operator approves it with ApproveFingerprint.
+// A pin of the whole certificate, as stored by earlier versions, is moved to
+// the key fingerprint when the certificate still matches.
+func (ns *NodeService) VerifyPeerFingerprint(ctx context.Context, nodeID string, identity domain.PeerIdentity) error {
+	fingerprint := identity.Fingerprint
+	if fingerprint == "" {
+		return fmt.Errorf("no certificate fingerprint presented by node %s", nodeID)
+	}
+
+	unlock := ns.lockNode(nodeID)
+	defer unlock()
+
 	ns.mu.Lock()
 	defer ns.mu.Unlock()
 
-	// Check if node exists in database
-	existingNode, err := ns.nodeRepo.GetNode(ctx, node.ID)
-	if err != nil {
-		return fmt.Errorf("failed to check existing node: %w", err)
+	node, exists := ns.knownNodes[nodeID]
+	if !exists {
+		return fmt.Errorf("%w: %s", domain.ErrNodeNotFound, nodeID)
+	}
+
+	switch {
+	case node.CertFingerprint == "":
+		if err := ns.nodeRepo.UpdateNodeFingerprints(ctx, nodeID, fingerprint, ""); err != nil {
+			return err
+		}
+		node.CertFingerprint = fingerprint
+		node.PendingFingerprint = ""
+		log.Printf("Pinned certificate %s for node %s (%s)", fingerprint, nodeID, node.FQDN)
+		return nil
+
+	case node.CertFingerprint == fingerprint:
+		return nil
+
+	case identity.CertificateFingerprint != "" && node.CertFingerprint == identity.CertificateFingerprint:
+		if err := ns.nodeRepo.UpdateNodeFingerprints(ctx, nodeID, fingerprint, node.PendingFingerprint); err != nil {
+			return err
+		}
+		node.CertFingerprint = fingerprint
+		log.Printf("Pinned key %s for node %s (%s) in place of its certificate", fingerprint, nodeID, node.FQDN)
+		return nil
+	}
+
+	if node.PendingFingerprint != fingerprint {
+		if err := ns.nodeRepo.UpdateNodeFingerprints(ctx, nodeID, node.CertFingerprint, fingerprint); err != nil {
+			return err
+		}
+		node.PendingFingerprint = fingerprint
+		log.Printf("ALERT: certificate for node %s (%s) changed from pinned %s to %s; refusing until approved",
+			nodeID, node.FQDN, node.CertFingerprint, fingerprint)
 	}
 
-	if existingNode == nil {
-		// Create new node
-		if err := ns.nodeRepo.CreateNode(ctx, node); err != nil {
-			return fmt.Errorf("failed to create node: %w", err)
+	return fmt.Errorf("%w: node %s presented %s", domain.ErrFingerprintMismatch, nodeID, fingerprint)
+}
+
+// ApproveFingerprint pins a node's pending fingerprint after an operator has
+// confirmed the certificate change; the fingerprint must match the pending one
+func (ns *NodeService) ApproveFingerprint(ctx context.Context, nodeID string, fingerprint string) error {
+	unlock := ns.lockNode(nodeID)
+	defer unlock()
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	node, exists := ns.knownNodes[nodeID]
+	if !exists {
+		return fmt.Errorf("%w: %s", domain.ErrNodeNotFound, nodeID)
+	}
+
+	if node.PendingFingerprint == "" || node.PendingFingerprint != fingerprint {
+		return fmt.Errorf("%w: node %s", domain.ErrNoPendingFingerprint, nodeID)
+	}
+
+	if err := ns.nodeRepo.UpdateNodeFingerprints(ctx, nodeID, fingerprint, ""); err != nil {
+		return err
+	}
+
+	log.Printf("Approved new certificate %s for node %s (previously %s)", fingerprint, nodeID, node.CertFingerprint)
+	node.CertFingerprint = fingerprint
+	node.PendingFingerprint = ""
+
+	return nil
+}
+
+// lockNode serializes writes to one node from the cached copy through the
+// database to the cache, so concurrent updates of a node cannot overwrite
+// each other. The database write happens outside the service mutex so
+// pollers and peers reporting on different nodes don't queue behind each
+// other's disk I/O. It returns the function that releases the lock.
+func (ns *NodeService) lockNode(nodeID string) func() {
+	ns.mu.Lock()
+	lock, ok := ns.nodeLocks[nodeID]
+	if !ok {
+		lock = &sync.Mutex{}
+		ns.nodeLocks[nodeID] = lock
+	}
+	ns.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// addOrUpdateNode upserts a node and refreshes the cache
+func (ns *NodeService) addOrUpdateNode(ctx context.Context, node *domain.Node) error {
+	unlock := ns.lockNode(node.ID)
+	defer unlock()
+
+	return ns.upsertNode(ctx, node)
+}
+
+// mergeSourceNode stores the node that sent node info. Fields it left out,
+// such as the address and labels absent from a network snapshot, or labels
+// from nodes that predate them in snapshots, keep their stored values.
+func (ns *NodeService) mergeSourceNode(ctx context.Context, source *domain.Node) error {
+	unlock := ns.lockNode(source.ID)
+	defer unlock()
+
+	ns.mu.RLock()
+	if known, ok := ns.knownNodes[source.ID]; ok {
+		if source.FQDN == "" {
+			source.FQDN = known.FQDN
+		}
+		if source.IP == "" {
+			source.IP = known.IP
 		}
-	} else {
-		// Update existing node but preserve first seen time
-		node.FirstSeen = existingNode.FirstSeen
-		if err := ns.nodeRepo.UpdateNode(ctx, node); err != nil {
-			return fmt.Errorf("failed to update node: %w", err)
+		if source.Labels == nil {
+			source.Labels = known.Labels
 		}
 	}
+	ns.mu.RUnlock()
+
+	return ns.upsertNode(ctx, source)
+}
+
+// updateNode applies update to a copy of the cached node and stores it,
+// unless update returns false; readers of the cache never see a partial
+// update
+func (ns *NodeService) updateNode(ctx context.Context, nodeID string, update func(node *domain.Node) bool) error {
+	unlock := ns.lockNode(nodeID)
+	defer unlock()
+
+	ns.mu.RLock()
+	existing, ok := ns.knownNodes[nodeID]
+	var node domain.Node
+	if ok {
+		node = *existing
+	}
+	ns.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", domain.ErrNodeNotFound, nodeID)
+	}
+	if !update(&node) {
+		return nil
+	}
 
-	// Update in-memory cache
+	return ns.upsertNode(ctx, &node)
+}
+
+// upsertNode writes node to the database and the cache; the caller holds
+// the node's lock
+func (ns *NodeService) upsertNode(ctx context.Context, node *domain.Node) error {
+	if err := ns.nodeRepo.UpsertNode(ctx, node); err != nil {
+		return fmt.Errorf("failed to upsert node: %w", err)
+	}
+
+	ns.mu.Lock()
+	// A peer reporting in brings an inactive node back
+	if existing, ok := ns.knownNodes[node.ID]; ok && existing.IsActive != node.IsActive {
+		ns.recordStatus(node.ID, node.IsActive, time.Now())
+	}
 	ns.knownNodes[node.ID] = node
+	ns.mu.Unlock()
 
 	return nil
 }
 
+// recordStatus adds a status to the node's flap history; ns.mu must be held
+func (ns *NodeService) recordStatus(nodeID string, isActive bool, now time.Time) {
+	detector, ok := ns.flaps[nodeID]
+	if !ok {
+		detector = &domain.FlapDetector{}
+		ns.flaps[nodeID] = detector
+	}
+
+	if detector.Record(isActive, now, ns.flapConfig) {
+		state := detector.State(nodeID, "")
+		if state.Flapping {
+			log.Printf("Node %s is flapping (%.0f%% state change)", nodeID, state.Score)
+		} else {
+			log.Printf("Node %s stopped flapping (%.0f%% state change)", nodeID, state.Score)
+		}
+	}
+}
+
+// GetFlapStates returns the flap scores of the nodes whose status has been
+// updated since startup, by node ID
+func (ns *NodeService) GetFlapStates(ctx context.Context) ([]domain.FlapState, error) {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	states := make([]domain.FlapState, 0, len(ns.flaps))
+	for nodeID, detector := range ns.flaps {
+		states = append(states, detector.State(nodeID, ""))
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].NodeID < states[j].NodeID })
+
+	return states, nil
+}
+
+// seedNodeIDPrefix starts the IDs seed nodes are known by until their own
+// node ID is learned from polling them
+const seedNodeIDPrefix = "seed-"
+
+// isSeedNodeID reports whether nodeID is a seed node's placeholder ID
+func isSeedNodeID(nodeID string) bool {
+	return strings.HasPrefix(nodeID, seedNodeIDPrefix)
+}
+
 func (ns *NodeService) loadSeedNodes(ctx context.Context) error {
 	seedConfig, err := ns.configSvc.LoadSeedConfig()
 	if err != nil {
@@ -236,7 +510,7 @@ func (ns *NodeService) loadSeedNodes(ctx context.Context) error {
 	for _, seedNode := range seedConfig.Nodes {
 		// Generate a deterministic ID for seed nodes based on their FQDN/IP
 		// This ensures seed nodes get consistent IDs across restarts
-		nodeID := fmt.Sprintf("seed-%s-%s", seedNode.FQDN, seedNode.IP)
+		nodeID := fmt.Sprintf("%s%s-%s", seedNodeIDPrefix, seedNode.FQDN, seedNode.IP)
 
 		// Skip if this is somehow our own node
 		if nodeID == myNodeID {
@@ -292,7 +566,7 @@ func (ns *NodeService) GetNodeByID(ctx context.Context, nodeID string) (*domain.
 
 	node, exists := ns.knownNodes[nodeID]
 	if !exists {
-		return nil, fmt.Errorf("node %s not found", nodeID)
+		return nil, fmt.Errorf("%w: %s", domain.ErrNodeNotFound, nodeID)
 	}
 
 	// Return a copy to prevent external modifications
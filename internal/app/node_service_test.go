@@ -0,0 +1,434 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+	"nodeprobe/internal/pkg/memory"
+)
+
+func TestNodeServiceInitializeLoadsStoredAndSeedNodes(t *testing.T) {
+	ctx := context.Background()
+	config := newFakeConfig("self")
+	config.seeds = &domain.SeedConfig{Nodes: []domain.SeedNode{{FQDN: "seed.example.com", IP: "10.0.0.10"}}}
+
+	ns, store := newTestNodeService(t, config)
+
+	stored := testNode("stored", "10.0.0.20")
+	if err := store.CreateNode(ctx, &stored); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	// A restarted service picks up the stored node and doesn't duplicate the seed
+	restarted := NewNodeService(store, config)
+	if err := restarted.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	for _, svc := range []*NodeService{ns, restarted} {
+		if _, err := svc.GetNodeByID(ctx, "seed-seed.example.com-10.0.0.10"); err != nil {
+			t.Errorf("seed node not registered: %v", err)
+		}
+	}
+	if _, err := restarted.GetNodeByID(ctx, "stored"); err != nil {
+		t.Errorf("stored node not loaded: %v", err)
+	}
+
+	nodes, _ := store.GetAllNodes(ctx)
+	if len(nodes) != 2 {
+		t.Errorf("store has %d nodes, want 2", len(nodes))
+	}
+}
+
+func TestNodeServiceMergeNodeInfo(t *testing.T) {
+	ctx := context.Background()
+	ns, store := newTestNodeService(t, newFakeConfig("self"))
+
+	info := &domain.NodeInfo{
+		ID:   "peer",
+		FQDN: "peer.example.com",
+		IP:   "10.0.0.2",
+		Nodes: []domain.Node{
+			testNode("self", "10.0.0.254"),
+			testNode("other", "10.0.0.3"),
+			testNode("bad id!", "10.0.0.4"),
+			testNode("loopback", "127.0.0.1"),
+		},
+	}
+	if err := ns.MergeNodeInfo(ctx, info, "peer"); err != nil {
+		t.Fatalf("MergeNodeInfo: %v", err)
+	}
+
+	known, _ := ns.GetKnownNodes(ctx)
+	ids := map[string]bool{}
+	for _, node := range known {
+		ids[node.ID] = true
+	}
+	if len(ids) != 2 || !ids["peer"] || !ids["other"] {
+		t.Errorf("known nodes = %v, want peer and other", ids)
+	}
+
+	other, _ := store.GetNode(ctx, "other")
+	if other == nil || other.DiscoveredBy != "peer" {
+		t.Errorf("stored node = %+v, want other discovered by peer", other)
+	}
+}
+
+func TestNodeServiceMergeKeepsFirstSeenAndUpdatesAddress(t *testing.T) {
+	ctx := context.Background()
+	ns, _ := newTestNodeService(t, newFakeConfig("self"))
+
+	info := &domain.NodeInfo{ID: "peer", FQDN: "peer.example.com", IP: "10.0.0.2",
+		Nodes: []domain.Node{testNode("other", "10.0.0.3")}}
+	if err := ns.MergeNodeInfo(ctx, info, "peer"); err != nil {
+		t.Fatalf("MergeNodeInfo: %v", err)
+	}
+	first, _ := ns.GetNodeByID(ctx, "other")
+
+	time.Sleep(10 * time.Millisecond)
+	info.Nodes = []domain.Node{testNode("other", "10.0.0.30")}
+	if err := ns.MergeNodeInfo(ctx, info, "peer"); err != nil {
+		t.Fatalf("MergeNodeInfo: %v", err)
+	}
+
+	updated, _ := ns.GetNodeByID(ctx, "other")
+	if updated.IP != "10.0.0.30" {
+		t.Errorf("IP = %s, want 10.0.0.30", updated.IP)
+	}
+	if !updated.FirstSeen.Equal(first.FirstSeen) {
+		t.Errorf("first seen changed from %v to %v", first.FirstSeen, updated.FirstSeen)
+	}
+	if !updated.LastSeen.After(first.LastSeen) {
+		t.Errorf("last seen not advanced: %v", updated.LastSeen)
+	}
+}
+
+func TestNodeServiceMergeLabels(t *testing.T) {
+	ctx := context.Background()
+	ns, store := newTestNodeService(t, newFakeConfig("self"))
+
+	// Labels heard about another node stand in until it reports its own
+	gossiped := testNode("other", "10.0.0.3")
+	gossiped.Labels = map[string]string{"region": "eu-west", "zone": "a"}
+	info := &domain.NodeInfo{ID: "peer", FQDN: "peer.example.com", IP: "10.0.0.2",
+		Labels: map[string]string{"region": "us-east"},
+		Nodes:  []domain.Node{gossiped}}
+	if err := ns.MergeNodeInfo(ctx, info, "peer"); err != nil {
+		t.Fatalf("MergeNodeInfo: %v", err)
+	}
+
+	other, _ := ns.GetNodeByID(ctx, "other")
+	if other.Labels["zone"] != "a" {
+		t.Errorf("other labels = %v, want the gossiped labels", other.Labels)
+	}
+
+	own := &domain.NodeInfo{ID: "other", FQDN: "other.example.com", IP: "10.0.0.3",
+		Labels: map[string]string{"region": "eu-west", "zone": "b"}}
+	if err := ns.MergeNodeInfo(ctx, own, "other"); err != nil {
+		t.Fatalf("MergeNodeInfo: %v", err)
+	}
+	if err := ns.MergeNodeInfo(ctx, info, "peer"); err != nil {
+		t.Fatalf("MergeNodeInfo: %v", err)
+	}
+
+	stored, _ := store.GetNode(ctx, "other")
+	if stored == nil || stored.Labels["zone"] != "b" {
+		t.Errorf("stored other = %+v, want its own zone b to outlast gossip", stored)
+	}
+	peer, _ := ns.GetNodeByID(ctx, "peer")
+	if peer.Labels["region"] != "us-east" {
+		t.Errorf("peer labels = %v, want region us-east", peer.Labels)
+	}
+
+	// Invalid labels make the whole node invalid
+	info.Labels = map[string]string{"bad name!": "x"}
+	if err := ns.MergeNodeInfo(ctx, info, "peer"); !errors.Is(err, domain.ErrInvalidNode) {
+		t.Errorf("MergeNodeInfo error = %v, want ErrInvalidNode", err)
+	}
+}
+
+func TestNodeServiceRejectsInvalidSource(t *testing.T) {
+	ns, _ := newTestNodeService(t, newFakeConfig("self"))
+
+	err := ns.MergeNodeInfo(context.Background(), &domain.NodeInfo{ID: "peer", IP: "0.0.0.0"}, "peer")
+	if !errors.Is(err, domain.ErrInvalidNode) {
+		t.Errorf("MergeNodeInfo error = %v, want ErrInvalidNode", err)
+	}
+}
+
+func TestNodeServiceLimitsIntroductionsPerPeer(t *testing.T) {
+	ctx := context.Background()
+	config := newFakeConfig("self")
+	config.limits.MaxNewNodesPerPeer = 2
+	ns, _ := newTestNodeService(t, config)
+
+	info := &domain.NodeInfo{ID: "peer", FQDN: "peer.example.com", IP: "10.0.0.2", Nodes: []domain.Node{
+		testNode("a", "10.0.1.1"),
+		testNode("b", "10.0.1.2"),
+		testNode("c", "10.0.1.3"),
+	}}
+	if err := ns.MergeNodeInfo(ctx, info, "peer"); err != nil {
+		t.Fatalf("MergeNodeInfo: %v", err)
+	}
+
+	if _, err := ns.GetNodeByID(ctx, "c"); !errors.Is(err, domain.ErrNodeNotFound) {
+		t.Errorf("third new node was accepted (err %v)", err)
+	}
+	known, _ := ns.GetKnownNodes(ctx)
+	if len(known) != 3 { // the peer itself plus two introductions
+		t.Errorf("known nodes = %d, want 3", len(known))
+	}
+}
+
+func TestNodeServiceUpdateNodeStatus(t *testing.T) {
+	ctx := context.Background()
+	ns, store := newTestNodeService(t, newFakeConfig("self"))
+
+	if err := ns.MergeNodeInfo(ctx, &domain.NodeInfo{ID: "peer", FQDN: "peer.example.com", IP: "10.0.0.2"}, "peer"); err != nil {
+		t.Fatalf("MergeNodeInfo: %v", err)
+	}
+
+	if err := ns.UpdateNodeStatus(ctx, "peer", false); err != nil {
+		t.Fatalf("UpdateNodeStatus: %v", err)
+	}
+
+	active, _ := ns.GetActiveNodes(ctx)
+	if len(active) != 0 {
+		t.Errorf("active nodes = %v, want none", active)
+	}
+	stored, _ := store.GetNode(ctx, "peer")
+	if stored.IsActive {
+		t.Error("inactive status not persisted")
+	}
+
+	if err := ns.UpdateNodeStatus(ctx, "missing", true); err == nil {
+		t.Error("UpdateNodeStatus(missing) succeeded")
+	}
+}
+
+// upsertHookStore calls beforeUpsert ahead of each node upsert
+type upsertHookStore struct {
+	*memory.Store
+	beforeUpsert func(node *domain.Node)
+}
+
+func (s *upsertHookStore) UpsertNode(ctx context.Context, node *domain.Node) error {
+	if s.beforeUpsert != nil {
+		s.beforeUpsert(node)
+	}
+	return s.Store.UpsertNode(ctx, node)
+}
+
+func TestNodeServiceConcurrentUpdatesKeepStatus(t *testing.T) {
+	ctx := context.Background()
+	config := newFakeConfig("self")
+	store := &upsertHookStore{Store: memory.NewStore()}
+	ns := NewNodeService(store, config)
+	if err := ns.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	info := &domain.NodeInfo{ID: "peer", FQDN: "peer.example.com", IP: "10.0.0.2", Nodes: []domain.Node{testNode("other", "10.0.0.3")}}
+	if err := ns.MergeNodeInfo(ctx, info, "peer"); err != nil {
+		t.Fatalf("MergeNodeInfo: %v", err)
+	}
+
+	// The poller marks other inactive while a peer's report of it is being written
+	var wg sync.WaitGroup
+	var once sync.Once
+	store.beforeUpsert = func(node *domain.Node) {
+		if node.ID != "other" {
+			return
+		}
+		once.Do(func() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := ns.UpdateNodeStatus(ctx, "other", false); err != nil {
+					t.Errorf("UpdateNodeStatus: %v", err)
+				}
+			}()
+			time.Sleep(20 * time.Millisecond)
+		})
+	}
+	if err := ns.MergeNodeInfo(ctx, info, "peer"); err != nil {
+		t.Fatalf("MergeNodeInfo: %v", err)
+	}
+	wg.Wait()
+
+	cached, _ := ns.GetNodeByID(ctx, "other")
+	stored, _ := store.GetNode(ctx, "other")
+	if cached.IsActive || stored.IsActive {
+		t.Errorf("cached active = %v, stored active = %v, want the status update kept in both", cached.IsActive, stored.IsActive)
+	}
+
+	// Peers, pollers and the discovery loop writing the same nodes at once
+	// leave the cache matching the database
+	store.beforeUpsert = nil
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				ns.MergeNodeInfo(ctx, info, "peer")
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				ns.UpdateNodeStatus(ctx, "other", (i+j)%2 == 0)
+				ns.UpdateNodeStatus(ctx, "peer", (i+j)%3 == 0)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				ns.DiscoverNodes(ctx)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, id := range []string{"peer", "other"} {
+		cached, _ := ns.GetNodeByID(ctx, id)
+		stored, _ := store.GetNode(ctx, id)
+		if cached.IsActive != stored.IsActive || !cached.LastSeen.Equal(stored.LastSeen) {
+			t.Errorf("%s cached = %+v, stored = %+v, want them equal", id, cached, stored)
+		}
+	}
+}
+
+func TestNodeServiceFingerprintPinning(t *testing.T) {
+	ctx := context.Background()
+	ns, store := newTestNodeService(t, newFakeConfig("self"))
+
+	if err := ns.MergeNodeInfo(ctx, &domain.NodeInfo{ID: "peer", FQDN: "peer.example.com", IP: "10.0.0.2"}, "peer"); err != nil {
+		t.Fatalf("MergeNodeInfo: %v", err)
+	}
+
+	if err := ns.VerifyPeerFingerprint(ctx, "peer", domain.PeerIdentity{Fingerprint: "aa"}); err != nil {
+		t.Fatalf("first fingerprint not pinned: %v", err)
+	}
+	if err := ns.VerifyPeerFingerprint(ctx, "peer", domain.PeerIdentity{Fingerprint: "aa"}); err != nil {
+		t.Errorf("pinned fingerprint refused: %v", err)
+	}
+
+	if err := ns.VerifyPeerFingerprint(ctx, "peer", domain.PeerIdentity{Fingerprint: "bb"}); !errors.Is(err, domain.ErrFingerprintMismatch) {
+		t.Fatalf("changed fingerprint error = %v, want ErrFingerprintMismatch", err)
+	}
+	stored, _ := store.GetNode(ctx, "peer")
+	if stored.CertFingerprint != "aa" || stored.PendingFingerprint != "bb" {
+		t.Errorf("stored fingerprints = %q/%q, want aa/bb", stored.CertFingerprint, stored.PendingFingerprint)
+	}
+
+	// Rediscovery must not reset the pin
+	if err := ns.MergeNodeInfo(ctx, &domain.NodeInfo{ID: "peer", FQDN: "peer.example.com", IP: "10.0.0.2"}, "peer"); err != nil {
+		t.Fatalf("MergeNodeInfo: %v", err)
+	}
+	if err := ns.VerifyPeerFingerprint(ctx, "peer", domain.PeerIdentity{Fingerprint: "bb"}); !errors.Is(err, domain.ErrFingerprintMismatch) {
+		t.Errorf("pin reset by rediscovery: %v", err)
+	}
+
+	if err := ns.ApproveFingerprint(ctx, "peer", "cc"); !errors.Is(err, domain.ErrNoPendingFingerprint) {
+		t.Errorf("approving a non-pending fingerprint: %v", err)
+	}
+	if err := ns.ApproveFingerprint(ctx, "peer", "bb"); err != nil {
+		t.Fatalf("ApproveFingerprint: %v", err)
+	}
+	if err := ns.VerifyPeerFingerprint(ctx, "peer", domain.PeerIdentity{Fingerprint: "bb"}); err != nil {
+		t.Errorf("approved fingerprint refused: %v", err)
+	}
+
+	if err := ns.VerifyPeerFingerprint(ctx, "missing", domain.PeerIdentity{Fingerprint: "aa"}); !errors.Is(err, domain.ErrNodeNotFound) {
+		t.Errorf("unknown node error = %v, want ErrNodeNotFound", err)
+	}
+}
+
+func TestNodeServiceMovesCertificatePinToKey(t *testing.T) {
+	ctx := context.Background()
+	ns, store := newTestNodeService(t, newFakeConfig("self"))
+	if err := ns.MergeNodeInfo(ctx, &domain.NodeInfo{ID: "peer", FQDN: "peer.example.com", IP: "10.0.0.2"}, "peer"); err != nil {
+		t.Fatalf("MergeNodeInfo: %v", err)
+	}
+
+	// Earlier versions pinned the whole certificate
+	if err := ns.VerifyPeerFingerprint(ctx, "peer", domain.PeerIdentity{Fingerprint: "cert"}); err != nil {
+		t.Fatalf("VerifyPeerFingerprint: %v", err)
+	}
+
+	identity := domain.PeerIdentity{Fingerprint: "key", CertificateFingerprint: "cert"}
+	if err := ns.VerifyPeerFingerprint(ctx, "peer", identity); err != nil {
+		t.Fatalf("certificate matching the old pin refused: %v", err)
+	}
+	if stored, _ := store.GetNode(ctx, "peer"); stored.CertFingerprint != "key" || stored.PendingFingerprint != "" {
+		t.Errorf("stored fingerprints = %q/%q, want key/none", stored.CertFingerprint, stored.PendingFingerprint)
+	}
+
+	// A renewed certificate with the same key still matches
+	if err := ns.VerifyPeerFingerprint(ctx, "peer", domain.PeerIdentity{Fingerprint: "key", CertificateFingerprint: "renewed"}); err != nil {
+		t.Errorf("renewed certificate with the pinned key refused: %v", err)
+	}
+}
+
+func TestNodeServiceFlapDetection(t *testing.T) {
+	ctx := context.Background()
+	ns, _ := newTestNodeService(t, newFakeConfig("self"))
+	for _, node := range []domain.Node{testNode("wifi", "10.0.0.2"), testNode("wired", "10.0.0.3")} {
+		if err := ns.addOrUpdateNode(ctx, &node); err != nil {
+			t.Fatalf("addOrUpdateNode: %v", err)
+		}
+	}
+
+	flapState := func(nodeID string) domain.FlapState {
+		t.Helper()
+		states, err := ns.GetFlapStates(ctx)
+		if err != nil {
+			t.Fatalf("GetFlapStates: %v", err)
+		}
+		for _, state := range states {
+			if state.NodeID == nodeID {
+				return state
+			}
+		}
+		t.Fatalf("no flap state for %s in %+v", nodeID, states)
+		return domain.FlapState{}
+	}
+
+	// One outage is not flapping
+	for _, active := range []bool{true, true, false, false, true, true} {
+		ns.UpdateNodeStatus(ctx, "wired", active)
+	}
+	if state := flapState("wired"); state.Flapping || state.Score >= domain.DefaultFlapLowThreshold {
+		t.Errorf("wired = %+v, want a low score", state)
+	}
+
+	// Toggling every other poll, with a peer's report bringing it back
+	for i := 0; i < 10; i++ {
+		ns.UpdateNodeStatus(ctx, "wifi", false)
+		if err := ns.MergeNodeInfo(ctx, &domain.NodeInfo{ID: "wifi", FQDN: "wifi.example.com", IP: "10.0.0.2"}, "wifi"); err != nil {
+			t.Fatalf("MergeNodeInfo: %v", err)
+		}
+	}
+	state := flapState("wifi")
+	if !state.Flapping || state.Since == nil || state.Score < 90 {
+		t.Fatalf("wifi = %+v, want flapping with a score near 100", state)
+	}
+
+	// Hysteresis: still flapping below the high threshold, settled below the low one
+	for i := 0; i < 10; i++ {
+		ns.UpdateNodeStatus(ctx, "wifi", true)
+	}
+	if state := flapState("wifi"); !state.Flapping || state.Score >= domain.DefaultFlapHighThreshold {
+		t.Errorf("wifi = %+v, want flapping between the thresholds", state)
+	}
+	for i := 0; i < 8; i++ {
+		ns.UpdateNodeStatus(ctx, "wifi", true)
+	}
+	if state := flapState("wifi"); state.Flapping || state.Since != nil {
+		t.Errorf("wifi = %+v, want settled", state)
+	}
+}
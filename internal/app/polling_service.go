@@ -3,7 +3,9 @@ package app
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
@@ -14,25 +16,33 @@ type PollingService struct {
 	nodeService domain.NodeService
 	pollRepo    domain.PollRepository
 	httpClient  domain.HTTPClient
+	coordinates domain.CoordinateService
 	configSvc   domain.ConfigService
+	writer      *resultWriter
 	running     bool
 	stopChan    chan struct{}
 	mu          sync.RWMutex
 	nodeIndex   int
 	firstPolls  map[string]bool // Track first polls for path MTU testing
+	pinPeers    bool            // Trust-on-first-use certificate pinning
+	mutualTLS   bool            // Peers' certificates name their node IDs
+	pollSubset  int             // nodes polled, 0 for all
 }
 
 func NewPollingService(
 	nodeService domain.NodeService,
 	pollRepo domain.PollRepository,
 	httpClient domain.HTTPClient,
+	coordinates domain.CoordinateService,
 	configSvc domain.ConfigService,
 ) *PollingService {
 	return &PollingService{
 		nodeService: nodeService,
 		pollRepo:    pollRepo,
 		httpClient:  httpClient,
+		coordinates: coordinates,
 		configSvc:   configSvc,
+		writer:      newResultWriter(pollRepo),
 		stopChan:    make(chan struct{}),
 		firstPolls:  make(map[string]bool),
 	}
@@ -47,9 +57,26 @@ func (ps *PollingService) Start(ctx context.Context) error {
 	ps.running = true
 	ps.mu.Unlock()
 
+	tlsConfig, err := ps.configSvc.LoadTLSConfig()
+	if err != nil {
+		log.Printf("Warning: failed to load TLS config, peer certificate pinning disabled: %v", err)
+	} else {
+		ps.pinPeers = tlsConfig.PinPeerCertificates
+		ps.mutualTLS = tlsConfig.MutualTLS
+	}
+
+	coordinateConfig, err := ps.configSvc.LoadCoordinateConfig()
+	if err != nil {
+		log.Printf("Warning: failed to load coordinate config, polling every node: %v", err)
+	} else if coordinateConfig.PollSubset > 0 {
+		ps.pollSubset = coordinateConfig.PollSubset
+		log.Printf("Polling at most %d nodes; round trips to the rest are estimated from coordinates", ps.pollSubset)
+	}
+
 	log.Println("Starting polling service...")
 
-	// Start the polling loop in a separate goroutine
+	// Start the result writer and the polling loop in separate goroutines
+	go ps.writer.run()
 	go ps.pollingLoop(ctx)
 
 	return nil
@@ -67,6 +94,9 @@ func (ps *PollingService) Stop() error {
 	ps.running = false
 	close(ps.stopChan)
 
+	// Write out results still queued
+	ps.writer.close()
+
 	return nil
 }
 
@@ -118,6 +148,7 @@ func (ps *PollingService) pollNextNode(ctx context.Context) error {
 	if len(filteredNodes) == 0 {
 		return nil // No other nodes to poll
 	}
+	filteredNodes = pollSubset(myNodeID, filteredNodes, ps.pollSubset)
 
 	// Rotate through nodes
 	ps.mu.Lock()
@@ -137,8 +168,8 @@ func (ps *PollingService) pollNextNode(ctx context.Context) error {
 		return nil // Don't return error to keep polling loop running
 	}
 
-	// Store the poll result
-	if err := ps.pollRepo.CreatePollResult(ctx, result); err != nil {
+	// Queue the poll result for the background writer
+	if err := ps.writer.enqueue(ctx, result); err != nil {
 		log.Printf("Failed to store poll result for node %s: %v", nodeToPoll.ID, err)
 	}
 
@@ -188,8 +219,29 @@ func (ps *PollingService) PollNode(ctx context.Context, node *domain.Node) (*dom
 		}
 	}
 
-	// Get node information from the target node
-	nodeInfo, err := ps.httpClient.GetNodeInfo(pollCtx, nodeURL)
+	// Peers are checked in the handshake, so an impostor is refused before
+	// it is sent anything
+	var verify domain.PeerVerifier
+	var certNodeID string
+	if ps.pinPeers || ps.mutualTLS {
+		verify = func(identity domain.PeerIdentity) error {
+			// A seed is only known by its address until it is first polled
+			if identity.NodeID != "" && !isSeedNodeID(node.ID) && identity.NodeID != node.ID {
+				return fmt.Errorf("%w: polled node %s, certificate is for node %s",
+					domain.ErrPeerIdentityMismatch, node.ID, identity.NodeID)
+			}
+			certNodeID = identity.NodeID
+			if ps.pinPeers {
+				return ps.nodeService.VerifyPeerFingerprint(pollCtx, node.ID, identity)
+			}
+			return nil
+		}
+	}
+
+	// Get node information from the target node; the round trip excludes
+	// the path MTU test
+	requestStart := time.Now()
+	nodeInfo, err := ps.httpClient.GetNodeInfo(pollCtx, nodeURL, verify)
 	endTime := time.Now()
 
 	// Calculate response time
@@ -204,18 +256,54 @@ func (ps *PollingService) PollNode(ctx context.Context, node *domain.Node) (*dom
 		return result, nil
 	}
 
+	// Don't trust anything a node says about itself beyond its certificate
+	if certNodeID != "" && nodeInfo.ID != certNodeID {
+		result.Success = false
+		result.Error = fmt.Sprintf("%v: node info is for node %s, certificate is for node %s",
+			domain.ErrPeerIdentityMismatch, nodeInfo.ID, certNodeID)
+		log.Printf("Poll refused for node %s (%s): %s", node.ID, node.FQDN, result.Error)
+		return result, nil
+	}
+
 	result.Success = true
 	log.Printf("Poll successful for node %s (%s): %dms",
 		node.ID, node.FQDN, responseMs)
 
+	// A polled node's labels are its own: none means it has none
+	if nodeInfo.Labels == nil {
+		nodeInfo.Labels = map[string]string{}
+	}
+
 	// Merge the discovered node information
 	if err := ps.nodeService.MergeNodeInfo(ctx, nodeInfo, node.ID); err != nil {
 		log.Printf("Failed to merge node info from %s: %v", node.ID, err)
 	}
+	ps.coordinates.Observe(ctx, nodeInfo, float64(endTime.Sub(requestStart).Microseconds())/1000)
 
 	return result, nil
 }
 
+// pollSubset returns the n nodes that rank first for localID. Ranks come
+// from hashing both node IDs, so the subset is stable while nodes come and
+// go and each node polls a different one.
+func pollSubset(localID string, nodes []domain.Node, n int) []domain.Node {
+	if n <= 0 || len(nodes) <= n {
+		return nodes
+	}
+
+	rank := func(nodeID string) uint64 {
+		h := fnv.New64a()
+		h.Write([]byte(localID))
+		h.Write([]byte{0})
+		h.Write([]byte(nodeID))
+		return h.Sum64()
+	}
+
+	ranked := append([]domain.Node(nil), nodes...)
+	sort.Slice(ranked, func(i, j int) bool { return rank(ranked[i].ID) < rank(ranked[j].ID) })
+	return ranked[:n]
+}
+
 // GetPollHistory returns recent poll results for a specific node
 func (ps *PollingService) GetPollHistory(ctx context.Context, nodeID string, limit int) ([]domain.PollResult, error) {
 	return ps.pollRepo.GetPollResults(ctx, nodeID, limit)
@@ -226,11 +314,6 @@ func (ps *PollingService) GetRecentPollResults(ctx context.Context, since time.T
 	return ps.pollRepo.GetRecentPollResults(ctx, since)
 }
 
-// CleanupOldResults removes old poll results to keep database size under control
-func (ps *PollingService) CleanupOldResults(ctx context.Context) error {
-	return ps.pollRepo.CleanupOldResults(ctx, domain.MaxDatabaseSizeMB)
-}
-
 // GetDatabaseSize returns the current database size in bytes
 func (ps *PollingService) GetDatabaseSize(ctx context.Context) (int64, error) {
 	return ps.pollRepo.GetDatabaseSize(ctx)
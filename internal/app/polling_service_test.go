@@ -0,0 +1,299 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"nodeprobe/internal/domain"
+)
+
+func TestPollNodeSuccessMergesPeers(t *testing.T) {
+	ctx := context.Background()
+	config := newFakeConfig("self")
+	ns, _ := newTestNodeService(t, config)
+	client := newFakeHTTPClient()
+	coordinate := domain.NewCoordinate()
+	client.nodeInfo["https://peer.example.com:443"] = &domain.NodeInfo{
+		ID: "peer", FQDN: "peer.example.com", IP: "10.0.0.2",
+		Nodes:      []domain.Node{testNode("other", "10.0.0.3")},
+		Coordinate: &coordinate,
+	}
+	cs := newTestCoordinateService(t, ns, config)
+	ps := NewPollingService(ns, nil, client, cs, config)
+
+	peer := testNode("peer", "10.0.0.2")
+	result, err := ps.PollNode(ctx, &peer)
+	if err != nil {
+		t.Fatalf("PollNode: %v", err)
+	}
+	if !result.Success || result.Error != "" || result.NodeID != "peer" {
+		t.Errorf("result = %+v, want success", result)
+	}
+	if result.PathMTU != 1500 {
+		t.Errorf("first poll path MTU = %d, want 1500", result.PathMTU)
+	}
+	if _, err := ns.GetNodeByID(ctx, "other"); err != nil {
+		t.Errorf("reported node not merged: %v", err)
+	}
+	if _, err := cs.EstimateRTT(ctx, "self", "peer"); err != nil {
+		t.Errorf("polled node's coordinate not recorded: %v", err)
+	}
+
+	// Path MTU is only measured on first contact
+	result, _ = ps.PollNode(ctx, &peer)
+	if result.PathMTU != 0 || len(client.mtuTests) != 1 {
+		t.Errorf("path MTU measured again: result %d, tests %v", result.PathMTU, client.mtuTests)
+	}
+}
+
+func TestPollNodeFallsBackToIP(t *testing.T) {
+	config := newFakeConfig("self")
+	ns, _ := newTestNodeService(t, config)
+	client := newFakeHTTPClient()
+	client.nodeInfo["https://10.0.0.2:443"] = &domain.NodeInfo{ID: "peer", FQDN: "unknown", IP: "10.0.0.2"}
+	ps := NewPollingService(ns, nil, client, newTestCoordinateService(t, ns, config), config)
+
+	peer := testNode("peer", "10.0.0.2")
+	peer.FQDN = "unknown"
+	result, err := ps.PollNode(context.Background(), &peer)
+	if err != nil || !result.Success {
+		t.Errorf("PollNode by IP = %+v, %v; want success", result, err)
+	}
+}
+
+func TestPollNodeFailureIsRecorded(t *testing.T) {
+	config := newFakeConfig("self")
+	ns, _ := newTestNodeService(t, config)
+	ps := NewPollingService(ns, nil, newFakeHTTPClient(), newTestCoordinateService(t, ns, config), config)
+
+	peer := testNode("peer", "10.0.0.2")
+	result, err := ps.PollNode(context.Background(), &peer)
+	if err != nil {
+		t.Fatalf("PollNode returned error for an unreachable node: %v", err)
+	}
+	if result.Success || result.Error == "" {
+		t.Errorf("result = %+v, want failure with error message", result)
+	}
+}
+
+func TestReportAfterPollKeepsPolledDetails(t *testing.T) {
+	ctx := context.Background()
+	config := newFakeConfig("self")
+	ns, store := newTestNodeService(t, config)
+	client := newFakeHTTPClient()
+	client.nodeInfo["https://peer.example.com:443"] = &domain.NodeInfo{
+		ID: "peer", FQDN: "peer.example.com", IP: "10.0.0.2",
+		Labels: map[string]string{"region": "eu-west"},
+	}
+	ps := NewPollingService(ns, nil, client, newTestCoordinateService(t, ns, config), config)
+
+	peer := testNode("peer", "10.0.0.2")
+	if result, err := ps.PollNode(ctx, &peer); err != nil || !result.Success {
+		t.Fatalf("PollNode = %+v, %v, want success", result, err)
+	}
+
+	// handleReport merges a snapshot as its sender's ID, labels and node list;
+	// snapshots from older nodes carry no labels
+	report := func(labels map[string]string) {
+		t.Helper()
+		info := &domain.NodeInfo{ID: "peer", Labels: labels, Nodes: []domain.Node{testNode("other", "10.0.0.3")}}
+		if err := ns.MergeNodeInfo(ctx, info, "report"); err != nil {
+			t.Fatalf("MergeNodeInfo: %v", err)
+		}
+	}
+
+	report(nil)
+	stored, _ := store.GetNode(ctx, "peer")
+	if stored.Labels["region"] != "eu-west" || stored.FQDN != "peer.example.com" || stored.IP != "10.0.0.2" {
+		t.Errorf("after a report without labels peer = %+v, want the polled FQDN, IP and labels", stored)
+	}
+
+	report(map[string]string{"region": "eu-central"})
+	if stored, _ := store.GetNode(ctx, "peer"); stored.Labels["region"] != "eu-central" {
+		t.Errorf("labels after a report with labels = %v, want the reported ones", stored.Labels)
+	}
+
+	// A node that removed its labels reports none
+	report(map[string]string{})
+	if stored, _ := store.GetNode(ctx, "peer"); len(stored.Labels) != 0 {
+		t.Errorf("labels after a report of no labels = %v, want none", stored.Labels)
+	}
+}
+
+func TestPollNodeRefusesChangedCertificate(t *testing.T) {
+	ctx := context.Background()
+	config := newFakeConfig("self")
+	config.tls.PinPeerCertificates = true
+	ns, _ := newTestNodeService(t, config)
+	if err := ns.MergeNodeInfo(ctx, &domain.NodeInfo{ID: "peer", FQDN: "peer.example.com", IP: "10.0.0.2"}, "peer"); err != nil {
+		t.Fatalf("MergeNodeInfo: %v", err)
+	}
+	if err := ns.VerifyPeerFingerprint(ctx, "peer", domain.PeerIdentity{Fingerprint: "pinned"}); err != nil {
+		t.Fatalf("VerifyPeerFingerprint: %v", err)
+	}
+
+	client := newFakeHTTPClient()
+	client.nodeInfo["https://peer.example.com:443"] = &domain.NodeInfo{
+		ID: "peer", FQDN: "peer.example.com", IP: "10.0.0.2",
+		Nodes: []domain.Node{testNode("planted", "10.0.0.66")},
+	}
+	client.identities["https://peer.example.com:443"] = domain.PeerIdentity{Fingerprint: "impostor"}
+	ps := NewPollingService(ns, nil, client, newTestCoordinateService(t, ns, config), config)
+	if err := ps.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer ps.Stop()
+
+	peer, _ := ns.GetNodeByID(ctx, "peer")
+	result, err := ps.PollNode(ctx, peer)
+	if err != nil {
+		t.Fatalf("PollNode: %v", err)
+	}
+	if result.Success || !strings.Contains(result.Error, domain.ErrFingerprintMismatch.Error()) {
+		t.Errorf("result = %+v, want a fingerprint mismatch", result)
+	}
+	if len(client.requests) != 0 {
+		t.Errorf("requests sent = %v, want none past the handshake", client.requests)
+	}
+	if _, err := ns.GetNodeByID(ctx, "planted"); !errors.Is(err, domain.ErrNodeNotFound) {
+		t.Error("nodes reported by an unverified peer were merged")
+	}
+}
+
+func TestPollNodeChecksCertificateNodeID(t *testing.T) {
+	ctx := context.Background()
+	url := "https://peer.example.com:443"
+
+	for name, tt := range map[string]struct {
+		nodeID   string // the ID the polled node is known by
+		certID   string
+		infoID   string
+		success  bool
+		requests int
+	}{
+		"matching":             {"peer", "peer", "peer", true, 1},
+		"other certificate":    {"peer", "impostor", "peer", false, 0},
+		"other node info":      {"peer", "peer", "impostor", false, 1},
+		"seed":                 {"seed-peer.example.com-10.0.0.2", "peer", "peer", true, 1},
+		"seed with other info": {"seed-peer.example.com-10.0.0.2", "peer", "impostor", false, 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := newFakeConfig("self")
+			config.tls.MutualTLS = true
+			ns, _ := newTestNodeService(t, config)
+			client := newFakeHTTPClient()
+			client.nodeInfo[url] = &domain.NodeInfo{
+				ID: tt.infoID, FQDN: "peer.example.com", IP: "10.0.0.2",
+				Nodes: []domain.Node{testNode("other", "10.0.0.3")},
+			}
+			client.identities[url] = domain.PeerIdentity{Fingerprint: "key", NodeID: tt.certID}
+			ps := NewPollingService(ns, nil, client, newTestCoordinateService(t, ns, config), config)
+			if err := ps.Start(ctx); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			defer ps.Stop()
+
+			peer := testNode(tt.nodeID, "10.0.0.2")
+			peer.FQDN = "peer.example.com"
+			result, err := ps.PollNode(ctx, &peer)
+			if err != nil {
+				t.Fatalf("PollNode: %v", err)
+			}
+			if result.Success != tt.success {
+				t.Errorf("result = %+v, want success %v", result, tt.success)
+			}
+			if !tt.success && !strings.Contains(result.Error, domain.ErrPeerIdentityMismatch.Error()) {
+				t.Errorf("error = %q, want a peer identity mismatch", result.Error)
+			}
+			if len(client.requests) != tt.requests {
+				t.Errorf("requests sent = %v, want %d", client.requests, tt.requests)
+			}
+			if _, err := ns.GetNodeByID(ctx, "other"); tt.success == errors.Is(err, domain.ErrNodeNotFound) {
+				t.Errorf("reported node merged = %v, want %v", err == nil, tt.success)
+			}
+		})
+	}
+}
+
+func TestPollNextNodeRotatesAndStoresResults(t *testing.T) {
+	ctx := context.Background()
+	config := newFakeConfig("self")
+	ns, store := newTestNodeService(t, config)
+
+	for _, info := range []*domain.NodeInfo{
+		{ID: "self", FQDN: "self.example.com", IP: "10.0.0.254"},
+		{ID: "peer-a", FQDN: "peer-a.example.com", IP: "10.0.0.2"},
+		{ID: "peer-b", FQDN: "peer-b.example.com", IP: "10.0.0.3"},
+	} {
+		node := domain.Node{ID: info.ID, FQDN: info.FQDN, IP: info.IP, IsActive: true}
+		if err := ns.addOrUpdateNode(ctx, &node); err != nil {
+			t.Fatalf("addOrUpdateNode: %v", err)
+		}
+	}
+
+	client := newFakeHTTPClient()
+	client.nodeInfo["https://peer-a.example.com:443"] = &domain.NodeInfo{ID: "peer-a", FQDN: "peer-a.example.com", IP: "10.0.0.2"}
+	ps := NewPollingService(ns, store, client, newTestCoordinateService(t, ns, config), config)
+	go ps.writer.run()
+
+	for i := 0; i < 4; i++ {
+		if err := ps.pollNextNode(ctx); err != nil {
+			t.Fatalf("pollNextNode: %v", err)
+		}
+	}
+	ps.writer.close()
+
+	polled := map[string]int{}
+	for _, id := range []string{"self", "peer-a", "peer-b"} {
+		results, _ := store.GetPollResults(ctx, id, 10)
+		polled[id] = len(results)
+	}
+	if polled["self"] != 0 {
+		t.Errorf("node polled itself %d times", polled["self"])
+	}
+	if polled["peer-a"] == 0 || polled["peer-b"] == 0 || polled["peer-a"]+polled["peer-b"] != 4 {
+		t.Errorf("poll counts = %v, want 4 polls rotated over both peers", polled)
+	}
+
+	// The unreachable peer is marked inactive, the reachable one stays active
+	active, _ := ns.GetActiveNodes(ctx)
+	for _, node := range active {
+		if node.ID == "peer-b" {
+			t.Error("unreachable peer still active")
+		}
+	}
+	if peerA, _ := ns.GetNodeByID(ctx, "peer-a"); !peerA.IsActive {
+		t.Error("reachable peer marked inactive")
+	}
+}
+
+func TestPollingServiceStopFlushesResults(t *testing.T) {
+	ctx := context.Background()
+	config := newFakeConfig("self")
+	ns, store := newTestNodeService(t, config)
+	ps := NewPollingService(ns, store, newFakeHTTPClient(), newTestCoordinateService(t, ns, config), config)
+
+	if err := ps.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := ps.Start(ctx); err == nil {
+		t.Error("second Start succeeded")
+	}
+
+	result := &domain.PollResult{NodeID: "peer", Success: true}
+	if err := ps.writer.enqueue(ctx, result); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := ps.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	if results, _ := store.GetPollResults(ctx, "peer", 10); len(results) != 1 {
+		t.Errorf("stored %d results after Stop, want 1", len(results))
+	}
+	if err := ps.writer.enqueue(ctx, result); err == nil {
+		t.Error("enqueue after Stop succeeded")
+	}
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter keeps a token bucket per source IP
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // tokens added per second
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// idleBucketTimeout is how long an untouched bucket is kept; by then it has
+// refilled and is indistinguishable from a new one
+const idleBucketTimeout = 10 * time.Minute
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes a token from the bucket for ip and reports whether one was available
+func (rl *rateLimiter) allow(ip string, now time.Time) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if now.Sub(rl.lastSweep) > idleBucketTimeout {
+		for key, bucket := range rl.buckets {
+			if now.Sub(bucket.last) > idleBucketTimeout {
+				delete(rl.buckets, key)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	bucket, ok := rl.buckets[ip]
+	if !ok {
+		bucket = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[ip] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.last).Seconds() * rl.rate
+	if bucket.tokens > rl.burst {
+		bucket.tokens = rl.burst
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		// requests are made from one IP at these offsets from start
+		at   []time.Duration
+		want []bool
+	}{
+		{
+			name:  "burst then refused",
+			rate:  1,
+			burst: 3,
+			at:    []time.Duration{0, 0, 0, 0},
+			want:  []bool{true, true, true, false},
+		},
+		{
+			name:  "refills at the rate",
+			rate:  2,
+			burst: 1,
+			at:    []time.Duration{0, 0, 250 * time.Millisecond, 500 * time.Millisecond, 500 * time.Millisecond},
+			want:  []bool{true, false, false, true, false},
+		},
+		{
+			name:  "refill is capped at the burst",
+			rate:  10,
+			burst: 2,
+			at:    []time.Duration{0, 0, time.Minute, time.Minute, time.Minute},
+			want:  []bool{true, true, true, true, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := newRateLimiter(tt.rate, tt.burst)
+			for i, offset := range tt.at {
+				if got := rl.allow("10.0.0.1", start.Add(offset)); got != tt.want[i] {
+					t.Errorf("request %d at +%v: allow = %v, want %v", i, offset, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterIsolatesIPs(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	rl := newRateLimiter(1, 1)
+
+	if !rl.allow("10.0.0.1", now) || rl.allow("10.0.0.1", now) {
+		t.Fatal("10.0.0.1 should get exactly one request")
+	}
+	if !rl.allow("10.0.0.2", now) {
+		t.Error("10.0.0.2 was limited by 10.0.0.1's bucket")
+	}
+	if !rl.allow("2001:db8::1", now) {
+		t.Error("2001:db8::1 was limited by another IP's bucket")
+	}
+}
+
+func TestRateLimiterSweepsIdleBuckets(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	rl := newRateLimiter(1, 1)
+
+	rl.allow("10.0.0.1", now)
+	rl.allow("10.0.0.2", now.Add(idleBucketTimeout))
+	rl.allow("10.0.0.2", now.Add(idleBucketTimeout+time.Second))
+	if _, ok := rl.buckets["10.0.0.1"]; ok {
+		t.Error("idle bucket for 10.0.0.1 was not swept")
+	}
+	if _, ok := rl.buckets["10.0.0.2"]; !ok {
+		t.Error("active bucket for 10.0.0.2 was swept")
+	}
+}
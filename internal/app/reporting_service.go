@@ -3,9 +3,7 @@ package app
 import (
 	"context"
 	"fmt"
-	"html/template"
 	"log"
-	"strings"
 	"sync"
 	"time"
 
@@ -17,6 +15,9 @@ type ReportingService struct {
 	httpClient  domain.HTTPClient
 	configSvc   domain.ConfigService
 	pollRepo    domain.PollRepository
+	alertRepo   domain.AlertRepository
+	anomalies   domain.AnomalyService
+	renderer    *TemplateRenderer
 	running     bool
 	stopChan    chan struct{}
 	mu          sync.RWMutex
@@ -27,12 +28,18 @@ func NewReportingService(
 	httpClient domain.HTTPClient,
 	configSvc domain.ConfigService,
 	pollRepo domain.PollRepository,
+	alertRepo domain.AlertRepository,
+	anomalyService domain.AnomalyService,
+	renderer *TemplateRenderer,
 ) *ReportingService {
 	return &ReportingService{
 		nodeService: nodeService,
 		httpClient:  httpClient,
 		configSvc:   configSvc,
 		pollRepo:    pollRepo,
+		alertRepo:   alertRepo,
+		anomalies:   anomalyService,
+		renderer:    renderer,
 		stopChan:    make(chan struct{}),
 	}
 }
@@ -118,6 +125,10 @@ func (rs *ReportingService) SendReport(ctx context.Context) error {
 		Timestamp: time.Now(),
 		NodeID:    nodeInfo.ID,
 		Nodes:     nodes,
+		Labels:    nodeInfo.Labels,
+	}
+	if snapshot.Labels == nil {
+		snapshot.Labels = map[string]string{}
 	}
 
 	// Send snapshot to reporting server
@@ -137,18 +148,20 @@ func (rs *ReportingService) SendReport(ctx context.Context) error {
 	return nil
 }
 
-func (rs *ReportingService) GenerateHTMLReport() (string, error) {
+// GenerateReport collects the data shown on the dashboard, limited to the
+// nodes in scope
+func (rs *ReportingService) GenerateReport(ctx context.Context, scope domain.ReportScope) (*domain.Report, error) {
 	// Get all known nodes
-	ctx := context.Background()
-	nodes, err := rs.nodeService.GetKnownNodes(ctx)
+	known, err := rs.nodeService.GetKnownNodes(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to get known nodes: %w", err)
+		return nil, fmt.Errorf("failed to get known nodes: %w", err)
 	}
+	nodes := filterNodes(known, scope.Labels)
 
 	// Get node info
 	nodeInfo, err := rs.configSvc.GetNodeInfo()
 	if err != nil {
-		return "", fmt.Errorf("failed to get node info: %w", err)
+		return nil, fmt.Errorf("failed to get node info: %w", err)
 	}
 
 	// Get recent poll results
@@ -159,24 +172,53 @@ func (rs *ReportingService) GenerateHTMLReport() (string, error) {
 		pollResults = []domain.PollResult{}
 	}
 
-	// Create report data structure
-	reportData := struct {
-		GeneratedAt   string
-		ReportingNode domain.NodeInfo
-		Nodes         []domain.Node
-		PollResults   []domain.PollResult
-		TotalNodes    int
-		ActiveNodes   int
-		InactiveNodes int
-		SuccessRate   float64
-	}{
-		GeneratedAt:   time.Now().Format("2006-01-02 15:04:05 UTC"),
+	alerts, err := rs.GetAlerts(ctx, since)
+	if err != nil {
+		log.Printf("Warning: failed to get alerts: %v", err)
+	}
+
+	flapping, err := rs.GetFlapStates(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to get flap states: %v", err)
+		flapping = &domain.FlapReport{Nodes: []domain.FlapState{}, Links: []domain.FlapState{}}
+	}
+
+	anomalies, err := rs.anomalies.GetAnomalies(ctx, since)
+	if err != nil {
+		log.Printf("Warning: failed to get latency anomalies: %v", err)
+	}
+
+	report := &domain.Report{
+		GeneratedAt:   time.Now(),
 		ReportingNode: *nodeInfo,
 		Nodes:         nodes,
 		PollResults:   pollResults,
 		TotalNodes:    len(nodes),
+		Alerts:        alerts,
+		Flapping:      flapping,
+		Anomalies:     anomalies,
+		Labels:        scope.Labels,
+		GroupBy:       scope.GroupBy,
+	}
+	if len(scope.Labels) > 0 {
+		scopeReport(report)
+	}
+	if scope.GroupBy != "" {
+		report.Groups = groupNodes(report.Nodes, scope.GroupBy)
+	}
+
+	// Observers outside the scope still place the polls they made
+	nodeLabels := nodeLabelsByID(nodeInfo, known)
+	for _, label := range domain.LocalityLabels {
+		matrix := localityMatrix(label, report.PollResults, nodeLabels, nodeInfo.ID, since, report.GeneratedAt)
+		if len(matrix.Links) > 0 {
+			report.LocalityMatrices = append(report.LocalityMatrices, *matrix)
+		}
 	}
 
+	// The rest of the report describes this node's own polls
+	report.PollResults = ownPollResults(report.PollResults)
+
 	// Calculate statistics
 	activeCount := 0
 	for _, node := range nodes {
@@ -184,249 +226,81 @@ func (rs *ReportingService) GenerateHTMLReport() (string, error) {
 			activeCount++
 		}
 	}
-	reportData.ActiveNodes = activeCount
-	reportData.InactiveNodes = len(nodes) - activeCount
+	report.ActiveNodes = activeCount
+	report.InactiveNodes = len(nodes) - activeCount
 
 	// Calculate success rate from recent polls
-	if len(pollResults) > 0 {
+	if len(report.PollResults) > 0 {
 		successCount := 0
-		for _, result := range pollResults {
+		for _, result := range report.PollResults {
 			if result.Success {
 				successCount++
 			}
 		}
-		reportData.SuccessRate = float64(successCount) / float64(len(pollResults)) * 100
+		report.SuccessRate = float64(successCount) / float64(len(report.PollResults)) * 100
 	}
 
-	// Generate HTML report
-	html, err := rs.generateHTMLFromTemplate(reportData)
+	return report, nil
+}
+
+// maxAlertHistory bounds how many resolved alerts a report or API call returns
+const maxAlertHistory = 100
+
+// GetAlerts returns the firing alerts followed by the other alerts fired
+// since the given time, newest first
+func (rs *ReportingService) GetAlerts(ctx context.Context, since time.Time) ([]domain.Alert, error) {
+	alerts := []domain.Alert{}
+
+	firing, err := rs.alertRepo.GetFiringAlerts(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate HTML from template: %w", err)
+		return alerts, fmt.Errorf("failed to get firing alerts: %w", err)
+	}
+	for i := len(firing) - 1; i >= 0; i-- {
+		alerts = append(alerts, firing[i])
 	}
 
-	return html, nil
+	history, err := rs.alertRepo.GetAlertHistory(ctx, since, maxAlertHistory)
+	if err != nil {
+		return alerts, fmt.Errorf("failed to get alert history: %w", err)
+	}
+	for _, alert := range history {
+		if alert.State != domain.AlertStateFiring {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	return alerts, nil
 }
 
-func (rs *ReportingService) generateHTMLFromTemplate(data interface{}) (string, error) {
-	const htmlTemplate = `
-<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>NodeProbe Network Report</title>
-    <style>
-        body {
-            font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
-            margin: 0;
-            padding: 20px;
-            background-color: #f5f5f5;
-        }
-        .container {
-            max-width: 1200px;
-            margin: 0 auto;
-            background-color: white;
-            border-radius: 8px;
-            box-shadow: 0 2px 10px rgba(0,0,0,0.1);
-            padding: 30px;
-        }
-        h1 {
-            color: #333;
-            border-bottom: 3px solid #007acc;
-            padding-bottom: 10px;
-        }
-        h2 {
-            color: #555;
-            margin-top: 30px;
-        }
-        .stats {
-            display: flex;
-            gap: 20px;
-            margin: 20px 0;
-            flex-wrap: wrap;
-        }
-        .stat-card {
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            color: white;
-            padding: 20px;
-            border-radius: 8px;
-            text-align: center;
-            min-width: 150px;
-            flex: 1;
-        }
-        .stat-value {
-            font-size: 2em;
-            font-weight: bold;
-            display: block;
-        }
-        .stat-label {
-            font-size: 0.9em;
-            opacity: 0.9;
-        }
-        table {
-            width: 100%;
-            border-collapse: collapse;
-            margin-top: 20px;
-        }
-        th, td {
-            padding: 12px;
-            text-align: left;
-            border-bottom: 1px solid #ddd;
-        }
-        th {
-            background-color: #f8f9fa;
-            font-weight: 600;
-            color: #333;
-        }
-        tr:hover {
-            background-color: #f8f9fa;
-        }
-        .status-active {
-            color: #28a745;
-            font-weight: bold;
-        }
-        .status-inactive {
-            color: #dc3545;
-            font-weight: bold;
-        }
-        .success {
-            color: #28a745;
-        }
-        .failure {
-            color: #dc3545;
-        }
-        .timestamp {
-            color: #666;
-            font-size: 0.9em;
-        }
-        .node-id {
-            font-family: monospace;
-            background-color: #f8f9fa;
-            padding: 2px 6px;
-            border-radius: 4px;
-            font-size: 0.9em;
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <h1>🌐 NodeProbe Network Report</h1>
-        
-        <div class="timestamp">
-            <strong>Generated:</strong> {{.GeneratedAt}}<br>
-            <strong>Reporting Node:</strong> {{.ReportingNode.ID}} ({{.ReportingNode.FQDN}})
-        </div>
-
-        <div class="stats">
-            <div class="stat-card">
-                <span class="stat-value">{{.TotalNodes}}</span>
-                <span class="stat-label">Total Nodes</span>
-            </div>
-            <div class="stat-card">
-                <span class="stat-value">{{.ActiveNodes}}</span>
-                <span class="stat-label">Active Nodes</span>
-            </div>
-            <div class="stat-card">
-                <span class="stat-value">{{.InactiveNodes}}</span>
-                <span class="stat-label">Inactive Nodes</span>
-            </div>
-            <div class="stat-card">
-                <span class="stat-value">{{printf "%.1f%%" .SuccessRate}}</span>
-                <span class="stat-label">Success Rate (24h)</span>
-            </div>
-        </div>
-
-        <h2>📊 Network Nodes</h2>
-        <table>
-            <thead>
-                <tr>
-                    <th>Node ID</th>
-                    <th>FQDN</th>
-                    <th>IP Address</th>
-                    <th>Status</th>
-                    <th>Discovered By</th>
-                    <th>First Seen</th>
-                    <th>Last Seen</th>
-                </tr>
-            </thead>
-            <tbody>
-                {{range .Nodes}}
-                <tr>
-                    <td><span class="node-id">{{.ID}}</span></td>
-                    <td>{{.FQDN}}</td>
-                    <td>{{.IP}}</td>
-                    <td>
-                        {{if .IsActive}}
-                            <span class="status-active">●&nbsp;Active</span>
-                        {{else}}
-                            <span class="status-inactive">●&nbsp;Inactive</span>
-                        {{end}}
-                    </td>
-                    <td>{{.DiscoveredBy}}</td>
-                    <td>{{.FirstSeen.Format "2006-01-02 15:04"}}</td>
-                    <td>{{.LastSeen.Format "2006-01-02 15:04"}}</td>
-                </tr>
-                {{end}}
-            </tbody>
-        </table>
-
-        <h2>🔍 Recent Poll Results (Last 24 Hours)</h2>
-        <table>
-            <thead>
-                <tr>
-                    <th>Time</th>
-                    <th>Node ID</th>
-                    <th>Status</th>
-                    <th>Response Time</th>
-                    <th>Path MTU</th>
-                    <th>Error</th>
-                </tr>
-            </thead>
-            <tbody>
-                {{range .PollResults}}
-                <tr>
-                    <td>{{.PollTime.Format "01-02 15:04:05"}}</td>
-                    <td><span class="node-id">{{.NodeID}}</span></td>
-                    <td>
-                        {{if .Success}}
-                            <span class="success">✓ Success</span>
-                        {{else}}
-                            <span class="failure">✗ Failed</span>
-                        {{end}}
-                    </td>
-                    <td>{{.ResponseMs}}ms</td>
-                    <td>
-                        {{if .PathMTU}}
-                            {{.PathMTU}} bytes
-                        {{else}}
-                            -
-                        {{end}}
-                    </td>
-                    <td>{{.Error}}</td>
-                </tr>
-                {{end}}
-            </tbody>
-        </table>
-
-        <div class="timestamp" style="margin-top: 40px; text-align: center; border-top: 1px solid #ddd; padding-top: 20px;">
-            <em>NodeProbe Distributed Network Monitor</em>
-        </div>
-    </div>
-</body>
-</html>
-`
-
-	tmpl, err := template.New("report").Parse(htmlTemplate)
+func (rs *ReportingService) GenerateHTMLReport(ctx context.Context, scope domain.ReportScope) (string, error) {
+	if rs.renderer == nil {
+		return "", domain.ErrHTMLDisabled
+	}
+
+	report, err := rs.GenerateReport(ctx, scope)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %w", err)
+		return "", err
+	}
+
+	// Ungrouped nodes are shown as one group without a heading
+	pageData := struct {
+		*domain.Report
+		NodeGroups []domain.NodeGroup
+	}{
+		Report:     report,
+		NodeGroups: report.Groups,
+	}
+	if scope.GroupBy == "" {
+		pageData.NodeGroups = []domain.NodeGroup{{Nodes: report.Nodes, ActiveNodes: report.ActiveNodes}}
 	}
 
-	var buf strings.Builder
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("failed to execute template: %w", err)
+	// Generate HTML report
+	html, err := rs.renderer.Render("dashboard.html", pageData)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate HTML from template: %w", err)
 	}
 
-	return buf.String(), nil
+	return html, nil
 }
 
 // IsRunning returns whether the reporting service is currently running
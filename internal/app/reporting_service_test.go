@@ -0,0 +1,420 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+	"nodeprobe/internal/pkg/memory"
+)
+
+// newTestReportingService returns a reporting service with two known peers
+func newTestReportingService(t *testing.T, config *fakeConfig) (*ReportingService, *memory.Store, *fakeHTTPClient) {
+	t.Helper()
+	ctx := context.Background()
+
+	ns, store := newTestNodeService(t, config)
+	for _, node := range []domain.Node{testNode("peer-a", "10.0.0.2"), testNode("peer-b", "10.0.0.3")} {
+		if err := ns.addOrUpdateNode(ctx, &node); err != nil {
+			t.Fatalf("addOrUpdateNode: %v", err)
+		}
+	}
+	if err := ns.UpdateNodeStatus(ctx, "peer-b", false); err != nil {
+		t.Fatalf("UpdateNodeStatus: %v", err)
+	}
+
+	client := newFakeHTTPClient()
+	anomalies := newTestAnomalyService(t, store, config)
+	return NewReportingService(ns, client, config, store, store, anomalies, nil), store, client
+}
+
+func TestGenerateReport(t *testing.T) {
+	ctx := context.Background()
+	rs, store, _ := newTestReportingService(t, newFakeConfig("self"))
+
+	now := time.Now()
+	results := []domain.PollResult{
+		{NodeID: "peer-a", PollTime: now.Add(-time.Minute), Success: true, ResponseMs: 10},
+		{NodeID: "peer-a", PollTime: now.Add(-2 * time.Minute), Success: true, ResponseMs: 12},
+		{NodeID: "peer-b", PollTime: now.Add(-3 * time.Minute), Success: false, Error: "timeout"},
+		{NodeID: "peer-b", PollTime: now.Add(-4 * time.Minute), Success: true, ResponseMs: 20},
+		{NodeID: "peer-a", PollTime: now.Add(-48 * time.Hour), Success: false}, // outside the report window
+	}
+	if err := store.CreatePollResults(ctx, results); err != nil {
+		t.Fatalf("CreatePollResults: %v", err)
+	}
+
+	report, err := rs.GenerateReport(ctx, domain.ReportScope{})
+	if err != nil {
+		t.Fatalf("GenerateReport: %v", err)
+	}
+
+	if report.TotalNodes != 2 || report.ActiveNodes != 1 || report.InactiveNodes != 1 {
+		t.Errorf("node counts = %d/%d/%d, want 2 total, 1 active, 1 inactive",
+			report.TotalNodes, report.ActiveNodes, report.InactiveNodes)
+	}
+	if len(report.PollResults) != 4 {
+		t.Errorf("report has %d poll results, want the 4 from the last 24h", len(report.PollResults))
+	}
+	if report.SuccessRate != 75 {
+		t.Errorf("success rate = %v, want 75", report.SuccessRate)
+	}
+	if report.ReportingNode.ID != "self" {
+		t.Errorf("reporting node = %q, want self", report.ReportingNode.ID)
+	}
+}
+
+func TestGenerateReportScope(t *testing.T) {
+	ctx := context.Background()
+	config := newFakeConfig("self")
+	rs, store, _ := newTestReportingService(t, config)
+
+	for _, node := range []domain.Node{testNode("peer-a", "10.0.0.2"), testNode("peer-b", "10.0.0.3"), testNode("peer-c", "10.0.0.4")} {
+		node.Labels = map[string]string{"region": "eu", "zone": "a"}
+		switch node.ID {
+		case "peer-b":
+			node.Labels["zone"] = "b"
+		case "peer-c":
+			node.Labels = map[string]string{"region": "us"}
+		}
+		if err := rs.nodeService.(*NodeService).addOrUpdateNode(ctx, &node); err != nil {
+			t.Fatalf("addOrUpdateNode: %v", err)
+		}
+	}
+
+	now := time.Now()
+	results := []domain.PollResult{
+		{NodeID: "peer-a", PollTime: now.Add(-time.Minute), Success: true, ResponseMs: 10},
+		{NodeID: "peer-b", PollTime: now.Add(-time.Minute), Success: false, Error: "timeout"},
+		{NodeID: "peer-c", PollTime: now.Add(-time.Minute), Success: false, Error: "timeout"},
+	}
+	if err := store.CreatePollResults(ctx, results); err != nil {
+		t.Fatalf("CreatePollResults: %v", err)
+	}
+	alert := domain.Alert{Rule: "down", NodeID: "peer-c", State: domain.AlertStateFiring, FiredAt: now}
+	if err := store.SaveAlert(ctx, &alert); err != nil {
+		t.Fatalf("SaveAlert: %v", err)
+	}
+
+	report, err := rs.GenerateReport(ctx, domain.ReportScope{Labels: map[string]string{"region": "eu"}, GroupBy: "zone"})
+	if err != nil {
+		t.Fatalf("GenerateReport: %v", err)
+	}
+
+	if report.TotalNodes != 2 || len(report.PollResults) != 2 || report.SuccessRate != 50 {
+		t.Errorf("report has %d nodes, %d polls at %v%%, want 2 nodes, 2 polls at 50%%",
+			report.TotalNodes, len(report.PollResults), report.SuccessRate)
+	}
+	if len(report.Alerts) != 0 {
+		t.Errorf("report alerts = %+v, want none outside the scope", report.Alerts)
+	}
+	if len(report.Groups) != 2 || report.Groups[0].Value != "a" || report.Groups[1].Value != "b" {
+		t.Errorf("groups = %+v, want zones a and b", report.Groups)
+	}
+
+	// Nodes without the label are grouped last
+	report, err = rs.GenerateReport(ctx, domain.ReportScope{GroupBy: "zone"})
+	if err != nil {
+		t.Fatalf("GenerateReport: %v", err)
+	}
+	last := report.Groups[len(report.Groups)-1]
+	if last.Value != "" || len(last.Nodes) != 1 || last.Nodes[0].ID != "peer-c" {
+		t.Errorf("last group = %+v, want peer-c, which has no zone", last)
+	}
+}
+
+func TestGetAlerts(t *testing.T) {
+	ctx := context.Background()
+	rs, store, _ := newTestReportingService(t, newFakeConfig("self"))
+
+	now := time.Now()
+	resolvedAt := now.Add(-time.Hour)
+	for _, alert := range []domain.Alert{
+		{Rule: "down", NodeID: "peer-b", State: domain.AlertStateFiring, FiredAt: now.Add(-48 * time.Hour)},
+		{Rule: "slow", NodeID: "peer-a", State: domain.AlertStateResolved, FiredAt: now.Add(-2 * time.Hour), ResolvedAt: &resolvedAt},
+		{Rule: "slow", NodeID: "peer-a", State: domain.AlertStateResolved, FiredAt: now.Add(-30 * time.Hour), ResolvedAt: &resolvedAt},
+	} {
+		if err := store.SaveAlert(ctx, &alert); err != nil {
+			t.Fatalf("SaveAlert: %v", err)
+		}
+	}
+
+	report, err := rs.GenerateReport(ctx, domain.ReportScope{})
+	if err != nil {
+		t.Fatalf("GenerateReport: %v", err)
+	}
+	if len(report.Alerts) != 2 || report.Alerts[0].Rule != "down" || report.Alerts[1].Rule != "slow" {
+		t.Errorf("report alerts = %+v, want the firing alert, then the one resolved within 24h", report.Alerts)
+	}
+}
+
+func TestGenerateHTMLReportDisabled(t *testing.T) {
+	rs, _, _ := newTestReportingService(t, newFakeConfig("self"))
+
+	if _, err := rs.GenerateHTMLReport(context.Background(), domain.ReportScope{}); !errors.Is(err, domain.ErrHTMLDisabled) {
+		t.Errorf("GenerateHTMLReport error = %v, want ErrHTMLDisabled", err)
+	}
+}
+
+func TestSendReport(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("not configured", func(t *testing.T) {
+		rs, _, client := newTestReportingService(t, newFakeConfig("self"))
+		if err := rs.SendReport(ctx); err != nil {
+			t.Fatalf("SendReport: %v", err)
+		}
+		if len(client.snapshots) != 0 {
+			t.Errorf("snapshots sent without a reporting server: %v", client.snapshots)
+		}
+	})
+
+	t.Run("by FQDN", func(t *testing.T) {
+		config := newFakeConfig("self")
+		config.reporting = &domain.ReportingConfig{ServerFQDN: "reporting.example.com", ServerIP: "10.0.0.100"}
+		rs, _, client := newTestReportingService(t, config)
+
+		if err := rs.SendReport(ctx); err != nil {
+			t.Fatalf("SendReport: %v", err)
+		}
+		sent := client.snapshots["https://reporting.example.com:443"]
+		if len(sent) != 1 {
+			t.Fatalf("snapshots = %v, want one sent to the FQDN", client.snapshots)
+		}
+		if sent[0].NodeID != "self" || len(sent[0].Nodes) != 2 {
+			t.Errorf("snapshot = %+v, want self with 2 nodes", sent[0])
+		}
+	})
+
+	t.Run("by IP", func(t *testing.T) {
+		config := newFakeConfig("self")
+		config.reporting = &domain.ReportingConfig{ServerFQDN: "unknown", ServerIP: "10.0.0.100"}
+		rs, _, client := newTestReportingService(t, config)
+
+		if err := rs.SendReport(ctx); err != nil {
+			t.Fatalf("SendReport: %v", err)
+		}
+		if len(client.snapshots["https://10.0.0.100:443"]) != 1 {
+			t.Errorf("snapshots = %v, want one sent to the IP", client.snapshots)
+		}
+	})
+}
+
+func TestGenerateNodeDetail(t *testing.T) {
+	ctx := context.Background()
+	rs, store, _ := newTestReportingService(t, newFakeConfig("self"))
+
+	now := time.Now()
+	results := []domain.PollResult{
+		{NodeID: "peer-a", PollTime: now.Add(-50 * time.Minute), Success: true, ResponseMs: 10, PathMTU: 1500},
+		{NodeID: "peer-a", PollTime: now.Add(-40 * time.Minute), Success: false, Error: "timeout"},
+		{NodeID: "peer-a", PollTime: now.Add(-30 * time.Minute), Success: false, Error: "timeout"},
+		{NodeID: "peer-a", PollTime: now.Add(-20 * time.Minute), Success: true, ResponseMs: 30, PathMTU: 1400},
+		{NodeID: "peer-b", PollTime: now.Add(-10 * time.Minute), Success: true, ResponseMs: 99},
+	}
+	if err := store.CreatePollResults(ctx, results); err != nil {
+		t.Fatalf("CreatePollResults: %v", err)
+	}
+
+	detail, err := rs.GenerateNodeDetail(ctx, "peer-a", "1h")
+	if err != nil {
+		t.Fatalf("GenerateNodeDetail: %v", err)
+	}
+
+	if detail.TotalPolls != 4 || detail.Successes != 2 || detail.SuccessRate != 50 {
+		t.Errorf("totals = %d polls, %d successes, %v%%; want 4, 2, 50%%",
+			detail.TotalPolls, detail.Successes, detail.SuccessRate)
+	}
+	if detail.BucketSize != "1m0s" {
+		t.Errorf("bucket size = %s, want 1m0s", detail.BucketSize)
+	}
+
+	var polls, successes int
+	for _, bucket := range detail.Buckets {
+		polls += bucket.Polls
+		successes += bucket.Successes
+	}
+	if polls != 4 || successes != 2 {
+		t.Errorf("buckets hold %d polls and %d successes, want 4 and 2", polls, successes)
+	}
+
+	if len(detail.Errors) != 1 || detail.Errors[0].Error != "timeout" || detail.Errors[0].Count != 2 {
+		t.Errorf("errors = %+v, want 2 timeouts", detail.Errors)
+	}
+	if len(detail.MTUHistory) != 2 || detail.MTUHistory[1].PathMTU != 1400 {
+		t.Errorf("MTU history = %+v, want 1500 then 1400", detail.MTUHistory)
+	}
+}
+
+func TestGenerateNodeDetailErrors(t *testing.T) {
+	ctx := context.Background()
+	rs, _, _ := newTestReportingService(t, newFakeConfig("self"))
+
+	if _, err := rs.GenerateNodeDetail(ctx, "peer-a", "3w"); !errors.Is(err, domain.ErrInvalidRange) {
+		t.Errorf("invalid range error = %v, want ErrInvalidRange", err)
+	}
+	if _, err := rs.GenerateNodeDetail(ctx, "missing", "24h"); !errors.Is(err, domain.ErrNodeNotFound) {
+		t.Errorf("unknown node error = %v, want ErrNodeNotFound", err)
+	}
+}
+
+func TestGetNodeRollups(t *testing.T) {
+	ctx := context.Background()
+	rs, store, _ := newTestReportingService(t, newFakeConfig("self"))
+
+	hour := time.Now().Truncate(time.Hour).Add(-time.Hour)
+	results := []domain.PollResult{
+		{NodeID: "peer-a", PollTime: hour.Add(time.Minute), Success: true, ResponseMs: 10},
+		{NodeID: "peer-a", PollTime: hour.Add(2 * time.Minute), Success: true, ResponseMs: 30},
+		{NodeID: "peer-a", PollTime: hour.Add(3 * time.Minute), Success: false},
+	}
+	rollups := rollupResults(results, time.Minute)
+	if err := store.SaveRollups(ctx, domain.RollupHour, mergeRollups(rollups, time.Hour), hour.Add(time.Hour)); err != nil {
+		t.Fatalf("SaveRollups: %v", err)
+	}
+
+	got, err := rs.GetNodeRollups(ctx, "peer-a", domain.RollupHour, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("GetNodeRollups: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("rollups = %+v, want one hour", got)
+	}
+	if got[0].Polls != 3 || got[0].Successes != 2 || got[0].MinMs != 10 || got[0].MaxMs != 30 || got[0].AvgMs != 20 {
+		t.Errorf("rollup = %+v", got[0])
+	}
+
+	if _, err := rs.GetNodeRollups(ctx, "peer-a", "5m", time.Hour); !errors.Is(err, domain.ErrInvalidRange) {
+		t.Errorf("invalid resolution error = %v, want ErrInvalidRange", err)
+	}
+	if _, err := rs.GetNodeRollups(ctx, "missing", domain.RollupHour, time.Hour); !errors.Is(err, domain.ErrNodeNotFound) {
+		t.Errorf("unknown node error = %v, want ErrNodeNotFound", err)
+	}
+}
+
+func TestGetFlapStates(t *testing.T) {
+	ctx := context.Background()
+	rs, store, _ := newTestReportingService(t, newFakeConfig("self"))
+
+	now := time.Now()
+	var results []domain.PollResult
+	for i := 0; i < 30; i++ {
+		at := now.Add(time.Duration(i-30) * time.Minute)
+		results = append(results,
+			domain.PollResult{NodeID: "peer-a", PollTime: at, Success: i%2 == 0},
+			domain.PollResult{NodeID: "peer-a", PollTime: at, Success: true, Observer: "peer-b"},
+			domain.PollResult{NodeID: "peer-b", PollTime: at, Success: i < 20},
+		)
+	}
+	if err := store.CreatePollResults(ctx, results); err != nil {
+		t.Fatalf("CreatePollResults: %v", err)
+	}
+
+	report, err := rs.GetFlapStates(ctx)
+	if err != nil {
+		t.Fatalf("GetFlapStates: %v", err)
+	}
+
+	want := []struct {
+		observer string
+		nodeID   string
+		flapping bool
+	}{
+		{"peer-b", "peer-a", false},
+		{"self", "peer-a", true},
+		{"self", "peer-b", false},
+	}
+	if len(report.Links) != len(want) {
+		t.Fatalf("links = %+v, want %d", report.Links, len(want))
+	}
+	for i, w := range want {
+		link := report.Links[i]
+		if link.Observer != w.observer || link.NodeID != w.nodeID || link.Flapping != w.flapping {
+			t.Errorf("link %d = %+v, want %s to %s flapping %v", i, link, w.observer, w.nodeID, w.flapping)
+		}
+	}
+	if since := report.Links[1].Since; since == nil || !since.Before(now.Add(-20*time.Minute)) {
+		t.Errorf("flapping since %v, want when the history first scored high", since)
+	}
+
+	// peer-b went down once while the report was set up
+	if len(report.Nodes) != 1 || report.Nodes[0].NodeID != "peer-b" || report.Nodes[0].Flapping {
+		t.Errorf("nodes = %+v, want peer-b not flapping", report.Nodes)
+	}
+}
+
+func TestGetLocalityMatrix(t *testing.T) {
+	ctx := context.Background()
+	config := newFakeConfig("self")
+	config.labels = map[string]string{"region": "eu-west"}
+	rs, store, _ := newTestReportingService(t, config)
+
+	regions := map[string]string{"peer-a": "eu-west", "peer-b": "us-east", "peer-c": "us-east"}
+	for id, region := range regions {
+		node := testNode(id, "10.0.0.9")
+		if existing, err := rs.nodeService.GetNodeByID(ctx, id); err == nil {
+			node = *existing
+		}
+		node.Labels = map[string]string{"region": region}
+		if err := rs.nodeService.(*NodeService).addOrUpdateNode(ctx, &node); err != nil {
+			t.Fatalf("addOrUpdateNode: %v", err)
+		}
+	}
+
+	now := time.Now()
+	var results []domain.PollResult
+	for i := 0; i < 10; i++ {
+		at := now.Add(time.Duration(i-10) * time.Minute)
+		results = append(results,
+			domain.PollResult{NodeID: "peer-a", PollTime: at, Success: true, ResponseMs: 2},
+			domain.PollResult{NodeID: "peer-b", PollTime: at, Success: i != 0, ResponseMs: 90},
+			domain.PollResult{NodeID: "peer-c", PollTime: at, Success: true, ResponseMs: 100},
+			domain.PollResult{NodeID: "peer-a", PollTime: at, Success: true, ResponseMs: 95, Observer: "peer-b"},
+			domain.PollResult{NodeID: "stranger", PollTime: at, Success: true, ResponseMs: 5},
+		)
+	}
+	if err := store.CreatePollResults(ctx, results); err != nil {
+		t.Fatalf("CreatePollResults: %v", err)
+	}
+
+	matrix, err := rs.GetLocalityMatrix(ctx, "region", now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetLocalityMatrix: %v", err)
+	}
+
+	if len(matrix.Localities) != 2 || matrix.Localities[0] != "eu-west" || matrix.Localities[1] != "us-east" {
+		t.Errorf("localities = %v, want eu-west and us-east", matrix.Localities)
+	}
+	if len(matrix.Links) != 3 || matrix.UnlabelledPolls != 10 {
+		t.Errorf("matrix has %d links and %d unlabelled polls, want 3 and 10", len(matrix.Links), matrix.UnlabelledPolls)
+	}
+
+	// Both us-east nodes polled from eu-west share one cell
+	transatlantic := matrix.Link("eu-west", "us-east")
+	if transatlantic == nil || transatlantic.Paths != 2 || transatlantic.Polls != 20 || transatlantic.LossPercent != 5 {
+		t.Fatalf("eu-west to us-east = %+v, want 20 polls over 2 paths with 5%% loss", transatlantic)
+	}
+	if transatlantic.P50Ms < 89 || transatlantic.P95Ms < 99 || transatlantic.P95Ms > 101 {
+		t.Errorf("eu-west to us-east p50/p95 = %v/%v, want about 90/100", transatlantic.P50Ms, transatlantic.P95Ms)
+	}
+	if back := matrix.Link("us-east", "eu-west"); back == nil || back.Polls != 10 || back.AvgMs != 95 {
+		t.Errorf("us-east to eu-west = %+v, want the 10 imported polls at 95ms", back)
+	}
+	if local := matrix.Link("eu-west", "eu-west"); local == nil || local.P99Ms > 3 {
+		t.Errorf("eu-west to eu-west = %+v, want the polls of peer-a at 2ms", local)
+	}
+	if matrix.Link("us-east", "us-east") != nil {
+		t.Errorf("us-east to us-east has a link, want none without polls")
+	}
+
+	// The dashboard shows the region matrix; no node has a zone
+	report, err := rs.GenerateReport(ctx, domain.ReportScope{})
+	if err != nil {
+		t.Fatalf("GenerateReport: %v", err)
+	}
+	if len(report.LocalityMatrices) != 1 || report.LocalityMatrices[0].Label != "region" {
+		t.Errorf("report matrices = %+v, want only region", report.LocalityMatrices)
+	}
+}
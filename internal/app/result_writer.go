@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// resultWriter stores poll results from a background goroutine, batching
+// them into one transaction per flush so pollers never wait on the
+// database write lock
+type resultWriter struct {
+	pollRepo domain.PollRepository
+	results  chan domain.PollResult
+	done     chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+}
+
+const (
+	resultBatchSize     = 256
+	resultQueueSize     = 4096
+	resultFlushInterval = 1 * time.Second
+	resultFlushTimeout  = 10 * time.Second
+)
+
+func newResultWriter(pollRepo domain.PollRepository) *resultWriter {
+	return &resultWriter{
+		pollRepo: pollRepo,
+		results:  make(chan domain.PollResult, resultQueueSize),
+		done:     make(chan struct{}),
+	}
+}
+
+// run writes queued results until close is called, then flushes the rest
+func (w *resultWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(resultFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]domain.PollResult, 0, resultBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), resultFlushTimeout)
+		defer cancel()
+
+		if err := w.pollRepo.CreatePollResults(ctx, batch); err != nil {
+			log.Printf("Failed to store %d poll results: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case result, ok := <-w.results:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, result)
+			if len(batch) >= resultBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// enqueue queues a result for the next flush, waiting while the queue is full
+func (w *resultWriter) enqueue(ctx context.Context, result *domain.PollResult) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return fmt.Errorf("poll result writer is closed")
+	}
+
+	select {
+	case w.results <- *result:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close stops accepting results and waits until the queue has been written
+func (w *resultWriter) close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.results)
+	w.mu.Unlock()
+
+	<-w.done
+}
@@ -0,0 +1,305 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// RetentionService rolls raw poll results up into 1-minute and 1-hour
+// aggregates and expires each tier after its configured window
+type RetentionService struct {
+	pollRepo  domain.PollRepository
+	configSvc domain.ConfigService
+
+	rawRetention    time.Duration
+	minuteRetention time.Duration
+	hourRetention   time.Duration
+	lastPrune       time.Time
+
+	running  bool
+	stopChan chan struct{}
+	mu       sync.RWMutex
+}
+
+// Rollups are computed at most this much source time per query, so catching
+// up after downtime or an upgrade never loads the whole history at once
+const (
+	minuteRollupBatch = 1 * time.Hour
+	hourRollupBatch   = 24 * time.Hour
+)
+
+func NewRetentionService(pollRepo domain.PollRepository, configSvc domain.ConfigService) *RetentionService {
+	return &RetentionService{
+		pollRepo:  pollRepo,
+		configSvc: configSvc,
+		stopChan:  make(chan struct{}),
+	}
+}
+
+func (rs *RetentionService) Start(ctx context.Context) error {
+	rs.mu.Lock()
+	if rs.running {
+		rs.mu.Unlock()
+		return fmt.Errorf("retention service is already running")
+	}
+
+	config, err := rs.configSvc.LoadRetentionConfig()
+	if err != nil {
+		rs.mu.Unlock()
+		return fmt.Errorf("failed to load retention config: %w", err)
+	}
+	rs.rawRetention = time.Duration(config.RawRetentionHours) * time.Hour
+	rs.minuteRetention = time.Duration(config.MinuteRollupRetentionDays) * 24 * time.Hour
+	rs.hourRetention = time.Duration(config.HourRollupRetentionDays) * 24 * time.Hour
+	rs.running = true
+	rs.mu.Unlock()
+
+	log.Printf("Starting retention service (raw %v, 1m rollups %v, 1h rollups %v)...",
+		rs.rawRetention, rs.minuteRetention, rs.hourRetention)
+
+	go rs.retentionLoop(ctx)
+
+	return nil
+}
+
+func (rs *RetentionService) Stop() error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if !rs.running {
+		return fmt.Errorf("retention service is not running")
+	}
+
+	log.Println("Stopping retention service...")
+	rs.running = false
+	close(rs.stopChan)
+
+	return nil
+}
+
+func (rs *RetentionService) retentionLoop(ctx context.Context) {
+	ticker := time.NewTicker(domain.RollupInterval)
+	defer ticker.Stop()
+
+	rs.runOnce(ctx, time.Now())
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Retention service stopped due to context cancellation")
+			return
+		case <-rs.stopChan:
+			log.Println("Retention service stopped")
+			return
+		case now := <-ticker.C:
+			rs.runOnce(ctx, now)
+		}
+	}
+}
+
+func (rs *RetentionService) runOnce(ctx context.Context, now time.Time) {
+	if err := rs.Rollup(ctx, now); err != nil {
+		log.Printf("Error rolling up poll results: %v", err)
+	}
+
+	if now.Sub(rs.lastPrune) >= domain.RetentionInterval {
+		if err := rs.Prune(ctx, now); err != nil {
+			log.Printf("Error applying retention: %v", err)
+		}
+		rs.lastPrune = now
+	}
+}
+
+// Rollup aggregates every complete minute of raw results not yet rolled up,
+// then every complete hour of minute rollups
+func (rs *RetentionService) Rollup(ctx context.Context, now time.Time) error {
+	minuteUntil := now.Add(-domain.RollupDelay).Truncate(time.Minute)
+	if err := rs.rollupRange(ctx, domain.RollupMinute, minuteUntil, rs.rawRetention, minuteRollupBatch,
+		func(since, until time.Time) ([]domain.PollRollup, error) {
+			results, err := rs.pollRepo.GetPollResultsBetween(ctx, since, until)
+			if err != nil {
+				return nil, err
+			}
+			return rollupResults(results, time.Minute), nil
+		}); err != nil {
+		return fmt.Errorf("failed to roll up minutes: %w", err)
+	}
+
+	// Hours are only complete once every minute in them has been rolled up
+	minuteWatermark, err := rs.pollRepo.GetRollupWatermark(ctx, domain.RollupMinute)
+	if err != nil {
+		return err
+	}
+	hourUntil := minuteWatermark.Truncate(time.Hour)
+	if err := rs.rollupRange(ctx, domain.RollupHour, hourUntil, rs.minuteRetention, hourRollupBatch,
+		func(since, until time.Time) ([]domain.PollRollup, error) {
+			minutes, err := rs.pollRepo.GetRollups(ctx, domain.RollupMinute, "", since, until)
+			if err != nil {
+				return nil, err
+			}
+			return mergeRollups(minutes, time.Hour), nil
+		}); err != nil {
+		return fmt.Errorf("failed to roll up hours: %w", err)
+	}
+
+	return nil
+}
+
+// rollupRange advances the watermark of resolution to until in batches.
+// Sources older than sourceRetention have already been deleted, so a
+// missing or stale watermark starts from there.
+func (rs *RetentionService) rollupRange(ctx context.Context, resolution string, until time.Time,
+	sourceRetention, batch time.Duration, build func(since, until time.Time) ([]domain.PollRollup, error)) error {
+	bucket := domain.RollupResolutions[resolution]
+
+	start, err := rs.pollRepo.GetRollupWatermark(ctx, resolution)
+	if err != nil {
+		return err
+	}
+	if earliest := until.Add(-sourceRetention).Truncate(bucket); start.Before(earliest) {
+		start = earliest
+	}
+
+	for start.Before(until) {
+		end := start.Add(batch)
+		if end.After(until) {
+			end = until
+		}
+
+		rollups, err := build(start, end)
+		if err != nil {
+			return err
+		}
+		if err := rs.pollRepo.SaveRollups(ctx, resolution, rollups, end); err != nil {
+			return err
+		}
+
+		start = end
+	}
+
+	return nil
+}
+
+// Prune deletes raw results and rollups that have aged out of their window
+// and hands the freed pages back to the filesystem
+func (rs *RetentionService) Prune(ctx context.Context, now time.Time) error {
+	rawDeleted, err := rs.pollRepo.DeletePollResultsBefore(ctx, now.Add(-rs.rawRetention))
+	if err != nil {
+		return err
+	}
+
+	minuteDeleted, err := rs.pollRepo.DeleteRollupsBefore(ctx, domain.RollupMinute, now.Add(-rs.minuteRetention))
+	if err != nil {
+		return err
+	}
+
+	hourDeleted, err := rs.pollRepo.DeleteRollupsBefore(ctx, domain.RollupHour, now.Add(-rs.hourRetention))
+	if err != nil {
+		return err
+	}
+
+	if rawDeleted+minuteDeleted+hourDeleted == 0 {
+		return nil
+	}
+
+	log.Printf("Retention removed %d poll results, %d minute rollups and %d hour rollups",
+		rawDeleted, minuteDeleted, hourDeleted)
+
+	return rs.pollRepo.ReclaimSpace(ctx)
+}
+
+// rollupKey identifies a bucket; times read back from the database may be in
+// a different location, so buckets are keyed by their instant
+type rollupKey struct {
+	nodeID string
+	bucket int64
+}
+
+// rollupResults aggregates this node's own raw poll results into per-node
+// buckets of the given size; imported results from other observers describe
+// other paths and are left out
+func rollupResults(results []domain.PollResult, size time.Duration) []domain.PollRollup {
+	var rollups []domain.PollRollup
+	index := make(map[rollupKey]int)
+
+	for _, result := range ownPollResults(results) {
+		bucket := result.PollTime.Truncate(size)
+		key := rollupKey{nodeID: result.NodeID, bucket: bucket.UnixNano()}
+		i, ok := index[key]
+		if !ok {
+			i = len(rollups)
+			index[key] = i
+			rollups = append(rollups, domain.PollRollup{NodeID: result.NodeID, BucketStart: bucket})
+		}
+
+		rollup := &rollups[i]
+		rollup.Polls++
+		if !result.Success {
+			continue
+		}
+
+		ms := float64(result.ResponseMs)
+		if rollup.Successes == 0 || ms < rollup.MinMs {
+			rollup.MinMs = ms
+		}
+		if ms > rollup.MaxMs {
+			rollup.MaxMs = ms
+		}
+		rollup.Successes++
+		rollup.SumMs += ms
+		rollup.Sketch.Add(ms)
+	}
+
+	return rollups
+}
+
+// mergeRollups combines finer rollups into per-node buckets of the given size
+func mergeRollups(source []domain.PollRollup, size time.Duration) []domain.PollRollup {
+	var rollups []domain.PollRollup
+	index := make(map[rollupKey]int)
+
+	for _, src := range source {
+		bucket := src.BucketStart.Truncate(size)
+		key := rollupKey{nodeID: src.NodeID, bucket: bucket.UnixNano()}
+		i, ok := index[key]
+		if !ok {
+			i = len(rollups)
+			index[key] = i
+			rollups = append(rollups, domain.PollRollup{NodeID: src.NodeID, BucketStart: bucket})
+		}
+
+		rollup := &rollups[i]
+		rollup.Polls += src.Polls
+		if src.Successes == 0 {
+			continue
+		}
+
+		if rollup.Successes == 0 || src.MinMs < rollup.MinMs {
+			rollup.MinMs = src.MinMs
+		}
+		if src.MaxMs > rollup.MaxMs {
+			rollup.MaxMs = src.MaxMs
+		}
+		rollup.Successes += src.Successes
+		rollup.SumMs += src.SumMs
+		rollup.Sketch.Merge(src.Sketch)
+	}
+
+	return rollups
+}
+
+// ownPollResults returns the results of polls this node made itself
+func ownPollResults(results []domain.PollResult) []domain.PollResult {
+	own := make([]domain.PollResult, 0, len(results))
+	for _, result := range results {
+		if result.Observer == "" {
+			own = append(own, result)
+		}
+	}
+	return own
+}
@@ -0,0 +1,146 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+	"nodeprobe/internal/pkg/memory"
+)
+
+// rollupBase is minute- and hour-aligned so buckets are easy to predict
+var rollupBase = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func TestRollupResults(t *testing.T) {
+	east := time.FixedZone("UTC+5:30", 5*60*60+30*60)
+	results := []domain.PollResult{
+		{NodeID: "peer-a", PollTime: rollupBase.Add(5 * time.Second), Success: true, ResponseMs: 30},
+		// The same minute read back in another zone
+		{NodeID: "peer-a", PollTime: rollupBase.Add(40 * time.Second).In(east), Success: true, ResponseMs: 10},
+		{NodeID: "peer-a", PollTime: rollupBase.Add(50 * time.Second), Success: false, Error: "timeout"},
+		{NodeID: "peer-a", PollTime: rollupBase.Add(70 * time.Second), Success: true, ResponseMs: 20},
+		{NodeID: "peer-b", PollTime: rollupBase.Add(10 * time.Second), Success: false, Error: "timeout"},
+		// Imported from another observer
+		{NodeID: "peer-a", PollTime: rollupBase.Add(20 * time.Second), Success: true, ResponseMs: 500, Observer: "other"},
+	}
+
+	rollups := rollupResults(results, time.Minute)
+	if len(rollups) != 3 {
+		t.Fatalf("rollupResults = %+v, want 3 buckets", rollups)
+	}
+
+	first := rollups[0]
+	if first.NodeID != "peer-a" || !first.BucketStart.Equal(rollupBase) {
+		t.Errorf("first bucket = %s at %v, want peer-a at %v", first.NodeID, first.BucketStart, rollupBase)
+	}
+	if first.Polls != 3 || first.Successes != 2 || first.MinMs != 10 || first.MaxMs != 30 || first.SumMs != 40 {
+		t.Errorf("first bucket = %+v, want 3 polls, 2 successes between 10 and 30 ms", first)
+	}
+	if first.Sketch.Count() != 2 {
+		t.Errorf("first bucket sketch holds %d latencies, want 2", first.Sketch.Count())
+	}
+
+	if second := rollups[1]; !second.BucketStart.Equal(rollupBase.Add(time.Minute)) || second.Polls != 1 || second.MinMs != 20 {
+		t.Errorf("second bucket = %+v, want one 20 ms poll in the next minute", second)
+	}
+
+	// A bucket of failures has no latency
+	if failed := rollups[2]; failed.NodeID != "peer-b" || failed.Polls != 1 || failed.Successes != 0 || failed.MinMs != 0 || failed.Sketch.Count() != 0 {
+		t.Errorf("failed bucket = %+v, want one failed poll", failed)
+	}
+}
+
+func TestMergeRollups(t *testing.T) {
+	minute := func(nodeID string, offset time.Duration, polls int, latencies ...float64) domain.PollRollup {
+		r := domain.PollRollup{NodeID: nodeID, BucketStart: rollupBase.Add(offset), Polls: polls}
+		for _, ms := range latencies {
+			if r.Successes == 0 || ms < r.MinMs {
+				r.MinMs = ms
+			}
+			if ms > r.MaxMs {
+				r.MaxMs = ms
+			}
+			r.Successes++
+			r.SumMs += ms
+			r.Sketch.Add(ms)
+		}
+		return r
+	}
+
+	hours := mergeRollups([]domain.PollRollup{
+		minute("peer-a", 0, 2, 15, 25),
+		minute("peer-a", 10*time.Minute, 1),
+		minute("peer-a", 59*time.Minute, 1, 5),
+		minute("peer-a", 61*time.Minute, 1, 100),
+		minute("peer-b", 30*time.Minute, 1, 50),
+	}, time.Hour)
+	if len(hours) != 3 {
+		t.Fatalf("mergeRollups = %+v, want 3 buckets", hours)
+	}
+
+	first := hours[0]
+	if first.NodeID != "peer-a" || !first.BucketStart.Equal(rollupBase) {
+		t.Errorf("first bucket = %s at %v, want peer-a at %v", first.NodeID, first.BucketStart, rollupBase)
+	}
+	// The failure-only minute adds polls but no latency
+	if first.Polls != 4 || first.Successes != 3 || first.MinMs != 5 || first.MaxMs != 25 || first.SumMs != 45 {
+		t.Errorf("first bucket = %+v, want 4 polls, 3 successes between 5 and 25 ms", first)
+	}
+	if first.Sketch.Count() != 3 {
+		t.Errorf("first bucket sketch holds %d latencies, want 3", first.Sketch.Count())
+	}
+
+	if next := hours[1]; !next.BucketStart.Equal(rollupBase.Add(time.Hour)) || next.MaxMs != 100 {
+		t.Errorf("second bucket = %+v, want the 100 ms minute in the next hour", next)
+	}
+	if other := hours[2]; other.NodeID != "peer-b" || !other.BucketStart.Equal(rollupBase) || other.SumMs != 50 {
+		t.Errorf("third bucket = %+v, want peer-b's own hour", other)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	rs := NewRetentionService(store, newFakeConfig("self"))
+	rs.rawRetention = 48 * time.Hour
+	rs.minuteRetention = 7 * 24 * time.Hour
+	rs.hourRetention = 90 * 24 * time.Hour
+
+	now := rollupBase
+	for _, age := range []time.Duration{time.Hour, 47 * time.Hour, 49 * time.Hour} {
+		if err := store.CreatePollResult(ctx, &domain.PollResult{NodeID: "peer", PollTime: now.Add(-age), Success: true}); err != nil {
+			t.Fatalf("CreatePollResult: %v", err)
+		}
+	}
+	for resolution, ages := range map[string][]time.Duration{
+		domain.RollupMinute: {24 * time.Hour, 8 * 24 * time.Hour},
+		domain.RollupHour:   {30 * 24 * time.Hour, 91 * 24 * time.Hour},
+	} {
+		var rollups []domain.PollRollup
+		for _, age := range ages {
+			rollups = append(rollups, domain.PollRollup{NodeID: "peer", BucketStart: now.Add(-age), Polls: 1})
+		}
+		if err := store.SaveRollups(ctx, resolution, rollups, now); err != nil {
+			t.Fatalf("SaveRollups: %v", err)
+		}
+	}
+
+	if err := rs.Prune(ctx, now); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+
+	results, err := store.GetPollResultsBetween(ctx, now.Add(-365*24*time.Hour), now)
+	if err != nil || len(results) != 2 {
+		t.Errorf("poll results after Prune = %d, %v; want the 2 within 48 hours", len(results), err)
+	}
+	for resolution, want := range map[string]time.Time{
+		domain.RollupMinute: now.Add(-24 * time.Hour),
+		domain.RollupHour:   now.Add(-30 * 24 * time.Hour),
+	} {
+		rollups, err := store.GetRollups(ctx, resolution, "", now.Add(-365*24*time.Hour), now)
+		if err != nil || len(rollups) != 1 || !rollups[0].BucketStart.Equal(want) {
+			t.Errorf("%s rollups after Prune = %+v, %v; want only the one at %v", resolution, rollups, err, want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// SilenceService creates, lists and removes alert silences for the API and
+// the silence command; the alert service reads them from the repository
+type SilenceService struct {
+	silenceRepo domain.SilenceRepository
+	configSvc   domain.ConfigService
+}
+
+func NewSilenceService(silenceRepo domain.SilenceRepository, configSvc domain.ConfigService) *SilenceService {
+	return &SilenceService{
+		silenceRepo: silenceRepo,
+		configSvc:   configSvc,
+	}
+}
+
+// CreateSilence validates and stores a silence. It must select alerts by
+// node, rule or label and end in the future; it starts now unless given a
+// start time.
+func (ss *SilenceService) CreateSilence(ctx context.Context, silence *domain.Silence) error {
+	now := time.Now()
+
+	if silence.NodeID == "" && silence.Rule == "" && len(silence.Labels) == 0 {
+		return fmt.Errorf("%w: select alerts by node, rule or label", domain.ErrInvalidSilence)
+	}
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = now
+	}
+	if !silence.EndsAt.After(silence.StartsAt) {
+		return fmt.Errorf("%w: must end after it starts", domain.ErrInvalidSilence)
+	}
+	if !silence.EndsAt.After(now) {
+		return fmt.Errorf("%w: already ended", domain.ErrInvalidSilence)
+	}
+
+	// A misspelt rule would silently match nothing
+	if silence.Rule != "" {
+		config, err := ss.configSvc.LoadAlertConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load alert config: %w", err)
+		}
+		known := false
+		for _, rule := range config.Rules {
+			known = known || rule.Name == silence.Rule
+		}
+		if !known {
+			return fmt.Errorf("%w: no alert rule named %q", domain.ErrInvalidSilence, silence.Rule)
+		}
+	}
+
+	silence.ID = 0
+	silence.CreatedAt = now
+	return ss.silenceRepo.CreateSilence(ctx, silence)
+}
+
+// GetSilences returns the active and upcoming silences
+func (ss *SilenceService) GetSilences(ctx context.Context) ([]domain.Silence, error) {
+	silences, err := ss.silenceRepo.GetSilences(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if silences == nil {
+		silences = []domain.Silence{}
+	}
+	return silences, nil
+}
+
+func (ss *SilenceService) DeleteSilence(ctx context.Context, id int64) error {
+	return ss.silenceRepo.DeleteSilence(ctx, id)
+}
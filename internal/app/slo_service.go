@@ -0,0 +1,374 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// SLOService evaluates the objectives in slo.json against poll history.
+// Long windows are read from the hour and minute rollups and the rest from
+// raw poll results, so every poll counts once whatever the window.
+type SLOService struct {
+	nodeRepo  domain.NodeRepository
+	pollRepo  domain.PollRepository
+	configSvc domain.ConfigService
+	renderer  *TemplateRenderer // nil when the dashboard runs in JSON-only mode
+
+	config *domain.SLOConfig
+}
+
+// sloColumns are the CSV columns of an SLO report
+var sloColumns = []string{
+	"objective", "type", "node_id", "target", "threshold_ms", "since", "until",
+	"polls", "good", "attainment", "p95_ms", "error_budget_remaining", "met",
+}
+
+func NewSLOService(nodeRepo domain.NodeRepository, pollRepo domain.PollRepository, configSvc domain.ConfigService, renderer *TemplateRenderer) *SLOService {
+	return &SLOService{
+		nodeRepo:  nodeRepo,
+		pollRepo:  pollRepo,
+		configSvc: configSvc,
+		renderer:  renderer,
+	}
+}
+
+// Initialize loads slo.json, so an invalid objective stops the node at
+// startup rather than failing every report
+func (ss *SLOService) Initialize() error {
+	config, err := ss.configSvc.LoadSLOConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load SLO config: %w", err)
+	}
+	ss.config = config
+
+	if len(config.Objectives) > 0 {
+		log.Printf("Tracking %d service level objective(s)", len(config.Objectives))
+	}
+	return nil
+}
+
+// GetSLOStatus evaluates each objective over its rolling window, which
+// starts on the hour, with the budget burn rate over SLOBurnWindows
+func (ss *SLOService) GetSLOStatus(ctx context.Context) ([]domain.SLOStatus, error) {
+	now := time.Now()
+
+	nodeLabels, err := ss.nodeLabels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	burnStats := make(map[time.Duration]map[string]*domain.PollRollup)
+	for _, window := range domain.SLOBurnWindows {
+		stats, err := ss.pollStats(ctx, now.Add(-window).Truncate(time.Minute), now)
+		if err != nil {
+			return nil, err
+		}
+		burnStats[window] = stats
+	}
+
+	// Objectives often share a window; read each window once
+	windowStats := make(map[int]map[string]*domain.PollRollup)
+	statuses := []domain.SLOStatus{}
+	for i := range ss.config.Objectives {
+		objective := &ss.config.Objectives[i]
+		window := time.Duration(objective.WindowDays) * 24 * time.Hour
+		since := now.Add(-window).Truncate(time.Hour)
+
+		stats, ok := windowStats[objective.WindowDays]
+		if !ok {
+			var err error
+			if stats, err = ss.pollStats(ctx, since, now); err != nil {
+				return nil, err
+			}
+			windowStats[objective.WindowDays] = stats
+		}
+
+		for _, status := range evaluateObjective(objective, stats, nodeLabels, since, now) {
+			status.BurnRates = make(map[string]float64, len(burnStats))
+			for burnWindow, recent := range burnStats {
+				status.BurnRates[formatWindow(burnWindow)] = burnRate(objective, recent[status.NodeID])
+			}
+			statuses = append(statuses, status)
+		}
+	}
+
+	return statuses, nil
+}
+
+// GetSLOReport evaluates each objective over the calendar month, in UTC,
+// starting at month; the current month is evaluated up to now
+func (ss *SLOService) GetSLOReport(ctx context.Context, month time.Time) (*domain.SLOReport, error) {
+	now := time.Now()
+	month = month.UTC()
+	since := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	until := since.AddDate(0, 1, 0)
+	if !since.Before(now) {
+		return nil, fmt.Errorf("%w: %s has not started", domain.ErrInvalidRange, since.Format("2006-01"))
+	}
+	if until.After(now) {
+		until = now
+	}
+
+	stats, err := ss.pollStats(ctx, since, until)
+	if err != nil {
+		return nil, err
+	}
+	nodeLabels, err := ss.nodeLabels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.SLOReport{
+		Month:       since.Format("2006-01"),
+		Since:       since,
+		Until:       until,
+		GeneratedAt: now,
+		Results:     []domain.SLOStatus{},
+	}
+	for i := range ss.config.Objectives {
+		report.Results = append(report.Results, evaluateObjective(&ss.config.Objectives[i], stats, nodeLabels, since, until)...)
+	}
+
+	return report, nil
+}
+
+// GenerateSLOReportHTML renders the monthly report page, with the rolling
+// status of each objective above it
+func (ss *SLOService) GenerateSLOReportHTML(ctx context.Context, month time.Time) (string, error) {
+	if ss.renderer == nil {
+		return "", domain.ErrHTMLDisabled
+	}
+
+	report, err := ss.GetSLOReport(ctx, month)
+	if err != nil {
+		return "", err
+	}
+	status, err := ss.GetSLOStatus(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	pageData := struct {
+		*domain.SLOReport
+		Status      []domain.SLOStatus
+		BurnWindows []string
+		Previous    string
+		Next        string // empty for the current month
+	}{
+		SLOReport: report,
+		Status:    status,
+		Previous:  report.Since.AddDate(0, -1, 0).Format("2006-01"),
+	}
+	for _, window := range domain.SLOBurnWindows {
+		pageData.BurnWindows = append(pageData.BurnWindows, formatWindow(window))
+	}
+	if next := report.Since.AddDate(0, 1, 0); next.Before(report.GeneratedAt) {
+		pageData.Next = next.Format("2006-01")
+	}
+
+	html, err := ss.renderer.Render("slo.html", pageData)
+	if err != nil {
+		return "", fmt.Errorf("failed to render SLO report: %w", err)
+	}
+	return html, nil
+}
+
+// WriteSLOReportCSV writes the report's results as CSV, one row per
+// objective and node
+func WriteSLOReportCSV(w io.Writer, report *domain.SLOReport) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(sloColumns); err != nil {
+		return err
+	}
+
+	for _, status := range report.Results {
+		if err := cw.Write([]string{
+			status.Objective,
+			status.Type,
+			status.NodeID,
+			strconv.FormatFloat(status.Target, 'f', -1, 64),
+			strconv.FormatFloat(status.ThresholdMs, 'f', -1, 64),
+			status.Since.UTC().Format(time.RFC3339),
+			status.Until.UTC().Format(time.RFC3339),
+			strconv.FormatInt(status.Polls, 10),
+			strconv.FormatInt(status.Good, 10),
+			strconv.FormatFloat(status.Attainment, 'f', 4, 64),
+			strconv.FormatFloat(status.P95Ms, 'f', 1, 64),
+			strconv.FormatFloat(status.ErrorBudgetRemaining, 'f', 2, 64),
+			strconv.FormatBool(status.Met),
+		}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// ParseSLOMonth parses a report month given as YYYY-MM; empty yields the
+// current month
+func ParseSLOMonth(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return now.UTC(), nil
+	}
+	month, err := time.Parse("2006-01", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: month %q is not YYYY-MM", domain.ErrInvalidRange, value)
+	}
+	return month, nil
+}
+
+// pollStats totals the polls to each node in [since, until): whole hours
+// from the hour rollups, then whole minutes from the minute rollups, then
+// raw results after the minute rollups' watermark. since must fall on a
+// minute, and on an hour for the hour rollups to be used.
+func (ss *SLOService) pollStats(ctx context.Context, since, until time.Time) (map[string]*domain.PollRollup, error) {
+	var rollups []domain.PollRollup
+	cursor := since
+
+	for _, resolution := range []string{domain.RollupHour, domain.RollupMinute} {
+		if !cursor.Equal(cursor.Truncate(domain.RollupResolutions[resolution])) {
+			continue
+		}
+		watermark, err := ss.pollRepo.GetRollupWatermark(ctx, resolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s rollup watermark: %w", resolution, err)
+		}
+		end := watermark
+		if end.After(until) {
+			end = until
+		}
+		if !cursor.Before(end) {
+			continue
+		}
+
+		found, err := ss.pollRepo.GetRollups(ctx, resolution, "", cursor, end)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s rollups: %w", resolution, err)
+		}
+		rollups = append(rollups, found...)
+		cursor = end
+	}
+
+	if cursor.Before(until) {
+		results, err := ss.pollRepo.GetPollResultsBetween(ctx, cursor, until)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get poll results: %w", err)
+		}
+		rollups = append(rollups, rollupResults(results, time.Minute)...)
+	}
+
+	stats := make(map[string]*domain.PollRollup)
+	for _, rollup := range rollups {
+		total, ok := stats[rollup.NodeID]
+		if !ok {
+			total = &domain.PollRollup{NodeID: rollup.NodeID}
+			stats[rollup.NodeID] = total
+		}
+		total.Polls += rollup.Polls
+		total.Successes += rollup.Successes
+		total.Sketch.Merge(rollup.Sketch)
+	}
+	return stats, nil
+}
+
+// nodeLabels returns the labels of every known node by node ID, read only
+// when an objective selects nodes by label
+func (ss *SLOService) nodeLabels(ctx context.Context) (map[string]map[string]string, error) {
+	needed := false
+	for _, objective := range ss.config.Objectives {
+		needed = needed || len(objective.NodeLabels) > 0
+	}
+	if !needed {
+		return nil, nil
+	}
+
+	nodes, err := ss.nodeRepo.GetAllNodes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+	labels := make(map[string]map[string]string, len(nodes))
+	for _, node := range nodes {
+		labels[node.ID] = node.Labels
+	}
+	return labels, nil
+}
+
+// evaluateObjective returns the status of every node the objective covers
+// that was polled in the window, by node ID
+func evaluateObjective(objective *domain.SLObjective, stats map[string]*domain.PollRollup, nodeLabels map[string]map[string]string, since, until time.Time) []domain.SLOStatus {
+	nodeIDs := make([]string, 0, len(stats))
+	for nodeID := range stats {
+		if len(objective.Nodes) > 0 && !slices.Contains(objective.Nodes, nodeID) {
+			continue
+		}
+		if !domain.LabelsMatch(objective.NodeLabels, nodeLabels[nodeID]) {
+			continue
+		}
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+
+	var statuses []domain.SLOStatus
+	for _, nodeID := range nodeIDs {
+		polls, good := sloEvents(objective, stats[nodeID])
+		if polls == 0 {
+			continue
+		}
+
+		attainment := float64(good) / float64(polls) * 100
+		budget := 100 - objective.Target
+		statuses = append(statuses, domain.SLOStatus{
+			Objective:            objective.Name,
+			Type:                 objective.Type,
+			NodeID:               nodeID,
+			Target:               objective.Target,
+			ThresholdMs:          objective.ThresholdMs,
+			Since:                since,
+			Until:                until,
+			Polls:                polls,
+			Good:                 good,
+			Attainment:           attainment,
+			P95Ms:                stats[nodeID].Sketch.Quantile(0.95),
+			Met:                  attainment >= objective.Target,
+			ErrorBudgetRemaining: (budget - (100 - attainment)) / budget * 100,
+		})
+	}
+	return statuses
+}
+
+// sloEvents counts the polls an objective judges and how many were good
+func sloEvents(objective *domain.SLObjective, stats *domain.PollRollup) (int64, int64) {
+	if stats == nil {
+		return 0, 0
+	}
+	if objective.Type == domain.SLOLatency {
+		return int64(stats.Successes), int64(stats.Sketch.CountAtMost(objective.ThresholdMs))
+	}
+	return int64(stats.Polls), int64(stats.Successes)
+}
+
+// burnRate is the share of bad polls in stats relative to the share the
+// objective allows; 0 when there were no polls
+func burnRate(objective *domain.SLObjective, stats *domain.PollRollup) float64 {
+	polls, good := sloEvents(objective, stats)
+	if polls == 0 {
+		return 0
+	}
+	badShare := float64(polls-good) / float64(polls) * 100
+	return badShare / (100 - objective.Target)
+}
+
+// formatWindow formats a whole number of hours as "6h" rather than "6h0m0s"
+func formatWindow(window time.Duration) string {
+	return strconv.Itoa(int(window.Hours())) + "h"
+}
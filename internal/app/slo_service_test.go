@@ -0,0 +1,170 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+	"nodeprobe/internal/pkg/memory"
+)
+
+// testRollup returns a rollup of polls with successes answered in ms
+func testRollup(nodeID string, bucket time.Time, polls int, successes int, ms float64) domain.PollRollup {
+	rollup := domain.PollRollup{NodeID: nodeID, BucketStart: bucket, Polls: polls, Successes: successes}
+	for i := 0; i < successes; i++ {
+		rollup.Sketch.Add(ms)
+		rollup.SumMs += ms
+	}
+	return rollup
+}
+
+func newTestSLOService(t *testing.T) (*SLOService, *memory.Store) {
+	t.Helper()
+
+	config := newFakeConfig("self")
+	config.slo.Objectives = []domain.SLObjective{
+		{Name: "available", Type: domain.SLOAvailability, Target: 99, WindowDays: 30},
+		{Name: "fast", Type: domain.SLOLatency, Target: 90, ThresholdMs: 50, WindowDays: 30, Nodes: []string{"peer-a"}},
+	}
+
+	store := memory.NewStore()
+	ss := NewSLOService(store, store, config, nil)
+	if err := ss.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return ss, store
+}
+
+func TestSLOStatus(t *testing.T) {
+	ctx := context.Background()
+	ss, store := newTestSLOService(t)
+
+	now := time.Now()
+	hourWatermark := now.Truncate(time.Hour).Add(-time.Hour)
+	minuteWatermark := now.Truncate(time.Minute).Add(-2 * time.Minute)
+
+	// Each poll must count once: hours up to their watermark, minutes up to
+	// theirs, raw results after that. The decoys sit in ranges already
+	// covered by a coarser source.
+	hours := []domain.PollRollup{
+		testRollup("peer-a", hourWatermark.Add(-48*time.Hour), 100, 99, 20),
+		testRollup("peer-b", hourWatermark.Add(-48*time.Hour), 10, 10, 20),
+		testRollup("peer-a", hourWatermark.Add(-40*24*time.Hour), 1000, 0, 0), // outside the window
+	}
+	if err := store.SaveRollups(ctx, domain.RollupHour, hours, hourWatermark); err != nil {
+		t.Fatalf("SaveRollups: %v", err)
+	}
+	minutes := []domain.PollRollup{
+		testRollup("peer-a", hourWatermark.Add(-30*time.Minute), 1000, 0, 0), // decoy
+		testRollup("peer-a", minuteWatermark.Add(-5*time.Minute), 10, 10, 100),
+	}
+	if err := store.SaveRollups(ctx, domain.RollupMinute, minutes, minuteWatermark); err != nil {
+		t.Fatalf("SaveRollups: %v", err)
+	}
+	results := []domain.PollResult{
+		{NodeID: "peer-a", PollTime: minuteWatermark.Add(-time.Minute), Success: false}, // decoy
+		{NodeID: "peer-a", PollTime: minuteWatermark, Success: false},
+	}
+	for i := 0; i < 4; i++ {
+		results = append(results, domain.PollResult{NodeID: "peer-a", PollTime: minuteWatermark.Add(time.Second), Success: true, ResponseMs: 10})
+	}
+	if err := store.CreatePollResults(ctx, results); err != nil {
+		t.Fatalf("CreatePollResults: %v", err)
+	}
+
+	status, err := ss.GetSLOStatus(ctx)
+	if err != nil {
+		t.Fatalf("GetSLOStatus: %v", err)
+	}
+	if len(status) != 3 {
+		t.Fatalf("status = %+v, want peer-a and peer-b available, peer-a fast", status)
+	}
+
+	available := status[0]
+	if available.NodeID != "peer-a" || available.Polls != 115 || available.Good != 113 || available.Met {
+		t.Errorf("peer-a availability = %+v, want 113 of 115 polls good, missed", available)
+	}
+	if want := (1 - (100-113.0/115*100)/1) * 100; math.Abs(available.ErrorBudgetRemaining-want) > 1e-9 {
+		t.Errorf("budget remaining = %v, want %v", available.ErrorBudgetRemaining, want)
+	}
+	// The last hour saw 1 failure in 15 polls, 6.7 times what 99% allows
+	if burn := available.BurnRates["1h"]; math.Abs(burn-100.0/15) > 1e-9 {
+		t.Errorf("1h burn rate = %v, want %v", burn, 100.0/15)
+	}
+	if !available.Since.Equal(now.Add(-30 * 24 * time.Hour).Truncate(time.Hour)) {
+		t.Errorf("window starts %v, want on the hour 30 days ago", available.Since)
+	}
+
+	if peerB := status[1]; peerB.NodeID != "peer-b" || peerB.Attainment != 100 || !peerB.Met || peerB.ErrorBudgetRemaining != 100 {
+		t.Errorf("peer-b availability = %+v, want met with the budget untouched", peerB)
+	}
+
+	// 99 of 113 successful polls were within 50ms
+	fast := status[2]
+	if fast.Objective != "fast" || fast.Polls != 113 || fast.Good != 103 || !fast.Met {
+		t.Errorf("peer-a latency = %+v, want 103 of 113 within 50ms, met", fast)
+	}
+	if fast.P95Ms < 95 || fast.P95Ms > 105 {
+		t.Errorf("p95 = %v, want about 100", fast.P95Ms)
+	}
+}
+
+func TestSLOReport(t *testing.T) {
+	ctx := context.Background()
+	ss, store := newTestSLOService(t)
+
+	now := time.Now().UTC()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	lastMonth := thisMonth.AddDate(0, -1, 0)
+
+	hours := []domain.PollRollup{
+		testRollup("peer-a", lastMonth.Add(-time.Hour), 7, 0, 0), // the month before
+		testRollup("peer-a", lastMonth.Add(5*time.Hour), 50, 50, 10),
+		testRollup("peer-a", thisMonth.Add(-time.Hour), 50, 49, 10),
+	}
+	if err := store.SaveRollups(ctx, domain.RollupHour, hours, thisMonth); err != nil {
+		t.Fatalf("SaveRollups: %v", err)
+	}
+
+	month, err := ParseSLOMonth(lastMonth.Format("2006-01"), now)
+	if err != nil {
+		t.Fatalf("ParseSLOMonth: %v", err)
+	}
+	report, err := ss.GetSLOReport(ctx, month)
+	if err != nil {
+		t.Fatalf("GetSLOReport: %v", err)
+	}
+	if report.Month != lastMonth.Format("2006-01") || !report.Since.Equal(lastMonth) || !report.Until.Equal(thisMonth) {
+		t.Errorf("report covers %s %v to %v, want last month", report.Month, report.Since, report.Until)
+	}
+	if len(report.Results) != 2 {
+		t.Fatalf("results = %+v, want availability and latency of peer-a", report.Results)
+	}
+	if got := report.Results[0]; got.Polls != 100 || got.Good != 99 || !got.Met || got.BurnRates != nil {
+		t.Errorf("availability = %+v, want 99 of 100, met, without burn rates", got)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSLOReportCSV(&buf, report); err != nil {
+		t.Fatalf("WriteSLOReportCSV: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+	if len(rows) != 3 || rows[1][0] != "available" || rows[1][9] != "99.0000" || rows[2][12] != "true" {
+		t.Errorf("CSV = %v, want a header and two results", rows)
+	}
+
+	if _, err := ss.GetSLOReport(ctx, thisMonth.AddDate(0, 1, 0)); !errors.Is(err, domain.ErrInvalidRange) {
+		t.Errorf("next month error = %v, want ErrInvalidRange", err)
+	}
+	if _, err := ParseSLOMonth("2026-13", now); !errors.Is(err, domain.ErrInvalidRange) {
+		t.Errorf("invalid month error = %v, want ErrInvalidRange", err)
+	}
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"nodeprobe/web"
+)
+
+// TemplateRenderer holds the dashboard templates, parsed once at startup,
+// and the static asset filesystem, both with optional operator overrides
+type TemplateRenderer struct {
+	templates *template.Template
+	static    fs.FS
+}
+
+// NewTemplateRenderer parses the embedded templates and, when overrideDir is
+// set, layers overrideDir/templates/*.html and overrideDir/static/ on top.
+// An override file with the same name as an embedded template replaces it;
+// other files can supply new blocks such as "panels" for the dashboard.
+func NewTemplateRenderer(overrideDir string) (*TemplateRenderer, error) {
+	templates, err := template.ParseFS(web.Templates, "templates/*.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse embedded templates: %w", err)
+	}
+
+	static, err := fs.Sub(web.Static, "static")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embedded static assets: %w", err)
+	}
+
+	renderer := &TemplateRenderer{
+		templates: templates,
+		static:    static,
+	}
+
+	if overrideDir == "" {
+		return renderer, nil
+	}
+
+	overrides, err := filepath.Glob(filepath.Join(overrideDir, "templates", "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list template overrides: %w", err)
+	}
+	if len(overrides) > 0 {
+		if _, err := renderer.templates.ParseFiles(overrides...); err != nil {
+			return nil, fmt.Errorf("failed to parse template overrides: %w", err)
+		}
+		log.Printf("Loaded %d dashboard template override(s) from %s", len(overrides), overrideDir)
+	}
+
+	staticDir := filepath.Join(overrideDir, "static")
+	if info, err := os.Stat(staticDir); err == nil && info.IsDir() {
+		renderer.static = overlayFS{primary: os.DirFS(staticDir), fallback: static}
+		log.Printf("Serving static asset overrides from %s", staticDir)
+	}
+
+	return renderer, nil
+}
+
+// Render executes the named template with the given data
+func (tr *TemplateRenderer) Render(name string, data interface{}) (string, error) {
+	var buf strings.Builder
+	if err := tr.templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("failed to execute template %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// Static returns the static asset filesystem served under /static/
+func (tr *TemplateRenderer) Static() fs.FS {
+	return tr.static
+}
+
+// overlayFS serves files from primary, falling back to fallback when absent
+type overlayFS struct {
+	primary  fs.FS
+	fallback fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.primary.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.fallback.Open(name)
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// Thresholds used to classify poll edges in the topology graph
+const (
+	degradedSuccessRate = 95.0
+	failingSuccessRate  = 50.0
+	degradedResponseMs  = 500.0
+)
+
+// GenerateTopology builds a graph of the cluster from the node registry and
+// the poll results recorded by this node within domain.TopologyWindow
+func (rs *ReportingService) GenerateTopology(ctx context.Context) (*domain.TopologyGraph, error) {
+	nodes, err := rs.nodeService.GetKnownNodes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get known nodes: %w", err)
+	}
+
+	nodeInfo, err := rs.configSvc.GetNodeInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node info: %w", err)
+	}
+
+	since := time.Now().Add(-domain.TopologyWindow)
+	pollResults, err := rs.pollRepo.GetRecentPollResults(ctx, since)
+	if err != nil {
+		log.Printf("Warning: failed to get recent poll results: %v", err)
+		pollResults = []domain.PollResult{}
+	}
+
+	graph := &domain.TopologyGraph{
+		GeneratedAt: time.Now(),
+		LocalNodeID: nodeInfo.ID,
+		Window:      domain.TopologyWindow.String(),
+		Nodes:       make([]domain.TopologyNode, 0, len(nodes)+1),
+		Edges:       make([]domain.TopologyEdge, 0),
+	}
+
+	// The local node is always present so poll edges have a source vertex
+	graph.Nodes = append(graph.Nodes, domain.TopologyNode{
+		ID:       nodeInfo.ID,
+		FQDN:     nodeInfo.FQDN,
+		IP:       nodeInfo.IP,
+		IsActive: true,
+		IsLocal:  true,
+	})
+
+	known := map[string]bool{nodeInfo.ID: true}
+	for _, node := range nodes {
+		if known[node.ID] {
+			continue
+		}
+		known[node.ID] = true
+		graph.Nodes = append(graph.Nodes, domain.TopologyNode{
+			ID:           node.ID,
+			FQDN:         node.FQDN,
+			IP:           node.IP,
+			DiscoveredBy: node.DiscoveredBy,
+			IsActive:     node.IsActive,
+		})
+	}
+
+	graph.Edges = append(graph.Edges, buildPollEdges(nodeInfo.ID, ownPollResults(pollResults), known)...)
+
+	// Discovery edges only make sense when the discoverer is itself a vertex,
+	// so "seed" and "report" origins are left on the node attributes instead
+	for _, node := range nodes {
+		if node.DiscoveredBy == "" || !known[node.DiscoveredBy] || node.DiscoveredBy == node.ID {
+			continue
+		}
+		graph.Edges = append(graph.Edges, domain.TopologyEdge{
+			Source: node.DiscoveredBy,
+			Target: node.ID,
+			Kind:   domain.EdgeKindDiscovery,
+		})
+	}
+
+	sort.Slice(graph.Nodes[1:], func(i, j int) bool {
+		return graph.Nodes[i+1].ID < graph.Nodes[j+1].ID
+	})
+
+	return graph, nil
+}
+
+// buildPollEdges aggregates poll results into one edge per polled node
+func buildPollEdges(sourceID string, results []domain.PollResult, known map[string]bool) []domain.TopologyEdge {
+	type edgeStats struct {
+		polls      int
+		successes  int
+		totalMs    int64
+		lastPoll   time.Time
+		lastMs     int64
+		lastFailed bool
+	}
+
+	stats := make(map[string]*edgeStats)
+	for _, result := range results {
+		if !known[result.NodeID] {
+			continue
+		}
+
+		s, ok := stats[result.NodeID]
+		if !ok {
+			s = &edgeStats{}
+			stats[result.NodeID] = s
+		}
+
+		s.polls++
+		if result.Success {
+			s.successes++
+			s.totalMs += result.ResponseMs
+		}
+		if result.PollTime.After(s.lastPoll) {
+			s.lastPoll = result.PollTime
+			s.lastMs = result.ResponseMs
+			s.lastFailed = !result.Success
+		}
+	}
+
+	targets := make([]string, 0, len(stats))
+	for target := range stats {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	edges := make([]domain.TopologyEdge, 0, len(targets))
+	for _, target := range targets {
+		s := stats[target]
+		edge := domain.TopologyEdge{
+			Source:         sourceID,
+			Target:         target,
+			Kind:           domain.EdgeKindPoll,
+			Polls:          s.polls,
+			Successes:      s.successes,
+			SuccessRate:    float64(s.successes) / float64(s.polls) * 100,
+			LastResponseMs: s.lastMs,
+		}
+		if s.successes > 0 {
+			edge.AvgResponseMs = float64(s.totalMs) / float64(s.successes)
+		}
+		edge.Health = classifyEdge(edge, s.lastFailed)
+		edges = append(edges, edge)
+	}
+
+	return edges
+}
+
+// classifyEdge maps an edge's success rate and latency onto a health class
+func classifyEdge(edge domain.TopologyEdge, lastFailed bool) string {
+	switch {
+	case edge.SuccessRate < failingSuccessRate:
+		return domain.EdgeHealthFailing
+	case lastFailed || edge.SuccessRate < degradedSuccessRate || edge.AvgResponseMs > degradedResponseMs:
+		return domain.EdgeHealthDegraded
+	default:
+		return domain.EdgeHealthHealthy
+	}
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"nodeprobe/internal/domain"
+)
+
+// nodeIDPattern accepts UUIDs as well as the seed-<fqdn>-<ip> IDs of seed nodes
+var nodeIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]{0,127}$`)
+
+// hostnameLabelPattern accepts one DNS label; underscores are allowed because
+// container runtimes commonly generate hostnames containing them
+var hostnameLabelPattern = regexp.MustCompile(`^[A-Za-z0-9_]([A-Za-z0-9_-]{0,61}[A-Za-z0-9_])?$`)
+
+// validateNode checks the identity, address and labels of a node reported by a peer
+// before it is added to the registry and polled
+func validateNode(node *domain.Node) error {
+	if !nodeIDPattern.MatchString(node.ID) {
+		return fmt.Errorf("%w: malformed ID %q", domain.ErrInvalidNode, node.ID)
+	}
+
+	if node.FQDN != "" && node.FQDN != "unknown" && !validHostname(node.FQDN) {
+		return fmt.Errorf("%w: node %s has malformed FQDN %q", domain.ErrInvalidNode, node.ID, node.FQDN)
+	}
+
+	if node.IP != "" {
+		ip := net.ParseIP(node.IP)
+		if ip == nil {
+			return fmt.Errorf("%w: node %s has malformed IP %q", domain.ErrInvalidNode, node.ID, node.IP)
+		}
+		if ip.IsUnspecified() || ip.IsLoopback() || ip.IsMulticast() {
+			return fmt.Errorf("%w: node %s has unusable IP %s", domain.ErrInvalidNode, node.ID, node.IP)
+		}
+	}
+
+	if err := domain.ValidateLabels(node.Labels); err != nil {
+		return fmt.Errorf("%w: node %s: %v", domain.ErrInvalidNode, node.ID, err)
+	}
+
+	return nil
+}
+
+func validHostname(name string) bool {
+	if net.ParseIP(name) != nil {
+		return true
+	}
+
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if !hostnameLabelPattern.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
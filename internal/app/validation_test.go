@@ -0,0 +1,55 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"nodeprobe/internal/domain"
+)
+
+func TestValidateNode(t *testing.T) {
+	tests := []struct {
+		name  string
+		node  domain.Node
+		valid bool
+	}{
+		{"UUID", domain.Node{ID: "3f1c2b7e-9a4d-4c1e-8f00-1234567890ab", FQDN: "node1.example.com", IP: "10.0.0.1"}, true},
+		{"seed ID", domain.Node{ID: "seed-node1.example.com-10.0.0.1", FQDN: "node1.example.com", IP: "10.0.0.1"}, true},
+		{"unknown FQDN", domain.Node{ID: "peer", FQDN: "unknown", IP: "10.0.0.1"}, true},
+		{"no address", domain.Node{ID: "peer"}, true},
+		{"underscore hostname", domain.Node{ID: "peer", FQDN: "web_1.internal"}, true},
+		{"trailing dot", domain.Node{ID: "peer", FQDN: "node1.example.com."}, true},
+		{"IP as FQDN", domain.Node{ID: "peer", FQDN: "10.0.0.1"}, true},
+		{"IPv6", domain.Node{ID: "peer", IP: "2001:db8::1"}, true},
+
+		{"empty ID", domain.Node{ID: ""}, false},
+		{"ID with a slash", domain.Node{ID: "peer/../admin"}, false},
+		{"ID with a space", domain.Node{ID: "peer one"}, false},
+		{"ID starting with a dash", domain.Node{ID: "-peer"}, false},
+		{"ID too long", domain.Node{ID: strings.Repeat("a", 129)}, false},
+		{"FQDN with a space", domain.Node{ID: "peer", FQDN: "node one.example.com"}, false},
+		{"FQDN with an empty label", domain.Node{ID: "peer", FQDN: "node..example.com"}, false},
+		{"FQDN label ending in a dash", domain.Node{ID: "peer", FQDN: "node-.example.com"}, false},
+		{"FQDN label too long", domain.Node{ID: "peer", FQDN: strings.Repeat("a", 64) + ".example.com"}, false},
+		{"FQDN with a scheme", domain.Node{ID: "peer", FQDN: "http://node1.example.com"}, false},
+		{"malformed IP", domain.Node{ID: "peer", IP: "10.0.0.256"}, false},
+		{"hostname as IP", domain.Node{ID: "peer", IP: "node1.example.com"}, false},
+		{"unspecified IP", domain.Node{ID: "peer", IP: "0.0.0.0"}, false},
+		{"loopback IP", domain.Node{ID: "peer", IP: "127.0.0.1"}, false},
+		{"IPv6 loopback", domain.Node{ID: "peer", IP: "::1"}, false},
+		{"multicast IP", domain.Node{ID: "peer", IP: "224.0.0.1"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNode(&tt.node)
+			if tt.valid && err != nil {
+				t.Errorf("validateNode = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, domain.ErrInvalidNode) {
+				t.Errorf("validateNode = %v, want ErrInvalidNode", err)
+			}
+		})
+	}
+}
@@ -3,9 +3,14 @@ package app
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"nodeprobe/internal/domain"
@@ -14,37 +19,72 @@ import (
 type WebServer struct {
 	nodeService      domain.NodeService
 	reportingService domain.ReportingService
+	exportService    domain.ExportService
+	silenceService   domain.SilenceService
+	sloService       domain.SLOService
+	anomalyService   domain.AnomalyService
+	coordinates      domain.CoordinateService
 	configSvc        domain.ConfigService
 	tlsService       domain.TLSService
+	signingService   domain.SigningService
+	authService      domain.AuthService
+	renderer         *TemplateRenderer // nil when the dashboard runs in JSON-only mode
 	server           *http.Server
+	limiter          *rateLimiter // nil when rate limiting is disabled
+	maxBodyBytes     int64
+	peerNets         []*net.IPNet             // allowed sources for inter-node endpoints, nil allows all
 	receivedReports  []domain.NetworkSnapshot // Store received reports for dashboard
 }
 
 func NewWebServer(
 	nodeService domain.NodeService,
 	reportingService domain.ReportingService,
+	exportService domain.ExportService,
+	silenceService domain.SilenceService,
+	sloService domain.SLOService,
+	anomalyService domain.AnomalyService,
+	coordinates domain.CoordinateService,
 	configSvc domain.ConfigService,
 	tlsService domain.TLSService,
+	signingService domain.SigningService,
+	authService domain.AuthService,
+	renderer *TemplateRenderer,
 ) *WebServer {
 	return &WebServer{
 		nodeService:      nodeService,
 		reportingService: reportingService,
+		exportService:    exportService,
+		silenceService:   silenceService,
+		sloService:       sloService,
+		anomalyService:   anomalyService,
+		coordinates:      coordinates,
 		configSvc:        configSvc,
 		tlsService:       tlsService,
+		signingService:   signingService,
+		authService:      authService,
+		renderer:         renderer,
 		receivedReports:  make([]domain.NetworkSnapshot, 0),
 	}
 }
 
 func (ws *WebServer) Start(ctx context.Context) error {
 	// Generate TLS certificate if needed
-	if err := ws.tlsService.GenerateSelfSignedCert(); err != nil {
+	if err := ws.tlsService.EnsureCertificate(); err != nil {
 		return fmt.Errorf("failed to generate TLS certificate: %w", err)
 	}
 
-	// Get certificate paths
-	certPath, keyPath, err := ws.tlsService.GetCertPath()
+	tlsConfig, err := ws.tlsService.ServerTLSConfig()
 	if err != nil {
-		return fmt.Errorf("failed to get certificate paths: %w", err)
+		return fmt.Errorf("failed to create TLS configuration: %w", err)
+	}
+
+	// Certificates are served through GetCertificate, which picks up
+	// renewed or replaced files without a restart
+	ws.checkCertificate()
+	go ws.certificateRenewalLoop(ctx)
+
+	if err := ws.configureLimits(); err != nil {
+		return err
 	}
 
 	// Set up HTTP routes
@@ -54,8 +94,8 @@ func (ws *WebServer) Start(ctx context.Context) error {
 	// Create HTTPS server
 	ws.server = &http.Server{
 		Addr:         ":443",
-		Handler:      mux,
-		TLSConfig:    nil, // Will use cert files
+		Handler:      ws.loggingMiddleware(ws.limitRequests(mux)),
+		TLSConfig:    tlsConfig,
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -65,7 +105,7 @@ func (ws *WebServer) Start(ctx context.Context) error {
 
 	// Start server in a goroutine
 	go func() {
-		if err := ws.server.ListenAndServeTLS(certPath, keyPath); err != nil && err != http.ErrServerClosed {
+		if err := ws.server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
 			log.Printf("HTTPS server error: %v", err)
 		}
 	}()
@@ -93,19 +133,90 @@ func (ws *WebServer) Stop(ctx context.Context) error {
 
 func (ws *WebServer) setupRoutes(mux *http.ServeMux) {
 	// Node info endpoint - returns this node's information and known nodes
-	mux.HandleFunc("/nodeinfo", ws.handleNodeInfo)
+	mux.HandleFunc("/nodeinfo", ws.peerRoute(ws.handleNodeInfo))
 
 	// Report endpoint - accepts network snapshots from other nodes
-	mux.HandleFunc("/report", ws.handleReport)
+	mux.HandleFunc("/report", ws.peerRoute(ws.handleReport))
+
+	// Health check endpoint - always public for load balancers and probes
+	mux.HandleFunc("/health", ws.handleHealth)
+
+	// Prometheus metrics
+	mux.HandleFunc("/metrics", ws.requireRole(domain.RoleViewer, ws.handleMetrics))
+
+	// Dashboard data as JSON
+	mux.HandleFunc("/api/v1/report", ws.requireRole(domain.RoleViewer, ws.handleReportJSON))
+
+	// Topology graph - JSON for the graph page and API consumers
+	mux.HandleFunc("/api/v1/topology", ws.requireRole(domain.RoleViewer, ws.handleTopology))
+
+	// Known nodes, optionally filtered by labels
+	mux.HandleFunc("/api/v1/nodes", ws.requireRole(domain.RoleViewer, ws.handleNodes))
+
+	// Per-node history as JSON
+	mux.HandleFunc("/api/v1/nodes/{id}", ws.requireRole(domain.RoleViewer, ws.handleNodeDetail))
+	mux.HandleFunc("/api/v1/nodes/{id}/rollups", ws.requireRole(domain.RoleViewer, ws.handleNodeRollups))
+
+	// Firing alerts and alert history
+	mux.HandleFunc("/api/v1/alerts", ws.requireRole(domain.RoleViewer, ws.handleAlerts))
+
+	// Flap scores of nodes and links
+	mux.HandleFunc("/api/v1/flapping", ws.requireRole(domain.RoleViewer, ws.handleFlapping))
+
+	// Latency baselines and the anomalies detected against them
+	mux.HandleFunc("/api/v1/baselines", ws.requireRole(domain.RoleViewer, ws.handleBaselines))
+	mux.HandleFunc("/api/v1/anomalies", ws.requireRole(domain.RoleViewer, ws.handleAnomalies))
+
+	// Network coordinates and the round-trip times estimated from them
+	mux.HandleFunc("/api/v1/coordinates", ws.requireRole(domain.RoleViewer, ws.handleCoordinates))
+	mux.HandleFunc("/api/v1/rtt", ws.requireRole(domain.RoleViewer, ws.handleRTT))
+
+	// Latency and loss between regions, zones or other localities
+	mux.HandleFunc("/api/v1/localities", ws.requireRole(domain.RoleViewer, ws.handleLocalities))
+
+	// Alert silences: anyone may list them, operators create and remove them
+	mux.HandleFunc("/api/v1/silences", ws.handleSilences)
+	mux.HandleFunc("/api/v1/silences/{id}", ws.requireRole(domain.RoleOperator, ws.handleDeleteSilence))
+
+	// Service level objectives: rolling status and monthly reports
+	mux.HandleFunc("/api/v1/slo", ws.requireRole(domain.RoleViewer, ws.handleSLOStatus))
+	mux.HandleFunc("/api/v1/slo/report", ws.requireRole(domain.RoleViewer, ws.handleSLOReport))
+
+	// Bulk export of the node registry and poll history
+	mux.HandleFunc("/api/v1/export", ws.requireRole(domain.RoleViewer, ws.handleExport))
+
+	// Pinned peer certificates and approval of changed ones
+	mux.HandleFunc("/api/v1/peers/fingerprints", ws.requireRole(domain.RoleViewer, ws.handleFingerprints))
+	mux.HandleFunc("/api/v1/peers/{id}/fingerprint/approve", ws.requireRole(domain.RoleOperator, ws.handleApproveFingerprint))
+
+	if ws.renderer == nil {
+		// Headless deployments serve the report as JSON in place of the dashboard
+		mux.HandleFunc("/dashboard", ws.requireRole(domain.RoleViewer, ws.handleReportJSON))
+		mux.HandleFunc("/{$}", ws.requireRole(domain.RoleViewer, ws.handleReportJSON))
+		return
+	}
+
+	// Browser sign-in with an API token
+	if ws.authService.Enabled() {
+		mux.HandleFunc("/login", ws.handleLogin)
+		mux.HandleFunc("/logout", ws.handleLogout)
+	}
 
 	// Dashboard endpoint - serves HTML report for humans
-	mux.HandleFunc("/dashboard", ws.handleDashboard)
+	mux.HandleFunc("/dashboard", ws.requireRole(domain.RoleViewer, ws.handleDashboard))
 
-	// Health check endpoint
-	mux.HandleFunc("/health", ws.handleHealth)
+	// Per-node detail page
+	mux.HandleFunc("/nodes/{id}", ws.requireRole(domain.RoleViewer, ws.handleNodeDetailPage))
+
+	// Monthly SLO report page
+	mux.HandleFunc("/slo", ws.requireRole(domain.RoleViewer, ws.handleSLOPage))
+
+	// Topology page and the static assets shared by all pages
+	mux.HandleFunc("/topology", ws.requireRole(domain.RoleViewer, ws.handleTopologyPage))
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(ws.renderer.Static()))))
 
 	// Default to dashboard
-	mux.HandleFunc("/", ws.handleDashboard)
+	mux.HandleFunc("/", ws.requireRole(domain.RoleViewer, ws.handleDashboard))
 }
 
 func (ws *WebServer) handleNodeInfo(w http.ResponseWriter, r *http.Request) {
@@ -133,6 +244,14 @@ func (ws *WebServer) handleNodeInfo(w http.ResponseWriter, r *http.Request) {
 
 	nodeInfo.Nodes = nodes
 
+	coordinate := ws.coordinates.LocalCoordinate()
+	nodeInfo.Coordinate = &coordinate
+	if nodeInfo.Coordinates, err = ws.coordinates.GetCoordinates(ctx); err != nil {
+		log.Printf("Failed to get coordinates: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
 	// Return JSON response
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -152,14 +271,55 @@ func (ws *WebServer) handleReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The signature covers the raw body, so read it before decoding
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			log.Printf("Rejected network snapshot from %s: body exceeds %d bytes", r.RemoteAddr, tooLarge.Limit)
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		log.Printf("Failed to read network snapshot: %v", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	signer, err := ws.signingService.VerifyRequest(r, body)
+	if err != nil {
+		log.Printf("Rejected network snapshot from %s: %v", r.RemoteAddr, err)
+		http.Error(w, "Invalid snapshot signature", http.StatusUnauthorized)
+		return
+	}
+
 	// Parse network snapshot from request body
 	var snapshot domain.NetworkSnapshot
-	if err := json.NewDecoder(r.Body).Decode(&snapshot); err != nil {
+	if err := json.Unmarshal(body, &snapshot); err != nil {
 		log.Printf("Failed to decode network snapshot: %v", err)
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
+	if err := validateNode(&domain.Node{ID: snapshot.NodeID}); err != nil {
+		log.Printf("Rejected network snapshot from %s: %v", r.RemoteAddr, err)
+		http.Error(w, "Bad request: invalid node ID", http.StatusBadRequest)
+		return
+	}
+
+	// A signed snapshot may only describe its signer
+	if signer != "" && signer != snapshot.NodeID {
+		log.Printf("Rejected network snapshot for node %s signed by node %s", snapshot.NodeID, signer)
+		http.Error(w, "Snapshot node ID does not match signer", http.StatusForbidden)
+		return
+	}
+
+	// With mutual TLS a node may only report on its own behalf
+	if peerID, ok := peerNodeID(r.Context()); ok && peerID != snapshot.NodeID {
+		log.Printf("Rejected network snapshot for node %s sent by node %s", snapshot.NodeID, peerID)
+		http.Error(w, "Snapshot node ID does not match client certificate", http.StatusForbidden)
+		return
+	}
+
 	// Store the received report (for dashboard purposes)
 	ws.receivedReports = append(ws.receivedReports, snapshot)
 
@@ -174,8 +334,9 @@ func (ws *WebServer) handleReport(w http.ResponseWriter, r *http.Request) {
 	// Merge the node information from the snapshot
 	ctx := r.Context()
 	nodeInfo := &domain.NodeInfo{
-		ID:    snapshot.NodeID,
-		Nodes: snapshot.Nodes,
+		ID:     snapshot.NodeID,
+		Labels: snapshot.Labels,
+		Nodes:  snapshot.Nodes,
 	}
 
 	if err := ws.nodeService.MergeNodeInfo(ctx, nodeInfo, "report"); err != nil {
@@ -192,14 +353,48 @@ func (ws *WebServer) handleReport(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (ws *WebServer) handleReportJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	scope, err := parseReportScope(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := ws.reportingService.GenerateReport(r.Context(), scope)
+	if err != nil {
+		log.Printf("Failed to generate report: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Failed to encode report: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
 func (ws *WebServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	scope, err := parseReportScope(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Generate HTML report
-	html, err := ws.reportingService.GenerateHTMLReport()
+	html, err := ws.reportingService.GenerateHTMLReport(r.Context(), scope)
 	if err != nil {
 		log.Printf("Failed to generate HTML report: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -217,71 +412,843 @@ func (ws *WebServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (ws *WebServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+// parseReportScope reads the labels and group_by query parameters
+func parseReportScope(r *http.Request) (domain.ReportScope, error) {
+	query := r.URL.Query()
+
+	labels, err := domain.ParseLabels(query.Get("labels"))
+	if err != nil {
+		return domain.ReportScope{}, fmt.Errorf("invalid labels: %v", err)
+	}
+
+	groupBy := query.Get("group_by")
+	if groupBy != "" {
+		if err := domain.ValidateLabels(map[string]string{groupBy: ""}); err != nil {
+			return domain.ReportScope{}, fmt.Errorf("invalid group_by: %v", err)
+		}
+	}
+
+	return domain.ReportScope{Labels: labels, GroupBy: groupBy}, nil
+}
+
+func (ws *WebServer) handleNodes(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get basic health information
-	ctx := r.Context()
-	nodes, err := ws.nodeService.GetKnownNodes(ctx)
+	labels, err := domain.ParseLabels(r.URL.Query().Get("labels"))
 	if err != nil {
-		log.Printf("Failed to get nodes for health check: %v", err)
+		http.Error(w, fmt.Sprintf("Invalid labels: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	nodes, err := ws.nodeService.GetKnownNodes(r.Context())
+	if err != nil {
+		log.Printf("Failed to get known nodes: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	nodeInfo, err := ws.configSvc.GetNodeInfo()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := json.NewEncoder(w).Encode(filterNodes(nodes, labels)); err != nil {
+		log.Printf("Failed to encode nodes: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (ws *WebServer) handleTopology(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	graph, err := ws.reportingService.GenerateTopology(r.Context())
 	if err != nil {
-		log.Printf("Failed to get node info for health check: %v", err)
+		log.Printf("Failed to generate topology: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	health := map[string]interface{}{
-		"status":      "healthy",
-		"timestamp":   time.Now().Format(time.RFC3339),
-		"node_id":     nodeInfo.ID,
-		"node_fqdn":   nodeInfo.FQDN,
-		"node_ip":     nodeInfo.IP,
-		"known_nodes": len(nodes),
-		"uptime":      time.Since(time.Now()).String(), // This is just a placeholder
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := json.NewEncoder(w).Encode(graph); err != nil {
+		log.Printf("Failed to encode topology: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (ws *WebServer) handleNodeDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	detail, err := ws.reportingService.GenerateNodeDetail(r.Context(), r.PathValue("id"), r.URL.Query().Get("range"))
+	if err != nil {
+		writeNodeDetailError(w, err)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(health); err != nil {
-		log.Printf("Failed to encode health response: %v", err)
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := json.NewEncoder(w).Encode(detail); err != nil {
+		log.Printf("Failed to encode node detail: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 }
 
-func (ws *WebServer) loggingMiddleware(next http.Handler) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+func (ws *WebServer) handleNodeDetailPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-		// Create a response writer wrapper to capture status code
-		wrapped := &responseWriter{ResponseWriter: w, statusCode: 200}
+	html, err := ws.reportingService.GenerateNodeDetailHTML(r.Context(), r.PathValue("id"), r.URL.Query().Get("range"))
+	if err != nil {
+		writeNodeDetailError(w, err)
+		return
+	}
 
-		// Process request
-		next.ServeHTTP(wrapped, r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if _, err := w.Write([]byte(html)); err != nil {
+		log.Printf("Failed to write HTML response: %v", err)
+	}
+}
 
-		// Log request
-		duration := time.Since(start)
-		log.Printf("%s %s %d %v %s",
-			r.Method, r.URL.Path, wrapped.statusCode, duration, r.RemoteAddr)
+// handleNodeRollups serves a node's aggregated history. resolution is 1m or
+// 1h (default) and window a duration such as 720h (default 24h).
+func (ws *WebServer) handleNodeRollups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resolution := r.URL.Query().Get("resolution")
+	if resolution == "" {
+		resolution = domain.RollupHour
+	}
+
+	window := 24 * time.Hour
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid window", http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	rollups, err := ws.reportingService.GetNodeRollups(r.Context(), r.PathValue("id"), resolution, window)
+	if err != nil {
+		writeNodeDetailError(w, err)
+		return
+	}
+	if rollups == nil {
+		rollups = []domain.PollRollup{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := json.NewEncoder(w).Encode(rollups); err != nil {
+		log.Printf("Failed to encode rollups: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 }
 
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
+func (ws *WebServer) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	window := 24 * time.Hour
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid window", http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	alerts, err := ws.reportingService.GetAlerts(r.Context(), time.Now().Add(-window))
+	if err != nil {
+		log.Printf("Failed to get alerts: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := json.NewEncoder(w).Encode(alerts); err != nil {
+		log.Printf("Failed to encode alerts: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 }
 
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
+func (ws *WebServer) handleFlapping(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flapping, err := ws.reportingService.GetFlapStates(r.Context())
+	if err != nil {
+		log.Printf("Failed to get flap states: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := json.NewEncoder(w).Encode(flapping); err != nil {
+		log.Printf("Failed to encode flap states: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (ws *WebServer) handleBaselines(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	baselines, err := ws.anomalyService.GetBaselines(r.Context())
+	if err != nil {
+		log.Printf("Failed to get latency baselines: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := json.NewEncoder(w).Encode(baselines); err != nil {
+		log.Printf("Failed to encode latency baselines: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (ws *WebServer) handleCoordinates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	coordinates, err := ws.coordinates.GetCoordinates(r.Context())
+	if err != nil {
+		log.Printf("Failed to get coordinates: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := json.NewEncoder(w).Encode(coordinates); err != nil {
+		log.Printf("Failed to encode coordinates: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (ws *WebServer) handleRTT(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if to == "" {
+		http.Error(w, "Missing to", http.StatusBadRequest)
+		return
+	}
+	if from == "" {
+		nodeID, err := ws.configSvc.GetNodeID()
+		if err != nil {
+			log.Printf("Failed to get node ID: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		from = nodeID
+	}
+
+	estimate, err := ws.coordinates.EstimateRTT(r.Context(), from, to)
+	if errors.Is(err, domain.ErrCoordinateNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to estimate RTT: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := json.NewEncoder(w).Encode(estimate); err != nil {
+		log.Printf("Failed to encode RTT estimate: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (ws *WebServer) handleLocalities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	label := r.URL.Query().Get("label")
+	if label == "" {
+		label = domain.LocalityLabels[0]
+	}
+	if err := domain.ValidateLabels(map[string]string{label: ""}); err != nil {
+		http.Error(w, "Invalid label", http.StatusBadRequest)
+		return
+	}
+
+	window := domain.LocalityWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid window", http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	matrix, err := ws.reportingService.GetLocalityMatrix(r.Context(), label, time.Now().Add(-window))
+	if err != nil {
+		log.Printf("Failed to get locality matrix: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := json.NewEncoder(w).Encode(matrix); err != nil {
+		log.Printf("Failed to encode locality matrix: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (ws *WebServer) handleAnomalies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	window := domain.AnomalyWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid window", http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	anomalies, err := ws.anomalyService.GetAnomalies(r.Context(), time.Now().Add(-window))
+	if err != nil {
+		log.Printf("Failed to get latency anomalies: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := json.NewEncoder(w).Encode(anomalies); err != nil {
+		log.Printf("Failed to encode latency anomalies: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (ws *WebServer) handleSilences(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		ws.requireRole(domain.RoleViewer, ws.handleListSilences)(w, r)
+	case http.MethodPost:
+		ws.requireRole(domain.RoleOperator, ws.handleCreateSilence)(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (ws *WebServer) handleListSilences(w http.ResponseWriter, r *http.Request) {
+	silences, err := ws.silenceService.GetSilences(r.Context())
+	if err != nil {
+		log.Printf("Failed to get silences: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := json.NewEncoder(w).Encode(silences); err != nil {
+		log.Printf("Failed to encode silences: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (ws *WebServer) handleCreateSilence(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		domain.Silence
+		Duration string `json:"duration"` // alternative to ends_at, from starts_at
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Bad request: invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	silence := request.Silence
+	if request.Duration != "" {
+		duration, err := time.ParseDuration(request.Duration)
+		if err != nil || !silence.EndsAt.IsZero() {
+			http.Error(w, "Bad request: give either ends_at or a valid duration", http.StatusBadRequest)
+			return
+		}
+		if silence.StartsAt.IsZero() {
+			silence.StartsAt = time.Now()
+		}
+		silence.EndsAt = silence.StartsAt.Add(duration)
+	}
+	silence.CreatedBy = principalName(r.Context())
+
+	if err := ws.silenceService.CreateSilence(r.Context(), &silence); err != nil {
+		if errors.Is(err, domain.ErrInvalidSilence) {
+			http.Error(w, "Bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Failed to create silence: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Silence %d created by %s until %s", silence.ID, silence.CreatedBy, silence.EndsAt.Format(time.RFC3339))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(silence)
+}
+
+func (ws *WebServer) handleDeleteSilence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Silence not found", http.StatusNotFound)
+		return
+	}
+
+	if err := ws.silenceService.DeleteSilence(r.Context(), id); err != nil {
+		if errors.Is(err, domain.ErrSilenceNotFound) {
+			http.Error(w, "Silence not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to delete silence %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Silence %d removed by %s", id, principalName(r.Context()))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ws *WebServer) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	opts := domain.ExportOptions{
+		Format: query.Get("format"),
+		Table:  query.Get("table"),
+	}
+
+	now := time.Now()
+	var err error
+	if opts.Since, err = ParseExportTime(query.Get("since"), now); err != nil {
+		http.Error(w, "Invalid since", http.StatusBadRequest)
+		return
+	}
+	if opts.Until, err = ParseExportTime(query.Get("until"), now); err != nil {
+		http.Error(w, "Invalid until", http.StatusBadRequest)
+		return
+	}
+
+	extension := domain.ExportFormatNDJSON
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	switch opts.Format {
+	case domain.ExportFormatCSV:
+		extension = domain.ExportFormatCSV
+		w.Header().Set("Content-Type", "text/csv")
+	case domain.ExportFormatParquet:
+		extension = domain.ExportFormatParquet
+		w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="nodeprobe-export.%s"`, extension))
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	// Large ranges take longer than the server's write timeout to stream
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Failed to lift write deadline for export: %v", err)
+	}
+
+	out := &exportWriter{Writer: w}
+	if err := ws.exportService.Export(r.Context(), out, opts); err != nil {
+		// Options are checked before anything is written, so these can still set the status
+		switch {
+		case errors.Is(err, domain.ErrInvalidExport), errors.Is(err, domain.ErrInvalidRange):
+			w.Header().Del("Content-Disposition")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case !out.wrote:
+			log.Printf("Failed to export data: %v", err)
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		default:
+			// The status is sent; the client sees a truncated download
+			log.Printf("Failed to export data: %v", err)
+		}
+	}
+}
+
+// exportWriter records whether an export has written any of the response
+type exportWriter struct {
+	io.Writer
+	wrote bool
+}
+
+func (ew *exportWriter) Write(p []byte) (int, error) {
+	ew.wrote = true
+	return ew.Writer.Write(p)
+}
+
+// writeNodeDetailError maps node detail errors onto HTTP status codes
+func writeNodeDetailError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNodeNotFound):
+		http.Error(w, "Node not found", http.StatusNotFound)
+	case errors.Is(err, domain.ErrInvalidRange):
+		http.Error(w, "Invalid range", http.StatusBadRequest)
+	default:
+		log.Printf("Failed to generate node detail: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (ws *WebServer) handleSLOStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status, err := ws.sloService.GetSLOStatus(r.Context())
+	if err != nil {
+		log.Printf("Failed to get SLO status: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Printf("Failed to encode SLO status: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSLOReport serves the report for month (YYYY-MM, default the current
+// month) as JSON or, with format=csv, as a CSV download
+func (ws *WebServer) handleSLOReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format != "" && format != "json" && format != domain.ExportFormatCSV {
+		http.Error(w, "Invalid format", http.StatusBadRequest)
+		return
+	}
+
+	month, err := ParseSLOMonth(query.Get("month"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := ws.sloService.GetSLOReport(r.Context(), month)
+	if err != nil {
+		writeSLOError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if format == domain.ExportFormatCSV {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="nodeprobe-slo-%s.csv"`, report.Month))
+		if err := WriteSLOReportCSV(w, report); err != nil {
+			log.Printf("Failed to write SLO report: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Failed to encode SLO report: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (ws *WebServer) handleSLOPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	month, err := ParseSLOMonth(r.URL.Query().Get("month"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	html, err := ws.sloService.GenerateSLOReportHTML(r.Context(), month)
+	if err != nil {
+		writeSLOError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if _, err := w.Write([]byte(html)); err != nil {
+		log.Printf("Failed to write HTML response: %v", err)
+	}
+}
+
+// writeSLOError maps SLO report errors onto HTTP status codes
+func writeSLOError(w http.ResponseWriter, err error) {
+	if errors.Is(err, domain.ErrInvalidRange) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Failed to generate SLO report: %v", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
+func (ws *WebServer) handleFingerprints(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	nodes, err := ws.nodeService.GetKnownNodes(r.Context())
+	if err != nil {
+		log.Printf("Failed to get known nodes: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type peerFingerprint struct {
+		NodeID             string `json:"node_id"`
+		FQDN               string `json:"fqdn"`
+		IP                 string `json:"ip"`
+		CertFingerprint    string `json:"cert_fingerprint"`
+		PendingFingerprint string `json:"pending_fingerprint,omitempty"`
+	}
+
+	peers := make([]peerFingerprint, 0, len(nodes))
+	for _, node := range nodes {
+		peers = append(peers, peerFingerprint{
+			NodeID:             node.ID,
+			FQDN:               node.FQDN,
+			IP:                 node.IP,
+			CertFingerprint:    node.CertFingerprint,
+			PendingFingerprint: node.PendingFingerprint,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(peers); err != nil {
+		log.Printf("Failed to encode fingerprints: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (ws *WebServer) handleApproveFingerprint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Fingerprint string `json:"fingerprint"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Fingerprint == "" {
+		http.Error(w, "Bad request: fingerprint is required", http.StatusBadRequest)
+		return
+	}
+
+	nodeID := r.PathValue("id")
+	if err := ws.nodeService.ApproveFingerprint(r.Context(), nodeID, request.Fingerprint); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNodeNotFound):
+			http.Error(w, "Node not found", http.StatusNotFound)
+		case errors.Is(err, domain.ErrNoPendingFingerprint):
+			http.Error(w, "Fingerprint does not match the pending certificate change", http.StatusConflict)
+		default:
+			log.Printf("Failed to approve fingerprint for node %s: %v", nodeID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Printf("Certificate fingerprint for node %s approved by %s", nodeID, principalName(r.Context()))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Certificate fingerprint approved",
+	})
+}
+
+func (ws *WebServer) handleTopologyPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	page, err := fs.ReadFile(ws.renderer.Static(), "topology.html")
+	if err != nil {
+		log.Printf("Failed to read topology page: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := w.Write(page); err != nil {
+		log.Printf("Failed to write topology page: %v", err)
+	}
+}
+
+func (ws *WebServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get basic health information
+	ctx := r.Context()
+	nodes, err := ws.nodeService.GetKnownNodes(ctx)
+	if err != nil {
+		log.Printf("Failed to get nodes for health check: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	nodeInfo, err := ws.configSvc.GetNodeInfo()
+	if err != nil {
+		log.Printf("Failed to get node info for health check: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	health := map[string]interface{}{
+		"status":              "healthy",
+		"timestamp":           time.Now().Format(time.RFC3339),
+		"node_id":             nodeInfo.ID,
+		"node_fqdn":           nodeInfo.FQDN,
+		"node_ip":             nodeInfo.IP,
+		"known_nodes":         len(nodes),
+		"snapshot_signatures": ws.signingService.Stats(),
+		"tls_certificate":     ws.certificateStatus(),
+		"uptime":              time.Since(time.Now()).String(), // This is just a placeholder
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		log.Printf("Failed to encode health response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+type peerNodeIDKey struct{}
+
+// requirePeer restricts inter-node endpoints to clients presenting a node
+// certificate issued by the cluster CA when mutual TLS is enabled
+func (ws *WebServer) requirePeer(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ws.tlsService.MutualTLSEnabled() {
+			next(w, r)
+			return
+		}
+
+		peerID, err := ws.tlsService.VerifyPeer(r.TLS)
+		if err != nil {
+			log.Printf("Rejected %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+			http.Error(w, "Client certificate required", http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), peerNodeIDKey{}, peerID)))
+	}
+}
+
+// peerNodeID returns the node ID of the verified client certificate, if any
+func peerNodeID(ctx context.Context) (string, bool) {
+	peerID, ok := ctx.Value(peerNodeIDKey{}).(string)
+	return peerID, ok
+}
+
+func (ws *WebServer) loggingMiddleware(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// Create a response writer wrapper to capture status code
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: 200}
+
+		// Process request
+		next.ServeHTTP(wrapped, r)
+
+		// Log request
+		duration := time.Since(start)
+		log.Printf("%s %s %d %v %s",
+			r.Method, r.URL.Path, wrapped.statusCode, duration, r.RemoteAddr)
+	}
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection, so
+// handlers can adjust their write deadline
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
 }
 
 // GetReceivedReports returns the recent network snapshots received from other nodes
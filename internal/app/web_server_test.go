@@ -0,0 +1,20 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleNodeRollupsRejectsInvalidWindow(t *testing.T) {
+	ws := &WebServer{}
+	for _, window := range []string{"0s", "-24h", "soon"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/nodes/peer/rollups?window="+window, nil)
+		req.SetPathValue("id", "peer")
+		rec := httptest.NewRecorder()
+		ws.handleNodeRollups(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("window %s: status = %d, want 400", window, rec.Code)
+		}
+	}
+}
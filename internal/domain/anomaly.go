@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// BaselineStat is an exponentially weighted mean and variance of latency
+type BaselineStat struct {
+	Samples  int64
+	Mean     float64
+	Variance float64
+}
+
+// Add folds ms into the statistic with weight alpha. Until 1/Samples drops
+// below alpha every poll weighs the same, so early polls are averaged rather
+// than dominated by the first.
+func (s *BaselineStat) Add(ms float64, alpha float64) {
+	s.Samples++
+	weight := math.Max(alpha, 1/float64(s.Samples))
+	diff := ms - s.Mean
+	increment := weight * diff
+	s.Mean += increment
+	s.Variance = (1 - weight) * (s.Variance + diff*increment)
+}
+
+// StdDev returns the standard deviation
+func (s *BaselineStat) StdDev() float64 {
+	return math.Sqrt(s.Variance)
+}
+
+// LatencyBaseline is the learned latency of one link, overall and for each
+// hour of day in UTC, so daily load patterns are not mistaken for anomalies
+type LatencyBaseline struct {
+	Overall BaselineStat
+	Hourly  [24]BaselineStat
+}
+
+// Add learns a poll's latency observed at t
+func (b *LatencyBaseline) Add(ms float64, t time.Time, alpha float64) {
+	b.Overall.Add(ms, alpha)
+	b.Hourly[t.UTC().Hour()].Add(ms, alpha)
+}
+
+// Expected returns the mean and standard deviation a poll at t is judged
+// against: its hour of day once that hour has minSamples polls, otherwise
+// the overall baseline. ok is false while the link has fewer than
+// minSamples polls.
+func (b *LatencyBaseline) Expected(t time.Time, minSamples int) (mean, stddev float64, ok bool) {
+	if hour := &b.Hourly[t.UTC().Hour()]; hour.Samples >= int64(minSamples) {
+		return hour.Mean, hour.StdDev(), true
+	}
+	return b.Overall.Mean, b.Overall.StdDev(), b.Overall.Samples >= int64(minSamples)
+}
+
+// AnomalyDetector learns the latency baseline of the link to NodeID and
+// tracks polls that deviate from it. It is not safe for concurrent use.
+type AnomalyDetector struct {
+	NodeID   string
+	Baseline LatencyBaseline
+
+	pending *AnomalyEvent // anomalous polls in a row, until ConsecutivePolls open an event
+	event   *AnomalyEvent // open event
+}
+
+// Record judges a successful poll's latency observed at t against the
+// baseline, then learns it. It returns the events the poll ended or opened,
+// in that order; a poll deviating the other way does both.
+func (d *AnomalyDetector) Record(ms float64, t time.Time, config AnomalyConfig) []AnomalyEvent {
+	mean, stddev, ok := d.Baseline.Expected(t, config.MinSamples)
+	if !ok {
+		d.Baseline.Add(ms, t, config.Alpha)
+		return nil
+	}
+
+	// The floor makes MinDeviationMs the smallest deviation that counts
+	stddev = math.Max(stddev, config.MinDeviationMs/config.Sigma)
+	sigma := (ms - mean) / stddev
+
+	// Polls are learned clipped to the normal range, so a spike cannot
+	// inflate the baseline while a lasting shift is still adopted over time
+	bound := config.Sigma * stddev
+	d.Baseline.Add(math.Max(mean-bound, math.Min(ms, mean+bound)), t, config.Alpha)
+
+	direction := ""
+	switch {
+	case sigma > config.Sigma:
+		direction = AnomalyAbove
+	case sigma < -config.Sigma:
+		direction = AnomalyBelow
+	}
+
+	var changed []AnomalyEvent
+	if d.event != nil && d.event.Direction != direction {
+		ended := *d.event
+		ended.EndedAt = &t
+		changed = append(changed, ended)
+		d.event = nil
+	}
+	if direction == "" {
+		d.pending = nil
+		return changed
+	}
+
+	event := d.event
+	if event == nil {
+		if d.pending == nil || d.pending.Direction != direction {
+			d.pending = &AnomalyEvent{NodeID: d.NodeID, Direction: direction, StartedAt: t}
+		}
+		event = d.pending
+	}
+
+	event.Polls++
+	event.LatestMs = ms
+	if math.Abs(sigma) > math.Abs(event.Sigma) {
+		event.PeakMs = ms
+		event.ExpectedMs = mean
+		event.StdDevMs = stddev
+		event.Sigma = sigma
+	}
+
+	if d.event == nil && d.pending.Polls >= config.ConsecutivePolls {
+		d.event, d.pending = d.pending, nil
+		changed = append(changed, *d.event)
+	}
+	return changed
+}
+
+// Event returns the open event, or nil when the link is normal
+func (d *AnomalyDetector) Event() *AnomalyEvent {
+	if d.event == nil {
+		return nil
+	}
+	event := *d.event
+	return &event
+}
+
+// State returns the link's baseline, with the values expected for a poll
+// at t
+func (d *AnomalyDetector) State(t time.Time, config AnomalyConfig) LinkBaseline {
+	mean, stddev, ok := d.Baseline.Expected(t, config.MinSamples)
+	state := LinkBaseline{
+		NodeID:           d.NodeID,
+		Samples:          d.Baseline.Overall.Samples,
+		MeanMs:           d.Baseline.Overall.Mean,
+		StdDevMs:         d.Baseline.Overall.StdDev(),
+		ExpectedMs:       mean,
+		ExpectedStdDevMs: stddev,
+		Learning:         !ok,
+		Hourly:           []HourlyBaseline{},
+		Anomaly:          d.Event(),
+	}
+	for hour := range d.Baseline.Hourly {
+		stat := &d.Baseline.Hourly[hour]
+		if stat.Samples > 0 {
+			state.Hourly = append(state.Hourly, HourlyBaseline{
+				Hour:     hour,
+				Samples:  stat.Samples,
+				MeanMs:   stat.Mean,
+				StdDevMs: stat.StdDev(),
+			})
+		}
+	}
+	return state
+}
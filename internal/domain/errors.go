@@ -0,0 +1,42 @@
+package domain
+
+import "errors"
+
+// Sentinel errors shared across services
+var (
+	ErrNodeNotFound = errors.New("node not found")
+	ErrInvalidRange = errors.New("invalid time range")
+	ErrHTMLDisabled = errors.New("HTML dashboard is disabled")
+
+	ErrFingerprintMismatch  = errors.New("certificate fingerprint does not match pinned fingerprint")
+	ErrNoPendingFingerprint = errors.New("no matching pending fingerprint to approve")
+	ErrPeerIdentityMismatch = errors.New("peer certificate identifies a different node")
+
+	ErrInvalidNode       = errors.New("invalid node")
+	ErrInvalidNodeConfig = errors.New("invalid node config")
+
+	ErrInvalidExport = errors.New("invalid export")
+
+	ErrInvalidAlertConfig = errors.New("invalid alert config")
+	ErrInvalidSilence     = errors.New("invalid silence")
+	ErrSilenceNotFound    = errors.New("silence not found")
+
+	ErrInvalidSLOConfig = errors.New("invalid SLO config")
+
+	ErrInvalidCoordinateConfig = errors.New("invalid coordinate config")
+	ErrCoordinateNotFound      = errors.New("no coordinate for node")
+
+	ErrSchemaTooNew = errors.New("database schema is newer than this version of nodeprobe supports")
+
+	ErrBackupUnsupported = errors.New("storage backend does not support backups")
+	ErrInvalidBackup     = errors.New("invalid backup")
+
+	ErrUnauthenticated = errors.New("missing or invalid API token")
+	ErrForbidden       = errors.New("role not permitted")
+
+	ErrUnsignedSnapshot = errors.New("snapshot is not signed")
+	ErrUnknownSigner    = errors.New("snapshot signer is not trusted")
+	ErrInvalidSignature = errors.New("snapshot signature is invalid")
+	ErrStaleSnapshot    = errors.New("snapshot timestamp is outside the allowed window")
+	ErrReplayedSnapshot = errors.New("snapshot nonce has already been used")
+)
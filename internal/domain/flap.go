@@ -0,0 +1,88 @@
+package domain
+
+import "time"
+
+// FlapDetector scores the recent states of one node or link and tracks
+// whether it is flapping. It is not safe for concurrent use.
+type FlapDetector struct {
+	states   []bool // oldest first, at most FlapHistorySize
+	score    float64
+	flapping bool
+	since    time.Time
+}
+
+// Record adds a state observed at t and reports whether the node or link
+// started or stopped flapping
+func (d *FlapDetector) Record(state bool, t time.Time, config FlapConfig) bool {
+	d.states = append(d.states, state)
+	if len(d.states) > FlapHistorySize {
+		d.states = d.states[len(d.states)-FlapHistorySize:]
+	}
+	d.score = PercentStateChange(d.states)
+
+	switch {
+	case !d.flapping && d.score >= config.HighThreshold:
+		d.flapping = true
+		d.since = t
+		return true
+	case d.flapping && d.score < config.LowThreshold:
+		d.flapping = false
+		d.since = time.Time{}
+		return true
+	}
+	return false
+}
+
+// Flapping reports whether the node or link is flapping
+func (d *FlapDetector) Flapping() bool {
+	return d.flapping
+}
+
+// State returns the detector's score for nodeID, polled by observer when it
+// scores a link
+func (d *FlapDetector) State(nodeID string, observer string) FlapState {
+	state := FlapState{
+		NodeID:   nodeID,
+		Observer: observer,
+		Score:    d.score,
+		Flapping: d.flapping,
+	}
+	if d.flapping {
+		since := d.since
+		state.Since = &since
+	}
+	return state
+}
+
+// PercentStateChange scores the last FlapHistorySize states like Nagios: the
+// share of the 20 possible changes that happened, weighted from 0.8 for the
+// oldest to 1.2 for the newest so recent changes count more. A shorter
+// history scores as if the earliest state had held before it.
+func PercentStateChange(states []bool) float64 {
+	if len(states) > FlapHistorySize {
+		states = states[len(states)-FlapHistorySize:]
+	}
+
+	const changes = FlapHistorySize - 1
+	total := 0.0
+	for i := 1; i < len(states); i++ {
+		if states[i] != states[i-1] {
+			age := len(states) - 1 - i // 0 for the newest change
+			total += 1.2 - 0.4*float64(age)/float64(changes-1)
+		}
+	}
+	return total / changes * 100
+}
+
+// NodeState returns the flap score of nodeID, or a zero state when it has
+// none
+func (r *FlapReport) NodeState(nodeID string) FlapState {
+	if r != nil {
+		for _, state := range r.Nodes {
+			if state.NodeID == nodeID {
+				return state
+			}
+		}
+	}
+	return FlapState{NodeID: nodeID}
+}
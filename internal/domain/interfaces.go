@@ -2,6 +2,9 @@ package domain
 
 import (
 	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
 	"time"
 )
 
@@ -11,22 +14,66 @@ type NodeRepository interface {
 	GetNode(ctx context.Context, id string) (*Node, error)
 	CreateNode(ctx context.Context, node *Node) error
 	UpdateNode(ctx context.Context, node *Node) error
+	UpsertNode(ctx context.Context, node *Node) error // keeps the stored first seen time and fingerprints
 	DeleteNode(ctx context.Context, id string) error
 	GetActiveNodes(ctx context.Context) ([]Node, error)
+	UpdateNodeFingerprints(ctx context.Context, id string, pinned string, pending string) error
 }
 
 // PollRepository defines the interface for poll result storage operations
 type PollRepository interface {
 	CreatePollResult(ctx context.Context, result *PollResult) error
+	CreatePollResults(ctx context.Context, results []PollResult) error
+	ImportPollResults(ctx context.Context, results []PollResult) (int64, error) // skips results already stored, returns the number added
 	GetPollResults(ctx context.Context, nodeID string, limit int) ([]PollResult, error)
 	GetRecentPollResults(ctx context.Context, since time.Time) ([]PollResult, error)
-	CleanupOldResults(ctx context.Context, maxSizeMB int) error
+	GetNodePollResultsSince(ctx context.Context, nodeID string, since time.Time) ([]PollResult, error)
+	GetPollResultsBetween(ctx context.Context, since, until time.Time) ([]PollResult, error)
+	DeletePollResultsBefore(ctx context.Context, before time.Time) (int64, error)
+	SaveRollups(ctx context.Context, resolution string, rollups []PollRollup, rolledUntil time.Time) error
+	GetRollups(ctx context.Context, resolution string, nodeID string, since, until time.Time) ([]PollRollup, error) // empty nodeID returns all nodes
+	GetRollupWatermark(ctx context.Context, resolution string) (time.Time, error)                                   // zero before the first rollup
+	DeleteRollupsBefore(ctx context.Context, resolution string, before time.Time) (int64, error)
+	ReclaimSpace(ctx context.Context) error
 	GetDatabaseSize(ctx context.Context) (int64, error)
 }
 
+// AlertRepository defines the interface for alert history storage
+type AlertRepository interface {
+	SaveAlert(ctx context.Context, alert *Alert) error // inserts when ID is zero and sets it, updates otherwise
+	GetFiringAlerts(ctx context.Context) ([]Alert, error)
+	GetAlertHistory(ctx context.Context, since time.Time, limit int) ([]Alert, error) // fired since, newest first
+}
+
+// SilenceRepository defines the interface for silence storage
+type SilenceRepository interface {
+	CreateSilence(ctx context.Context, silence *Silence) error               // sets the ID
+	GetSilences(ctx context.Context, endsAfter time.Time) ([]Silence, error) // by start time
+	DeleteSilence(ctx context.Context, id int64) error
+}
+
+// Store is a storage backend providing all repositories
+type Store interface {
+	NodeRepository
+	PollRepository
+	AlertRepository
+	SilenceRepository
+	Close() error
+}
+
+// BackupStore is implemented by storage backends that can write a
+// consistent copy of their database to path while in use
+type BackupStore interface {
+	Backup(ctx context.Context, path string) error
+}
+
+// PeerVerifier decides during the TLS handshake whether a peer may be talked
+// to; an error aborts the handshake before any request is sent
+type PeerVerifier func(identity PeerIdentity) error
+
 // HTTPClient defines the interface for making HTTP requests to other nodes
 type HTTPClient interface {
-	GetNodeInfo(ctx context.Context, nodeURL string) (*NodeInfo, error)
+	GetNodeInfo(ctx context.Context, nodeURL string, verify PeerVerifier) (*NodeInfo, error) // a nil verify accepts any peer
 	SendNetworkSnapshot(ctx context.Context, reportingURL string, snapshot *NetworkSnapshot) error
 	TestPathMTU(ctx context.Context, nodeURL string) (int, error)
 }
@@ -35,6 +82,17 @@ type HTTPClient interface {
 type ConfigService interface {
 	LoadSeedConfig() (*SeedConfig, error)
 	LoadReportingConfig() (*ReportingConfig, error)
+	LoadDashboardConfig() (*DashboardConfig, error)
+	LoadTLSConfig() (*TLSConfig, error)
+	LoadSigningConfig() (*SigningConfig, error)
+	LoadAuthConfig() (*AuthConfig, error)
+	LoadLimitsConfig() (*LimitsConfig, error)
+	LoadRetentionConfig() (*RetentionConfig, error)
+	LoadStorageConfig() (*StorageConfig, error)
+	LoadBackupConfig() (*BackupConfig, error)
+	LoadAlertConfig() (*AlertConfig, error)
+	LoadSLOConfig() (*SLOConfig, error)
+	LoadCoordinateConfig() (*CoordinateConfig, error)
 	GetNodeID() (string, error)
 	GetNodeInfo() (*NodeInfo, error)
 	SaveNodeID(id string) error
@@ -42,8 +100,28 @@ type ConfigService interface {
 
 // TLSService defines the interface for TLS certificate management
 type TLSService interface {
-	GenerateSelfSignedCert() error
+	EnsureCertificate() error
 	GetCertPath() (string, string, error) // returns cert path, key path, error
+	CertificateExpiry() (time.Time, error)
+	ServerTLSConfig() (*tls.Config, error)
+	ClientTLSConfig(verify PeerVerifier) (*tls.Config, error) // verify, if not nil, is called in every handshake
+	MutualTLSEnabled() bool
+	VerifyPeer(state *tls.ConnectionState) (string, error) // returns the verified peer node ID
+}
+
+// SigningService defines the interface for signing and verifying network snapshots
+type SigningService interface {
+	Enabled() bool
+	SignRequest(req *http.Request, body []byte) error
+	VerifyRequest(r *http.Request, body []byte) (string, error) // returns the verified signer node ID
+	Stats() SignatureStats
+}
+
+// AuthService defines the interface for API token authentication
+type AuthService interface {
+	Enabled() bool
+	Authenticate(token string) (*Principal, error)
+	Authorize(principal *Principal, role string) error
 }
 
 // PollingService defines the interface for the polling service
@@ -58,7 +136,56 @@ type ReportingService interface {
 	Start(ctx context.Context) error
 	Stop() error
 	SendReport(ctx context.Context) error
-	GenerateHTMLReport() (string, error)
+	GenerateReport(ctx context.Context, scope ReportScope) (*Report, error)
+	GenerateHTMLReport(ctx context.Context, scope ReportScope) (string, error)
+	GenerateTopology(ctx context.Context) (*TopologyGraph, error)
+	GenerateNodeDetail(ctx context.Context, nodeID string, rangeName string) (*NodeDetail, error)
+	GenerateNodeDetailHTML(ctx context.Context, nodeID string, rangeName string) (string, error)
+	GetNodeRollups(ctx context.Context, nodeID string, resolution string, window time.Duration) ([]PollRollup, error)
+	GetAlerts(ctx context.Context, since time.Time) ([]Alert, error)
+	GetFlapStates(ctx context.Context) (*FlapReport, error)
+	GetLocalityMatrix(ctx context.Context, label string, since time.Time) (*LocalityMatrix, error)
+}
+
+// SilenceService defines the interface for managing alert silences
+type SilenceService interface {
+	CreateSilence(ctx context.Context, silence *Silence) error
+	GetSilences(ctx context.Context) ([]Silence, error) // active and upcoming
+	DeleteSilence(ctx context.Context, id int64) error
+}
+
+// SLOService defines the interface for evaluating service level objectives
+type SLOService interface {
+	GetSLOStatus(ctx context.Context) ([]SLOStatus, error)                 // over each objective's rolling window
+	GetSLOReport(ctx context.Context, month time.Time) (*SLOReport, error) // for one calendar month in UTC
+	GenerateSLOReportHTML(ctx context.Context, month time.Time) (string, error)
+}
+
+// CoordinateService maintains Vivaldi network coordinates from poll round
+// trips and estimates round-trip times between any two nodes
+type CoordinateService interface {
+	Observe(ctx context.Context, nodeInfo *NodeInfo, rttMs float64) // a successful poll of nodeInfo.ID
+	LocalCoordinate() Coordinate
+	GetCoordinates(ctx context.Context) ([]NodeCoordinate, error) // this node's, then fresh ones of other nodes by ID
+	EstimateRTT(ctx context.Context, from, to string) (*RTTEstimate, error)
+}
+
+// AnomalyService defines the interface for latency baselines and the
+// anomalies detected against them
+type AnomalyService interface {
+	GetBaselines(ctx context.Context) ([]LinkBaseline, error)
+	GetAnomalies(ctx context.Context, since time.Time) ([]AnomalyEvent, error) // ongoing, then started since, newest first
+}
+
+// AlertNotifier delivers alert notifications to a channel
+type AlertNotifier interface {
+	Notify(ctx context.Context, channel *AlertChannel, notification *AlertNotification) error
+}
+
+// ExportService defines the interface for exporting and importing stored data
+type ExportService interface {
+	Export(ctx context.Context, w io.Writer, opts ExportOptions) error
+	Import(ctx context.Context, r io.Reader, format string) (*ImportStats, error)
 }
 
 // WebServer defines the interface for the web server
@@ -74,4 +201,8 @@ type NodeService interface {
 	GetKnownNodes(ctx context.Context) ([]Node, error)
 	GetActiveNodes(ctx context.Context) ([]Node, error)
 	UpdateNodeStatus(ctx context.Context, nodeID string, isActive bool) error
+	GetNodeByID(ctx context.Context, nodeID string) (*Node, error)
+	VerifyPeerFingerprint(ctx context.Context, nodeID string, identity PeerIdentity) error
+	ApproveFingerprint(ctx context.Context, nodeID string, fingerprint string) error
+	GetFlapStates(ctx context.Context) ([]FlapState, error)
 }
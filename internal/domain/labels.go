@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Label limits, keeping what a peer can make this node store bounded
+const (
+	MaxNodeLabels       = 32
+	MaxLabelValueLength = 128
+)
+
+// labelNamePattern accepts names such as region, zone or app.tier
+var labelNamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_.-]{0,62})$`)
+
+// ValidateLabels checks the names, values and number of labels
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > MaxNodeLabels {
+		return fmt.Errorf("%d labels, at most %d allowed", len(labels), MaxNodeLabels)
+	}
+	for name, value := range labels {
+		if !labelNamePattern.MatchString(name) {
+			return fmt.Errorf("malformed label name %q", name)
+		}
+		if len(value) > MaxLabelValueLength || strings.ContainsAny(value, ",\n\r") {
+			return fmt.Errorf("label %s has a malformed value", name)
+		}
+	}
+	return nil
+}
+
+// LabelsMatch reports whether labels carries every name and value in want;
+// an empty want matches everything
+func LabelsMatch(want map[string]string, labels map[string]string) bool {
+	for name, value := range want {
+		if labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatLabels formats labels as name=value pairs sorted by name and
+// separated by commas, as read by ParseLabels
+func FormatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + labels[name]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ParseLabels parses name=value pairs separated by commas; empty yields nil
+func ParseLabels(value string) (map[string]string, error) {
+	var labels map[string]string
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, labelValue, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("label %q is not name=value", pair)
+		}
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[strings.TrimSpace(name)] = strings.TrimSpace(labelValue)
+	}
+	if err := ValidateLabels(labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
@@ -0,0 +1,37 @@
+package domain
+
+// Add records one poll on the link
+func (l *LocalityLink) Add(result PollResult) {
+	l.Polls++
+	if !result.Success {
+		return
+	}
+	l.Successes++
+	l.SumMs += float64(result.ResponseMs)
+	l.Sketch.Add(float64(result.ResponseMs))
+}
+
+// Summarize fills in the loss, average and percentile fields
+func (l *LocalityLink) Summarize() {
+	if l.Polls > 0 {
+		l.LossPercent = float64(l.Polls-l.Successes) / float64(l.Polls) * 100
+	}
+	if l.Successes == 0 {
+		return
+	}
+	l.AvgMs = l.SumMs / float64(l.Successes)
+	l.P50Ms = l.Sketch.Quantile(0.50)
+	l.P95Ms = l.Sketch.Quantile(0.95)
+	l.P99Ms = l.Sketch.Quantile(0.99)
+}
+
+// Link returns the link from source to target, or nil when no polls were
+// made between them
+func (m LocalityMatrix) Link(source, target string) *LocalityLink {
+	for i := range m.Links {
+		if m.Links[i].Source == source && m.Links[i].Target == target {
+			return &m.Links[i]
+		}
+	}
+	return nil
+}
@@ -13,6 +13,14 @@ type Node struct {
 	FirstSeen    time.Time `json:"first_seen" db:"first_seen"`
 	LastSeen     time.Time `json:"last_seen" db:"last_seen"`
 	IsActive     bool      `json:"is_active" db:"is_active"`
+
+	// Labels are the key/value metadata the node advertises in its node.json,
+	// such as region, zone or role
+	Labels map[string]string `json:"labels,omitempty" db:"labels"`
+
+	// Trust-on-first-use certificate pinning, see NodeService.VerifyPeerFingerprint
+	CertFingerprint    string `json:"cert_fingerprint,omitempty" db:"cert_fingerprint"`
+	PendingFingerprint string `json:"pending_fingerprint,omitempty" db:"pending_fingerprint"`
 }
 
 // PollResult represents the result of polling a node
@@ -24,6 +32,10 @@ type PollResult struct {
 	ResponseMs int64     `json:"response_ms" db:"response_ms"`
 	Error      string    `json:"error,omitempty" db:"error"`
 	PathMTU    int       `json:"path_mtu,omitempty" db:"path_mtu"`
+
+	// Observer is the ID of the node that made the poll; empty for this
+	// node's own polls and set on results imported from another node
+	Observer string `json:"observer,omitempty" db:"observer"`
 }
 
 // NetworkSnapshot represents a snapshot of all known nodes
@@ -31,6 +43,10 @@ type NetworkSnapshot struct {
 	Timestamp time.Time `json:"timestamp"`
 	NodeID    string    `json:"node_id"`
 	Nodes     []Node    `json:"nodes"`
+
+	// Labels are the reporting node's own labels: empty when it has none,
+	// and null from nodes that predate labels in snapshots
+	Labels map[string]string `json:"labels"`
 }
 
 // SeedConfig represents the seed.json configuration
@@ -44,24 +60,869 @@ type SeedNode struct {
 	IP   string `json:"ip"`
 }
 
+// NodeConfig represents the node.json configuration: metadata this node
+// advertises to its peers
+type NodeConfig struct {
+	Labels map[string]string `json:"labels"`
+}
+
 // ReportingConfig represents the reportingserver.json configuration
 type ReportingConfig struct {
 	ServerFQDN string `json:"server_fqdn"`
 	ServerIP   string `json:"server_ip"`
 }
 
+// TLSConfig represents the tls.json configuration
+type TLSConfig struct {
+	MutualTLS           bool   `json:"mutual_tls"`
+	CACert              string `json:"ca_cert"`
+	CAKey               string `json:"ca_key"`
+	PinPeerCertificates bool   `json:"pin_peer_certificates"`
+
+	// Generated certificates
+	KeyType       string   `json:"key_type"` // "rsa" (default), "ecdsa-p256" or "ed25519"
+	ValidityDays  int      `json:"validity_days"`
+	ExtraDNSNames []string `json:"extra_dns_names"`
+	ExtraIPs      []string `json:"extra_ips"`
+
+	// Operator-provided certificate, used instead of generating one
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+}
+
+// Certificate key types
+const (
+	KeyTypeRSA       = "rsa"
+	KeyTypeECDSAP256 = "ecdsa-p256"
+	KeyTypeEd25519   = "ed25519"
+)
+
+// SigningConfig represents the signing.json configuration
+type SigningConfig struct {
+	Mode             string            `json:"mode"` // "", "ed25519" or "hmac"
+	PrivateKey       string            `json:"private_key"`
+	SharedSecretFile string            `json:"shared_secret_file"`
+	TrustedKeys      map[string]string `json:"trusted_keys"` // node ID -> base64 Ed25519 public key
+	MaxClockSkew     int               `json:"max_clock_skew_seconds"`
+}
+
+// Snapshot signing modes
+const (
+	SigningModeEd25519 = "ed25519"
+	SigningModeHMAC    = "hmac"
+)
+
+// SignatureStats counts verified and rejected network snapshots
+type SignatureStats struct {
+	Mode             string `json:"mode"`
+	Accepted         uint64 `json:"accepted"`
+	Unsigned         uint64 `json:"unsigned"`
+	UnknownSigner    uint64 `json:"unknown_signer"`
+	InvalidSignature uint64 `json:"invalid_signature"`
+	Stale            uint64 `json:"stale"`
+	Replayed         uint64 `json:"replayed"`
+}
+
+// AuthConfig represents the auth.json configuration
+type AuthConfig struct {
+	Enabled       bool       `json:"enabled"`
+	Tokens        []APIToken `json:"tokens"`
+	PeerTokenFile string     `json:"peer_token_file"`
+}
+
+// APIToken is a bearer token accepted by the web server. Only the SHA-256
+// hash of the token is stored.
+type APIToken struct {
+	Name        string `json:"name"`
+	Role        string `json:"role"`
+	TokenSHA256 string `json:"token_sha256"`
+}
+
+// Principal identifies the caller of an authenticated request
+type Principal struct {
+	Name string
+	Role string
+}
+
+// Roles for API token authentication. Operators can do everything viewers
+// can; peers may only use the inter-node endpoints.
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RolePeer     = "peer"
+)
+
+// LimitsConfig represents the limits.json configuration. Zero values are
+// replaced with the defaults below when the file is loaded, except for
+// RateLimit, which only defaults when it is left out.
+type LimitsConfig struct {
+	MaxBodyBytes       int64    `json:"max_body_bytes"`
+	RateLimit          float64  `json:"rate_limit_per_second"` // per source IP, zero or negative disables
+	RateBurst          int      `json:"rate_burst"`
+	PeerCIDRs          []string `json:"peer_cidrs"` // allowed sources for /nodeinfo and /report, empty allows all
+	MaxNewNodesPerPeer int      `json:"max_new_nodes_per_peer"`
+	NewNodeWindow      int      `json:"new_node_window_seconds"`
+}
+
+// Default request limits
+const (
+	DefaultMaxBodyBytes       = 1 << 20
+	DefaultRateLimit          = 20
+	DefaultRateBurst          = 40
+	DefaultMaxNewNodesPerPeer = 100
+	DefaultNewNodeWindow      = time.Hour
+)
+
+// StorageConfig represents the storage.json configuration
+type StorageConfig struct {
+	Backend string `json:"backend"` // sqlite (default), bolt or memory
+}
+
+// Storage backends
+const (
+	StorageBackendSQLite = "sqlite"
+	StorageBackendBolt   = "bolt"
+	StorageBackendMemory = "memory"
+)
+
+// Export formats
+const (
+	ExportFormatNDJSON  = "ndjson"
+	ExportFormatCSV     = "csv"
+	ExportFormatParquet = "parquet"
+)
+
+// Export tables; an empty table exports both (NDJSON only)
+const (
+	ExportTableNodes       = "nodes"
+	ExportTablePollResults = "poll_results"
+)
+
+// ExportVersion is written to every NDJSON export header and checked on import
+const ExportVersion = 1
+
+// ExportOptions selects what an export contains. Poll results are limited
+// to [Since, Until); the node registry is always exported whole.
+type ExportOptions struct {
+	Format string
+	Table  string
+	Since  time.Time
+	Until  time.Time
+}
+
+// ExportHeader is the first record of an NDJSON export
+type ExportHeader struct {
+	Version    int       `json:"version"`
+	NodeID     string    `json:"node_id"`
+	Since      time.Time `json:"since"`
+	Until      time.Time `json:"until"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// ExportRecord is one line of an NDJSON export; exactly one of the
+// pointers is set, matching Type
+type ExportRecord struct {
+	Type       string        `json:"type"` // header, node or poll_result
+	Header     *ExportHeader `json:"header,omitempty"`
+	Node       *Node         `json:"node,omitempty"`
+	PollResult *PollResult   `json:"poll_result,omitempty"`
+}
+
+// Export record types
+const (
+	ExportRecordHeader     = "header"
+	ExportRecordNode       = "node"
+	ExportRecordPollResult = "poll_result"
+)
+
+// ImportStats summarizes a merged import
+type ImportStats struct {
+	NodesAdded     int   `json:"nodes_added"`
+	NodesUpdated   int   `json:"nodes_updated"`
+	NodesSkipped   int   `json:"nodes_skipped"` // invalid, or this node itself
+	PollResults    int64 `json:"poll_results"`
+	DuplicatePolls int64 `json:"duplicate_polls"`
+}
+
+// RetentionConfig represents the retention.json configuration. Zero values
+// are replaced with the defaults below when the file is loaded.
+type RetentionConfig struct {
+	RawRetentionHours         int `json:"raw_retention_hours"`
+	MinuteRollupRetentionDays int `json:"minute_rollup_retention_days"`
+	HourRollupRetentionDays   int `json:"hour_rollup_retention_days"`
+}
+
+// Default retention windows. Raw results cover the longest node detail range.
+const (
+	DefaultRawRetention          = 7 * 24 * time.Hour
+	DefaultMinuteRollupRetention = 30 * 24 * time.Hour
+	DefaultHourRollupRetention   = 365 * 24 * time.Hour
+)
+
+// BackupConfig represents the backup.json configuration. Zero values are
+// replaced with the defaults below when the file is loaded.
+type BackupConfig struct {
+	Enabled       bool   `json:"enabled"`
+	Dir           string `json:"dir"`
+	IntervalHours int    `json:"interval_hours"`
+	Keep          int    `json:"keep"`
+}
+
+// Backup defaults. The directory should be a different volume from /app/data.
+const (
+	DefaultBackupDir      = "/app/backups"
+	DefaultBackupInterval = 24 * time.Hour
+	DefaultBackupKeep     = 7
+)
+
+// Backup describes one backup directory
+type Backup struct {
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AlertConfig represents the alerts.json configuration
+type AlertConfig struct {
+	EvaluationIntervalSeconds int                 `json:"evaluation_interval_seconds"`
+	GroupWaitSeconds          int                 `json:"group_wait_seconds"` // delay before a group's first notification
+	Rules                     []AlertRule         `json:"rules"`
+	Channels                  []AlertChannel      `json:"channels"`
+	MaintenanceWindows        []MaintenanceWindow `json:"maintenance_windows"`
+	FlapDetection             FlapConfig          `json:"flap_detection"`
+	AnomalyDetection          AnomalyConfig       `json:"anomaly_detection"`
+}
+
+// AlertRule is a condition evaluated for every known node. The alert turns
+// pending when the condition first holds and fires once it has held for
+// ForMinutes; it resolves when the condition no longer holds.
+type AlertRule struct {
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	Threshold     float64  `json:"threshold"`      // ms for latency_p95, percent for success_rate
+	WindowMinutes int      `json:"window_minutes"` // poll results or discovery time considered
+	ForMinutes    int      `json:"for_minutes"`
+	Severity      string   `json:"severity"`
+	Nodes         []string `json:"nodes,omitempty"`    // node IDs to evaluate, empty for all
+	Channels      []string `json:"channels,omitempty"` // channel names to notify, empty for all
+
+	// NodeLabels limits the rule to nodes carrying all of these labels
+	NodeLabels map[string]string `json:"node_labels,omitempty"`
+
+	// Labels are matched by silences and maintenance windows, together with
+	// the severity label
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Alert rule types
+const (
+	AlertRuleNodeDown    = "node_down"    // node is marked inactive
+	AlertRuleLatencyP95  = "latency_p95"  // p95 of successful polls in the window above Threshold ms
+	AlertRuleSuccessRate = "success_rate" // success rate in the window below Threshold percent
+	AlertRuleMTUDrop     = "mtu_drop"     // latest path MTU in the window below an earlier one
+	AlertRuleNewNode     = "new_node"     // node discovered through a peer within the window
+
+	AlertRuleLatencyAnomaly = "latency_anomaly" // latency deviates from the link's learned baseline
+)
+
+// DefaultAlertWindows holds every rule type and its default window in
+// minutes, applied when window_minutes is unset; node_down has no window
+var DefaultAlertWindows = map[string]int{
+	AlertRuleNodeDown:    0,
+	AlertRuleLatencyP95:  5,
+	AlertRuleSuccessRate: 5,
+	AlertRuleMTUDrop:     24 * 60,
+	AlertRuleNewNode:     60,
+
+	AlertRuleLatencyAnomaly: 0,
+}
+
+// Alert defaults
+const (
+	DefaultAlertEvaluationInterval = 30 * time.Second
+	DefaultAlertSeverity           = "warning"
+)
+
+// AlertChannel is a notification destination
+type AlertChannel struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // webhook or smtp
+
+	// Webhook
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// SMTP
+	Host     string   `json:"host,omitempty"`
+	Port     int      `json:"port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+	StartTLS bool     `json:"starttls,omitempty"`
+
+	// RepeatIntervalMinutes resends a group's firing alerts this often while
+	// they keep firing; zero sends them once
+	RepeatIntervalMinutes int `json:"repeat_interval_minutes,omitempty"`
+}
+
+// Alert channel types
+const (
+	AlertChannelWebhook = "webhook"
+	AlertChannelSMTP    = "smtp"
+)
+
+// Alert is one firing of a rule for a node. Pending alerts are kept in
+// memory only; an alert is stored when it fires and updated when it resolves.
+type Alert struct {
+	ID         int64      `json:"id" db:"id"`
+	Rule       string     `json:"rule" db:"rule"`
+	NodeID     string     `json:"node_id" db:"node_id"`
+	Severity   string     `json:"severity" db:"severity"`
+	State      string     `json:"state" db:"state"`
+	Value      float64    `json:"value" db:"value"`
+	Message    string     `json:"message" db:"message"`
+	StartedAt  time.Time  `json:"started_at" db:"started_at"` // condition first held
+	FiredAt    time.Time  `json:"fired_at" db:"fired_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+}
+
+// Alert states
+const (
+	AlertStatePending  = "pending"
+	AlertStateFiring   = "firing"
+	AlertStateResolved = "resolved"
+)
+
+// AlertNotification is the payload sent to alert channels. Alerts of one
+// rule are grouped, so a partition that takes down many nodes sends one
+// notification listing the firing alerts and those resolved since the last.
+type AlertNotification struct {
+	ReportingNode string  `json:"reporting_node"`
+	Rule          string  `json:"rule"`
+	Severity      string  `json:"severity"`
+	State         string  `json:"state"` // firing while any alert in the group fires
+	Alerts        []Alert `json:"alerts"`
+}
+
+// AlertMatcher selects alerts by node, rule and labels. Every non-empty
+// field must match; an empty matcher matches every alert.
+type AlertMatcher struct {
+	Nodes  []string          `json:"nodes,omitempty"`
+	Rules  []string          `json:"rules,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// MaintenanceWindow silences matching alerts during a weekly recurring
+// period, such as a nightly change window
+type MaintenanceWindow struct {
+	Name            string   `json:"name"`
+	Days            []string `json:"days,omitempty"` // mon to sun, empty for every day
+	Start           string   `json:"start"`          // HH:MM in Timezone
+	DurationMinutes int      `json:"duration_minutes"`
+	Timezone        string   `json:"timezone,omitempty"` // IANA name, default UTC
+	AlertMatcher
+}
+
+// FlapConfig sets the percent state change thresholds of flap detection. A
+// node or link starts flapping when its score reaches HighThreshold and stops
+// once it drops below LowThreshold.
+type FlapConfig struct {
+	LowThreshold  float64 `json:"low_threshold"`
+	HighThreshold float64 `json:"high_threshold"`
+}
+
+// Flap detection defaults, as in Nagios
+const (
+	DefaultFlapLowThreshold  = 25.0
+	DefaultFlapHighThreshold = 50.0
+
+	// FlapHistorySize is the number of states scored, giving 20 possible changes
+	FlapHistorySize = 21
+)
+
+// FlapState is the flap score of a node, or of the link polling it
+type FlapState struct {
+	NodeID   string     `json:"node_id"`
+	Observer string     `json:"observer,omitempty"` // links only: the polling node
+	Score    float64    `json:"score"`              // weighted percent state change
+	Flapping bool       `json:"flapping"`
+	Since    *time.Time `json:"since,omitempty"` // when flapping started
+}
+
+// FlapReport lists the flap scores of nodes, from their status updates, and
+// of links, from poll results
+type FlapReport struct {
+	Nodes []FlapState `json:"nodes"`
+	Links []FlapState `json:"links"`
+}
+
+// AnomalyConfig sets how latency baselines are learned from this node's
+// polls and how far a poll may stray from its link's baseline. A poll is
+// anomalous when it deviates by more than Sigma standard deviations and by
+// more than MinDeviationMs, so jitter on quiet LAN links is not flagged.
+type AnomalyConfig struct {
+	Sigma            float64 `json:"sigma"`
+	MinDeviationMs   float64 `json:"min_deviation_ms"`
+	Alpha            float64 `json:"alpha"`             // EWMA weight of each new poll
+	MinSamples       int     `json:"min_samples"`       // polls before a baseline, or an hour of its profile, is trusted
+	ConsecutivePolls int     `json:"consecutive_polls"` // anomalous polls in a row that open an event
+	TrainingDays     int     `json:"training_days"`     // poll history learned from at startup
+}
+
+// Anomaly detection defaults
+const (
+	DefaultAnomalySigma            = 3.0
+	DefaultAnomalyMinDeviationMs   = 5.0
+	DefaultAnomalyAlpha            = 0.05
+	DefaultAnomalyMinSamples       = 30
+	DefaultAnomalyConsecutivePolls = 3
+	DefaultAnomalyTrainingDays     = 7
+)
+
+// Anomaly directions
+const (
+	AnomalyAbove = "above" // slower than the baseline
+	AnomalyBelow = "below" // faster than the baseline
+)
+
+// AnomalyEvent is a run of polls to one node whose latency deviated from
+// the link's baseline in the same direction
+type AnomalyEvent struct {
+	NodeID     string     `json:"node_id"`
+	Direction  string     `json:"direction"`
+	StartedAt  time.Time  `json:"started_at"`         // first anomalous poll
+	EndedAt    *time.Time `json:"ended_at,omitempty"` // first normal poll after it, nil while ongoing
+	Polls      int        `json:"polls"`
+	LatestMs   float64    `json:"latest_ms"`
+	PeakMs     float64    `json:"peak_ms"`     // the poll furthest from the baseline
+	ExpectedMs float64    `json:"expected_ms"` // baseline mean at the peak
+	StdDevMs   float64    `json:"stddev_ms"`   // baseline standard deviation at the peak
+	Sigma      float64    `json:"sigma"`       // standard deviations of the peak from the baseline
+}
+
+// LinkBaseline is the learned latency baseline of the link from this node
+// to a polled node
+type LinkBaseline struct {
+	NodeID           string           `json:"node_id"`
+	Samples          int64            `json:"samples"`
+	MeanMs           float64          `json:"mean_ms"` // over all hours
+	StdDevMs         float64          `json:"stddev_ms"`
+	ExpectedMs       float64          `json:"expected_ms"` // for the current hour of day
+	ExpectedStdDevMs float64          `json:"expected_stddev_ms"`
+	Learning         bool             `json:"learning"` // too few polls to judge yet
+	Hourly           []HourlyBaseline `json:"hourly"`   // hours of day in UTC with polls
+	Anomaly          *AnomalyEvent    `json:"anomaly,omitempty"`
+}
+
+// HourlyBaseline is a link's latency baseline for one hour of day in UTC
+type HourlyBaseline struct {
+	Hour     int     `json:"hour"`
+	Samples  int64   `json:"samples"`
+	MeanMs   float64 `json:"mean_ms"`
+	StdDevMs float64 `json:"stddev_ms"`
+}
+
+// SLOConfig represents the slo.json configuration
+type SLOConfig struct {
+	Objectives []SLObjective `json:"objectives"`
+}
+
+// SLObjective is a service level objective evaluated for every node it
+// covers, from the polls made to it. An availability objective counts
+// successful polls among all polls; a latency objective counts successful
+// polls answered within ThresholdMs, so a target of 95 means p95 below it.
+type SLObjective struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Target      float64  `json:"target"`                 // percent of good polls
+	ThresholdMs float64  `json:"threshold_ms,omitempty"` // latency objectives only
+	WindowDays  int      `json:"window_days"`
+	Nodes       []string `json:"nodes,omitempty"` // empty for every node
+
+	// NodeLabels limits the objective to nodes carrying all of these labels
+	NodeLabels map[string]string `json:"node_labels,omitempty"`
+}
+
+// SLO types
+const (
+	SLOAvailability = "availability"
+	SLOLatency      = "latency"
+)
+
+// DefaultSLOWindowDays is the rolling window of an objective without one
+const DefaultSLOWindowDays = 30
+
+// SLOBurnWindows are the recent windows the error budget burn rate is
+// reported over
+var SLOBurnWindows = []time.Duration{1 * time.Hour, 6 * time.Hour}
+
+// SLOStatus is how one node did against one objective over a window
+type SLOStatus struct {
+	Objective   string    `json:"objective"`
+	Type        string    `json:"type"`
+	NodeID      string    `json:"node_id"`
+	Target      float64   `json:"target"`
+	ThresholdMs float64   `json:"threshold_ms,omitempty"`
+	Since       time.Time `json:"since"`
+	Until       time.Time `json:"until"`
+	Polls       int64     `json:"polls"` // successful polls only for latency objectives
+	Good        int64     `json:"good"`
+	Attainment  float64   `json:"attainment"` // percent of good polls
+	P95Ms       float64   `json:"p95_ms"`
+	Met         bool      `json:"met"`
+
+	// ErrorBudgetRemaining is the percent of the window's allowed bad polls
+	// not yet used; negative once the objective is missed
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+
+	// BurnRates is the rate the budget was spent at over each of
+	// SLOBurnWindows, by window; 1 spends exactly the budget over the
+	// objective's window. Rolling status only.
+	BurnRates map[string]float64 `json:"burn_rates,omitempty"`
+}
+
+// SLOReport is the availability report for one calendar month
+type SLOReport struct {
+	Month       string      `json:"month"` // YYYY-MM
+	Since       time.Time   `json:"since"`
+	Until       time.Time   `json:"until"` // now for the current month
+	GeneratedAt time.Time   `json:"generated_at"`
+	Results     []SLOStatus `json:"results"`
+}
+
+// Silence suppresses notifications for matching alerts between StartsAt
+// and EndsAt. Alerts are still evaluated and recorded.
+type Silence struct {
+	ID        int64             `json:"id" db:"id"`
+	NodeID    string            `json:"node_id,omitempty" db:"node_id"`
+	Rule      string            `json:"rule,omitempty" db:"rule"`
+	Labels    map[string]string `json:"labels,omitempty" db:"labels"`
+	StartsAt  time.Time         `json:"starts_at" db:"starts_at"`
+	EndsAt    time.Time         `json:"ends_at" db:"ends_at"`
+	CreatedBy string            `json:"created_by" db:"created_by"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+	Comment   string            `json:"comment,omitempty" db:"comment"`
+}
+
+// DashboardConfig represents the dashboard.json configuration
+type DashboardConfig struct {
+	TemplateDir string `json:"template_dir"`
+	JSONOnly    bool   `json:"json_only"`
+}
+
 // NodeInfo represents the information this node exposes via JSON API
 type NodeInfo struct {
-	ID    string `json:"id"`
-	FQDN  string `json:"fqdn"`
-	IP    string `json:"ip"`
-	Nodes []Node `json:"nodes"`
+	ID     string            `json:"id"`
+	FQDN   string            `json:"fqdn"`
+	IP     string            `json:"ip"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Nodes  []Node            `json:"nodes"`
+
+	// Coordinate is the node's network coordinate and Coordinates those it
+	// holds for other nodes, so peers can estimate round-trip times between
+	// nodes that never poll each other
+	Coordinate  *Coordinate      `json:"coordinate,omitempty"`
+	Coordinates []NodeCoordinate `json:"coordinates,omitempty"`
+}
+
+// PeerIdentity describes the certificate a peer presented in a TLS handshake
+type PeerIdentity struct {
+	// Fingerprint is the hex SHA-256 of the certificate's public key, so it
+	// survives renewals that keep the key
+	Fingerprint string
+
+	// CertificateFingerprint is the hex SHA-256 of the whole certificate,
+	// which pins stored by earlier versions hold
+	CertificateFingerprint string
+
+	// NodeID is the node the cluster CA issued the certificate to; empty
+	// without mutual TLS
+	NodeID string
+}
+
+// Coordinate is a Vivaldi network coordinate: a point in Euclidean space
+// plus a height for the node's access link, placed so the distance between
+// two coordinates predicts the round-trip time between their nodes in ms.
+// Error is the node's own estimate of its relative prediction error.
+type Coordinate struct {
+	Vec    []float64 `json:"vec"`
+	Height float64   `json:"height"`
+	Error  float64   `json:"error"`
+}
+
+// NodeCoordinate is the coordinate held for one node and where it came
+// from: this node's own, reported by the node when polled, or heard from a
+// peer that knows it
+type NodeCoordinate struct {
+	NodeID     string     `json:"node_id"`
+	Coordinate Coordinate `json:"coordinate"`
+	Source     string     `json:"source"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Coordinate sources
+const (
+	CoordinateSelf   = "self"
+	CoordinateDirect = "direct"
+	CoordinateGossip = "gossip"
+)
+
+// RTTEstimate is the round-trip time predicted between two nodes from
+// their coordinates. Error is the mean of both nodes' relative error, and
+// MeasuredMs the recent median poll time when From polls To directly.
+type RTTEstimate struct {
+	From        string  `json:"from"`
+	To          string  `json:"to"`
+	EstimatedMs float64 `json:"estimated_ms"`
+	Error       float64 `json:"error"`
+	ErrorMs     float64 `json:"error_ms"`
+	MeasuredMs  float64 `json:"measured_ms,omitempty"`
+}
+
+// CoordinateConfig represents the coordinates.json configuration
+type CoordinateConfig struct {
+	// PollSubset limits polling to this many nodes, chosen per node so the
+	// cluster as a whole covers every node; 0 polls every node
+	PollSubset int `json:"poll_subset"`
+
+	// StaleAfterMinutes drops coordinates not refreshed for this long
+	StaleAfterMinutes int `json:"stale_after_minutes"`
+}
+
+// DefaultCoordinateStaleAfterMinutes applies when stale_after_minutes is unset
+const DefaultCoordinateStaleAfterMinutes = 30
+
+// Report represents the network report shown on the dashboard
+type Report struct {
+	GeneratedAt   time.Time      `json:"generated_at"`
+	ReportingNode NodeInfo       `json:"reporting_node"`
+	Nodes         []Node         `json:"nodes"`
+	PollResults   []PollResult   `json:"poll_results"` // this node's own polls
+	TotalNodes    int            `json:"total_nodes"`
+	ActiveNodes   int            `json:"active_nodes"`
+	InactiveNodes int            `json:"inactive_nodes"`
+	SuccessRate   float64        `json:"success_rate"`
+	Alerts        []Alert        `json:"alerts"` // firing, and fired in the last 24 hours
+	Flapping      *FlapReport    `json:"flapping"`
+	Anomalies     []AnomalyEvent `json:"anomalies"` // ongoing, and started in the last 24 hours
+
+	// LocalityMatrices summarize the report's polls between the values of
+	// each of LocalityLabels that nodes carry
+	LocalityMatrices []LocalityMatrix `json:"locality_matrices,omitempty"`
+
+	// Labels and GroupBy echo the report's scope; Groups holds the nodes
+	// by the value of the GroupBy label
+	Labels  map[string]string `json:"labels,omitempty"`
+	GroupBy string            `json:"group_by,omitempty"`
+	Groups  []NodeGroup       `json:"groups,omitempty"`
+}
+
+// ReportScope narrows a report to the nodes carrying every one of Labels,
+// and groups its nodes by the value of the GroupBy label when set
+type ReportScope struct {
+	Labels  map[string]string
+	GroupBy string
+}
+
+// NodeGroup is the nodes of a report sharing one value of the label it is
+// grouped by; nodes without the label are grouped under an empty value
+type NodeGroup struct {
+	Value       string `json:"value"`
+	Nodes       []Node `json:"nodes"`
+	ActiveNodes int    `json:"active_nodes"`
+}
+
+// LocalityMatrix summarizes polls between localities, the values of one
+// node label such as region or zone. The source of a poll is the locality
+// of the node that made it and the target that of the node polled.
+type LocalityMatrix struct {
+	Label           string         `json:"label"`
+	Since           time.Time      `json:"since"`
+	Until           time.Time      `json:"until"`
+	Localities      []string       `json:"localities"` // sorted
+	Links           []LocalityLink `json:"links"`      // by source, then target
+	UnlabelledPolls int            `json:"unlabelled_polls"`
+}
+
+// LocalityLink holds the polls from one locality to another, possibly the
+// same; latency is over successful polls
+type LocalityLink struct {
+	Source      string        `json:"source"`
+	Target      string        `json:"target"`
+	Paths       int           `json:"paths"` // observer and polled node pairs
+	Polls       int           `json:"polls"`
+	Successes   int           `json:"successes"`
+	LossPercent float64       `json:"loss_percent"`
+	SumMs       float64       `json:"-"`
+	Sketch      LatencySketch `json:"-"`
+	AvgMs       float64       `json:"avg_ms"`
+	P50Ms       float64       `json:"p50_ms"`
+	P95Ms       float64       `json:"p95_ms"`
+	P99Ms       float64       `json:"p99_ms"`
+}
+
+// LocalityLabels are the labels the dashboard summarizes polls by
+var LocalityLabels = []string{"region", "zone"}
+
+// TopologyGraph represents the cluster as a graph of nodes and observed paths
+type TopologyGraph struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	LocalNodeID string         `json:"local_node_id"`
+	Window      string         `json:"window"`
+	Nodes       []TopologyNode `json:"nodes"`
+	Edges       []TopologyEdge `json:"edges"`
+}
+
+// TopologyNode represents a vertex in the topology graph
+type TopologyNode struct {
+	ID           string `json:"id"`
+	FQDN         string `json:"fqdn"`
+	IP           string `json:"ip"`
+	DiscoveredBy string `json:"discovered_by,omitempty"`
+	IsActive     bool   `json:"is_active"`
+	IsLocal      bool   `json:"is_local"`
+}
+
+// TopologyEdge represents a directed edge in the topology graph
+type TopologyEdge struct {
+	Source         string  `json:"source"`
+	Target         string  `json:"target"`
+	Kind           string  `json:"kind"`
+	Health         string  `json:"health,omitempty"`
+	Polls          int     `json:"polls,omitempty"`
+	Successes      int     `json:"successes,omitempty"`
+	SuccessRate    float64 `json:"success_rate,omitempty"`
+	AvgResponseMs  float64 `json:"avg_response_ms,omitempty"`
+	LastResponseMs int64   `json:"last_response_ms,omitempty"`
+}
+
+// Topology edge kinds
+const (
+	EdgeKindPoll      = "poll"
+	EdgeKindDiscovery = "discovery"
+)
+
+// Topology edge health classifications
+const (
+	EdgeHealthHealthy  = "healthy"
+	EdgeHealthDegraded = "degraded"
+	EdgeHealthFailing  = "failing"
+)
+
+// TimeRange describes a selectable reporting window and its bucket size
+type TimeRange struct {
+	Name     string
+	Duration time.Duration
+	Bucket   time.Duration
+}
+
+// DetailRanges are the time ranges offered on the node detail page
+var DetailRanges = []TimeRange{
+	{Name: "1h", Duration: time.Hour, Bucket: time.Minute},
+	{Name: "24h", Duration: 24 * time.Hour, Bucket: 15 * time.Minute},
+	{Name: "7d", Duration: 7 * 24 * time.Hour, Bucket: 2 * time.Hour},
+}
+
+// NodeDetail represents aggregated poll history for a single node
+type NodeDetail struct {
+	Node        Node            `json:"node"`
+	Range       string          `json:"range"`
+	BucketSize  string          `json:"bucket_size"`
+	Since       time.Time       `json:"since"`
+	Until       time.Time       `json:"until"`
+	TotalPolls  int             `json:"total_polls"`
+	Successes   int             `json:"successes"`
+	SuccessRate float64         `json:"success_rate"`
+	Buckets     []LatencyBucket `json:"buckets"`
+	Errors      []ErrorCount    `json:"errors"`
+	MTUHistory  []MTUChange     `json:"mtu_history"`
+}
+
+// LatencyBucket summarises the polls of a node within one time bucket
+type LatencyBucket struct {
+	Start       time.Time `json:"start"`
+	Polls       int       `json:"polls"`
+	Successes   int       `json:"successes"`
+	SuccessRate float64   `json:"success_rate"`
+	MinMs       float64   `json:"min_ms"`
+	P5Ms        float64   `json:"p5_ms"`
+	P25Ms       float64   `json:"p25_ms"`
+	P50Ms       float64   `json:"p50_ms"`
+	P75Ms       float64   `json:"p75_ms"`
+	P95Ms       float64   `json:"p95_ms"`
+	MaxMs       float64   `json:"max_ms"`
+}
+
+// Rollup resolutions
+const (
+	RollupMinute = "1m"
+	RollupHour   = "1h"
+)
+
+// RollupResolutions maps each rollup resolution to its bucket size
+var RollupResolutions = map[string]time.Duration{
+	RollupMinute: time.Minute,
+	RollupHour:   time.Hour,
+}
+
+// PollRollup aggregates a node's poll results over one bucket. Latency
+// statistics cover successful polls only; the derived averages and
+// percentiles are filled in by Summarize.
+type PollRollup struct {
+	NodeID      string        `json:"node_id"`
+	BucketStart time.Time     `json:"bucket_start"`
+	Polls       int           `json:"polls"`
+	Successes   int           `json:"successes"`
+	MinMs       float64       `json:"min_ms"`
+	MaxMs       float64       `json:"max_ms"`
+	SumMs       float64       `json:"-"`
+	Sketch      LatencySketch `json:"-"`
+	AvgMs       float64       `json:"avg_ms"`
+	P50Ms       float64       `json:"p50_ms"`
+	P95Ms       float64       `json:"p95_ms"`
+	P99Ms       float64       `json:"p99_ms"`
+}
+
+// Summarize fills in the average and percentile fields from the sum and sketch
+func (r *PollRollup) Summarize() {
+	if r.Successes == 0 {
+		return
+	}
+	r.AvgMs = r.SumMs / float64(r.Successes)
+	r.P50Ms = r.Sketch.Quantile(0.50)
+	r.P95Ms = r.Sketch.Quantile(0.95)
+	r.P99Ms = r.Sketch.Quantile(0.99)
+}
+
+// ErrorCount counts occurrences of a poll error message
+type ErrorCount struct {
+	Error    string    `json:"error"`
+	Count    int       `json:"count"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// MTUChange records a path MTU measurement that differs from the previous one
+type MTUChange struct {
+	Time    time.Time `json:"time"`
+	PathMTU int       `json:"path_mtu"`
 }
 
 // Constants
 const (
-	PollInterval      = 30 * time.Second
-	ReportInterval    = 5 * time.Minute
-	MaxDatabaseSizeMB = 10
-	DefaultPort       = 443
+	PollInterval   = 30 * time.Second
+	ReportInterval = 5 * time.Minute
+	DefaultPort    = 443
+	TopologyWindow = 1 * time.Hour
+	FlapWindow     = 6 * time.Hour  // poll history replayed to score links
+	AnomalyWindow  = 24 * time.Hour // anomaly events returned by default
+	LocalityWindow = 24 * time.Hour // polls summarized between localities by default
+	MaxClockSkew   = 5 * time.Minute
+
+	RollupInterval    = 1 * time.Minute
+	RollupDelay       = 1 * time.Minute // lets in-flight polls land before their minute is rolled up
+	RetentionInterval = 1 * time.Hour
+
+	CertificateValidity      = 365 * 24 * time.Hour
+	CertificateCheckInterval = 1 * time.Hour
+	CertificateExpiryWarning = 7 * 24 * time.Hour
 )
@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// maxMaintenanceWindow keeps a window shorter than the week it recurs in
+const maxMaintenanceWindow = 7 * 24 * time.Hour
+
+// Matches reports whether an alert of rule on nodeID, carrying labels,
+// is selected by the matcher
+func (m *AlertMatcher) Matches(nodeID string, rule string, labels map[string]string) bool {
+	if len(m.Nodes) > 0 && !slices.Contains(m.Nodes, nodeID) {
+		return false
+	}
+	if len(m.Rules) > 0 && !slices.Contains(m.Rules, rule) {
+		return false
+	}
+	return LabelsMatch(m.Labels, labels)
+}
+
+// Matches reports whether the silence selects an alert of rule on nodeID,
+// carrying labels; the silence's time range is not checked
+func (s *Silence) Matches(nodeID string, rule string, labels map[string]string) bool {
+	if s.NodeID != "" && s.NodeID != nodeID {
+		return false
+	}
+	if s.Rule != "" && s.Rule != rule {
+		return false
+	}
+	return LabelsMatch(s.Labels, labels)
+}
+
+// ActiveAt reports whether the silence is in effect at t
+func (s *Silence) ActiveAt(t time.Time) bool {
+	return !t.Before(s.StartsAt) && t.Before(s.EndsAt)
+}
+
+// Validate checks the window's schedule and defaults its time zone to UTC
+func (w *MaintenanceWindow) Validate() error {
+	for _, day := range w.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("unknown day %q, use mon to sun", day)
+		}
+	}
+	if _, err := time.Parse("15:04", w.Start); err != nil {
+		return fmt.Errorf("start %q is not HH:MM", w.Start)
+	}
+	duration := time.Duration(w.DurationMinutes) * time.Minute
+	if duration <= 0 || duration > maxMaintenanceWindow {
+		return fmt.Errorf("duration_minutes must be between 1 and %d", int(maxMaintenanceWindow.Minutes()))
+	}
+	if w.Timezone == "" {
+		w.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(w.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", w.Timezone)
+	}
+	return nil
+}
+
+// ActiveAt reports whether a validated window is open at t. Windows may
+// run past midnight, so openings on the preceding days are checked too.
+func (w *MaintenanceWindow) ActiveAt(t time.Time) bool {
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return false
+	}
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return false
+	}
+	duration := time.Duration(w.DurationMinutes) * time.Minute
+
+	local := t.In(loc)
+	for daysBack := 0; daysBack <= 7; daysBack++ {
+		day := local.AddDate(0, 0, -daysBack)
+		if !w.onDay(day.Weekday()) {
+			continue
+		}
+		opens := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, loc)
+		if !t.Before(opens) && t.Before(opens.Add(duration)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *MaintenanceWindow) onDay(weekday time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, day := range w.Days {
+		if weekdays[strings.ToLower(day)] == weekday {
+			return true
+		}
+	}
+	return false
+}
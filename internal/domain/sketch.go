@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"math"
+	"sort"
+)
+
+// SketchAccuracy is the relative error of quantiles read from a LatencySketch
+const SketchAccuracy = 0.01
+
+var (
+	sketchGamma    = (1 + SketchAccuracy) / (1 - SketchAccuracy)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// LatencySketch is a mergeable histogram of response times. Buckets grow
+// logarithmically so every quantile is within SketchAccuracy of the true
+// value, and sketches from adjacent buckets combine by adding counts.
+type LatencySketch struct {
+	Zero    uint64         `json:"zero,omitempty"`
+	Buckets map[int]uint64 `json:"buckets,omitempty"`
+}
+
+// Add records one response time in milliseconds
+func (s *LatencySketch) Add(ms float64) {
+	if ms <= 0 {
+		s.Zero++
+		return
+	}
+	if s.Buckets == nil {
+		s.Buckets = make(map[int]uint64)
+	}
+	s.Buckets[int(math.Ceil(math.Log(ms)/sketchLogGamma))]++
+}
+
+// Merge adds the counts of other into s
+func (s *LatencySketch) Merge(other LatencySketch) {
+	s.Zero += other.Zero
+	if len(other.Buckets) > 0 && s.Buckets == nil {
+		s.Buckets = make(map[int]uint64, len(other.Buckets))
+	}
+	for index, count := range other.Buckets {
+		s.Buckets[index] += count
+	}
+}
+
+// Count returns the number of recorded values
+func (s LatencySketch) Count() uint64 {
+	count := s.Zero
+	for _, c := range s.Buckets {
+		count += c
+	}
+	return count
+}
+
+// Quantile estimates the q-th quantile (0 to 1) of the recorded values
+func (s LatencySketch) Quantile(q float64) float64 {
+	count := s.Count()
+	if count == 0 {
+		return 0
+	}
+
+	rank := uint64(q * float64(count-1))
+	if rank < s.Zero {
+		return 0
+	}
+	seen := s.Zero
+
+	indexes := make([]int, 0, len(s.Buckets))
+	for index := range s.Buckets {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	for _, index := range indexes {
+		seen += s.Buckets[index]
+		if seen > rank {
+			return 2 * math.Pow(sketchGamma, float64(index)) / (sketchGamma + 1)
+		}
+	}
+	return 2 * math.Pow(sketchGamma, float64(indexes[len(indexes)-1])) / (sketchGamma + 1)
+}
+
+// CountAtMost estimates how many recorded values are at most ms. Values
+// within SketchAccuracy above ms may be counted too.
+func (s LatencySketch) CountAtMost(ms float64) uint64 {
+	if ms < 0 {
+		return 0
+	}
+	count := s.Zero
+	if ms == 0 {
+		return count
+	}
+
+	limit := int(math.Ceil(math.Log(ms) / sketchLogGamma))
+	for index, c := range s.Buckets {
+		if index <= limit {
+			count += c
+		}
+	}
+	return count
+}
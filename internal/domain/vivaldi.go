@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// Vivaldi tuning, as in the Vivaldi paper and Serf, with distances in
+// milliseconds
+const (
+	VivaldiDimensions = 8
+	VivaldiErrorMax   = 1.5  // error of a new coordinate, and the most any may claim
+	VivaldiCE         = 0.25 // how fast the error estimate follows new samples
+	VivaldiCC         = 0.25 // how far one sample moves the coordinate
+	VivaldiHeightMin  = 0.01 // ms of access link every node has at least
+	VivaldiMaxMs      = 1e6  // bound on any component, rejecting absurd peers
+)
+
+// minRTTMs keeps sub-millisecond LAN samples from dividing by zero
+const minRTTMs = 0.01
+
+// NewCoordinate returns a coordinate at the origin that trusts itself least
+func NewCoordinate() Coordinate {
+	return Coordinate{
+		Vec:    make([]float64, VivaldiDimensions),
+		Error:  VivaldiErrorMax,
+		Height: VivaldiHeightMin,
+	}
+}
+
+// Valid reports whether c could have been produced by Update; coordinates
+// from peers are checked before they are used
+func (c Coordinate) Valid() bool {
+	if len(c.Vec) != VivaldiDimensions {
+		return false
+	}
+	for _, x := range c.Vec {
+		if math.IsNaN(x) || math.Abs(x) > VivaldiMaxMs {
+			return false
+		}
+	}
+	return c.Height >= VivaldiHeightMin && c.Height <= VivaldiMaxMs &&
+		c.Error > 0 && c.Error <= VivaldiErrorMax
+}
+
+// DistanceTo returns the round-trip time c predicts to other, in ms
+func (c Coordinate) DistanceTo(other Coordinate) float64 {
+	_, mag := unitVectorAt(c.Vec, other.Vec)
+	return mag + c.Height + other.Height
+}
+
+// Update moves c towards or away from other so its prediction better
+// matches a measured round-trip time, weighing the sample by how much c
+// trusts itself compared to other
+func (c *Coordinate) Update(other Coordinate, rttMs float64) {
+	rttMs = math.Max(rttMs, minRTTMs)
+	dist := c.DistanceTo(other)
+	wrongness := math.Abs(dist-rttMs) / rttMs
+
+	weight := c.Error / math.Max(c.Error+other.Error, minRTTMs)
+	c.Error = math.Min(VivaldiCE*weight*wrongness+c.Error*(1-VivaldiCE*weight), VivaldiErrorMax)
+
+	force := VivaldiCC * weight * (rttMs - dist)
+	unit, mag := unitVectorAt(c.Vec, other.Vec)
+	for i := range c.Vec {
+		c.Vec[i] += unit[i] * force
+	}
+	if mag > minRTTMs {
+		c.Height = math.Max((c.Height+other.Height)*force/mag+c.Height, VivaldiHeightMin)
+	}
+}
+
+// Clone returns a copy of c that shares no memory with it
+func (c Coordinate) Clone() Coordinate {
+	c.Vec = append([]float64(nil), c.Vec...)
+	return c
+}
+
+// unitVectorAt returns the unit vector pointing from b to a and the
+// distance between them; coinciding points get a random direction so they
+// can push each other apart
+func unitVectorAt(a, b []float64) ([]float64, float64) {
+	unit := make([]float64, len(a))
+	mag := 0.0
+	for i := range a {
+		unit[i] = a[i] - b[i]
+		mag += unit[i] * unit[i]
+	}
+	mag = math.Sqrt(mag)
+	if mag > minRTTMs {
+		for i := range unit {
+			unit[i] /= mag
+		}
+		return unit, mag
+	}
+
+	random := 0.0
+	for i := range unit {
+		unit[i] = rand.Float64() - 0.5
+		random += unit[i] * unit[i]
+	}
+	random = math.Sqrt(random)
+	if random > 0 {
+		for i := range unit {
+			unit[i] /= random
+		}
+	}
+	return unit, 0
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"nodeprobe/internal/domain"
+)
+
+type Service struct {
+	enabled   bool
+	tokens    map[string]domain.Principal // SHA-256 hex of token -> principal
+	peerToken string
+}
+
+// NewService builds the token table from the auth configuration. When a
+// peer_token_file is configured its token is sent to other nodes and
+// accepted with the peer role.
+func NewService(config *domain.AuthConfig) (*Service, error) {
+	s := &Service{
+		tokens: make(map[string]domain.Principal),
+	}
+	if config == nil {
+		return s, nil
+	}
+
+	s.enabled = config.Enabled
+
+	for _, token := range config.Tokens {
+		if !ValidRole(token.Role) {
+			return nil, fmt.Errorf("token %q has unknown role %q", token.Name, token.Role)
+		}
+		hash := strings.ToLower(token.TokenSHA256)
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("token %q: token_sha256 must be a hex SHA-256 hash", token.Name)
+		}
+		s.tokens[hash] = domain.Principal{Name: token.Name, Role: token.Role}
+	}
+
+	if config.PeerTokenFile != "" {
+		data, err := os.ReadFile(config.PeerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read peer token: %w", err)
+		}
+		s.peerToken = strings.TrimSpace(string(data))
+		if s.peerToken == "" {
+			return nil, fmt.Errorf("peer token file %s is empty", config.PeerTokenFile)
+		}
+		s.tokens[HashToken(s.peerToken)] = domain.Principal{Name: "cluster-peer", Role: domain.RolePeer}
+	}
+
+	return s, nil
+}
+
+// Enabled reports whether requests must carry an API token
+func (s *Service) Enabled() bool {
+	return s.enabled
+}
+
+// PeerToken returns the token this node presents to other nodes, if any
+func (s *Service) PeerToken() string {
+	return s.peerToken
+}
+
+// Authenticate looks up the principal for a bearer token
+func (s *Service) Authenticate(token string) (*domain.Principal, error) {
+	if token == "" {
+		return nil, domain.ErrUnauthenticated
+	}
+
+	principal, ok := s.tokens[HashToken(token)]
+	if !ok {
+		return nil, domain.ErrUnauthenticated
+	}
+
+	return &principal, nil
+}
+
+// Authorize checks that principal may use a route requiring role
+func (s *Service) Authorize(principal *domain.Principal, role string) error {
+	if principal.Role == role {
+		return nil
+	}
+	if principal.Role == domain.RoleOperator && role == domain.RoleViewer {
+		return nil
+	}
+	return fmt.Errorf("%w: %s %q requires %s", domain.ErrForbidden, principal.Role, principal.Name, role)
+}
+
+// HashToken returns the hex SHA-256 hash of a token as stored in auth.json
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateToken returns a new random token with 256 bits of entropy
+func GenerateToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// ValidRole reports whether role is one of the known API roles
+func ValidRole(role string) bool {
+	switch role {
+	case domain.RoleViewer, domain.RoleOperator, domain.RolePeer:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nodeprobe/internal/domain"
+)
+
+func TestAuthenticate(t *testing.T) {
+	peerTokenFile := filepath.Join(t.TempDir(), "peer.token")
+	if err := os.WriteFile(peerTokenFile, []byte("peer-secret\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	s, err := NewService(&domain.AuthConfig{
+		Enabled: true,
+		Tokens: []domain.APIToken{
+			{Name: "grafana", Role: domain.RoleViewer, TokenSHA256: HashToken("viewer-secret")},
+			{Name: "oncall", Role: domain.RoleOperator, TokenSHA256: HashToken("operator-secret")},
+		},
+		PeerTokenFile: peerTokenFile,
+	})
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	if s.PeerToken() != "peer-secret" {
+		t.Errorf("PeerToken = %q, want the trimmed file contents", s.PeerToken())
+	}
+
+	tests := []struct {
+		token string
+		want  string // role, or empty when the token is refused
+	}{
+		{"viewer-secret", domain.RoleViewer},
+		{"operator-secret", domain.RoleOperator},
+		{"peer-secret", domain.RolePeer},
+		{"", ""},
+		{"wrong", ""},
+		{HashToken("viewer-secret"), ""}, // the stored hash is not itself a token
+	}
+	for _, tt := range tests {
+		principal, err := s.Authenticate(tt.token)
+		if tt.want == "" {
+			if !errors.Is(err, domain.ErrUnauthenticated) {
+				t.Errorf("Authenticate(%q) = %+v, %v, want ErrUnauthenticated", tt.token, principal, err)
+			}
+			continue
+		}
+		if err != nil || principal.Role != tt.want {
+			t.Errorf("Authenticate(%q) = %+v, %v, want role %s", tt.token, principal, err, tt.want)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	s, err := NewService(nil)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+
+	roles := []string{domain.RoleViewer, domain.RoleOperator, domain.RolePeer}
+	// allowed[held][required]; operators can do everything viewers can, peers
+	// are kept to the inter-node endpoints
+	allowed := map[string]map[string]bool{
+		domain.RoleViewer:   {domain.RoleViewer: true},
+		domain.RoleOperator: {domain.RoleViewer: true, domain.RoleOperator: true},
+		domain.RolePeer:     {domain.RolePeer: true},
+	}
+
+	for _, held := range roles {
+		for _, required := range roles {
+			err := s.Authorize(&domain.Principal{Name: "caller", Role: held}, required)
+			if allowed[held][required] && err != nil {
+				t.Errorf("%s on a %s route: %v, want allowed", held, required, err)
+			}
+			if !allowed[held][required] && !errors.Is(err, domain.ErrForbidden) {
+				t.Errorf("%s on a %s route: %v, want ErrForbidden", held, required, err)
+			}
+		}
+	}
+}
+
+func TestNewServiceRejectsBadTokens(t *testing.T) {
+	tests := map[string]domain.APIToken{
+		"unknown role": {Name: "admin", Role: "admin", TokenSHA256: HashToken("secret")},
+		"plain token":  {Name: "viewer", Role: domain.RoleViewer, TokenSHA256: "secret"},
+		"short hash":   {Name: "viewer", Role: domain.RoleViewer, TokenSHA256: HashToken("secret")[:32]},
+		"not hex":      {Name: "viewer", Role: domain.RoleViewer, TokenSHA256: "zz" + HashToken("secret")[2:]},
+	}
+	for name, token := range tests {
+		if _, err := NewService(&domain.AuthConfig{Enabled: true, Tokens: []domain.APIToken{token}}); err == nil {
+			t.Errorf("%s: NewService accepted %+v", name, token)
+		}
+	}
+
+	empty := filepath.Join(t.TempDir(), "peer.token")
+	if err := os.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := NewService(&domain.AuthConfig{PeerTokenFile: empty}); err == nil {
+		t.Error("NewService accepted an empty peer token file")
+	}
+}
+
+func TestNewServiceAcceptsUppercaseHash(t *testing.T) {
+	upper := strings.ToUpper(HashToken("secret"))
+	s, err := NewService(&domain.AuthConfig{Enabled: true, Tokens: []domain.APIToken{{Name: "viewer", Role: domain.RoleViewer, TokenSHA256: upper}}})
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	if _, err := s.Authenticate("secret"); err != nil {
+		t.Errorf("Authenticate = %v, want the token matched case-insensitively", err)
+	}
+}
@@ -0,0 +1,187 @@
+package boltdb
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"nodeprobe/internal/domain"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	alertsBucket   = []byte("alerts")
+	silencesBucket = []byte("silences")
+)
+
+// idKey encodes a sequence-allocated ID so byte order matches ID order
+func idKey(id int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+// AlertRepository implementation
+
+// SaveAlert inserts an alert and sets its ID, or replaces a stored one
+func (s *Store) SaveAlert(ctx context.Context, alert *domain.Alert) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		alerts := tx.Bucket(alertsBucket)
+
+		stored := *alert
+		if stored.ID == 0 {
+			seq, err := alerts.NextSequence()
+			if err != nil {
+				return fmt.Errorf("failed to allocate alert ID: %w", err)
+			}
+			stored.ID = int64(seq)
+		} else if alerts.Get(idKey(stored.ID)) == nil {
+			return fmt.Errorf("alert %d does not exist", stored.ID)
+		}
+
+		data, err := json.Marshal(&stored)
+		if err != nil {
+			return fmt.Errorf("failed to encode alert: %w", err)
+		}
+		if err := alerts.Put(idKey(stored.ID), data); err != nil {
+			return err
+		}
+
+		alert.ID = stored.ID
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save alert: %w", err)
+	}
+	return nil
+}
+
+// listAlerts returns the stored alerts that keep accepts. The bucket is
+// keyed by ID, so callers sort by time themselves; alert history is small.
+func (s *Store) listAlerts(keep func(*domain.Alert) bool) ([]domain.Alert, error) {
+	var alerts []domain.Alert
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(alertsBucket).ForEach(func(k, v []byte) error {
+			var alert domain.Alert
+			if err := json.Unmarshal(v, &alert); err != nil {
+				return fmt.Errorf("failed to decode alert: %w", err)
+			}
+			if keep(&alert) {
+				alerts = append(alerts, alert)
+			}
+			return nil
+		})
+	})
+	return alerts, err
+}
+
+// GetFiringAlerts returns alerts that have not resolved, oldest first
+func (s *Store) GetFiringAlerts(ctx context.Context) ([]domain.Alert, error) {
+	alerts, err := s.listAlerts(func(a *domain.Alert) bool {
+		return a.State == domain.AlertStateFiring
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get firing alerts: %w", err)
+	}
+
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].FiredAt.Before(alerts[j].FiredAt)
+	})
+	return alerts, nil
+}
+
+// GetAlertHistory returns alerts fired since a given time, newest first
+func (s *Store) GetAlertHistory(ctx context.Context, since time.Time, limit int) ([]domain.Alert, error) {
+	alerts, err := s.listAlerts(func(a *domain.Alert) bool {
+		return !a.FiredAt.Before(since)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert history: %w", err)
+	}
+
+	// IDs increase with insertion, so reversing first keeps ties newest first
+	for i, j := 0, len(alerts)-1; i < j; i, j = i+1, j-1 {
+		alerts[i], alerts[j] = alerts[j], alerts[i]
+	}
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].FiredAt.After(alerts[j].FiredAt)
+	})
+	if limit > 0 && len(alerts) > limit {
+		alerts = alerts[:limit]
+	}
+	return alerts, nil
+}
+
+// SilenceRepository implementation
+
+// CreateSilence stores a silence and sets its ID
+func (s *Store) CreateSilence(ctx context.Context, silence *domain.Silence) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		silences := tx.Bucket(silencesBucket)
+
+		seq, err := silences.NextSequence()
+		if err != nil {
+			return fmt.Errorf("failed to allocate silence ID: %w", err)
+		}
+
+		stored := *silence
+		stored.ID = int64(seq)
+		data, err := json.Marshal(&stored)
+		if err != nil {
+			return fmt.Errorf("failed to encode silence: %w", err)
+		}
+		if err := silences.Put(idKey(stored.ID), data); err != nil {
+			return err
+		}
+
+		silence.ID = stored.ID
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create silence: %w", err)
+	}
+	return nil
+}
+
+// GetSilences returns the silences ending after a given time, by start time
+func (s *Store) GetSilences(ctx context.Context, endsAfter time.Time) ([]domain.Silence, error) {
+	var silences []domain.Silence
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(silencesBucket).ForEach(func(k, v []byte) error {
+			var silence domain.Silence
+			if err := json.Unmarshal(v, &silence); err != nil {
+				return fmt.Errorf("failed to decode silence: %w", err)
+			}
+			if silence.EndsAt.After(endsAfter) {
+				silences = append(silences, silence)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get silences: %w", err)
+	}
+
+	sort.SliceStable(silences, func(i, j int) bool {
+		return silences[i].StartsAt.Before(silences[j].StartsAt)
+	})
+	return silences, nil
+}
+
+func (s *Store) DeleteSilence(ctx context.Context, id int64) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		silences := tx.Bucket(silencesBucket)
+		if silences.Get(idKey(id)) == nil {
+			return domain.ErrSilenceNotFound
+		}
+		return silences.Delete(idKey(id))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete silence: %w", err)
+	}
+	return nil
+}
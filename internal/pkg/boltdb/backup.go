@@ -0,0 +1,42 @@
+package boltdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"nodeprobe/internal/domain"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Backup writes a consistent copy of the database to path from a read
+// transaction, so writes continue meanwhile
+func (s *Store) Backup(ctx context.Context, path string) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(path, 0600)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
+
+// ValidateBackup checks that the file at path is a bbolt database holding
+// every nodeprobe bucket
+func ValidateBackup(path string) error {
+	db, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("%w: %s is not a readable bbolt database: %v", domain.ErrInvalidBackup, path, err)
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{nodesBucket, pollsBucket, minuteRollupsBucket, hourRollupsBucket, rollupStateBucket} {
+			if tx.Bucket(name) == nil {
+				return fmt.Errorf("%w: missing bucket %s", domain.ErrInvalidBackup, name)
+			}
+		}
+		return nil
+	})
+}
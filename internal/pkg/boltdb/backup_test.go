@@ -0,0 +1,54 @@
+package boltdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nodeprobe/internal/domain"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBackupAndValidate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := NewStore(filepath.Join(dir, "nodeprobe.bolt"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	defer store.Close()
+
+	node := domain.Node{ID: "peer", FQDN: "peer.example.com", IP: "10.0.0.2"}
+	if err := store.CreateNode(ctx, &node); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	backupPath := filepath.Join(dir, "backup.bolt")
+	if err := store.Backup(ctx, backupPath); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if err := ValidateBackup(backupPath); err != nil {
+		t.Fatalf("ValidateBackup: %v", err)
+	}
+
+	other, err := bolt.Open(filepath.Join(dir, "other.bolt"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Close()
+	if err := ValidateBackup(filepath.Join(dir, "other.bolt")); !errors.Is(err, domain.ErrInvalidBackup) {
+		t.Errorf("foreign database error = %v, want ErrInvalidBackup", err)
+	}
+
+	garbage := filepath.Join(dir, "garbage.bolt")
+	if err := os.WriteFile(garbage, []byte("not a database"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateBackup(garbage); !errors.Is(err, domain.ErrInvalidBackup) {
+		t.Errorf("garbage backup error = %v, want ErrInvalidBackup", err)
+	}
+}
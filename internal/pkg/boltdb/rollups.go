@@ -0,0 +1,178 @@
+package boltdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"nodeprobe/internal/domain"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	minuteRollupsBucket = []byte("rollups_1m")
+	hourRollupsBucket   = []byte("rollups_1h")
+)
+
+// storedRollup is the persisted form of a rollup; PollRollup hides its sum
+// and sketch from the API
+type storedRollup struct {
+	NodeID      string               `json:"node_id"`
+	BucketStart time.Time            `json:"bucket_start"`
+	Polls       int                  `json:"polls"`
+	Successes   int                  `json:"successes"`
+	MinMs       float64              `json:"min_ms"`
+	MaxMs       float64              `json:"max_ms"`
+	SumMs       float64              `json:"sum_ms"`
+	Sketch      domain.LatencySketch `json:"sketch"`
+}
+
+// rollupBucket returns the bucket holding rollups of the given resolution
+func rollupBucket(tx *bolt.Tx, resolution string) (*bolt.Bucket, error) {
+	switch resolution {
+	case domain.RollupMinute:
+		return tx.Bucket(minuteRollupsBucket), nil
+	case domain.RollupHour:
+		return tx.Bucket(hourRollupsBucket), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown rollup resolution %q", domain.ErrInvalidRange, resolution)
+	}
+}
+
+// SaveRollups stores rollups and advances the resolution's watermark in one
+// transaction, so a crash never leaves a bucket counted twice or skipped
+func (s *Store) SaveRollups(ctx context.Context, resolution string, rollups []domain.PollRollup, rolledUntil time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := rollupBucket(tx, resolution)
+		if err != nil {
+			return err
+		}
+
+		for _, rollup := range rollups {
+			nodeRollups, err := bucket.CreateBucketIfNotExists([]byte(rollup.NodeID))
+			if err != nil {
+				return fmt.Errorf("failed to create rollup bucket for node %s: %w", rollup.NodeID, err)
+			}
+
+			data, err := json.Marshal(storedRollup{
+				NodeID:      rollup.NodeID,
+				BucketStart: rollup.BucketStart,
+				Polls:       rollup.Polls,
+				Successes:   rollup.Successes,
+				MinMs:       rollup.MinMs,
+				MaxMs:       rollup.MaxMs,
+				SumMs:       rollup.SumMs,
+				Sketch:      rollup.Sketch,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to encode rollup: %w", err)
+			}
+
+			if err := nodeRollups.Put(timeKey(rollup.BucketStart), data); err != nil {
+				return fmt.Errorf("failed to save rollup: %w", err)
+			}
+		}
+
+		watermark, err := rolledUntil.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("failed to encode rollup watermark: %w", err)
+		}
+		if err := tx.Bucket(rollupStateBucket).Put([]byte(resolution), watermark); err != nil {
+			return fmt.Errorf("failed to update rollup watermark: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// GetRollups returns rollups with bucket start in [since, until) ordered by
+// node and time; an empty nodeID returns every node
+func (s *Store) GetRollups(ctx context.Context, resolution string, nodeID string, since, until time.Time) ([]domain.PollRollup, error) {
+	var rollups []domain.PollRollup
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket, err := rollupBucket(tx, resolution)
+		if err != nil {
+			return err
+		}
+
+		scan := func(nodeRollups *bolt.Bucket) error {
+			end := timeKey(until)
+			c := nodeRollups.Cursor()
+			for k, v := c.Seek(timeKey(since)); k != nil && string(k) < string(end); k, v = c.Next() {
+				var stored storedRollup
+				if err := json.Unmarshal(v, &stored); err != nil {
+					return fmt.Errorf("failed to decode rollup: %w", err)
+				}
+				rollups = append(rollups, domain.PollRollup{
+					NodeID:      stored.NodeID,
+					BucketStart: stored.BucketStart,
+					Polls:       stored.Polls,
+					Successes:   stored.Successes,
+					MinMs:       stored.MinMs,
+					MaxMs:       stored.MaxMs,
+					SumMs:       stored.SumMs,
+					Sketch:      stored.Sketch,
+				})
+			}
+			return nil
+		}
+
+		if nodeID != "" {
+			if nodeRollups := bucket.Bucket([]byte(nodeID)); nodeRollups != nil {
+				return scan(nodeRollups)
+			}
+			return nil
+		}
+
+		return bucket.ForEachBucket(func(k []byte) error {
+			return scan(bucket.Bucket(k))
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rollups: %w", err)
+	}
+
+	return rollups, nil
+}
+
+// GetRollupWatermark returns the time up to which results have been rolled
+// up at the given resolution, or the zero time if they never have
+func (s *Store) GetRollupWatermark(ctx context.Context, resolution string) (time.Time, error) {
+	var rolledUntil time.Time
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(rollupStateBucket).Get([]byte(resolution))
+		if data == nil {
+			return nil
+		}
+		return rolledUntil.UnmarshalBinary(data)
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get rollup watermark: %w", err)
+	}
+
+	return rolledUntil, nil
+}
+
+// DeleteRollupsBefore removes rollups whose bucket starts before the given time
+func (s *Store) DeleteRollupsBefore(ctx context.Context, resolution string, before time.Time) (int64, error) {
+	var deleted int64
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := rollupBucket(tx, resolution)
+		if err != nil {
+			return err
+		}
+
+		deleted, err = deleteAllBefore(bucket, before)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old rollups: %w", err)
+	}
+
+	return deleted, nil
+}
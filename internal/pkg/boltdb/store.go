@@ -0,0 +1,511 @@
+package boltdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"nodeprobe/internal/domain"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store keeps nodes, poll results and rollups in a bbolt file. It needs no
+// cgo, so it suits static and cross-compiled builds.
+//
+// Layout:
+//
+//	nodes          node ID -> JSON node
+//	poll_results   one nested bucket per node ID, keyed by poll time and sequence
+//	rollups_1m/1h  one nested bucket per node ID, keyed by bucket start
+//	rollup_state   resolution -> rolled-until time
+//	alerts         alert ID -> JSON alert
+//	silences       silence ID -> JSON silence
+type Store struct {
+	db   *bolt.DB
+	path string
+}
+
+var (
+	nodesBucket       = []byte("nodes")
+	pollsBucket       = []byte("poll_results")
+	rollupStateBucket = []byte("rollup_state")
+)
+
+func NewStore(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{nodesBucket, pollsBucket, minuteRollupsBucket, hourRollupsBucket, rollupStateBucket, alertsBucket, silencesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("failed to create bucket %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db, path: path}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// timeKey encodes t so byte order matches time order; times before the Unix
+// epoch sort first
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	if nanos := t.UnixNano(); !t.Before(time.Unix(0, 0)) {
+		binary.BigEndian.PutUint64(key, uint64(nanos))
+	}
+	return key
+}
+
+// pollKey orders results by poll time, with the sequence keeping results
+// from the same instant apart
+func pollKey(t time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	copy(key, timeKey(t))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+func getNode(tx *bolt.Tx, id string) (*domain.Node, error) {
+	data := tx.Bucket(nodesBucket).Get([]byte(id))
+	if data == nil {
+		return nil, nil
+	}
+
+	var node domain.Node
+	if err := json.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("failed to decode node %s: %w", id, err)
+	}
+	return &node, nil
+}
+
+func putNode(tx *bolt.Tx, node *domain.Node) error {
+	data, err := json.Marshal(node)
+	if err != nil {
+		return fmt.Errorf("failed to encode node %s: %w", node.ID, err)
+	}
+	return tx.Bucket(nodesBucket).Put([]byte(node.ID), data)
+}
+
+// listNodes returns the nodes accepted by keep, oldest first
+func (s *Store) listNodes(keep func(*domain.Node) bool) ([]domain.Node, error) {
+	var nodes []domain.Node
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(nodesBucket).ForEach(func(k, v []byte) error {
+			var node domain.Node
+			if err := json.Unmarshal(v, &node); err != nil {
+				return fmt.Errorf("failed to decode node %s: %w", k, err)
+			}
+			if keep(&node) {
+				nodes = append(nodes, node)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].FirstSeen.Before(nodes[j].FirstSeen) })
+	return nodes, nil
+}
+
+// NodeRepository implementation
+func (s *Store) GetAllNodes(ctx context.Context) ([]domain.Node, error) {
+	return s.listNodes(func(*domain.Node) bool { return true })
+}
+
+func (s *Store) GetActiveNodes(ctx context.Context) ([]domain.Node, error) {
+	return s.listNodes(func(node *domain.Node) bool { return node.IsActive })
+}
+
+func (s *Store) GetNode(ctx context.Context, id string) (*domain.Node, error) {
+	var node *domain.Node
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		node, err = getNode(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node: %w", err)
+	}
+	return node, nil
+}
+
+func (s *Store) CreateNode(ctx context.Context, node *domain.Node) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		existing, err := getNode(tx, node.ID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return fmt.Errorf("node %s already exists", node.ID)
+		}
+
+		// Fingerprints are only set through UpdateNodeFingerprints
+		created := *node
+		created.CertFingerprint = ""
+		created.PendingFingerprint = ""
+		return putNode(tx, &created)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create node: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) UpdateNode(ctx context.Context, node *domain.Node) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		existing, err := getNode(tx, node.ID)
+		if err != nil || existing == nil {
+			return err
+		}
+
+		updated := *node
+		updated.CertFingerprint = existing.CertFingerprint
+		updated.PendingFingerprint = existing.PendingFingerprint
+		return putNode(tx, &updated)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update node: %w", err)
+	}
+	return nil
+}
+
+// UpsertNode creates a node or updates it, keeping the stored first seen
+// time and pinned fingerprints and copying them back into node
+func (s *Store) UpsertNode(ctx context.Context, node *domain.Node) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		existing, err := getNode(tx, node.ID)
+		if err != nil {
+			return err
+		}
+
+		if existing != nil {
+			node.FirstSeen = existing.FirstSeen
+			node.CertFingerprint = existing.CertFingerprint
+			node.PendingFingerprint = existing.PendingFingerprint
+		} else {
+			node.CertFingerprint = ""
+			node.PendingFingerprint = ""
+		}
+		return putNode(tx, node)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upsert node: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteNode(ctx context.Context, id string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(nodesBucket).Delete([]byte(id))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete node: %w", err)
+	}
+	return nil
+}
+
+// UpdateNodeFingerprints sets a node's pinned and pending certificate
+// fingerprints; UpdateNode leaves them untouched so discovery can't reset a pin
+func (s *Store) UpdateNodeFingerprints(ctx context.Context, id string, pinned string, pending string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		node, err := getNode(tx, id)
+		if err != nil || node == nil {
+			return err
+		}
+
+		node.CertFingerprint = pinned
+		node.PendingFingerprint = pending
+		return putNode(tx, node)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update node fingerprints: %w", err)
+	}
+	return nil
+}
+
+// PollRepository implementation
+func putPollResult(tx *bolt.Tx, result *domain.PollResult) error {
+	polls := tx.Bucket(pollsBucket)
+	nodePolls, err := polls.CreateBucketIfNotExists([]byte(result.NodeID))
+	if err != nil {
+		return fmt.Errorf("failed to create poll bucket for node %s: %w", result.NodeID, err)
+	}
+
+	seq, err := polls.NextSequence()
+	if err != nil {
+		return fmt.Errorf("failed to allocate poll result ID: %w", err)
+	}
+
+	stored := *result
+	stored.ID = int64(seq)
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return fmt.Errorf("failed to encode poll result: %w", err)
+	}
+
+	return nodePolls.Put(pollKey(result.PollTime, seq), data)
+}
+
+func (s *Store) CreatePollResult(ctx context.Context, result *domain.PollResult) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return putPollResult(tx, result)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create poll result: %w", err)
+	}
+	return nil
+}
+
+// CreatePollResults stores a batch of poll results in a single transaction
+func (s *Store) CreatePollResults(ctx context.Context, results []domain.PollResult) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for i := range results {
+			if err := putPollResult(tx, &results[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create poll results: %w", err)
+	}
+	return nil
+}
+
+// hasPollResult reports whether the observer already recorded a poll of the
+// node at the result's poll time
+func hasPollResult(tx *bolt.Tx, result *domain.PollResult) (bool, error) {
+	nodePolls := tx.Bucket(pollsBucket).Bucket([]byte(result.NodeID))
+	if nodePolls == nil {
+		return false, nil
+	}
+
+	prefix := timeKey(result.PollTime)
+	c := nodePolls.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		stored, err := decodePollResult(v)
+		if err != nil {
+			return false, err
+		}
+		if stored.Observer == result.Observer && stored.PollTime.Equal(result.PollTime) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ImportPollResults stores poll results in a single transaction, skipping
+// any the same observer already recorded for the node at the same time
+func (s *Store) ImportPollResults(ctx context.Context, results []domain.PollResult) (int64, error) {
+	var added int64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		added = 0
+		for i := range results {
+			exists, err := hasPollResult(tx, &results[i])
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			if err := putPollResult(tx, &results[i]); err != nil {
+				return err
+			}
+			added++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to import poll results: %w", err)
+	}
+	return added, nil
+}
+
+func decodePollResult(data []byte) (domain.PollResult, error) {
+	var result domain.PollResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, fmt.Errorf("failed to decode poll result: %w", err)
+	}
+	return result, nil
+}
+
+// scanPolls appends a node bucket's results with poll time in [since, until)
+// in time order; a zero until means no upper bound
+func scanPolls(nodePolls *bolt.Bucket, since, until time.Time, results []domain.PollResult) ([]domain.PollResult, error) {
+	c := nodePolls.Cursor()
+	end := timeKey(until)
+
+	for k, v := c.Seek(timeKey(since)); k != nil; k, v = c.Next() {
+		if !until.IsZero() && string(k[:8]) >= string(end) {
+			break
+		}
+		result, err := decodePollResult(v)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// scanAllPolls applies scanPolls to every node, in node ID order
+func scanAllPolls(tx *bolt.Tx, since, until time.Time) ([]domain.PollResult, error) {
+	var results []domain.PollResult
+	err := tx.Bucket(pollsBucket).ForEachBucket(func(nodeID []byte) error {
+		var err error
+		results, err = scanPolls(tx.Bucket(pollsBucket).Bucket(nodeID), since, until, results)
+		return err
+	})
+	return results, err
+}
+
+func (s *Store) GetPollResults(ctx context.Context, nodeID string, limit int) ([]domain.PollResult, error) {
+	var results []domain.PollResult
+	err := s.db.View(func(tx *bolt.Tx) error {
+		nodePolls := tx.Bucket(pollsBucket).Bucket([]byte(nodeID))
+		if nodePolls == nil {
+			return nil
+		}
+
+		c := nodePolls.Cursor()
+		for k, v := c.Last(); k != nil && len(results) < limit; k, v = c.Prev() {
+			result, err := decodePollResult(v)
+			if err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query poll results: %w", err)
+	}
+	return results, nil
+}
+
+func (s *Store) GetRecentPollResults(ctx context.Context, since time.Time) ([]domain.PollResult, error) {
+	var results []domain.PollResult
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		results, err = scanAllPolls(tx, since, time.Time{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recent poll results: %w", err)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].PollTime.After(results[j].PollTime) })
+	return results, nil
+}
+
+// GetNodePollResultsSince returns a node's poll results since a given time, oldest first
+func (s *Store) GetNodePollResultsSince(ctx context.Context, nodeID string, since time.Time) ([]domain.PollResult, error) {
+	var results []domain.PollResult
+	err := s.db.View(func(tx *bolt.Tx) error {
+		nodePolls := tx.Bucket(pollsBucket).Bucket([]byte(nodeID))
+		if nodePolls == nil {
+			return nil
+		}
+
+		var err error
+		results, err = scanPolls(nodePolls, since, time.Time{}, nil)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query node poll results: %w", err)
+	}
+	return results, nil
+}
+
+// GetPollResultsBetween returns all nodes' poll results in [since, until),
+// ordered by node and time
+func (s *Store) GetPollResultsBetween(ctx context.Context, since, until time.Time) ([]domain.PollResult, error) {
+	var results []domain.PollResult
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		results, err = scanAllPolls(tx, since, until)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query poll results: %w", err)
+	}
+	return results, nil
+}
+
+// deleteBefore removes the keys of bucket whose leading time is before the given time
+func deleteBefore(bucket *bolt.Bucket, before time.Time) (int64, error) {
+	end := timeKey(before)
+
+	// Collect first: deleting under a cursor skips the following key
+	var keys [][]byte
+	c := bucket.Cursor()
+	for k, _ := c.First(); k != nil && string(k[:8]) < string(end); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+
+	for _, k := range keys {
+		if err := bucket.Delete(k); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(keys)), nil
+}
+
+// deleteAllBefore applies deleteBefore to every nested node bucket of parent
+func deleteAllBefore(parent *bolt.Bucket, before time.Time) (int64, error) {
+	var deleted int64
+	err := parent.ForEachBucket(func(nodeID []byte) error {
+		n, err := deleteBefore(parent.Bucket(nodeID), before)
+		deleted += n
+		return err
+	})
+	return deleted, err
+}
+
+// DeletePollResultsBefore removes raw poll results older than before
+func (s *Store) DeletePollResultsBefore(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		deleted, err = deleteAllBefore(tx.Bucket(pollsBucket), before)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old poll results: %w", err)
+	}
+	return deleted, nil
+}
+
+// ReclaimSpace is a no-op: bbolt reuses freed pages for new writes but
+// never shrinks the file while it is open
+func (s *Store) ReclaimSpace(ctx context.Context) error {
+	return nil
+}
+
+func (s *Store) GetDatabaseSize(ctx context.Context) (int64, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get database file info: %w", err)
+	}
+	return info.Size(), nil
+}
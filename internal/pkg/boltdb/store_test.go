@@ -0,0 +1,20 @@
+package boltdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"nodeprobe/internal/domain"
+	"nodeprobe/internal/pkg/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) domain.Store {
+		store, err := NewStore(filepath.Join(t.TempDir(), "nodeprobe.bolt"))
+		if err != nil {
+			t.Fatalf("NewStore: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
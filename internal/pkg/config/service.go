@@ -91,6 +91,464 @@ func (s *Service) LoadReportingConfig() (*domain.ReportingConfig, error) {
 	return &config, nil
 }
 
+func (s *Service) LoadDashboardConfig() (*domain.DashboardConfig, error) {
+	dashboardPath := filepath.Join(s.configDir, "dashboard.json")
+
+	// Check if dashboard.json exists
+	if _, err := os.Stat(dashboardPath); os.IsNotExist(err) {
+		// Serve the embedded HTML dashboard by default
+		return &domain.DashboardConfig{}, nil
+	}
+
+	data, err := os.ReadFile(dashboardPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dashboard config: %w", err)
+	}
+
+	var config domain.DashboardConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dashboard config: %w", err)
+	}
+
+	return &config, nil
+}
+
+func (s *Service) LoadTLSConfig() (*domain.TLSConfig, error) {
+	tlsPath := filepath.Join(s.configDir, "tls.json")
+
+	// Check if tls.json exists
+	if _, err := os.Stat(tlsPath); os.IsNotExist(err) {
+		// Self-signed certificates without client verification by default
+		return &domain.TLSConfig{}, nil
+	}
+
+	data, err := os.ReadFile(tlsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS config: %w", err)
+	}
+
+	var config domain.TLSConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal TLS config: %w", err)
+	}
+
+	return &config, nil
+}
+
+func (s *Service) LoadSigningConfig() (*domain.SigningConfig, error) {
+	signingPath := filepath.Join(s.configDir, "signing.json")
+
+	// Check if signing.json exists
+	if _, err := os.Stat(signingPath); os.IsNotExist(err) {
+		// Snapshots are sent and accepted unsigned by default
+		return &domain.SigningConfig{}, nil
+	}
+
+	data, err := os.ReadFile(signingPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing config: %w", err)
+	}
+
+	var config domain.SigningConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal signing config: %w", err)
+	}
+
+	return &config, nil
+}
+
+func (s *Service) LoadAuthConfig() (*domain.AuthConfig, error) {
+	authPath := filepath.Join(s.configDir, "auth.json")
+
+	// Check if auth.json exists
+	if _, err := os.Stat(authPath); os.IsNotExist(err) {
+		// All endpoints are open by default
+		return &domain.AuthConfig{}, nil
+	}
+
+	data, err := os.ReadFile(authPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth config: %w", err)
+	}
+
+	var config domain.AuthConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal auth config: %w", err)
+	}
+
+	return &config, nil
+}
+
+func (s *Service) LoadLimitsConfig() (*domain.LimitsConfig, error) {
+	limitsPath := filepath.Join(s.configDir, "limits.json")
+
+	config := domain.LimitsConfig{RateLimit: domain.DefaultRateLimit}
+
+	// Defaults apply when limits.json is absent or leaves a field unset; a
+	// rate limit that is set, even to 0, is kept
+	if _, err := os.Stat(limitsPath); err == nil {
+		data, err := os.ReadFile(limitsPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read limits config: %w", err)
+		}
+
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal limits config: %w", err)
+		}
+	}
+
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = domain.DefaultMaxBodyBytes
+	}
+	if config.RateBurst <= 0 {
+		config.RateBurst = domain.DefaultRateBurst
+	}
+	if config.MaxNewNodesPerPeer <= 0 {
+		config.MaxNewNodesPerPeer = domain.DefaultMaxNewNodesPerPeer
+	}
+	if config.NewNodeWindow <= 0 {
+		config.NewNodeWindow = int(domain.DefaultNewNodeWindow.Seconds())
+	}
+
+	for _, cidr := range config.PeerCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("invalid peer CIDR %q: %w", cidr, err)
+		}
+	}
+
+	return &config, nil
+}
+
+func (s *Service) LoadStorageConfig() (*domain.StorageConfig, error) {
+	storagePath := filepath.Join(s.configDir, "storage.json")
+
+	config := domain.StorageConfig{Backend: domain.StorageBackendSQLite}
+
+	if _, err := os.Stat(storagePath); err == nil {
+		data, err := os.ReadFile(storagePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read storage config: %w", err)
+		}
+
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal storage config: %w", err)
+		}
+	}
+
+	switch config.Backend {
+	case domain.StorageBackendSQLite, domain.StorageBackendBolt, domain.StorageBackendMemory:
+	case "":
+		config.Backend = domain.StorageBackendSQLite
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", config.Backend)
+	}
+
+	return &config, nil
+}
+
+func (s *Service) LoadAlertConfig() (*domain.AlertConfig, error) {
+	alertsPath := filepath.Join(s.configDir, "alerts.json")
+
+	var config domain.AlertConfig
+
+	// Without alerts.json no rules are evaluated
+	if _, err := os.Stat(alertsPath); err == nil {
+		data, err := os.ReadFile(alertsPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read alert config: %w", err)
+		}
+
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal alert config: %w", err)
+		}
+	}
+
+	if config.EvaluationIntervalSeconds <= 0 {
+		config.EvaluationIntervalSeconds = int(domain.DefaultAlertEvaluationInterval.Seconds())
+	}
+
+	if err := normalizeAlertConfig(&config); err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidAlertConfig, err)
+	}
+
+	return &config, nil
+}
+
+// normalizeAlertConfig fills in rule and channel defaults and rejects
+// incomplete entries, so a typo fails at startup instead of silencing alerts
+func normalizeAlertConfig(config *domain.AlertConfig) error {
+	channels := make(map[string]bool)
+	for i := range config.Channels {
+		channel := &config.Channels[i]
+		if channel.Name == "" || channels[channel.Name] {
+			return fmt.Errorf("channel %d needs a unique name", i+1)
+		}
+		channels[channel.Name] = true
+
+		switch channel.Type {
+		case domain.AlertChannelWebhook:
+			if !strings.HasPrefix(channel.URL, "http://") && !strings.HasPrefix(channel.URL, "https://") {
+				return fmt.Errorf("webhook channel %s needs an http(s) url", channel.Name)
+			}
+		case domain.AlertChannelSMTP:
+			if channel.Host == "" || channel.From == "" || len(channel.To) == 0 {
+				return fmt.Errorf("smtp channel %s needs host, from and to", channel.Name)
+			}
+			if channel.Port == 0 {
+				channel.Port = 587
+			}
+		default:
+			return fmt.Errorf("channel %s has unknown type %q", channel.Name, channel.Type)
+		}
+		if channel.RepeatIntervalMinutes < 0 {
+			return fmt.Errorf("channel %s has a negative repeat interval", channel.Name)
+		}
+	}
+
+	rules := make(map[string]bool)
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if rule.Name == "" || rules[rule.Name] {
+			return fmt.Errorf("rule %d needs a unique name", i+1)
+		}
+		rules[rule.Name] = true
+
+		defaultWindow, ok := domain.DefaultAlertWindows[rule.Type]
+		if !ok {
+			return fmt.Errorf("rule %s has unknown type %q", rule.Name, rule.Type)
+		}
+		if rule.WindowMinutes <= 0 {
+			rule.WindowMinutes = defaultWindow
+		}
+		if rule.Severity == "" {
+			rule.Severity = domain.DefaultAlertSeverity
+		}
+
+		switch rule.Type {
+		case domain.AlertRuleLatencyP95:
+			if rule.Threshold <= 0 {
+				return fmt.Errorf("rule %s needs a threshold in milliseconds", rule.Name)
+			}
+		case domain.AlertRuleSuccessRate:
+			if rule.Threshold <= 0 || rule.Threshold > 100 {
+				return fmt.Errorf("rule %s needs a threshold percentage between 0 and 100", rule.Name)
+			}
+		}
+
+		for _, name := range rule.Channels {
+			if !channels[name] {
+				return fmt.Errorf("rule %s refers to unknown channel %q", rule.Name, name)
+			}
+		}
+		if err := domain.ValidateLabels(rule.NodeLabels); err != nil {
+			return fmt.Errorf("rule %s node_labels: %v", rule.Name, err)
+		}
+	}
+
+	if config.GroupWaitSeconds < 0 {
+		return fmt.Errorf("group_wait_seconds must not be negative")
+	}
+
+	for i := range config.MaintenanceWindows {
+		window := &config.MaintenanceWindows[i]
+		if window.Name == "" {
+			return fmt.Errorf("maintenance window %d needs a name", i+1)
+		}
+		if err := window.Validate(); err != nil {
+			return fmt.Errorf("maintenance window %s: %v", window.Name, err)
+		}
+	}
+
+	flap := &config.FlapDetection
+	if flap.LowThreshold == 0 {
+		flap.LowThreshold = domain.DefaultFlapLowThreshold
+	}
+	if flap.HighThreshold == 0 {
+		flap.HighThreshold = domain.DefaultFlapHighThreshold
+	}
+	if flap.LowThreshold < 0 || flap.LowThreshold > flap.HighThreshold || flap.HighThreshold > 100 {
+		return fmt.Errorf("flap_detection needs 0 < low_threshold <= high_threshold <= 100")
+	}
+
+	anomaly := &config.AnomalyDetection
+	if anomaly.Sigma == 0 {
+		anomaly.Sigma = domain.DefaultAnomalySigma
+	}
+	if anomaly.MinDeviationMs == 0 {
+		anomaly.MinDeviationMs = domain.DefaultAnomalyMinDeviationMs
+	}
+	if anomaly.Alpha == 0 {
+		anomaly.Alpha = domain.DefaultAnomalyAlpha
+	}
+	if anomaly.MinSamples == 0 {
+		anomaly.MinSamples = domain.DefaultAnomalyMinSamples
+	}
+	if anomaly.ConsecutivePolls == 0 {
+		anomaly.ConsecutivePolls = domain.DefaultAnomalyConsecutivePolls
+	}
+	if anomaly.TrainingDays == 0 {
+		anomaly.TrainingDays = domain.DefaultAnomalyTrainingDays
+	}
+	if anomaly.Sigma < 0 || anomaly.MinDeviationMs < 0 || anomaly.MinSamples < 0 ||
+		anomaly.ConsecutivePolls < 0 || anomaly.TrainingDays < 0 {
+		return fmt.Errorf("anomaly_detection settings must not be negative")
+	}
+	if anomaly.Alpha < 0 || anomaly.Alpha > 1 {
+		return fmt.Errorf("anomaly_detection needs 0 < alpha <= 1")
+	}
+
+	return nil
+}
+
+func (s *Service) LoadSLOConfig() (*domain.SLOConfig, error) {
+	sloPath := filepath.Join(s.configDir, "slo.json")
+
+	var config domain.SLOConfig
+
+	// Without slo.json no objectives are tracked
+	if _, err := os.Stat(sloPath); err == nil {
+		data, err := os.ReadFile(sloPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SLO config: %w", err)
+		}
+
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal SLO config: %w", err)
+		}
+	}
+
+	if err := normalizeSLOConfig(&config); err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidSLOConfig, err)
+	}
+
+	return &config, nil
+}
+
+func (s *Service) LoadCoordinateConfig() (*domain.CoordinateConfig, error) {
+	coordinatePath := filepath.Join(s.configDir, "coordinates.json")
+
+	var config domain.CoordinateConfig
+
+	// Without coordinates.json every node is polled
+	if _, err := os.Stat(coordinatePath); err == nil {
+		data, err := os.ReadFile(coordinatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read coordinate config: %w", err)
+		}
+
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal coordinate config: %w", err)
+		}
+	}
+
+	if config.StaleAfterMinutes == 0 {
+		config.StaleAfterMinutes = domain.DefaultCoordinateStaleAfterMinutes
+	}
+	if config.PollSubset < 0 || config.StaleAfterMinutes < 0 {
+		return nil, fmt.Errorf("%w: poll_subset and stale_after_minutes must not be negative", domain.ErrInvalidCoordinateConfig)
+	}
+
+	return &config, nil
+}
+
+// normalizeSLOConfig fills in objective defaults and rejects objectives
+// that could never be met or never be missed
+func normalizeSLOConfig(config *domain.SLOConfig) error {
+	names := make(map[string]bool)
+	for i := range config.Objectives {
+		objective := &config.Objectives[i]
+		if objective.Name == "" || names[objective.Name] {
+			return fmt.Errorf("objective %d needs a unique name", i+1)
+		}
+		names[objective.Name] = true
+
+		switch objective.Type {
+		case domain.SLOAvailability:
+		case domain.SLOLatency:
+			if objective.ThresholdMs <= 0 {
+				return fmt.Errorf("objective %s needs a threshold_ms", objective.Name)
+			}
+		default:
+			return fmt.Errorf("objective %s has unknown type %q", objective.Name, objective.Type)
+		}
+
+		if objective.Target <= 0 || objective.Target >= 100 {
+			return fmt.Errorf("objective %s needs a target percentage above 0 and below 100", objective.Name)
+		}
+		if objective.WindowDays == 0 {
+			objective.WindowDays = domain.DefaultSLOWindowDays
+		}
+		if objective.WindowDays < 0 {
+			return fmt.Errorf("objective %s has a negative window", objective.Name)
+		}
+		if err := domain.ValidateLabels(objective.NodeLabels); err != nil {
+			return fmt.Errorf("objective %s node_labels: %v", objective.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) LoadBackupConfig() (*domain.BackupConfig, error) {
+	backupPath := filepath.Join(s.configDir, "backup.json")
+
+	var config domain.BackupConfig
+
+	// Defaults apply when backup.json is absent or leaves a field unset
+	if _, err := os.Stat(backupPath); err == nil {
+		data, err := os.ReadFile(backupPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup config: %w", err)
+		}
+
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal backup config: %w", err)
+		}
+	}
+
+	if config.Dir == "" {
+		config.Dir = domain.DefaultBackupDir
+	}
+	if config.IntervalHours <= 0 {
+		config.IntervalHours = int(domain.DefaultBackupInterval.Hours())
+	}
+	if config.Keep <= 0 {
+		config.Keep = domain.DefaultBackupKeep
+	}
+
+	return &config, nil
+}
+
+func (s *Service) LoadRetentionConfig() (*domain.RetentionConfig, error) {
+	retentionPath := filepath.Join(s.configDir, "retention.json")
+
+	var config domain.RetentionConfig
+
+	// Defaults apply when retention.json is absent or leaves a field unset
+	if _, err := os.Stat(retentionPath); err == nil {
+		data, err := os.ReadFile(retentionPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read retention config: %w", err)
+		}
+
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal retention config: %w", err)
+		}
+	}
+
+	if config.RawRetentionHours <= 0 {
+		config.RawRetentionHours = int(domain.DefaultRawRetention.Hours())
+	}
+	if config.MinuteRollupRetentionDays <= 0 {
+		config.MinuteRollupRetentionDays = int(domain.DefaultMinuteRollupRetention.Hours() / 24)
+	}
+	if config.HourRollupRetentionDays <= 0 {
+		config.HourRollupRetentionDays = int(domain.DefaultHourRollupRetention.Hours() / 24)
+	}
+
+	return &config, nil
+}
+
 func (s *Service) GetNodeID() (string, error) {
 	return s.nodeID, nil
 }
@@ -139,16 +597,47 @@ func (s *Service) initializeNodeInfo() (*domain.NodeInfo, error) {
 		return nil, fmt.Errorf("failed to get local network info: %w", err)
 	}
 
+	nodeConfig, err := s.LoadNodeConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	nodeInfo := &domain.NodeInfo{
-		ID:    s.nodeID,
-		FQDN:  fqdn,
-		IP:    ip,
-		Nodes: []domain.Node{}, // Will be populated by the node service
+		ID:     s.nodeID,
+		FQDN:   fqdn,
+		IP:     ip,
+		Labels: nodeConfig.Labels,
+		Nodes:  []domain.Node{}, // Will be populated by the node service
 	}
 
 	return nodeInfo, nil
 }
 
+// LoadNodeConfig reads node.json, holding the labels this node advertises
+func (s *Service) LoadNodeConfig() (*domain.NodeConfig, error) {
+	nodePath := filepath.Join(s.configDir, "node.json")
+
+	var config domain.NodeConfig
+
+	// Without node.json the node advertises no labels
+	if _, err := os.Stat(nodePath); err == nil {
+		data, err := os.ReadFile(nodePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read node config: %w", err)
+		}
+
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal node config: %w", err)
+		}
+	}
+
+	if err := domain.ValidateLabels(config.Labels); err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidNodeConfig, err)
+	}
+
+	return &config, nil
+}
+
 func (s *Service) getLocalNetworkInfo() (string, string, error) {
 	// Get hostname
 	hostname, err := os.Hostname()
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nodeprobe/internal/domain"
+)
+
+func TestLoadLimitsConfigRateLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		file string // limits.json contents; empty means no file
+		want float64
+	}{
+		{"no file", "", domain.DefaultRateLimit},
+		{"left out", `{"rate_burst": 10}`, domain.DefaultRateLimit},
+		{"explicit zero", `{"rate_limit_per_second": 0}`, 0},
+		{"negative", `{"rate_limit_per_second": -1}`, -1},
+		{"set", `{"rate_limit_per_second": 5}`, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.file != "" {
+				if err := os.WriteFile(filepath.Join(dir, "limits.json"), []byte(tt.file), 0o644); err != nil {
+					t.Fatalf("WriteFile: %v", err)
+				}
+			}
+
+			limits, err := (&Service{configDir: dir}).LoadLimitsConfig()
+			if err != nil {
+				t.Fatalf("LoadLimitsConfig: %v", err)
+			}
+			if limits.RateLimit != tt.want {
+				t.Errorf("RateLimit = %v, want %v", limits.RateLimit, tt.want)
+			}
+		})
+	}
+}
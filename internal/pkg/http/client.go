@@ -6,6 +6,7 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strings"
@@ -14,16 +15,33 @@ import (
 	"nodeprobe/internal/domain"
 )
 
+// maxResponseBytes bounds how much of a peer's node info response is read
+const maxResponseBytes = 10 << 20
+
+// TLSConfigFunc returns the TLS configuration for connections to other
+// nodes, calling verify in each handshake when it is not nil
+type TLSConfigFunc func(verify domain.PeerVerifier) (*tls.Config, error)
+
 type Client struct {
 	httpClient *http.Client
+	transport  *http.Transport
+	tlsConfig  TLSConfigFunc
+	signer     domain.SigningService
+	peerToken  string
 }
 
-func NewClient() *Client {
-	// Create HTTP client with TLS configuration that accepts self-signed certificates
+// NewClient creates a client for talking to other nodes with the TLS
+// configuration from tlsConfig; a nil signer sends network snapshots
+// unsigned. A non-empty peerToken is sent as a bearer token to nodes that
+// require API authentication.
+func NewClient(tlsConfig TLSConfigFunc, signer domain.SigningService, peerToken string) (*Client, error) {
+	config, err := tlsConfig(nil)
+	if err != nil {
+		return nil, err
+	}
+
 	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true, // Accept self-signed certificates
-		},
+		TLSClientConfig: config,
 		DialContext: (&net.Dialer{
 			Timeout:   10 * time.Second,
 			KeepAlive: 10 * time.Second,
@@ -42,10 +60,18 @@ func NewClient() *Client {
 
 	return &Client{
 		httpClient: client,
-	}
+		transport:  tr,
+		tlsConfig:  tlsConfig,
+		signer:     signer,
+		peerToken:  peerToken,
+	}, nil
 }
 
-func (c *Client) GetNodeInfo(ctx context.Context, nodeURL string) (*domain.NodeInfo, error) {
+// GetNodeInfo fetches a node's information. When verify is not nil the
+// request goes over a new connection whose handshake fails unless verify
+// accepts the peer, so nothing, not even the peer token, reaches a node
+// that is refused.
+func (c *Client) GetNodeInfo(ctx context.Context, nodeURL string, verify domain.PeerVerifier) (*domain.NodeInfo, error) {
 	// Ensure URL has https scheme and proper format
 	if !strings.HasPrefix(nodeURL, "https://") {
 		nodeURL = "https://" + nodeURL
@@ -63,9 +89,25 @@ func (c *Client) GetNodeInfo(ctx context.Context, nodeURL string) (*domain.NodeI
 	}
 
 	req.Header.Set("Accept", "application/json")
+	c.setPeerToken(req)
 	req.Header.Set("User-Agent", "NodeProbe/1.0")
 
-	resp, err := c.httpClient.Do(req)
+	client := c.httpClient
+	if verify != nil {
+		tlsConfig, err := c.tlsConfig(verify)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create TLS configuration: %w", err)
+		}
+
+		// Pooled connections were verified for some other poll, if at all
+		tr := c.transport.Clone()
+		tr.TLSClientConfig = tlsConfig
+		tr.DisableKeepAlives = true
+		defer tr.CloseIdleConnections()
+		client = &http.Client{Transport: tr, Timeout: c.httpClient.Timeout}
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -76,7 +118,7 @@ func (c *Client) GetNodeInfo(ctx context.Context, nodeURL string) (*domain.NodeI
 	}
 
 	var nodeInfo domain.NodeInfo
-	if err := json.NewDecoder(resp.Body).Decode(&nodeInfo); err != nil {
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&nodeInfo); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
@@ -107,6 +149,13 @@ func (c *Client) SendNetworkSnapshot(ctx context.Context, reportingURL string, s
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "NodeProbe/1.0")
+	c.setPeerToken(req)
+
+	if c.signer != nil {
+		if err := c.signer.SignRequest(req, data); err != nil {
+			return fmt.Errorf("failed to sign snapshot: %w", err)
+		}
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -198,6 +247,13 @@ func (c *Client) binarySearchMTU(ctx context.Context, host string, min, max int)
 	}
 }
 
+// setPeerToken authenticates a request to another node's peer endpoints
+func (c *Client) setPeerToken(req *http.Request) {
+	if c.peerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.peerToken)
+	}
+}
+
 func (c *Client) Close() error {
 	// Close idle connections
 	c.httpClient.CloseIdleConnections()
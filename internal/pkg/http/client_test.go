@@ -0,0 +1,61 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"nodeprobe/internal/domain"
+	nptls "nodeprobe/internal/pkg/tls"
+)
+
+func TestGetNodeInfoVerifiesPeerBeforeSending(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		json.NewEncoder(w).Encode(domain.NodeInfo{ID: "peer"})
+	}))
+	defer server.Close()
+
+	sum := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	serverFingerprint := hex.EncodeToString(sum[:])
+
+	tlsService := nptls.NewService(t.TempDir(), "self", nil)
+	client, err := NewClient(tlsService.ClientTLSConfig, nil, "secret")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	defer client.Close()
+
+	// A refused peer fails the handshake and never sees a request
+	_, err = client.GetNodeInfo(context.Background(), server.URL, func(identity domain.PeerIdentity) error {
+		return domain.ErrFingerprintMismatch
+	})
+	if !errors.Is(err, domain.ErrFingerprintMismatch) {
+		t.Errorf("GetNodeInfo error = %v, want ErrFingerprintMismatch", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("refused peer received %d request(s)", n)
+	}
+
+	var seen string
+	info, err := client.GetNodeInfo(context.Background(), server.URL, func(identity domain.PeerIdentity) error {
+		seen = identity.Fingerprint
+		return nil
+	})
+	if err != nil || info.ID != "peer" {
+		t.Fatalf("GetNodeInfo = %+v, %v, want the peer's info", info, err)
+	}
+	if seen != serverFingerprint {
+		t.Errorf("verified fingerprint = %s, want the server key's %s", seen, serverFingerprint)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("accepted peer received %d request(s), want 1", n)
+	}
+}
@@ -0,0 +1,527 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// Store keeps nodes, poll results and rollups in memory with the same
+// semantics as the persistent backends. Nothing survives a restart, which
+// suits stateless sidecars and unit tests.
+type Store struct {
+	mu            sync.RWMutex
+	nodes         map[string]domain.Node
+	polls         []domain.PollResult // ordered by poll time, then insertion
+	nextPollID    int64
+	rollups       map[string]map[string]map[int64]domain.PollRollup // resolution -> node ID -> bucket start
+	watermarks    map[string]time.Time
+	alerts        []domain.Alert // ordered by ID
+	silences      map[int64]domain.Silence
+	lastSilenceID int64
+}
+
+func NewStore() *Store {
+	return &Store{
+		nodes: make(map[string]domain.Node),
+		rollups: map[string]map[string]map[int64]domain.PollRollup{
+			domain.RollupMinute: make(map[string]map[int64]domain.PollRollup),
+			domain.RollupHour:   make(map[string]map[int64]domain.PollRollup),
+		},
+		watermarks: make(map[string]time.Time),
+		silences:   make(map[int64]domain.Silence),
+	}
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+// listNodes returns copies of the nodes accepted by keep, oldest first
+func (s *Store) listNodes(keep func(*domain.Node) bool) []domain.Node {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var nodes []domain.Node
+	for _, node := range s.nodes {
+		if keep(&node) {
+			nodes = append(nodes, node)
+		}
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		if !nodes[i].FirstSeen.Equal(nodes[j].FirstSeen) {
+			return nodes[i].FirstSeen.Before(nodes[j].FirstSeen)
+		}
+		return nodes[i].ID < nodes[j].ID
+	})
+	return nodes
+}
+
+// NodeRepository implementation
+func (s *Store) GetAllNodes(ctx context.Context) ([]domain.Node, error) {
+	return s.listNodes(func(*domain.Node) bool { return true }), nil
+}
+
+func (s *Store) GetActiveNodes(ctx context.Context) ([]domain.Node, error) {
+	return s.listNodes(func(node *domain.Node) bool { return node.IsActive }), nil
+}
+
+func (s *Store) GetNode(ctx context.Context, id string) (*domain.Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	node, ok := s.nodes[id]
+	if !ok {
+		return nil, nil
+	}
+	return &node, nil
+}
+
+func (s *Store) CreateNode(ctx context.Context, node *domain.Node) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.nodes[node.ID]; ok {
+		return fmt.Errorf("failed to create node: node %s already exists", node.ID)
+	}
+
+	// Fingerprints are only set through UpdateNodeFingerprints
+	created := *node
+	created.CertFingerprint = ""
+	created.PendingFingerprint = ""
+	created.Labels = maps.Clone(node.Labels)
+	s.nodes[node.ID] = created
+
+	return nil
+}
+
+func (s *Store) UpdateNode(ctx context.Context, node *domain.Node) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.nodes[node.ID]
+	if !ok {
+		return nil
+	}
+
+	updated := *node
+	updated.CertFingerprint = existing.CertFingerprint
+	updated.PendingFingerprint = existing.PendingFingerprint
+	updated.Labels = maps.Clone(node.Labels)
+	s.nodes[node.ID] = updated
+
+	return nil
+}
+
+// UpsertNode creates a node or updates it, keeping the stored first seen
+// time and pinned fingerprints and copying them back into node
+func (s *Store) UpsertNode(ctx context.Context, node *domain.Node) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.nodes[node.ID]; ok {
+		node.FirstSeen = existing.FirstSeen
+		node.CertFingerprint = existing.CertFingerprint
+		node.PendingFingerprint = existing.PendingFingerprint
+	} else {
+		node.CertFingerprint = ""
+		node.PendingFingerprint = ""
+	}
+	stored := *node
+	stored.Labels = maps.Clone(node.Labels)
+	s.nodes[node.ID] = stored
+
+	return nil
+}
+
+func (s *Store) DeleteNode(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.nodes, id)
+	return nil
+}
+
+// UpdateNodeFingerprints sets a node's pinned and pending certificate
+// fingerprints; UpdateNode leaves them untouched so discovery can't reset a pin
+func (s *Store) UpdateNodeFingerprints(ctx context.Context, id string, pinned string, pending string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	node, ok := s.nodes[id]
+	if !ok {
+		return nil
+	}
+
+	node.CertFingerprint = pinned
+	node.PendingFingerprint = pending
+	s.nodes[id] = node
+
+	return nil
+}
+
+// PollRepository implementation
+func (s *Store) insertPollResult(result domain.PollResult) {
+	s.nextPollID++
+	result.ID = s.nextPollID
+
+	// Insert after every result at or before this poll time
+	i := sort.Search(len(s.polls), func(i int) bool { return s.polls[i].PollTime.After(result.PollTime) })
+	s.polls = append(s.polls, domain.PollResult{})
+	copy(s.polls[i+1:], s.polls[i:])
+	s.polls[i] = result
+}
+
+func (s *Store) CreatePollResult(ctx context.Context, result *domain.PollResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.insertPollResult(*result)
+	return nil
+}
+
+// CreatePollResults stores a batch of poll results
+func (s *Store) CreatePollResults(ctx context.Context, results []domain.PollResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, result := range results {
+		s.insertPollResult(result)
+	}
+	return nil
+}
+
+// ImportPollResults stores poll results, skipping any the same observer
+// already recorded for the node at the same time
+func (s *Store) ImportPollResults(ctx context.Context, results []domain.PollResult) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var added int64
+	for _, result := range results {
+		if s.hasPollResult(&result) {
+			continue
+		}
+		s.insertPollResult(result)
+		added++
+	}
+	return added, nil
+}
+
+// hasPollResult reports whether the observer already recorded a poll of the
+// node at the result's poll time
+func (s *Store) hasPollResult(result *domain.PollResult) bool {
+	for i := s.firstPollAt(result.PollTime); i < len(s.polls) && s.polls[i].PollTime.Equal(result.PollTime); i++ {
+		if s.polls[i].NodeID == result.NodeID && s.polls[i].Observer == result.Observer {
+			return true
+		}
+	}
+	return false
+}
+
+// firstPollAt returns the index of the first result polled at or after t
+func (s *Store) firstPollAt(t time.Time) int {
+	return sort.Search(len(s.polls), func(i int) bool { return !s.polls[i].PollTime.Before(t) })
+}
+
+func (s *Store) GetPollResults(ctx context.Context, nodeID string, limit int) ([]domain.PollResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []domain.PollResult
+	for i := len(s.polls) - 1; i >= 0 && len(results) < limit; i-- {
+		if s.polls[i].NodeID == nodeID {
+			results = append(results, s.polls[i])
+		}
+	}
+	return results, nil
+}
+
+func (s *Store) GetRecentPollResults(ctx context.Context, since time.Time) ([]domain.PollResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []domain.PollResult
+	for i := len(s.polls) - 1; i >= s.firstPollAt(since); i-- {
+		results = append(results, s.polls[i])
+	}
+	return results, nil
+}
+
+// GetNodePollResultsSince returns a node's poll results since a given time, oldest first
+func (s *Store) GetNodePollResultsSince(ctx context.Context, nodeID string, since time.Time) ([]domain.PollResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []domain.PollResult
+	for _, result := range s.polls[s.firstPollAt(since):] {
+		if result.NodeID == nodeID {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// GetPollResultsBetween returns all nodes' poll results in [since, until),
+// ordered by node and time
+func (s *Store) GetPollResultsBetween(ctx context.Context, since, until time.Time) ([]domain.PollResult, error) {
+	s.mu.RLock()
+	results := append([]domain.PollResult(nil), s.polls[s.firstPollAt(since):s.firstPollAt(until)]...)
+	s.mu.RUnlock()
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].NodeID < results[j].NodeID })
+	return results, nil
+}
+
+// DeletePollResultsBefore removes raw poll results older than before
+func (s *Store) DeletePollResultsBefore(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.firstPollAt(before)
+	s.polls = append([]domain.PollResult(nil), s.polls[n:]...)
+	return int64(n), nil
+}
+
+// ReclaimSpace is a no-op; deleted results are released to the garbage collector
+func (s *Store) ReclaimSpace(ctx context.Context) error {
+	return nil
+}
+
+// GetDatabaseSize estimates the memory held by stored records
+func (s *Store) GetDatabaseSize(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Rough per-record sizes: fixed fields plus strings
+	var size int64
+	for _, node := range s.nodes {
+		size += 128 + int64(len(node.ID)+len(node.FQDN)+len(node.IP)+len(node.DiscoveredBy)+
+			len(node.CertFingerprint)+len(node.PendingFingerprint))
+	}
+	for _, result := range s.polls {
+		size += 64 + int64(len(result.NodeID)+len(result.Error)+len(result.Observer))
+	}
+	for _, byNode := range s.rollups {
+		for _, buckets := range byNode {
+			for _, rollup := range buckets {
+				size += 96 + int64(len(rollup.NodeID)) + 16*int64(len(rollup.Sketch.Buckets))
+			}
+		}
+	}
+
+	return size, nil
+}
+
+// rollupsFor returns the rollups of the given resolution
+func (s *Store) rollupsFor(resolution string) (map[string]map[int64]domain.PollRollup, error) {
+	byNode, ok := s.rollups[resolution]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown rollup resolution %q", domain.ErrInvalidRange, resolution)
+	}
+	return byNode, nil
+}
+
+// cloneRollup copies a rollup so the stored sketch never aliases the caller's
+func cloneRollup(rollup domain.PollRollup) domain.PollRollup {
+	sketch := domain.LatencySketch{Zero: rollup.Sketch.Zero}
+	sketch.Merge(domain.LatencySketch{Buckets: rollup.Sketch.Buckets})
+	rollup.Sketch = sketch
+	return rollup
+}
+
+// SaveRollups stores rollups and advances the resolution's watermark
+func (s *Store) SaveRollups(ctx context.Context, resolution string, rollups []domain.PollRollup, rolledUntil time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byNode, err := s.rollupsFor(resolution)
+	if err != nil {
+		return err
+	}
+
+	for _, rollup := range rollups {
+		buckets, ok := byNode[rollup.NodeID]
+		if !ok {
+			buckets = make(map[int64]domain.PollRollup)
+			byNode[rollup.NodeID] = buckets
+		}
+		buckets[rollup.BucketStart.UnixNano()] = cloneRollup(rollup)
+	}
+	s.watermarks[resolution] = rolledUntil
+
+	return nil
+}
+
+// GetRollups returns rollups with bucket start in [since, until) ordered by
+// node and time; an empty nodeID returns every node
+func (s *Store) GetRollups(ctx context.Context, resolution string, nodeID string, since, until time.Time) ([]domain.PollRollup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byNode, err := s.rollupsFor(resolution)
+	if err != nil {
+		return nil, err
+	}
+
+	var rollups []domain.PollRollup
+	for id, buckets := range byNode {
+		if nodeID != "" && id != nodeID {
+			continue
+		}
+		for _, rollup := range buckets {
+			if !rollup.BucketStart.Before(since) && rollup.BucketStart.Before(until) {
+				rollups = append(rollups, cloneRollup(rollup))
+			}
+		}
+	}
+
+	sort.Slice(rollups, func(i, j int) bool {
+		if rollups[i].NodeID != rollups[j].NodeID {
+			return rollups[i].NodeID < rollups[j].NodeID
+		}
+		return rollups[i].BucketStart.Before(rollups[j].BucketStart)
+	})
+	return rollups, nil
+}
+
+// GetRollupWatermark returns the time up to which results have been rolled
+// up at the given resolution, or the zero time if they never have
+func (s *Store) GetRollupWatermark(ctx context.Context, resolution string) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.watermarks[resolution], nil
+}
+
+// DeleteRollupsBefore removes rollups whose bucket starts before the given time
+func (s *Store) DeleteRollupsBefore(ctx context.Context, resolution string, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byNode, err := s.rollupsFor(resolution)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, buckets := range byNode {
+		for start, rollup := range buckets {
+			if rollup.BucketStart.Before(before) {
+				delete(buckets, start)
+				deleted++
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// AlertRepository implementation
+
+// SaveAlert inserts an alert and sets its ID, or replaces a stored one
+func (s *Store) SaveAlert(ctx context.Context, alert *domain.Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if alert.ID == 0 {
+		alert.ID = int64(len(s.alerts)) + 1
+		s.alerts = append(s.alerts, *alert)
+		return nil
+	}
+
+	if alert.ID < 1 || alert.ID > int64(len(s.alerts)) {
+		return fmt.Errorf("failed to update alert: alert %d does not exist", alert.ID)
+	}
+	s.alerts[alert.ID-1] = *alert
+	return nil
+}
+
+// GetFiringAlerts returns alerts that have not resolved, oldest first
+func (s *Store) GetFiringAlerts(ctx context.Context) ([]domain.Alert, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var alerts []domain.Alert
+	for _, alert := range s.alerts {
+		if alert.State == domain.AlertStateFiring {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].FiredAt.Before(alerts[j].FiredAt)
+	})
+	return alerts, nil
+}
+
+// GetAlertHistory returns alerts fired since a given time, newest first
+func (s *Store) GetAlertHistory(ctx context.Context, since time.Time, limit int) ([]domain.Alert, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var alerts []domain.Alert
+	for i := len(s.alerts) - 1; i >= 0; i-- {
+		if !s.alerts[i].FiredAt.Before(since) {
+			alerts = append(alerts, s.alerts[i])
+		}
+	}
+
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].FiredAt.After(alerts[j].FiredAt)
+	})
+	if limit > 0 && len(alerts) > limit {
+		alerts = alerts[:limit]
+	}
+	return alerts, nil
+}
+
+// SilenceRepository implementation
+
+// CreateSilence stores a silence and sets its ID
+func (s *Store) CreateSilence(ctx context.Context, silence *domain.Silence) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastSilenceID++
+	silence.ID = s.lastSilenceID
+	s.silences[silence.ID] = *silence
+	return nil
+}
+
+// GetSilences returns the silences ending after a given time, by start time
+func (s *Store) GetSilences(ctx context.Context, endsAfter time.Time) ([]domain.Silence, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var silences []domain.Silence
+	for _, silence := range s.silences {
+		if silence.EndsAt.After(endsAfter) {
+			silences = append(silences, silence)
+		}
+	}
+
+	sort.Slice(silences, func(i, j int) bool {
+		if !silences[i].StartsAt.Equal(silences[j].StartsAt) {
+			return silences[i].StartsAt.Before(silences[j].StartsAt)
+		}
+		return silences[i].ID < silences[j].ID
+	})
+	return silences, nil
+}
+
+func (s *Store) DeleteSilence(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.silences[id]; !ok {
+		return domain.ErrSilenceNotFound
+	}
+	delete(s.silences, id)
+	return nil
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"nodeprobe/internal/domain"
+	"nodeprobe/internal/pkg/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) domain.Store {
+		return NewStore()
+	})
+}
@@ -0,0 +1,180 @@
+// Package notify delivers alert notifications to webhook and SMTP channels.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// sendTimeout bounds a single delivery when the context has no deadline
+const sendTimeout = 30 * time.Second
+
+type Notifier struct {
+	httpClient *http.Client
+}
+
+func NewNotifier() *Notifier {
+	return &Notifier{
+		httpClient: &http.Client{Timeout: sendTimeout},
+	}
+}
+
+// Notify sends the notification to the channel
+func (n *Notifier) Notify(ctx context.Context, channel *domain.AlertChannel, notification *domain.AlertNotification) error {
+	switch channel.Type {
+	case domain.AlertChannelWebhook:
+		return n.sendWebhook(ctx, channel, notification)
+	case domain.AlertChannelSMTP:
+		return n.sendMail(ctx, channel, notification)
+	default:
+		return fmt.Errorf("unknown channel type %q", channel.Type)
+	}
+}
+
+// sendWebhook posts the notification as JSON; any status other than 2xx is
+// an error
+func (n *Notifier) sendWebhook(ctx context.Context, channel *domain.AlertChannel, notification *domain.AlertNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", channel.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range channel.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sendMail delivers the notification as a plain text email. Credentials are
+// only sent after STARTTLS, or to a server on localhost.
+func (n *Notifier) sendMail(ctx context.Context, channel *domain.AlertChannel, notification *domain.AlertNotification) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(sendTimeout)
+	}
+
+	addr := net.JoinHostPort(channel.Host, strconv.Itoa(channel.Port))
+	dialer := &net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, channel.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if channel.StartTLS {
+		if err := client.StartTLS(&tls.Config{ServerName: channel.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if channel.Username != "" {
+		auth := smtp.PlainAuth("", channel.Username, channel.Password, channel.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	if err := client.Mail(channel.From); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	for _, to := range channel.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("failed to add recipient %s: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start message: %w", err)
+	}
+	if _, err := w.Write(formatMessage(channel, notification)); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// Subject formats the one-line summary used as the email subject
+func Subject(notification *domain.AlertNotification) string {
+	firing := 0
+	for _, alert := range notification.Alerts {
+		if alert.State == domain.AlertStateFiring {
+			firing++
+		}
+	}
+
+	target := fmt.Sprintf("%d nodes", len(notification.Alerts))
+	if len(notification.Alerts) == 1 {
+		target = notification.Alerts[0].NodeID
+	}
+	subject := fmt.Sprintf("[nodeprobe] %s %s: %s on %s",
+		strings.ToUpper(notification.State), notification.Severity, notification.Rule, target)
+	if resolved := len(notification.Alerts) - firing; firing > 0 && resolved > 0 {
+		subject += fmt.Sprintf(" (%d resolved)", resolved)
+	}
+	return subject
+}
+
+func formatMessage(channel *domain.AlertChannel, notification *domain.AlertNotification) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", channel.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(channel.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", Subject(notification))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+
+	fmt.Fprintf(&b, "Rule:      %s\r\n", notification.Rule)
+	fmt.Fprintf(&b, "Severity:  %s\r\n", notification.Severity)
+	for _, alert := range notification.Alerts {
+		fmt.Fprintf(&b, "\r\n[%s] %s\r\n", strings.ToUpper(alert.State), alert.Message)
+		fmt.Fprintf(&b, "Node:      %s\r\n", alert.NodeID)
+		fmt.Fprintf(&b, "Value:     %g\r\n", alert.Value)
+		fmt.Fprintf(&b, "Fired:     %s\r\n", alert.FiredAt.Format(time.RFC3339))
+		if alert.ResolvedAt != nil {
+			fmt.Fprintf(&b, "Resolved:  %s\r\n", alert.ResolvedAt.Format(time.RFC3339))
+		}
+	}
+	fmt.Fprintf(&b, "\r\nReported by %s\r\n", notification.ReportingNode)
+
+	return b.Bytes()
+}
@@ -0,0 +1,177 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+func testNotification() *domain.AlertNotification {
+	firedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	resolvedAt := firedAt.Add(time.Minute)
+	return &domain.AlertNotification{
+		ReportingNode: "edge-1",
+		Rule:          "peer-down",
+		Severity:      "critical",
+		State:         domain.AlertStateFiring,
+		Alerts: []domain.Alert{
+			{ID: 7, Rule: "peer-down", NodeID: "peer", Severity: "critical", State: domain.AlertStateFiring,
+				Message: "peer has been down for 5m", FiredAt: firedAt},
+			{ID: 8, Rule: "peer-down", NodeID: "other", Severity: "critical", State: domain.AlertStateFiring,
+				Message: "other has been down for 5m", FiredAt: firedAt},
+			{ID: 6, Rule: "peer-down", NodeID: "third", Severity: "critical", State: domain.AlertStateResolved,
+				Message: "third has been down for 5m", FiredAt: firedAt, ResolvedAt: &resolvedAt},
+		},
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got domain.AlertNotification
+	var token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	channel := &domain.AlertChannel{Name: "hook", Type: domain.AlertChannelWebhook, URL: server.URL,
+		Headers: map[string]string{"Authorization": "Bearer secret"}}
+	if err := NewNotifier().Notify(context.Background(), channel, testNotification()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if got.ReportingNode != "edge-1" || got.Rule != "peer-down" || len(got.Alerts) != 3 || got.Alerts[0].ID != 7 {
+		t.Errorf("webhook received %+v", got)
+	}
+	if token != "Bearer secret" {
+		t.Errorf("Authorization header = %q, want the configured header", token)
+	}
+}
+
+func TestWebhookErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	channel := &domain.AlertChannel{Name: "hook", Type: domain.AlertChannelWebhook, URL: server.URL}
+	err := NewNotifier().Notify(context.Background(), channel, testNotification())
+	if err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("Notify error = %v, want status 502", err)
+	}
+}
+
+// smtpStandIn accepts one SMTP session without TLS or authentication and
+// returns the envelope and message it received
+func smtpStandIn(t *testing.T) (addr string, received <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	out := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var session strings.Builder
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 localhost ESMTP stand-in")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				out <- session.String()
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			session.WriteString(line + "\n")
+
+			switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); {
+			case verb == "EHLO" || verb == "HELO":
+				reply("250 localhost")
+			case verb == "DATA":
+				reply("354 end with .")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						out <- session.String()
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					session.WriteString(line)
+				}
+				reply("250 queued")
+			case verb == "QUIT":
+				reply("221 bye")
+				out <- session.String()
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), out
+}
+
+func TestSubject(t *testing.T) {
+	notification := testNotification()
+	notification.State = domain.AlertStateResolved
+	notification.Alerts = notification.Alerts[2:]
+
+	if got, want := Subject(notification), "[nodeprobe] RESOLVED critical: peer-down on third"; got != want {
+		t.Errorf("Subject = %q, want %q", got, want)
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	addr, received := smtpStandIn(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+
+	channel := &domain.AlertChannel{Name: "mail", Type: domain.AlertChannelSMTP, Host: host, Port: portNum,
+		From: "nodeprobe@example.com", To: []string{"ops@example.com", "oncall@example.com"}}
+	if err := NewNotifier().Notify(context.Background(), channel, testNotification()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var session string
+	select {
+	case session = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP stand-in received nothing")
+	}
+
+	for _, want := range []string{
+		"MAIL FROM:<nodeprobe@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"RCPT TO:<oncall@example.com>",
+		"Subject: [nodeprobe] FIRING critical: peer-down on 3 nodes (1 resolved)",
+		"[FIRING] peer has been down for 5m",
+		"[RESOLVED] third has been down for 5m",
+		"QUIT",
+	} {
+		if !strings.Contains(session, want) {
+			t.Errorf("SMTP session lacks %q:\n%s", want, session)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package signing
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadOrGenerateKeyReusesKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "signing.key")
+
+	generated, err := LoadOrGenerateKey(path)
+	if err != nil {
+		t.Fatalf("LoadOrGenerateKey: %v", err)
+	}
+	loaded, err := LoadOrGenerateKey(path)
+	if err != nil {
+		t.Fatalf("LoadOrGenerateKey on an existing key: %v", err)
+	}
+	if !generated.Equal(loaded) {
+		t.Error("LoadOrGenerateKey generated a new key instead of loading the existing one")
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	for _, encoded := range []string{"not base64!", "c2hvcnQ="} {
+		if _, err := ParsePublicKey(encoded); err == nil {
+			t.Errorf("ParsePublicKey(%q) accepted a malformed key", encoded)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// Request headers carrying a snapshot signature
+const (
+	HeaderNode      = "X-NodeProbe-Node"
+	HeaderTimestamp = "X-NodeProbe-Timestamp"
+	HeaderNonce     = "X-NodeProbe-Nonce"
+	HeaderSignature = "X-NodeProbe-Signature"
+)
+
+// signatureVersion prefixes the signed message so signatures cannot be
+// reused for other purposes or future message formats
+const signatureVersion = "nodeprobe-snapshot-v1"
+
+type Service struct {
+	nodeID       string
+	mode         string
+	privateKey   ed25519.PrivateKey
+	sharedSecret []byte
+	trustedKeys  map[string]ed25519.PublicKey
+	maxSkew      time.Duration
+
+	noncesMu  sync.Mutex
+	nonces    map[string]time.Time // signer/nonce -> time after which it can be forgotten
+	lastSweep time.Time
+
+	accepted         atomic.Uint64
+	unsigned         atomic.Uint64
+	unknownSigner    atomic.Uint64
+	invalidSignature atomic.Uint64
+	stale            atomic.Uint64
+	replayed         atomic.Uint64
+}
+
+// NewService loads the signing keys for the configured mode. With Ed25519 the
+// node's private key is read from config.PrivateKey (default dataDir/signing.key)
+// and generated on first start; this node's own key is always trusted.
+func NewService(dataDir string, nodeID string, config *domain.SigningConfig) (*Service, error) {
+	s := &Service{
+		nodeID:      nodeID,
+		trustedKeys: make(map[string]ed25519.PublicKey),
+		maxSkew:     domain.MaxClockSkew,
+		nonces:      make(map[string]time.Time),
+	}
+	if config == nil {
+		return s, nil
+	}
+
+	s.mode = config.Mode
+	if config.MaxClockSkew > 0 {
+		s.maxSkew = time.Duration(config.MaxClockSkew) * time.Second
+	}
+
+	switch config.Mode {
+	case "":
+		return s, nil
+
+	case domain.SigningModeEd25519:
+		keyPath := config.PrivateKey
+		if keyPath == "" {
+			keyPath = filepath.Join(dataDir, "signing.key")
+		}
+		privateKey, err := LoadOrGenerateKey(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		s.privateKey = privateKey
+		s.trustedKeys[nodeID] = privateKey.Public().(ed25519.PublicKey)
+
+		for peerID, encoded := range config.TrustedKeys {
+			publicKey, err := ParsePublicKey(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted key for node %s: %w", peerID, err)
+			}
+			s.trustedKeys[peerID] = publicKey
+		}
+		return s, nil
+
+	case domain.SigningModeHMAC:
+		if config.SharedSecretFile == "" {
+			return nil, fmt.Errorf("shared_secret_file is required for hmac signing")
+		}
+		secret, err := os.ReadFile(config.SharedSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read shared secret: %w", err)
+		}
+		secret = []byte(strings.TrimSpace(string(secret)))
+		if len(secret) < 32 {
+			return nil, fmt.Errorf("shared secret must be at least 32 bytes")
+		}
+		s.sharedSecret = secret
+		return s, nil
+
+	default:
+		return nil, fmt.Errorf("unknown signing mode %q", config.Mode)
+	}
+}
+
+// Enabled reports whether snapshots are signed and signatures required
+func (s *Service) Enabled() bool {
+	return s.mode != ""
+}
+
+// PublicKey returns this node's Ed25519 public key, base64 encoded, for
+// adding to other nodes' trusted_keys; it is empty outside Ed25519 mode
+func (s *Service) PublicKey() string {
+	if s.privateKey == nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(s.privateKey.Public().(ed25519.PublicKey))
+}
+
+// SignRequest adds signature headers covering the request method, path and
+// body. It does nothing when signing is disabled.
+func (s *Service) SignRequest(req *http.Request, body []byte) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonceHex := hex.EncodeToString(nonce)
+	message := signedMessage(req.Method, req.URL.Path, s.nodeID, timestamp, nonceHex, body)
+
+	req.Header.Set(HeaderNode, s.nodeID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonceHex)
+	req.Header.Set(HeaderSignature, base64.StdEncoding.EncodeToString(s.sign(message)))
+	return nil
+}
+
+// VerifyRequest checks the signature headers of a received snapshot and
+// returns the signer's node ID. With signing disabled every request passes
+// and the signer is empty.
+func (s *Service) VerifyRequest(r *http.Request, body []byte) (string, error) {
+	if !s.Enabled() {
+		return "", nil
+	}
+
+	signer := r.Header.Get(HeaderNode)
+	timestamp := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+	encoded := r.Header.Get(HeaderSignature)
+	if signer == "" || timestamp == "" || nonce == "" || encoded == "" {
+		s.unsigned.Add(1)
+		return "", domain.ErrUnsignedSnapshot
+	}
+
+	if s.mode == domain.SigningModeEd25519 {
+		if _, ok := s.trustedKeys[signer]; !ok {
+			s.unknownSigner.Add(1)
+			return "", fmt.Errorf("%w: %s", domain.ErrUnknownSigner, signer)
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		s.invalidSignature.Add(1)
+		return "", fmt.Errorf("%w: malformed timestamp", domain.ErrInvalidSignature)
+	}
+	signedAt := time.Unix(unix, 0)
+	now := time.Now()
+	if signedAt.Before(now.Add(-s.maxSkew)) || signedAt.After(now.Add(s.maxSkew)) {
+		s.stale.Add(1)
+		return "", fmt.Errorf("%w: signed at %s", domain.ErrStaleSnapshot, signedAt.UTC().Format(time.RFC3339))
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || !s.verify(signer, signedMessage(r.Method, r.URL.Path, signer, timestamp, nonce, body), signature) {
+		s.invalidSignature.Add(1)
+		return "", fmt.Errorf("%w: signer %s", domain.ErrInvalidSignature, signer)
+	}
+
+	// Only remember nonces of authentic requests, so forged requests cannot fill the cache
+	if !s.rememberNonce(signer+"/"+nonce, signedAt.Add(s.maxSkew), now) {
+		s.replayed.Add(1)
+		return "", fmt.Errorf("%w: signer %s", domain.ErrReplayedSnapshot, signer)
+	}
+
+	s.accepted.Add(1)
+	return signer, nil
+}
+
+// Stats returns the snapshot verification counters
+func (s *Service) Stats() domain.SignatureStats {
+	mode := s.mode
+	if mode == "" {
+		mode = "disabled"
+	}
+
+	return domain.SignatureStats{
+		Mode:             mode,
+		Accepted:         s.accepted.Load(),
+		Unsigned:         s.unsigned.Load(),
+		UnknownSigner:    s.unknownSigner.Load(),
+		InvalidSignature: s.invalidSignature.Load(),
+		Stale:            s.stale.Load(),
+		Replayed:         s.replayed.Load(),
+	}
+}
+
+func (s *Service) sign(message []byte) []byte {
+	if s.mode == domain.SigningModeHMAC {
+		mac := hmac.New(sha256.New, s.sharedSecret)
+		mac.Write(message)
+		return mac.Sum(nil)
+	}
+	return ed25519.Sign(s.privateKey, message)
+}
+
+func (s *Service) verify(signer string, message, signature []byte) bool {
+	if s.mode == domain.SigningModeHMAC {
+		mac := hmac.New(sha256.New, s.sharedSecret)
+		mac.Write(message)
+		return hmac.Equal(mac.Sum(nil), signature)
+	}
+	return ed25519.Verify(s.trustedKeys[signer], message, signature)
+}
+
+// rememberNonce records a nonce until expiry and reports whether it was new.
+// A nonce only needs to be kept while its timestamp is inside the skew
+// window; older replays are already rejected as stale.
+func (s *Service) rememberNonce(key string, expiry time.Time, now time.Time) bool {
+	s.noncesMu.Lock()
+	defer s.noncesMu.Unlock()
+
+	if now.Sub(s.lastSweep) > s.maxSkew {
+		for k, exp := range s.nonces {
+			if now.After(exp) {
+				delete(s.nonces, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	if _, seen := s.nonces[key]; seen {
+		return false
+	}
+	s.nonces[key] = expiry
+	return true
+}
+
+// signedMessage builds the byte string covered by a snapshot signature
+func signedMessage(method, path, signer, timestamp, nonce string, body []byte) []byte {
+	sum := sha256.Sum256(body)
+	return []byte(strings.Join([]string{
+		signatureVersion,
+		method,
+		path,
+		signer,
+		timestamp,
+		nonce,
+		hex.EncodeToString(sum[:]),
+	}, "\n"))
+}
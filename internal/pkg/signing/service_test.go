@@ -0,0 +1,192 @@
+package signing
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// newEd25519Service creates an Ed25519 signer with its key in a temporary directory
+func newEd25519Service(t *testing.T, nodeID string, trusted map[string]string) *Service {
+	t.Helper()
+
+	s, err := NewService(t.TempDir(), nodeID, &domain.SigningConfig{Mode: domain.SigningModeEd25519, TrustedKeys: trusted})
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return s
+}
+
+// newHMACService creates an HMAC signer sharing secret
+func newHMACService(t *testing.T, nodeID, secret string) *Service {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	s, err := NewService(t.TempDir(), nodeID, &domain.SigningConfig{Mode: domain.SigningModeHMAC, SharedSecretFile: path})
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return s
+}
+
+func newReport(body string) *http.Request {
+	return httptest.NewRequest(http.MethodPost, "/report", strings.NewReader(body))
+}
+
+// signAt signs req as SignRequest does, with the given timestamp and nonce
+func signAt(s *Service, req *http.Request, body []byte, signedAt time.Time, nonce string) {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	message := signedMessage(req.Method, req.URL.Path, s.nodeID, timestamp, nonce, body)
+
+	req.Header.Set(HeaderNode, s.nodeID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, base64.StdEncoding.EncodeToString(s.sign(message)))
+}
+
+func TestVerifyRequest(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	body := []byte(`{"nodes":[]}`)
+
+	sender := newEd25519Service(t, "sender", nil)
+	receiver := newEd25519Service(t, "receiver", map[string]string{"sender": sender.PublicKey()})
+	stranger := newEd25519Service(t, "stranger", nil)
+	impostor := newEd25519Service(t, "sender", nil)
+
+	tests := []struct {
+		name     string
+		sender   *Service
+		receiver *Service
+		body     []byte // received body, when it differs from the signed one
+		want     error
+	}{
+		{"ed25519 trusted signer", sender, receiver, nil, nil},
+		{"ed25519 unknown signer", stranger, receiver, nil, domain.ErrUnknownSigner},
+		{"ed25519 trusted ID with another key", impostor, receiver, nil, domain.ErrInvalidSignature},
+		{"ed25519 tampered body", sender, receiver, []byte(`{"nodes":[{}]}`), domain.ErrInvalidSignature},
+		{"hmac shared secret", newHMACService(t, "sender", secret), newHMACService(t, "receiver", secret), nil, nil},
+		{"hmac other secret", newHMACService(t, "sender", secret), newHMACService(t, "receiver", strings.ToUpper(secret)), nil, domain.ErrInvalidSignature},
+		{"hmac tampered body", newHMACService(t, "sender", secret), newHMACService(t, "receiver", secret), []byte(`{}`), domain.ErrInvalidSignature},
+		// An Ed25519 signature is not an HMAC and the other way around
+		{"ed25519 signature to hmac receiver", sender, newHMACService(t, "receiver", secret), nil, domain.ErrInvalidSignature},
+		{"hmac signature to ed25519 receiver", newHMACService(t, "sender", secret), receiver, nil, domain.ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newReport(string(body))
+			if err := tt.sender.SignRequest(req, body); err != nil {
+				t.Fatalf("SignRequest: %v", err)
+			}
+			received := body
+			if tt.body != nil {
+				received = tt.body
+			}
+
+			signer, err := tt.receiver.VerifyRequest(req, received)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("VerifyRequest error = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && signer != "sender" {
+				t.Errorf("VerifyRequest signer = %q, want sender", signer)
+			}
+		})
+	}
+}
+
+func TestVerifyRequestRejectsUnsigned(t *testing.T) {
+	receiver := newEd25519Service(t, "receiver", nil)
+	if _, err := receiver.VerifyRequest(newReport("{}"), []byte("{}")); !errors.Is(err, domain.ErrUnsignedSnapshot) {
+		t.Errorf("VerifyRequest error = %v, want ErrUnsignedSnapshot", err)
+	}
+
+	// Without signing every request passes
+	disabled, err := NewService(t.TempDir(), "receiver", nil)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	if _, err := disabled.VerifyRequest(newReport("{}"), []byte("{}")); err != nil {
+		t.Errorf("VerifyRequest with signing disabled = %v, want nil", err)
+	}
+}
+
+func TestVerifyRequestRejectsReplay(t *testing.T) {
+	body := []byte(`{"nodes":[]}`)
+	sender := newEd25519Service(t, "sender", nil)
+	receiver := newEd25519Service(t, "receiver", map[string]string{"sender": sender.PublicKey()})
+
+	first := newReport(string(body))
+	if err := sender.SignRequest(first, body); err != nil {
+		t.Fatalf("SignRequest: %v", err)
+	}
+	if _, err := receiver.VerifyRequest(first, body); err != nil {
+		t.Fatalf("first VerifyRequest = %v, want nil", err)
+	}
+
+	replay := newReport(string(body))
+	replay.Header = first.Header.Clone()
+	if _, err := receiver.VerifyRequest(replay, body); !errors.Is(err, domain.ErrReplayedSnapshot) {
+		t.Errorf("replayed VerifyRequest = %v, want ErrReplayedSnapshot", err)
+	}
+
+	// A fresh nonce from the same signer is accepted
+	next := newReport(string(body))
+	if err := sender.SignRequest(next, body); err != nil {
+		t.Fatalf("SignRequest: %v", err)
+	}
+	if _, err := receiver.VerifyRequest(next, body); err != nil {
+		t.Errorf("VerifyRequest with a new nonce = %v, want nil", err)
+	}
+
+	if stats := receiver.Stats(); stats.Accepted != 2 || stats.Replayed != 1 {
+		t.Errorf("Stats = %+v, want 2 accepted and 1 replayed", stats)
+	}
+}
+
+func TestVerifyRequestRejectsStaleTimestamp(t *testing.T) {
+	body := []byte(`{"nodes":[]}`)
+	sender := newEd25519Service(t, "sender", nil)
+	receiver := newEd25519Service(t, "receiver", map[string]string{"sender": sender.PublicKey()})
+
+	tests := []struct {
+		name   string
+		offset time.Duration
+		want   error
+	}{
+		{"just inside the window", -domain.MaxClockSkew + 5*time.Second, nil},
+		{"too old", -domain.MaxClockSkew - 5*time.Second, domain.ErrStaleSnapshot},
+		{"too far ahead", domain.MaxClockSkew + 5*time.Second, domain.ErrStaleSnapshot},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newReport(string(body))
+			signAt(sender, req, body, time.Now().Add(tt.offset), "nonce"+strconv.Itoa(i))
+			if _, err := receiver.VerifyRequest(req, body); !errors.Is(err, tt.want) {
+				t.Errorf("VerifyRequest error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewServiceRejectsShortSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("too short"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := NewService(t.TempDir(), "node", &domain.SigningConfig{Mode: domain.SigningModeHMAC, SharedSecretFile: path}); err == nil {
+		t.Error("NewService accepted a shared secret under 32 bytes")
+	}
+}
@@ -0,0 +1,182 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// AlertRepository implementation
+
+// SaveAlert inserts an alert and sets its ID, or updates the state, value,
+// message and resolution time of a stored one
+func (r *Repository) SaveAlert(ctx context.Context, alert *domain.Alert) error {
+	if alert.ID != 0 {
+		result, err := r.exec(ctx, `UPDATE alerts SET state = ?, value = ?, message = ?, resolved_at = ? WHERE id = ?`,
+			alert.State, alert.Value, alert.Message, alert.ResolvedAt, alert.ID)
+		if err != nil {
+			return fmt.Errorf("failed to update alert: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("failed to update alert: alert %d does not exist", alert.ID)
+		}
+		return nil
+	}
+
+	result, err := r.exec(ctx, `INSERT INTO alerts
+			  (rule, node_id, severity, state, value, message, started_at, fired_at, resolved_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		alert.Rule, alert.NodeID, alert.Severity, alert.State, alert.Value, alert.Message,
+		alert.StartedAt, alert.FiredAt, alert.ResolvedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create alert: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get alert ID: %w", err)
+	}
+	alert.ID = id
+	return nil
+}
+
+const selectAlertsQuery = `SELECT id, rule, node_id, severity, state, value, message, started_at, fired_at, resolved_at
+			  FROM alerts`
+
+// GetFiringAlerts returns alerts that have not resolved, oldest first
+func (r *Repository) GetFiringAlerts(ctx context.Context) ([]domain.Alert, error) {
+	rows, err := r.query(ctx, selectAlertsQuery+` WHERE state = ? ORDER BY fired_at ASC, id ASC`, domain.AlertStateFiring)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query firing alerts: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAlerts(rows)
+}
+
+// GetAlertHistory returns alerts fired since a given time, newest first
+func (r *Repository) GetAlertHistory(ctx context.Context, since time.Time, limit int) ([]domain.Alert, error) {
+	rows, err := r.query(ctx, selectAlertsQuery+` WHERE fired_at >= ? ORDER BY fired_at DESC, id DESC LIMIT ?`, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert history: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAlerts(rows)
+}
+
+func scanAlerts(rows *sql.Rows) ([]domain.Alert, error) {
+	var alerts []domain.Alert
+	for rows.Next() {
+		var alert domain.Alert
+		var resolvedAt sql.NullTime
+
+		if err := rows.Scan(&alert.ID, &alert.Rule, &alert.NodeID, &alert.Severity, &alert.State,
+			&alert.Value, &alert.Message, &alert.StartedAt, &alert.FiredAt, &resolvedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %w", err)
+		}
+
+		if resolvedAt.Valid {
+			alert.ResolvedAt = &resolvedAt.Time
+		}
+
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
+
+// SilenceRepository implementation
+
+// CreateSilence stores a silence and sets its ID; labels are kept as JSON
+func (r *Repository) CreateSilence(ctx context.Context, silence *domain.Silence) error {
+	labels, err := encodeLabels(silence.Labels)
+	if err != nil {
+		return fmt.Errorf("failed to encode silence labels: %w", err)
+	}
+
+	result, err := r.exec(ctx, `INSERT INTO silences
+			  (node_id, rule, labels, starts_at, ends_at, created_by, created_at, comment)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		silence.NodeID, silence.Rule, labels, silence.StartsAt, silence.EndsAt,
+		silence.CreatedBy, silence.CreatedAt, silence.Comment)
+	if err != nil {
+		return fmt.Errorf("failed to create silence: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get silence ID: %w", err)
+	}
+	silence.ID = id
+	return nil
+}
+
+// GetSilences returns the silences ending after a given time, by start time
+func (r *Repository) GetSilences(ctx context.Context, endsAfter time.Time) ([]domain.Silence, error) {
+	rows, err := r.query(ctx, `SELECT id, node_id, rule, labels, starts_at, ends_at, created_by, created_at, comment
+			  FROM silences WHERE ends_at > ? ORDER BY starts_at ASC, id ASC`, endsAfter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query silences: %w", err)
+	}
+	defer rows.Close()
+
+	var silences []domain.Silence
+	for rows.Next() {
+		var silence domain.Silence
+		var labels string
+
+		if err := rows.Scan(&silence.ID, &silence.NodeID, &silence.Rule, &labels, &silence.StartsAt,
+			&silence.EndsAt, &silence.CreatedBy, &silence.CreatedAt, &silence.Comment); err != nil {
+			return nil, fmt.Errorf("failed to scan silence: %w", err)
+		}
+
+		if silence.Labels, err = decodeLabels(labels); err != nil {
+			return nil, fmt.Errorf("failed to decode silence labels: %w", err)
+		}
+
+		silences = append(silences, silence)
+	}
+
+	return silences, rows.Err()
+}
+
+func (r *Repository) DeleteSilence(ctx context.Context, id int64) error {
+	result, err := r.exec(ctx, `DELETE FROM silences WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete silence: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return domain.ErrSilenceNotFound
+	}
+	return nil
+}
+
+// encodeLabels encodes labels as JSON for a labels column, or empty when
+// there are none
+func encodeLabels(labels map[string]string) (string, error) {
+	if len(labels) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodeLabels decodes a labels column written by encodeLabels
+func decodeLabels(value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(value), &labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
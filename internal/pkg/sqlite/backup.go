@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"nodeprobe/internal/domain"
+)
+
+// Backup writes a consistent, compacted copy of the database to path with
+// VACUUM INTO. It reads from a snapshot, so polling continues meanwhile.
+// path must not exist.
+func (r *Repository) Backup(ctx context.Context, path string) error {
+	if _, err := r.db.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
+
+// ValidateBackup checks that the database at path is intact and that its
+// schema is not newer than this build supports, returning its version.
+// Older schemas are accepted; they are migrated when the node starts.
+func ValidateBackup(path string) (int, error) {
+	// immutable keeps the check from creating journal files next to the backup
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro&immutable=1")
+	if err != nil {
+		return 0, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow(`PRAGMA quick_check`).Scan(&result); err != nil {
+		return 0, fmt.Errorf("%w: %s is not a readable SQLite database: %v", domain.ErrInvalidBackup, path, err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("%w: integrity check failed: %s", domain.ErrInvalidBackup, result)
+	}
+
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("%w: no schema version: %v", domain.ErrInvalidBackup, err)
+	}
+	if version > SchemaVersion() {
+		return version, fmt.Errorf("%w: backup is at version %d, this build supports up to %d",
+			domain.ErrSchemaTooNew, version, SchemaVersion())
+	}
+
+	return version, nil
+}
@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+func TestBackupAndValidate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	repo, err := NewRepository(filepath.Join(dir, "nodeprobe.db"))
+	if err != nil {
+		t.Fatalf("NewRepository: %v", err)
+	}
+	defer repo.Close()
+
+	result := domain.PollResult{NodeID: "peer", PollTime: time.Now(), Success: true, ResponseMs: 7}
+	if err := repo.CreatePollResult(ctx, &result); err != nil {
+		t.Fatalf("CreatePollResult: %v", err)
+	}
+
+	backupPath := filepath.Join(dir, "backup.db")
+	if err := repo.Backup(ctx, backupPath); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	version, err := ValidateBackup(backupPath)
+	if err != nil || version != SchemaVersion() {
+		t.Fatalf("ValidateBackup = %d, %v; want version %d", version, err, SchemaVersion())
+	}
+	if _, err := os.Stat(backupPath + "-wal"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("validation left a write-ahead log next to the backup")
+	}
+
+	backup, err := NewRepository(backupPath)
+	if err != nil {
+		t.Fatalf("opening backup: %v", err)
+	}
+	results, _ := backup.GetPollResults(ctx, "peer", 10)
+	if len(results) != 1 || results[0].ResponseMs != 7 {
+		t.Errorf("backup holds %+v, want the stored poll result", results)
+	}
+
+	// A backup from a newer build is refused
+	if _, err := backup.db.Exec(`INSERT INTO schema_version (version, description, applied_at) VALUES (?, 'future', ?)`,
+		SchemaVersion()+1, time.Now()); err != nil {
+		t.Fatalf("recording future version: %v", err)
+	}
+	backup.Close()
+	if _, err := ValidateBackup(backupPath); !errors.Is(err, domain.ErrSchemaTooNew) {
+		t.Errorf("newer backup error = %v, want ErrSchemaTooNew", err)
+	}
+
+	garbage := filepath.Join(dir, "garbage.db")
+	if err := os.WriteFile(garbage, []byte("not a database, not even close to one"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateBackup(garbage); !errors.Is(err, domain.ErrInvalidBackup) {
+		t.Errorf("garbage backup error = %v, want ErrInvalidBackup", err)
+	}
+}
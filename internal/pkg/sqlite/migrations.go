@@ -0,0 +1,335 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// migration is one step of the schema. Migrations are applied in order,
+// each in its own transaction, and recorded in schema_version. Never edit a
+// released migration; append a new one instead.
+type migration struct {
+	version     int
+	description string
+	up          func(tx *sql.Tx) error
+}
+
+var migrations = []migration{
+	{
+		version:     1,
+		description: "nodes and poll results",
+		// IF NOT EXISTS lets databases created before schema versioning adopt this version
+		up: execAll(
+			`CREATE TABLE IF NOT EXISTS nodes (
+				id TEXT PRIMARY KEY,
+				fqdn TEXT NOT NULL,
+				ip TEXT NOT NULL,
+				discovered_by TEXT NOT NULL,
+				first_seen DATETIME NOT NULL,
+				last_seen DATETIME NOT NULL,
+				is_active BOOLEAN NOT NULL DEFAULT true
+			)`,
+			`CREATE TABLE IF NOT EXISTS poll_results (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				node_id TEXT NOT NULL,
+				poll_time DATETIME NOT NULL,
+				success BOOLEAN NOT NULL,
+				response_ms INTEGER,
+				error TEXT,
+				path_mtu INTEGER,
+				FOREIGN KEY (node_id) REFERENCES nodes(id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_nodes_is_active ON nodes(is_active)`,
+			`CREATE INDEX IF NOT EXISTS idx_poll_results_node_id ON poll_results(node_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_poll_results_poll_time ON poll_results(poll_time)`,
+		),
+	},
+	{
+		version:     2,
+		description: "certificate fingerprints on nodes",
+		// Unversioned databases may already have these columns
+		up: func(tx *sql.Tx) error {
+			if err := addColumnIfMissing(tx, "nodes", "cert_fingerprint", "TEXT"); err != nil {
+				return err
+			}
+			return addColumnIfMissing(tx, "nodes", "pending_fingerprint", "TEXT")
+		},
+	},
+	{
+		version:     3,
+		description: "poll result rollups",
+		up: execAll(
+			`CREATE TABLE poll_rollups_1m (
+				node_id TEXT NOT NULL,
+				bucket_start DATETIME NOT NULL,
+				polls INTEGER NOT NULL,
+				successes INTEGER NOT NULL,
+				min_ms REAL NOT NULL,
+				max_ms REAL NOT NULL,
+				sum_ms REAL NOT NULL,
+				sketch TEXT NOT NULL,
+				PRIMARY KEY (node_id, bucket_start)
+			)`,
+			`CREATE TABLE poll_rollups_1h (
+				node_id TEXT NOT NULL,
+				bucket_start DATETIME NOT NULL,
+				polls INTEGER NOT NULL,
+				successes INTEGER NOT NULL,
+				min_ms REAL NOT NULL,
+				max_ms REAL NOT NULL,
+				sum_ms REAL NOT NULL,
+				sketch TEXT NOT NULL,
+				PRIMARY KEY (node_id, bucket_start)
+			)`,
+			`CREATE INDEX idx_poll_rollups_1m_bucket_start ON poll_rollups_1m(bucket_start)`,
+			`CREATE INDEX idx_poll_rollups_1h_bucket_start ON poll_rollups_1h(bucket_start)`,
+			`CREATE TABLE rollup_state (
+				resolution TEXT PRIMARY KEY,
+				rolled_until DATETIME NOT NULL
+			)`,
+		),
+	},
+	{
+		version:     4,
+		description: "poll result observer for imported results",
+		up: func(tx *sql.Tx) error {
+			if err := addColumnIfMissing(tx, "poll_results", "observer", "TEXT NOT NULL DEFAULT ''"); err != nil {
+				return err
+			}
+			// Serves both per-node range queries and import de-duplication
+			_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_poll_results_node_time ON poll_results(node_id, poll_time)`)
+			return err
+		},
+	},
+	{
+		version:     5,
+		description: "alert history",
+		up: execAll(
+			`CREATE TABLE alerts (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				rule TEXT NOT NULL,
+				node_id TEXT NOT NULL,
+				severity TEXT NOT NULL,
+				state TEXT NOT NULL,
+				value REAL NOT NULL,
+				message TEXT NOT NULL,
+				started_at DATETIME NOT NULL,
+				fired_at DATETIME NOT NULL,
+				resolved_at DATETIME
+			)`,
+			`CREATE INDEX idx_alerts_fired_at ON alerts(fired_at)`,
+			`CREATE INDEX idx_alerts_state ON alerts(state)`,
+		),
+	},
+	{
+		version:     6,
+		description: "alert silences",
+		up: execAll(
+			`CREATE TABLE silences (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				node_id TEXT NOT NULL DEFAULT '',
+				rule TEXT NOT NULL DEFAULT '',
+				labels TEXT NOT NULL DEFAULT '',
+				starts_at DATETIME NOT NULL,
+				ends_at DATETIME NOT NULL,
+				created_by TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				comment TEXT NOT NULL DEFAULT ''
+			)`,
+			`CREATE INDEX idx_silences_ends_at ON silences(ends_at)`,
+		),
+	},
+	{
+		version:     7,
+		description: "node labels",
+		up: func(tx *sql.Tx) error {
+			return addColumnIfMissing(tx, "nodes", "labels", "TEXT NOT NULL DEFAULT ''")
+		},
+	},
+	{
+		version:     8,
+		description: "times in UTC",
+		// Earlier versions stored times in whatever zone they were in,
+		// mostly local, which text comparisons cannot order
+		up: func(tx *sql.Tx) error {
+			for table, columns := range map[string][]string{
+				"nodes":           {"first_seen", "last_seen"},
+				"poll_results":    {"poll_time"},
+				"poll_rollups_1m": {"bucket_start"},
+				"poll_rollups_1h": {"bucket_start"},
+				"rollup_state":    {"rolled_until"},
+				"alerts":          {"started_at", "fired_at", "resolved_at"},
+				"silences":        {"starts_at", "ends_at", "created_at"},
+			} {
+				for _, column := range columns {
+					if err := convertToUTC(tx, table, column); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// SchemaVersion returns the newest schema version this build knows about
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate brings the database schema up to date, refusing to touch a
+// database written by a newer version of nodeprobe
+func (r *Repository) migrate() error {
+	if _, err := r.db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	current, err := r.currentSchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	if current > SchemaVersion() {
+		return fmt.Errorf("%w: database is at version %d, this build supports up to %d",
+			domain.ErrSchemaTooNew, current, SchemaVersion())
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		if err := r.applyMigration(m); err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", m.version, m.description, err)
+		}
+		log.Printf("Applied database migration %d: %s", m.version, m.description)
+	}
+
+	return nil
+}
+
+func (r *Repository) currentSchemaVersion() (int, error) {
+	var version int
+	if err := r.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+func (r *Repository) applyMigration(m migration) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.description, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// execAll returns a migration step that executes the statements in order
+func execAll(statements ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement); err != nil {
+				return fmt.Errorf("failed to execute %s: %w", statement, err)
+			}
+		}
+		return nil
+	}
+}
+
+// addColumnIfMissing adds a column unless a database from before schema
+// versioning already has it
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    bool
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return fmt.Errorf("failed to scan column info: %w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	rows.Close()
+
+	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+
+	return nil
+}
+
+// convertToUTC rewrites the times in a column that are not yet stored in
+// UTC, a batch at a time
+func convertToUTC(tx *sql.Tx, table, column string) error {
+	type row struct {
+		id int64
+		t  time.Time
+	}
+
+	query := fmt.Sprintf(`SELECT rowid, %s FROM %s WHERE %s NOT LIKE '%%+00:00' LIMIT 10000`, column, table, column)
+	update := fmt.Sprintf(`UPDATE %s SET %s = ? WHERE rowid = ?`, table, column)
+	for {
+		rows, err := tx.Query(query)
+		if err != nil {
+			return fmt.Errorf("failed to read %s.%s: %w", table, column, err)
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.t); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read %s.%s: %w", table, column, err)
+			}
+			batch = append(batch, r)
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read %s.%s: %w", table, column, err)
+		}
+		rows.Close()
+
+		if len(batch) == 0 {
+			return nil
+		}
+		for _, r := range batch {
+			if _, err := tx.Exec(update, r.t.UTC(), r.id); err != nil {
+				return fmt.Errorf("failed to update %s.%s: %w", table, column, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// baselineSchema is the schema databases had before schema versioning
+var baselineSchema = []string{
+	`CREATE TABLE nodes (
+		id TEXT PRIMARY KEY,
+		fqdn TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		discovered_by TEXT NOT NULL,
+		first_seen DATETIME NOT NULL,
+		last_seen DATETIME NOT NULL,
+		is_active BOOLEAN NOT NULL DEFAULT true
+	)`,
+	`CREATE TABLE poll_results (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		node_id TEXT NOT NULL,
+		poll_time DATETIME NOT NULL,
+		success BOOLEAN NOT NULL,
+		response_ms INTEGER,
+		error TEXT,
+		path_mtu INTEGER,
+		FOREIGN KEY (node_id) REFERENCES nodes(id)
+	)`,
+	`CREATE INDEX idx_nodes_is_active ON nodes(is_active)`,
+	`CREATE INDEX idx_poll_results_node_id ON poll_results(node_id)`,
+	`CREATE INDEX idx_poll_results_poll_time ON poll_results(poll_time)`,
+}
+
+// createDatabase writes a database at path with the given statements
+func createDatabase(t *testing.T, path string, statements ...string) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer db.Close()
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Exec %s: %v", statement, err)
+		}
+	}
+}
+
+func columnsOf(t *testing.T, repo *Repository, table string) map[string]bool {
+	t.Helper()
+
+	rows, err := repo.db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		t.Fatalf("table_info %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		columns[name] = true
+	}
+	return columns
+}
+
+func schemaVersions(t *testing.T, repo *Repository) int {
+	t.Helper()
+
+	var count int
+	if err := repo.db.QueryRow(`SELECT COUNT(*) FROM schema_version`).Scan(&count); err != nil {
+		t.Fatalf("count schema versions: %v", err)
+	}
+	return count
+}
+
+func TestMigrateBaselineDatabase(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	for name, extra := range map[string][]string{
+		"baseline": nil,
+		// Pinning added these columns before the schema was versioned
+		"with fingerprints": {
+			`ALTER TABLE nodes ADD COLUMN cert_fingerprint TEXT`,
+			`ALTER TABLE nodes ADD COLUMN pending_fingerprint TEXT`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "nodeprobe.db")
+			statements := append(append([]string{}, baselineSchema...), extra...)
+			statements = append(statements,
+				`INSERT INTO nodes (id, fqdn, ip, discovered_by, first_seen, last_seen, is_active)
+				 VALUES ('peer', 'peer.example.com', '10.0.0.2', 'seed', '`+now+`', '`+now+`', 1)`,
+				`INSERT INTO poll_results (node_id, poll_time, success, response_ms, error, path_mtu)
+				 VALUES ('peer', '`+now+`', 1, 12, '', 1500)`)
+			createDatabase(t, path, statements...)
+
+			repo, err := NewRepository(path)
+			if err != nil {
+				t.Fatalf("NewRepository: %v", err)
+			}
+
+			if version, err := repo.currentSchemaVersion(); err != nil || version != SchemaVersion() {
+				t.Errorf("schema version = %d, %v, want %d", version, err, SchemaVersion())
+			}
+			for table, want := range map[string][]string{
+				"nodes":        {"cert_fingerprint", "pending_fingerprint", "labels"},
+				"poll_results": {"observer"},
+				"alerts":       {"rule", "resolved_at"},
+			} {
+				columns := columnsOf(t, repo, table)
+				for _, column := range want {
+					if !columns[column] {
+						t.Errorf("%s has no %s column after migrating", table, column)
+					}
+				}
+			}
+
+			// Existing rows survive and read back with the new columns' defaults
+			node, err := repo.GetNode(ctx, "peer")
+			if err != nil || node == nil || node.FQDN != "peer.example.com" || node.CertFingerprint != "" {
+				t.Errorf("GetNode = %+v, %v, want the baseline row", node, err)
+			}
+			results, err := repo.GetPollResults(ctx, "peer", 10)
+			if err != nil || len(results) != 1 || results[0].ResponseMs != 12 || results[0].Observer != "" {
+				t.Errorf("GetPollResults = %+v, %v, want the baseline row", results, err)
+			}
+
+			// Opening an up-to-date database applies nothing
+			applied := schemaVersions(t, repo)
+			repo.Close()
+			repo, err = NewRepository(path)
+			if err != nil {
+				t.Fatalf("NewRepository on an up-to-date database: %v", err)
+			}
+			defer repo.Close()
+			if again := schemaVersions(t, repo); again != applied || applied != len(migrations) {
+				t.Errorf("schema_version rows = %d after reopening, %d before, want %d", again, applied, len(migrations))
+			}
+		})
+	}
+}
+
+func TestMigrateConvertsTimesToUTC(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nodeprobe.db")
+	statements := append(append([]string{}, baselineSchema...),
+		`INSERT INTO nodes (id, fqdn, ip, discovered_by, first_seen, last_seen, is_active)
+		 VALUES ('peer', 'peer.example.com', '10.0.0.2', 'seed', '2025-06-01 17:00:00+05:00', '2025-06-01 05:30:00-07:00', 1)`,
+		// Written by a node east of UTC, and one west of it
+		`INSERT INTO poll_results (node_id, poll_time, success, response_ms, error, path_mtu)
+		 VALUES ('peer', '2025-06-01 17:00:00.25+05:00', 1, 1, '', 1500)`,
+		`INSERT INTO poll_results (node_id, poll_time, success, response_ms, error, path_mtu)
+		 VALUES ('peer', '2025-06-01 05:30:00-07:00', 1, 2, '', 1500)`)
+	createDatabase(t, path, statements...)
+
+	repo, err := NewRepository(path)
+	if err != nil {
+		t.Fatalf("NewRepository: %v", err)
+	}
+	defer repo.Close()
+
+	var stored []string
+	rows, err := repo.db.Query(`SELECT CAST(poll_time AS TEXT) FROM poll_results ORDER BY poll_time`)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var text string
+		if err := rows.Scan(&text); err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		stored = append(stored, text)
+	}
+	want := []string{"2025-06-01 12:00:00.25+00:00", "2025-06-01 12:30:00+00:00"}
+	if len(stored) != 2 || stored[0] != want[0] || stored[1] != want[1] {
+		t.Errorf("stored poll times = %q, want %q", stored, want)
+	}
+
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	results, err := repo.GetPollResultsBetween(ctx, base, base.Add(time.Hour))
+	if err != nil || len(results) != 2 || !results[0].PollTime.Equal(base.Add(250*time.Millisecond)) {
+		t.Errorf("GetPollResultsBetween = %+v, %v, want both results", results, err)
+	}
+	node, err := repo.GetNode(ctx, "peer")
+	if err != nil || !node.FirstSeen.Equal(base) || !node.LastSeen.Equal(base.Add(30*time.Minute)) {
+		t.Errorf("GetNode = %+v, %v, want first and last seen converted", node, err)
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodeprobe.db")
+	repo, err := NewRepository(path)
+	if err != nil {
+		t.Fatalf("NewRepository: %v", err)
+	}
+	if _, err := repo.db.Exec(`INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)`,
+		SchemaVersion()+1, "from the future", time.Now()); err != nil {
+		t.Fatalf("insert schema version: %v", err)
+	}
+	repo.Close()
+
+	if repo, err := NewRepository(path); !errors.Is(err, domain.ErrSchemaTooNew) {
+		if repo != nil {
+			repo.Close()
+		}
+		t.Fatalf("NewRepository error = %v, want ErrSchemaTooNew", err)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log"
 	"os"
+	"sync"
 	"time"
 
 	"nodeprobe/internal/domain"
@@ -15,10 +17,19 @@ import (
 type Repository struct {
 	db     *sql.DB
 	dbPath string
+
+	stmtMu sync.Mutex
+	stmts  map[string]*sql.Stmt // prepared statements by query text
 }
 
+// connectionOptions are applied by the driver to every pooled connection:
+// WAL lets readers run alongside the writer, and the busy timeout makes
+// concurrent writers wait for the lock instead of failing with SQLITE_BUSY.
+// Times are stored in UTC (see utcArgs) and read back in the local zone.
+const connectionOptions = "_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL&_loc=auto"
+
 func NewRepository(dbPath string) (*Repository, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?"+connectionOptions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -26,87 +37,161 @@ func NewRepository(dbPath string) (*Repository, error) {
 	repo := &Repository{
 		db:     db,
 		dbPath: dbPath,
+		stmts:  make(map[string]*sql.Stmt),
+	}
+	if err := repo.enableIncrementalVacuum(); err != nil {
+		db.Close()
+		return nil, err
 	}
-	if err := repo.initTables(); err != nil {
-		return nil, fmt.Errorf("failed to initialize tables: %w", err)
+	if err := repo.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database schema: %w", err)
 	}
 
 	return repo, nil
 }
 
 func (r *Repository) Close() error {
+	r.stmtMu.Lock()
+	for query, stmt := range r.stmts {
+		stmt.Close()
+		delete(r.stmts, query)
+	}
+	r.stmtMu.Unlock()
+
 	return r.db.Close()
 }
 
-func (r *Repository) initTables() error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS nodes (
-			id TEXT PRIMARY KEY,
-			fqdn TEXT NOT NULL,
-			ip TEXT NOT NULL,
-			discovered_by TEXT NOT NULL,
-			first_seen DATETIME NOT NULL,
-			last_seen DATETIME NOT NULL,
-			is_active BOOLEAN NOT NULL DEFAULT true
-		)`,
-		`CREATE TABLE IF NOT EXISTS poll_results (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			node_id TEXT NOT NULL,
-			poll_time DATETIME NOT NULL,
-			success BOOLEAN NOT NULL,
-			response_ms INTEGER,
-			error TEXT,
-			path_mtu INTEGER,
-			FOREIGN KEY (node_id) REFERENCES nodes(id)
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_nodes_is_active ON nodes(is_active)`,
-		`CREATE INDEX IF NOT EXISTS idx_poll_results_node_id ON poll_results(node_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_poll_results_poll_time ON poll_results(poll_time)`,
-	}
-
-	for _, query := range queries {
-		if _, err := r.db.Exec(query); err != nil {
-			return fmt.Errorf("failed to execute query %s: %w", query, err)
-		}
+// prepare returns the cached prepared statement for query, preparing it on first use
+func (r *Repository) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	r.stmtMu.Lock()
+	defer r.stmtMu.Unlock()
+
+	if stmt, ok := r.stmts[query]; ok {
+		return stmt, nil
 	}
 
-	return nil
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	r.stmts[query] = stmt
+
+	return stmt, nil
 }
 
-// NodeRepository implementation
-func (r *Repository) GetAllNodes(ctx context.Context) ([]domain.Node, error) {
-	query := `SELECT id, fqdn, ip, discovered_by, first_seen, last_seen, is_active 
-			  FROM nodes ORDER BY first_seen ASC`
+// exec runs a statement through the prepared statement cache
+func (r *Repository) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := r.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.ExecContext(ctx, utcArgs(args)...)
+}
 
-	rows, err := r.db.QueryContext(ctx, query)
+// query runs a query through the prepared statement cache
+func (r *Repository) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := r.prepare(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query nodes: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
+	return stmt.QueryContext(ctx, utcArgs(args)...)
+}
 
+// queryRow runs a single-row query through the prepared statement cache
+func (r *Repository) queryRow(ctx context.Context, query string, args ...interface{}) (*sql.Row, error) {
+	stmt, err := r.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.QueryRowContext(ctx, utcArgs(args)...), nil
+}
+
+// utcArgs converts the times among a statement's arguments to UTC. The
+// driver stores times as text with the zone offset of the value, and text
+// only sorts and compares like time when every value has the same offset,
+// so every statement binds its arguments through here.
+func utcArgs(args []interface{}) []interface{} {
+	for i, arg := range args {
+		switch t := arg.(type) {
+		case time.Time:
+			args[i] = t.UTC()
+		case *time.Time:
+			if t != nil {
+				args[i] = t.UTC()
+			}
+		}
+	}
+	return args
+}
+
+// nodeColumns lists the columns read by scanNode, in order
+const nodeColumns = `id, fqdn, ip, discovered_by, first_seen, last_seen, is_active,
+			  cert_fingerprint, pending_fingerprint, labels`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanNode(row rowScanner) (*domain.Node, error) {
+	var node domain.Node
+	var certFingerprint, pendingFingerprint sql.NullString
+	var labels string
+
+	if err := row.Scan(&node.ID, &node.FQDN, &node.IP, &node.DiscoveredBy,
+		&node.FirstSeen, &node.LastSeen, &node.IsActive,
+		&certFingerprint, &pendingFingerprint, &labels); err != nil {
+		return nil, err
+	}
+
+	node.CertFingerprint = certFingerprint.String
+	node.PendingFingerprint = pendingFingerprint.String
+
+	var err error
+	if node.Labels, err = decodeLabels(labels); err != nil {
+		return nil, fmt.Errorf("failed to decode labels of node %s: %w", node.ID, err)
+	}
+
+	return &node, nil
+}
+
+func scanNodes(rows *sql.Rows) ([]domain.Node, error) {
 	var nodes []domain.Node
 	for rows.Next() {
-		var node domain.Node
-		err := rows.Scan(&node.ID, &node.FQDN, &node.IP, &node.DiscoveredBy,
-			&node.FirstSeen, &node.LastSeen, &node.IsActive)
+		node, err := scanNode(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan node: %w", err)
 		}
-		nodes = append(nodes, node)
+		nodes = append(nodes, *node)
 	}
 
 	return nodes, rows.Err()
 }
 
+// NodeRepository implementation
+func (r *Repository) GetAllNodes(ctx context.Context) ([]domain.Node, error) {
+	query := `SELECT ` + nodeColumns + `
+			  FROM nodes ORDER BY first_seen ASC`
+
+	rows, err := r.query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query nodes: %w", err)
+	}
+	defer rows.Close()
+
+	return scanNodes(rows)
+}
+
 func (r *Repository) GetNode(ctx context.Context, id string) (*domain.Node, error) {
-	query := `SELECT id, fqdn, ip, discovered_by, first_seen, last_seen, is_active 
+	query := `SELECT ` + nodeColumns + `
 			  FROM nodes WHERE id = ?`
 
-	var node domain.Node
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&node.ID, &node.FQDN, &node.IP, &node.DiscoveredBy,
-		&node.FirstSeen, &node.LastSeen, &node.IsActive)
+	row, err := r.queryRow(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node: %w", err)
+	}
 
+	node, err := scanNode(row)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -114,15 +199,20 @@ func (r *Repository) GetNode(ctx context.Context, id string) (*domain.Node, erro
 		return nil, fmt.Errorf("failed to get node: %w", err)
 	}
 
-	return &node, nil
+	return node, nil
 }
 
 func (r *Repository) CreateNode(ctx context.Context, node *domain.Node) error {
-	query := `INSERT INTO nodes (id, fqdn, ip, discovered_by, first_seen, last_seen, is_active)
-			  VALUES (?, ?, ?, ?, ?, ?, ?)`
+	labels, err := encodeLabels(node.Labels)
+	if err != nil {
+		return fmt.Errorf("failed to encode node labels: %w", err)
+	}
+
+	query := `INSERT INTO nodes (id, fqdn, ip, discovered_by, first_seen, last_seen, is_active, labels)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err := r.db.ExecContext(ctx, query, node.ID, node.FQDN, node.IP,
-		node.DiscoveredBy, node.FirstSeen, node.LastSeen, node.IsActive)
+	_, err = r.exec(ctx, query, node.ID, node.FQDN, node.IP,
+		node.DiscoveredBy, node.FirstSeen, node.LastSeen, node.IsActive, labels)
 	if err != nil {
 		return fmt.Errorf("failed to create node: %w", err)
 	}
@@ -131,11 +221,16 @@ func (r *Repository) CreateNode(ctx context.Context, node *domain.Node) error {
 }
 
 func (r *Repository) UpdateNode(ctx context.Context, node *domain.Node) error {
+	labels, err := encodeLabels(node.Labels)
+	if err != nil {
+		return fmt.Errorf("failed to encode node labels: %w", err)
+	}
+
 	query := `UPDATE nodes SET fqdn = ?, ip = ?, discovered_by = ?, 
-			  first_seen = ?, last_seen = ?, is_active = ? WHERE id = ?`
+			  first_seen = ?, last_seen = ?, is_active = ?, labels = ? WHERE id = ?`
 
-	_, err := r.db.ExecContext(ctx, query, node.FQDN, node.IP, node.DiscoveredBy,
-		node.FirstSeen, node.LastSeen, node.IsActive, node.ID)
+	_, err = r.exec(ctx, query, node.FQDN, node.IP, node.DiscoveredBy,
+		node.FirstSeen, node.LastSeen, node.IsActive, labels, node.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update node: %w", err)
 	}
@@ -143,10 +238,42 @@ func (r *Repository) UpdateNode(ctx context.Context, node *domain.Node) error {
 	return nil
 }
 
+// UpsertNode creates a node or updates its address, discoverer, last seen
+// time, status and labels in one statement. The stored first seen time and
+// pinned fingerprints are kept and copied back into node.
+func (r *Repository) UpsertNode(ctx context.Context, node *domain.Node) error {
+	labels, err := encodeLabels(node.Labels)
+	if err != nil {
+		return fmt.Errorf("failed to encode node labels: %w", err)
+	}
+
+	query := `INSERT INTO nodes (id, fqdn, ip, discovered_by, first_seen, last_seen, is_active, labels)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			  ON CONFLICT(id) DO UPDATE SET fqdn = excluded.fqdn, ip = excluded.ip,
+			  discovered_by = excluded.discovered_by, last_seen = excluded.last_seen,
+			  is_active = excluded.is_active, labels = excluded.labels
+			  RETURNING first_seen, cert_fingerprint, pending_fingerprint`
+
+	row, err := r.queryRow(ctx, query, node.ID, node.FQDN, node.IP,
+		node.DiscoveredBy, node.FirstSeen, node.LastSeen, node.IsActive, labels)
+	if err != nil {
+		return fmt.Errorf("failed to upsert node: %w", err)
+	}
+
+	var certFingerprint, pendingFingerprint sql.NullString
+	if err := row.Scan(&node.FirstSeen, &certFingerprint, &pendingFingerprint); err != nil {
+		return fmt.Errorf("failed to upsert node: %w", err)
+	}
+	node.CertFingerprint = certFingerprint.String
+	node.PendingFingerprint = pendingFingerprint.String
+
+	return nil
+}
+
 func (r *Repository) DeleteNode(ctx context.Context, id string) error {
 	query := `DELETE FROM nodes WHERE id = ?`
 
-	_, err := r.db.ExecContext(ctx, query, id)
+	_, err := r.exec(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete node: %w", err)
 	}
@@ -155,36 +282,44 @@ func (r *Repository) DeleteNode(ctx context.Context, id string) error {
 }
 
 func (r *Repository) GetActiveNodes(ctx context.Context) ([]domain.Node, error) {
-	query := `SELECT id, fqdn, ip, discovered_by, first_seen, last_seen, is_active 
+	query := `SELECT ` + nodeColumns + `
 			  FROM nodes WHERE is_active = true ORDER BY first_seen ASC`
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := r.query(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query active nodes: %w", err)
 	}
 	defer rows.Close()
 
-	var nodes []domain.Node
-	for rows.Next() {
-		var node domain.Node
-		err := rows.Scan(&node.ID, &node.FQDN, &node.IP, &node.DiscoveredBy,
-			&node.FirstSeen, &node.LastSeen, &node.IsActive)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan node: %w", err)
-		}
-		nodes = append(nodes, node)
+	return scanNodes(rows)
+}
+
+// UpdateNodeFingerprints sets a node's pinned and pending certificate
+// fingerprints; UpdateNode leaves them untouched so discovery can't reset a pin
+func (r *Repository) UpdateNodeFingerprints(ctx context.Context, id string, pinned string, pending string) error {
+	query := `UPDATE nodes SET cert_fingerprint = ?, pending_fingerprint = ? WHERE id = ?`
+
+	_, err := r.exec(ctx, query, pinned, pending, id)
+	if err != nil {
+		return fmt.Errorf("failed to update node fingerprints: %w", err)
 	}
 
-	return nodes, rows.Err()
+	return nil
 }
 
 // PollRepository implementation
-func (r *Repository) CreatePollResult(ctx context.Context, result *domain.PollResult) error {
-	query := `INSERT INTO poll_results (node_id, poll_time, success, response_ms, error, path_mtu)
-			  VALUES (?, ?, ?, ?, ?, ?)`
+const insertPollResultQuery = `INSERT INTO poll_results (node_id, poll_time, success, response_ms, error, path_mtu, observer)
+			  VALUES (?, ?, ?, ?, ?, ?, ?)`
 
-	_, err := r.db.ExecContext(ctx, query, result.NodeID, result.PollTime,
-		result.Success, result.ResponseMs, result.Error, result.PathMTU)
+// importPollResultQuery inserts a poll result unless the same observer
+// already recorded a poll of the node at that time
+const importPollResultQuery = `INSERT INTO poll_results (node_id, poll_time, success, response_ms, error, path_mtu, observer)
+			  SELECT ?, ?, ?, ?, ?, ?, ?
+			  WHERE NOT EXISTS (SELECT 1 FROM poll_results WHERE node_id = ? AND poll_time = ? AND observer = ?)`
+
+func (r *Repository) CreatePollResult(ctx context.Context, result *domain.PollResult) error {
+	_, err := r.exec(ctx, insertPollResultQuery, result.NodeID, result.PollTime,
+		result.Success, result.ResponseMs, result.Error, result.PathMTU, result.Observer)
 	if err != nil {
 		return fmt.Errorf("failed to create poll result: %w", err)
 	}
@@ -192,51 +327,127 @@ func (r *Repository) CreatePollResult(ctx context.Context, result *domain.PollRe
 	return nil
 }
 
-func (r *Repository) GetPollResults(ctx context.Context, nodeID string, limit int) ([]domain.PollResult, error) {
-	query := `SELECT id, node_id, poll_time, success, response_ms, error, path_mtu
-			  FROM poll_results WHERE node_id = ? ORDER BY poll_time DESC LIMIT ?`
-
-	rows, err := r.db.QueryContext(ctx, query, nodeID, limit)
+// CreatePollResults stores a batch of poll results in a single transaction
+func (r *Repository) CreatePollResults(ctx context.Context, results []domain.PollResult) error {
+	stmt, err := r.prepare(ctx, insertPollResultQuery)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query poll results: %w", err)
+		return err
 	}
-	defer rows.Close()
 
-	var results []domain.PollResult
-	for rows.Next() {
-		var result domain.PollResult
-		var errorStr sql.NullString
-		var pathMTU sql.NullInt64
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-		err := rows.Scan(&result.ID, &result.NodeID, &result.PollTime,
-			&result.Success, &result.ResponseMs, &errorStr, &pathMTU)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan poll result: %w", err)
+	txStmt := tx.StmtContext(ctx, stmt)
+	for _, result := range results {
+		if _, err := txStmt.ExecContext(ctx, utcArgs([]interface{}{result.NodeID, result.PollTime,
+			result.Success, result.ResponseMs, result.Error, result.PathMTU, result.Observer})...); err != nil {
+			return fmt.Errorf("failed to create poll result: %w", err)
 		}
+	}
 
-		if errorStr.Valid {
-			result.Error = errorStr.String
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit poll results: %w", err)
+	}
+
+	return nil
+}
+
+// ImportPollResults stores poll results in a single transaction, skipping
+// any the same observer already recorded for the node at the same time
+func (r *Repository) ImportPollResults(ctx context.Context, results []domain.PollResult) (int64, error) {
+	stmt, err := r.prepare(ctx, importPollResultQuery)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var added int64
+	txStmt := tx.StmtContext(ctx, stmt)
+	for _, result := range results {
+		res, err := txStmt.ExecContext(ctx, utcArgs([]interface{}{result.NodeID, result.PollTime,
+			result.Success, result.ResponseMs, result.Error, result.PathMTU, result.Observer,
+			result.NodeID, result.PollTime, result.Observer})...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to import poll result: %w", err)
 		}
-		if pathMTU.Valid {
-			result.PathMTU = int(pathMTU.Int64)
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get rows affected: %w", err)
 		}
+		added += n
+	}
 
-		results = append(results, result)
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit imported poll results: %w", err)
 	}
 
-	return results, rows.Err()
+	return added, nil
+}
+
+func (r *Repository) GetPollResults(ctx context.Context, nodeID string, limit int) ([]domain.PollResult, error) {
+	query := `SELECT id, node_id, poll_time, success, response_ms, error, path_mtu, observer
+			  FROM poll_results WHERE node_id = ? ORDER BY poll_time DESC LIMIT ?`
+
+	rows, err := r.query(ctx, query, nodeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query poll results: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPollResults(rows)
 }
 
 func (r *Repository) GetRecentPollResults(ctx context.Context, since time.Time) ([]domain.PollResult, error) {
-	query := `SELECT id, node_id, poll_time, success, response_ms, error, path_mtu
+	query := `SELECT id, node_id, poll_time, success, response_ms, error, path_mtu, observer
 			  FROM poll_results WHERE poll_time >= ? ORDER BY poll_time DESC`
 
-	rows, err := r.db.QueryContext(ctx, query, since)
+	rows, err := r.query(ctx, query, since)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query recent poll results: %w", err)
 	}
 	defer rows.Close()
 
+	return scanPollResults(rows)
+}
+
+// GetNodePollResultsSince returns a node's poll results since a given time, oldest first
+func (r *Repository) GetNodePollResultsSince(ctx context.Context, nodeID string, since time.Time) ([]domain.PollResult, error) {
+	query := `SELECT id, node_id, poll_time, success, response_ms, error, path_mtu, observer
+			  FROM poll_results WHERE node_id = ? AND poll_time >= ? ORDER BY poll_time ASC`
+
+	rows, err := r.query(ctx, query, nodeID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query node poll results: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPollResults(rows)
+}
+
+// GetPollResultsBetween returns all nodes' poll results in [since, until),
+// ordered by node and time
+func (r *Repository) GetPollResultsBetween(ctx context.Context, since, until time.Time) ([]domain.PollResult, error) {
+	query := `SELECT id, node_id, poll_time, success, response_ms, error, path_mtu, observer
+			  FROM poll_results WHERE poll_time >= ? AND poll_time < ? ORDER BY node_id, poll_time ASC`
+
+	rows, err := r.query(ctx, query, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query poll results: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPollResults(rows)
+}
+
+func scanPollResults(rows *sql.Rows) ([]domain.PollResult, error) {
 	var results []domain.PollResult
 	for rows.Next() {
 		var result domain.PollResult
@@ -244,7 +455,7 @@ func (r *Repository) GetRecentPollResults(ctx context.Context, since time.Time)
 		var pathMTU sql.NullInt64
 
 		err := rows.Scan(&result.ID, &result.NodeID, &result.PollTime,
-			&result.Success, &result.ResponseMs, &errorStr, &pathMTU)
+			&result.Success, &result.ResponseMs, &errorStr, &pathMTU, &result.Observer)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan poll result: %w", err)
 		}
@@ -269,53 +480,76 @@ func (r *Repository) GetDatabaseSize(ctx context.Context) (int64, error) {
 		return 0, fmt.Errorf("failed to get database file info: %w", err)
 	}
 
-	return info.Size(), nil
+	// Recent writes live in the write-ahead log until the next checkpoint
+	size := info.Size()
+	if wal, err := os.Stat(r.dbPath + "-wal"); err == nil {
+		size += wal.Size()
+	}
+
+	return size, nil
 }
 
-func (r *Repository) CleanupOldResults(ctx context.Context, maxSizeMB int) error {
-	// Check current database size
-	currentSize, err := r.GetDatabaseSize(ctx)
+// DeletePollResultsBefore removes raw poll results older than before
+func (r *Repository) DeletePollResultsBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.exec(ctx, `DELETE FROM poll_results WHERE poll_time < ?`, before)
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("failed to delete old poll results: %w", err)
 	}
 
-	maxSizeBytes := int64(maxSizeMB * 1024 * 1024)
-	if currentSize <= maxSizeBytes {
-		return nil // No cleanup needed
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
-	// Delete oldest poll results until we're under the limit
-	query := `DELETE FROM poll_results WHERE id IN (
-		SELECT id FROM poll_results ORDER BY poll_time ASC LIMIT 1000
-	)`
+	return deleted, nil
+}
 
-	for currentSize > maxSizeBytes {
-		result, err := r.db.ExecContext(ctx, query)
-		if err != nil {
-			return fmt.Errorf("failed to cleanup old results: %w", err)
-		}
+// ReclaimSpace returns free pages left by deletions to the filesystem
+// without rewriting the whole database like VACUUM does
+func (r *Repository) ReclaimSpace(ctx context.Context) error {
+	if _, err := r.db.ExecContext(ctx, "PRAGMA incremental_vacuum"); err != nil {
+		return fmt.Errorf("failed to run incremental vacuum: %w", err)
+	}
+	return nil
+}
 
-		rowsAffected, err := result.RowsAffected()
-		if err != nil {
-			return fmt.Errorf("failed to get rows affected: %w", err)
-		}
+// enableIncrementalVacuum switches the database to incremental auto-vacuum.
+// Existing databases need one full VACUUM for the mode to take effect; new
+// ones are empty and convert instantly.
+func (r *Repository) enableIncrementalVacuum() error {
+	ctx := context.Background()
 
-		if rowsAffected == 0 {
-			break // No more rows to delete
-		}
+	// auto_vacuum is set per connection until VACUUM writes it to the file
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
 
-		// Re-check database size
-		currentSize, err = r.GetDatabaseSize(ctx)
-		if err != nil {
-			return err
-		}
+	var mode int
+	if err := conn.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return fmt.Errorf("failed to read auto_vacuum mode: %w", err)
+	}
+	if mode == autoVacuumIncremental {
+		return nil
 	}
 
-	// Run VACUUM to reclaim space
-	_, err = r.db.ExecContext(ctx, "VACUUM")
-	if err != nil {
+	var pages int
+	if err := conn.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pages); err != nil {
+		return fmt.Errorf("failed to read page count: %w", err)
+	}
+	if pages > 0 {
+		log.Printf("Converting database to incremental auto-vacuum, this may take a moment...")
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+		return fmt.Errorf("failed to set auto_vacuum mode: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "VACUUM"); err != nil {
 		return fmt.Errorf("failed to vacuum database: %w", err)
 	}
 
 	return nil
 }
+
+// autoVacuumIncremental is the value PRAGMA auto_vacuum reports for INCREMENTAL
+const autoVacuumIncremental = 2
@@ -0,0 +1,33 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+	"nodeprobe/internal/pkg/storetest"
+)
+
+func openTestRepository(t *testing.T) domain.Store {
+	repo, err := NewRepository(filepath.Join(t.TempDir(), "nodeprobe.db"))
+	if err != nil {
+		t.Fatalf("NewRepository: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, openTestRepository)
+}
+
+// Nodes run in their local zone, which the driver writes times in unless
+// told otherwise
+func TestConformanceInLocalZone(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5:30", 5*60*60+30*60)
+	defer func() { time.Local = local }()
+
+	storetest.Run(t, openTestRepository)
+}
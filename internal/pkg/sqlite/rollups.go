@@ -0,0 +1,147 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// rollupTable returns the table holding rollups of the given resolution
+func rollupTable(resolution string) (string, error) {
+	switch resolution {
+	case domain.RollupMinute:
+		return "poll_rollups_1m", nil
+	case domain.RollupHour:
+		return "poll_rollups_1h", nil
+	default:
+		return "", fmt.Errorf("%w: unknown rollup resolution %q", domain.ErrInvalidRange, resolution)
+	}
+}
+
+// SaveRollups stores rollups and advances the resolution's watermark in one
+// transaction, so a crash never leaves a bucket counted twice or skipped
+func (r *Repository) SaveRollups(ctx context.Context, resolution string, rollups []domain.PollRollup, rolledUntil time.Time) error {
+	table, err := rollupTable(resolution)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := r.prepare(ctx, fmt.Sprintf(`INSERT OR REPLACE INTO %s
+			  (node_id, bucket_start, polls, successes, min_ms, max_ms, sum_ms, sketch)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, table))
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	txStmt := tx.StmtContext(ctx, stmt)
+
+	for _, rollup := range rollups {
+		sketch, err := json.Marshal(rollup.Sketch)
+		if err != nil {
+			return fmt.Errorf("failed to encode latency sketch: %w", err)
+		}
+
+		if _, err := txStmt.ExecContext(ctx, utcArgs([]interface{}{rollup.NodeID, rollup.BucketStart,
+			rollup.Polls, rollup.Successes, rollup.MinMs, rollup.MaxMs, rollup.SumMs, string(sketch)})...); err != nil {
+			return fmt.Errorf("failed to save rollup: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO rollup_state (resolution, rolled_until) VALUES (?, ?)`,
+		utcArgs([]interface{}{resolution, rolledUntil})...); err != nil {
+		return fmt.Errorf("failed to update rollup watermark: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetRollups returns rollups with bucket_start in [since, until) ordered by
+// node and time; an empty nodeID returns every node
+func (r *Repository) GetRollups(ctx context.Context, resolution string, nodeID string, since, until time.Time) ([]domain.PollRollup, error) {
+	table, err := rollupTable(resolution)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT node_id, bucket_start, polls, successes, min_ms, max_ms, sum_ms, sketch
+			  FROM %s WHERE bucket_start >= ? AND bucket_start < ?`, table)
+	args := []interface{}{since, until}
+	if nodeID != "" {
+		query += ` AND node_id = ?`
+		args = append(args, nodeID)
+	}
+	query += ` ORDER BY node_id, bucket_start ASC`
+
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rollups: %w", err)
+	}
+	defer rows.Close()
+
+	var rollups []domain.PollRollup
+	for rows.Next() {
+		var rollup domain.PollRollup
+		var sketch string
+
+		if err := rows.Scan(&rollup.NodeID, &rollup.BucketStart, &rollup.Polls, &rollup.Successes,
+			&rollup.MinMs, &rollup.MaxMs, &rollup.SumMs, &sketch); err != nil {
+			return nil, fmt.Errorf("failed to scan rollup: %w", err)
+		}
+		if err := json.Unmarshal([]byte(sketch), &rollup.Sketch); err != nil {
+			return nil, fmt.Errorf("failed to decode latency sketch: %w", err)
+		}
+
+		rollups = append(rollups, rollup)
+	}
+
+	return rollups, rows.Err()
+}
+
+// GetRollupWatermark returns the time up to which results have been rolled
+// up at the given resolution, or the zero time if they never have
+func (r *Repository) GetRollupWatermark(ctx context.Context, resolution string) (time.Time, error) {
+	row, err := r.queryRow(ctx, `SELECT rolled_until FROM rollup_state WHERE resolution = ?`, resolution)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get rollup watermark: %w", err)
+	}
+
+	var rolledUntil time.Time
+	err = row.Scan(&rolledUntil)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get rollup watermark: %w", err)
+	}
+	return rolledUntil, nil
+}
+
+// DeleteRollupsBefore removes rollups whose bucket starts before the given time
+func (r *Repository) DeleteRollupsBefore(ctx context.Context, resolution string, before time.Time) (int64, error) {
+	table, err := rollupTable(resolution)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE bucket_start < ?`, table), before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old rollups: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return deleted, nil
+}
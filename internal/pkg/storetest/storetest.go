@@ -0,0 +1,749 @@
+// Package storetest is a conformance suite for domain.Store implementations.
+// Each backend runs it from its own tests so all of them behave alike.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// Run exercises a backend; open must return a new, empty store that is
+// closed when the test finishes
+func Run(t *testing.T, open func(t *testing.T) domain.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store domain.Store)
+	}{
+		{"CreateAndGetNode", testCreateAndGetNode},
+		{"GetMissingNode", testGetMissingNode},
+		{"ListNodes", testListNodes},
+		{"UpdateNodeKeepsFingerprints", testUpdateNodeKeepsFingerprints},
+		{"UpsertNode", testUpsertNode},
+		{"NodeLabels", testNodeLabels},
+		{"DeleteNode", testDeleteNode},
+		{"PollResults", testPollResults},
+		{"PollResultsBetween", testPollResultsBetween},
+		{"ImportPollResults", testImportPollResults},
+		{"DeletePollResultsBefore", testDeletePollResultsBefore},
+		{"MixedZones", testMixedZones},
+		{"Rollups", testRollups},
+		{"RollupWatermark", testRollupWatermark},
+		{"UnknownResolution", testUnknownResolution},
+		{"Alerts", testAlerts},
+		{"Silences", testSilences},
+		{"DatabaseSize", testDatabaseSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+// base is a fixed reference time so results don't depend on when tests run
+var base = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newNode(id string, firstSeen time.Time) *domain.Node {
+	return &domain.Node{
+		ID:           id,
+		FQDN:         id + ".example.com",
+		IP:           "10.0.0.1",
+		DiscoveredBy: "seed",
+		FirstSeen:    firstSeen,
+		LastSeen:     firstSeen,
+		IsActive:     true,
+	}
+}
+
+func mustCreateNode(t *testing.T, store domain.Store, node *domain.Node) {
+	t.Helper()
+	if err := store.CreateNode(context.Background(), node); err != nil {
+		t.Fatalf("CreateNode(%s): %v", node.ID, err)
+	}
+}
+
+func mustGetNode(t *testing.T, store domain.Store, id string) *domain.Node {
+	t.Helper()
+	node, err := store.GetNode(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetNode(%s): %v", id, err)
+	}
+	if node == nil {
+		t.Fatalf("GetNode(%s) returned nil", id)
+	}
+	return node
+}
+
+func nodeIDs(nodes []domain.Node) []string {
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	return ids
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testCreateAndGetNode(t *testing.T, store domain.Store) {
+	want := newNode("node-a", base)
+	mustCreateNode(t, store, want)
+
+	got := mustGetNode(t, store, "node-a")
+	if got.FQDN != want.FQDN || got.IP != want.IP || got.DiscoveredBy != want.DiscoveredBy || !got.IsActive {
+		t.Errorf("GetNode = %+v, want %+v", got, want)
+	}
+	if !got.FirstSeen.Equal(want.FirstSeen) || !got.LastSeen.Equal(want.LastSeen) {
+		t.Errorf("GetNode times = %v/%v, want %v/%v", got.FirstSeen, got.LastSeen, want.FirstSeen, want.LastSeen)
+	}
+
+	if err := store.CreateNode(context.Background(), newNode("node-a", base)); err == nil {
+		t.Error("CreateNode with a duplicate ID succeeded")
+	}
+}
+
+func testGetMissingNode(t *testing.T, store domain.Store) {
+	node, err := store.GetNode(context.Background(), "missing")
+	if err != nil {
+		t.Fatalf("GetNode: %v", err)
+	}
+	if node != nil {
+		t.Errorf("GetNode(missing) = %+v, want nil", node)
+	}
+}
+
+func testListNodes(t *testing.T, store domain.Store) {
+	ctx := context.Background()
+
+	mustCreateNode(t, store, newNode("node-c", base.Add(2*time.Minute)))
+	mustCreateNode(t, store, newNode("node-a", base))
+	inactive := newNode("node-b", base.Add(time.Minute))
+	inactive.IsActive = false
+	mustCreateNode(t, store, inactive)
+
+	all, err := store.GetAllNodes(ctx)
+	if err != nil {
+		t.Fatalf("GetAllNodes: %v", err)
+	}
+	if got, want := nodeIDs(all), []string{"node-a", "node-b", "node-c"}; !equalStrings(got, want) {
+		t.Errorf("GetAllNodes = %v, want %v (oldest first)", got, want)
+	}
+
+	active, err := store.GetActiveNodes(ctx)
+	if err != nil {
+		t.Fatalf("GetActiveNodes: %v", err)
+	}
+	if got, want := nodeIDs(active), []string{"node-a", "node-c"}; !equalStrings(got, want) {
+		t.Errorf("GetActiveNodes = %v, want %v", got, want)
+	}
+}
+
+func testUpdateNodeKeepsFingerprints(t *testing.T, store domain.Store) {
+	ctx := context.Background()
+	mustCreateNode(t, store, newNode("node-a", base))
+
+	if err := store.UpdateNodeFingerprints(ctx, "node-a", "pinned", "pending"); err != nil {
+		t.Fatalf("UpdateNodeFingerprints: %v", err)
+	}
+
+	update := newNode("node-a", base)
+	update.FQDN = "renamed.example.com"
+	update.IsActive = false
+	if err := store.UpdateNode(ctx, update); err != nil {
+		t.Fatalf("UpdateNode: %v", err)
+	}
+
+	got := mustGetNode(t, store, "node-a")
+	if got.FQDN != "renamed.example.com" || got.IsActive {
+		t.Errorf("UpdateNode not applied: %+v", got)
+	}
+	if got.CertFingerprint != "pinned" || got.PendingFingerprint != "pending" {
+		t.Errorf("fingerprints = %q/%q, want pinned/pending", got.CertFingerprint, got.PendingFingerprint)
+	}
+
+	// Updating an unknown node is not an error and creates nothing
+	if err := store.UpdateNode(ctx, newNode("missing", base)); err != nil {
+		t.Errorf("UpdateNode(missing): %v", err)
+	}
+	if node, _ := store.GetNode(ctx, "missing"); node != nil {
+		t.Error("UpdateNode created a missing node")
+	}
+}
+
+func testUpsertNode(t *testing.T, store domain.Store) {
+	ctx := context.Background()
+
+	created := newNode("node-a", base)
+	if err := store.UpsertNode(ctx, created); err != nil {
+		t.Fatalf("UpsertNode (create): %v", err)
+	}
+	if err := store.UpdateNodeFingerprints(ctx, "node-a", "pinned", ""); err != nil {
+		t.Fatalf("UpdateNodeFingerprints: %v", err)
+	}
+
+	later := base.Add(time.Hour)
+	update := newNode("node-a", later)
+	update.IP = "10.0.0.2"
+	if err := store.UpsertNode(ctx, update); err != nil {
+		t.Fatalf("UpsertNode (update): %v", err)
+	}
+
+	if !update.FirstSeen.Equal(base) || update.CertFingerprint != "pinned" {
+		t.Errorf("UpsertNode returned first seen %v and fingerprint %q, want %v and pinned",
+			update.FirstSeen, update.CertFingerprint, base)
+	}
+
+	got := mustGetNode(t, store, "node-a")
+	if got.IP != "10.0.0.2" || !got.LastSeen.Equal(later) || !got.FirstSeen.Equal(base) || got.CertFingerprint != "pinned" {
+		t.Errorf("GetNode after upsert = %+v", got)
+	}
+}
+
+func testNodeLabels(t *testing.T, store domain.Store) {
+	ctx := context.Background()
+
+	labeled := newNode("node-a", base)
+	labeled.Labels = map[string]string{"region": "eu-west", "zone": "eu-west-1a"}
+	mustCreateNode(t, store, labeled)
+	mustCreateNode(t, store, newNode("node-b", base))
+
+	if got := mustGetNode(t, store, "node-a"); got.Labels["region"] != "eu-west" || len(got.Labels) != 2 {
+		t.Errorf("GetNode labels = %v, want %v", got.Labels, labeled.Labels)
+	}
+	if got := mustGetNode(t, store, "node-b"); len(got.Labels) != 0 {
+		t.Errorf("GetNode labels of unlabeled node = %v, want none", got.Labels)
+	}
+
+	update := newNode("node-a", base)
+	update.Labels = map[string]string{"region": "us-east"}
+	if err := store.UpsertNode(ctx, update); err != nil {
+		t.Fatalf("UpsertNode: %v", err)
+	}
+	update.Labels["region"] = "changed after the write"
+
+	nodes, err := store.GetAllNodes(ctx)
+	if err != nil {
+		t.Fatalf("GetAllNodes: %v", err)
+	}
+	if len(nodes) != 2 || nodes[0].ID != "node-a" || len(nodes[0].Labels) != 1 || nodes[0].Labels["region"] != "us-east" {
+		t.Errorf("GetAllNodes = %+v, want node-a relabeled region=us-east", nodes)
+	}
+
+	update = newNode("node-a", base)
+	if err := store.UpdateNode(ctx, update); err != nil {
+		t.Fatalf("UpdateNode: %v", err)
+	}
+	if got := mustGetNode(t, store, "node-a"); len(got.Labels) != 0 {
+		t.Errorf("GetNode labels after clearing = %v, want none", got.Labels)
+	}
+}
+
+func testDeleteNode(t *testing.T, store domain.Store) {
+	ctx := context.Background()
+	mustCreateNode(t, store, newNode("node-a", base))
+
+	if err := store.DeleteNode(ctx, "node-a"); err != nil {
+		t.Fatalf("DeleteNode: %v", err)
+	}
+	if node, _ := store.GetNode(ctx, "node-a"); node != nil {
+		t.Error("node still present after DeleteNode")
+	}
+}
+
+func pollResult(nodeID string, at time.Time, success bool, ms int64) domain.PollResult {
+	result := domain.PollResult{NodeID: nodeID, PollTime: at, Success: success, ResponseMs: ms}
+	if !success {
+		result.Error = "timeout"
+	} else {
+		result.PathMTU = 1500
+	}
+	return result
+}
+
+func pollTimes(results []domain.PollResult) []time.Time {
+	times := make([]time.Time, len(results))
+	for i, result := range results {
+		times[i] = result.PollTime
+	}
+	return times
+}
+
+func equalTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func testPollResults(t *testing.T, store domain.Store) {
+	ctx := context.Background()
+	mustCreateNode(t, store, newNode("node-a", base))
+	mustCreateNode(t, store, newNode("node-b", base))
+
+	first := pollResult("node-a", base, true, 12)
+	if err := store.CreatePollResult(ctx, &first); err != nil {
+		t.Fatalf("CreatePollResult: %v", err)
+	}
+	batch := []domain.PollResult{
+		pollResult("node-a", base.Add(time.Minute), false, 0),
+		pollResult("node-b", base.Add(90*time.Second), true, 30),
+		pollResult("node-a", base.Add(2*time.Minute), true, 15),
+	}
+	if err := store.CreatePollResults(ctx, batch); err != nil {
+		t.Fatalf("CreatePollResults: %v", err)
+	}
+
+	latest, err := store.GetPollResults(ctx, "node-a", 2)
+	if err != nil {
+		t.Fatalf("GetPollResults: %v", err)
+	}
+	if got, want := pollTimes(latest), []time.Time{base.Add(2 * time.Minute), base.Add(time.Minute)}; !equalTimes(got, want) {
+		t.Errorf("GetPollResults = %v, want %v (newest first, limited)", got, want)
+	}
+	if failed := latest[1]; failed.Success || failed.Error != "timeout" || failed.NodeID != "node-a" {
+		t.Errorf("failed poll result = %+v", failed)
+	}
+	if latest[0].PathMTU != 1500 || latest[0].ResponseMs != 15 {
+		t.Errorf("poll result fields = %+v", latest[0])
+	}
+
+	recent, err := store.GetRecentPollResults(ctx, base.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetRecentPollResults: %v", err)
+	}
+	want := []time.Time{base.Add(2 * time.Minute), base.Add(90 * time.Second), base.Add(time.Minute)}
+	if got := pollTimes(recent); !equalTimes(got, want) {
+		t.Errorf("GetRecentPollResults = %v, want %v (all nodes, newest first)", got, want)
+	}
+
+	since, err := store.GetNodePollResultsSince(ctx, "node-a", base.Add(30*time.Second))
+	if err != nil {
+		t.Fatalf("GetNodePollResultsSince: %v", err)
+	}
+	if got, want := pollTimes(since), []time.Time{base.Add(time.Minute), base.Add(2 * time.Minute)}; !equalTimes(got, want) {
+		t.Errorf("GetNodePollResultsSince = %v, want %v (oldest first)", got, want)
+	}
+
+	none, err := store.GetPollResults(ctx, "missing", 10)
+	if err != nil || len(none) != 0 {
+		t.Errorf("GetPollResults(missing) = %v, %v; want no results", none, err)
+	}
+}
+
+func testPollResultsBetween(t *testing.T, store domain.Store) {
+	ctx := context.Background()
+	results := []domain.PollResult{
+		pollResult("node-b", base.Add(10*time.Second), true, 1),
+		pollResult("node-a", base.Add(20*time.Second), true, 2),
+		pollResult("node-a", base, true, 3),
+		pollResult("node-a", base.Add(time.Minute), true, 4), // excluded: until is exclusive
+		pollResult("node-b", base.Add(-time.Second), true, 5),
+	}
+	if err := store.CreatePollResults(ctx, results); err != nil {
+		t.Fatalf("CreatePollResults: %v", err)
+	}
+
+	between, err := store.GetPollResultsBetween(ctx, base, base.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetPollResultsBetween: %v", err)
+	}
+
+	var got []int64
+	for _, result := range between {
+		got = append(got, result.ResponseMs)
+	}
+	want := []int64{3, 2, 1} // node-a then node-b, each oldest first
+	if len(got) != len(want) {
+		t.Fatalf("GetPollResultsBetween returned %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("GetPollResultsBetween returned %v, want %v", got, want)
+		}
+	}
+}
+
+func testImportPollResults(t *testing.T, store domain.Store) {
+	ctx := context.Background()
+
+	imported := func(observer string, at time.Time, ms int64) domain.PollResult {
+		result := pollResult("node-a", at, true, ms)
+		result.Observer = observer
+		return result
+	}
+	batch := []domain.PollResult{
+		imported("peer-1", base, 10),
+		imported("peer-1", base.Add(time.Minute), 11),
+		imported("peer-1", base, 99), // duplicate within the batch
+		imported("peer-2", base, 20), // same time, different observer
+	}
+
+	added, err := store.ImportPollResults(ctx, batch)
+	if err != nil {
+		t.Fatalf("ImportPollResults: %v", err)
+	}
+	if added != 3 {
+		t.Errorf("first import added %d results, want 3", added)
+	}
+
+	// Re-importing an overlapping export only adds what's new
+	added, err = store.ImportPollResults(ctx, append(batch, imported("peer-1", base.Add(2*time.Minute), 12)))
+	if err != nil {
+		t.Fatalf("ImportPollResults: %v", err)
+	}
+	if added != 1 {
+		t.Errorf("second import added %d results, want 1", added)
+	}
+
+	results, err := store.GetNodePollResultsSince(ctx, "node-a", base)
+	if err != nil {
+		t.Fatalf("GetNodePollResultsSince: %v", err)
+	}
+	observers := map[string]int{}
+	for _, result := range results {
+		observers[result.Observer]++
+		if result.Observer == "peer-1" && result.PollTime.Equal(base) && result.ResponseMs != 10 {
+			t.Errorf("duplicate replaced the first result: %+v", result)
+		}
+	}
+	if len(results) != 4 || observers["peer-1"] != 3 || observers["peer-2"] != 1 {
+		t.Errorf("stored results by observer = %v (%d total), want peer-1: 3, peer-2: 1", observers, len(results))
+	}
+}
+
+func testDeletePollResultsBefore(t *testing.T, store domain.Store) {
+	ctx := context.Background()
+	results := []domain.PollResult{
+		pollResult("node-a", base.Add(-2*time.Hour), true, 1),
+		pollResult("node-b", base.Add(-time.Hour), true, 2),
+		pollResult("node-a", base, true, 3),
+	}
+	if err := store.CreatePollResults(ctx, results); err != nil {
+		t.Fatalf("CreatePollResults: %v", err)
+	}
+
+	deleted, err := store.DeletePollResultsBefore(ctx, base.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("DeletePollResultsBefore: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("DeletePollResultsBefore deleted %d, want 2", deleted)
+	}
+
+	left, err := store.GetRecentPollResults(ctx, base.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("GetRecentPollResults: %v", err)
+	}
+	if len(left) != 1 || left[0].ResponseMs != 3 {
+		t.Errorf("results left = %+v, want only the newest", left)
+	}
+
+	if err := store.ReclaimSpace(ctx); err != nil {
+		t.Errorf("ReclaimSpace: %v", err)
+	}
+}
+
+// testMixedZones stores times in one zone and queries them in others, as
+// polls (local time), SLO months (UTC) and export bounds (any offset) do
+func testMixedZones(t *testing.T, store domain.Store) {
+	ctx := context.Background()
+	east := time.FixedZone("UTC+5", 5*60*60)
+	west := time.FixedZone("UTC-7", -7*60*60)
+
+	for i := 0; i < 4; i++ {
+		if err := store.CreatePollResult(ctx, &domain.PollResult{
+			NodeID: "node-a", PollTime: base.Add(time.Duration(i) * time.Hour).In(east), Success: true, ResponseMs: int64(i),
+		}); err != nil {
+			t.Fatalf("CreatePollResult: %v", err)
+		}
+	}
+
+	between, err := store.GetPollResultsBetween(ctx, base.Add(time.Hour).In(west), base.Add(3*time.Hour).UTC())
+	if err != nil {
+		t.Fatalf("GetPollResultsBetween: %v", err)
+	}
+	if got, want := pollTimes(between), []time.Time{base.Add(time.Hour), base.Add(2 * time.Hour)}; !equalTimes(got, want) {
+		t.Errorf("GetPollResultsBetween = %v, want %v", got, want)
+	}
+
+	since, err := store.GetNodePollResultsSince(ctx, "node-a", base.Add(3*time.Hour).In(west))
+	if err != nil || len(since) != 1 {
+		t.Errorf("GetNodePollResultsSince = %d results, %v; want 1", len(since), err)
+	}
+
+	// The same instant in another zone is a duplicate
+	added, err := store.ImportPollResults(ctx, []domain.PollResult{pollResult("node-a", base.In(west), true, 0)})
+	if err != nil || added != 0 {
+		t.Errorf("ImportPollResults added %d, %v; want the duplicate skipped", added, err)
+	}
+
+	if err := store.SaveRollups(ctx, domain.RollupMinute, []domain.PollRollup{rollup("node-a", base.In(east), 10)}, base.Add(time.Minute).In(west)); err != nil {
+		t.Fatalf("SaveRollups: %v", err)
+	}
+	rollups, err := store.GetRollups(ctx, domain.RollupMinute, "", base.UTC(), base.Add(time.Minute).In(east))
+	if err != nil || len(rollups) != 1 || !rollups[0].BucketStart.Equal(base) {
+		t.Errorf("GetRollups = %+v, %v; want the bucket at %v", rollups, err, base)
+	}
+	watermark, err := store.GetRollupWatermark(ctx, domain.RollupMinute)
+	if err != nil || !watermark.Equal(base.Add(time.Minute)) {
+		t.Errorf("watermark = %v, %v; want %v", watermark, err, base.Add(time.Minute))
+	}
+
+	deleted, err := store.DeletePollResultsBefore(ctx, base.Add(2*time.Hour).In(west))
+	if err != nil || deleted != 2 {
+		t.Errorf("DeletePollResultsBefore deleted %d, %v; want 2", deleted, err)
+	}
+}
+
+func rollup(nodeID string, start time.Time, latencies ...float64) domain.PollRollup {
+	r := domain.PollRollup{NodeID: nodeID, BucketStart: start, Polls: len(latencies) + 1}
+	for _, ms := range latencies {
+		if r.Successes == 0 || ms < r.MinMs {
+			r.MinMs = ms
+		}
+		if ms > r.MaxMs {
+			r.MaxMs = ms
+		}
+		r.Successes++
+		r.SumMs += ms
+		r.Sketch.Add(ms)
+	}
+	return r
+}
+
+func testRollups(t *testing.T, store domain.Store) {
+	ctx := context.Background()
+
+	rollups := []domain.PollRollup{
+		rollup("node-b", base, 5),
+		rollup("node-a", base.Add(time.Minute), 10, 20, 30),
+		rollup("node-a", base, 40),
+	}
+	if err := store.SaveRollups(ctx, domain.RollupMinute, rollups, base.Add(2*time.Minute)); err != nil {
+		t.Fatalf("SaveRollups: %v", err)
+	}
+
+	nodeA, err := store.GetRollups(ctx, domain.RollupMinute, "node-a", base, base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetRollups: %v", err)
+	}
+	if len(nodeA) != 2 || !nodeA[0].BucketStart.Equal(base) || !nodeA[1].BucketStart.Equal(base.Add(time.Minute)) {
+		t.Fatalf("GetRollups(node-a) = %+v, want two buckets oldest first", nodeA)
+	}
+
+	got := nodeA[1]
+	if got.Polls != 4 || got.Successes != 3 || got.MinMs != 10 || got.MaxMs != 30 || got.SumMs != 60 {
+		t.Errorf("rollup = %+v", got)
+	}
+	if got.Sketch.Count() != 3 {
+		t.Errorf("sketch count = %d, want 3", got.Sketch.Count())
+	}
+	got.Summarize()
+	if got.AvgMs != 20 || got.P50Ms < 19.5 || got.P50Ms > 20.5 {
+		t.Errorf("summary avg %v p50 %v, want 20 and about 20", got.AvgMs, got.P50Ms)
+	}
+
+	all, err := store.GetRollups(ctx, domain.RollupMinute, "", base, base.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetRollups(all): %v", err)
+	}
+	if len(all) != 2 || all[0].NodeID != "node-a" || all[1].NodeID != "node-b" {
+		t.Errorf("GetRollups(all) = %+v, want node-a and node-b at base (until is exclusive)", all)
+	}
+
+	// Saving a bucket again replaces it
+	if err := store.SaveRollups(ctx, domain.RollupMinute, []domain.PollRollup{rollup("node-b", base, 7, 9)}, base.Add(2*time.Minute)); err != nil {
+		t.Fatalf("SaveRollups (replace): %v", err)
+	}
+	nodeB, err := store.GetRollups(ctx, domain.RollupMinute, "node-b", base, base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetRollups(node-b): %v", err)
+	}
+	if len(nodeB) != 1 || nodeB[0].Successes != 2 {
+		t.Errorf("GetRollups(node-b) after replace = %+v", nodeB)
+	}
+
+	hours, err := store.GetRollups(ctx, domain.RollupHour, "", base.Add(-time.Hour), base.Add(time.Hour))
+	if err != nil || len(hours) != 0 {
+		t.Errorf("hour rollups = %+v, %v; want none", hours, err)
+	}
+
+	deleted, err := store.DeleteRollupsBefore(ctx, domain.RollupMinute, base.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("DeleteRollupsBefore: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("DeleteRollupsBefore deleted %d, want 2", deleted)
+	}
+}
+
+func testRollupWatermark(t *testing.T, store domain.Store) {
+	ctx := context.Background()
+
+	watermark, err := store.GetRollupWatermark(ctx, domain.RollupHour)
+	if err != nil {
+		t.Fatalf("GetRollupWatermark: %v", err)
+	}
+	if !watermark.IsZero() {
+		t.Errorf("initial watermark = %v, want zero", watermark)
+	}
+
+	if err := store.SaveRollups(ctx, domain.RollupHour, nil, base); err != nil {
+		t.Fatalf("SaveRollups: %v", err)
+	}
+	if err := store.SaveRollups(ctx, domain.RollupMinute, nil, base.Add(time.Hour)); err != nil {
+		t.Fatalf("SaveRollups: %v", err)
+	}
+
+	watermark, err = store.GetRollupWatermark(ctx, domain.RollupHour)
+	if err != nil {
+		t.Fatalf("GetRollupWatermark: %v", err)
+	}
+	if !watermark.Equal(base) {
+		t.Errorf("watermark = %v, want %v", watermark, base)
+	}
+}
+
+func testUnknownResolution(t *testing.T, store domain.Store) {
+	_, err := store.GetRollups(context.Background(), "5m", "", base, base.Add(time.Hour))
+	if !errors.Is(err, domain.ErrInvalidRange) {
+		t.Errorf("GetRollups(5m) error = %v, want ErrInvalidRange", err)
+	}
+}
+
+func testAlerts(t *testing.T, store domain.Store) {
+	ctx := context.Background()
+
+	older := domain.Alert{Rule: "down", NodeID: "node-a", Severity: "critical", State: domain.AlertStateFiring,
+		Message: "node-a is down", StartedAt: base, FiredAt: base.Add(time.Minute)}
+	newer := domain.Alert{Rule: "slow", NodeID: "node-b", Severity: "warning", State: domain.AlertStateFiring,
+		Value: 250, StartedAt: base, FiredAt: base.Add(time.Hour)}
+	for _, alert := range []*domain.Alert{&older, &newer} {
+		if err := store.SaveAlert(ctx, alert); err != nil {
+			t.Fatalf("SaveAlert: %v", err)
+		}
+	}
+	if older.ID == 0 || newer.ID == 0 || older.ID == newer.ID {
+		t.Fatalf("SaveAlert IDs = %d, %d; want distinct nonzero IDs", older.ID, newer.ID)
+	}
+
+	firing, err := store.GetFiringAlerts(ctx)
+	if err != nil {
+		t.Fatalf("GetFiringAlerts: %v", err)
+	}
+	if len(firing) != 2 || firing[0].ID != older.ID || firing[1].Value != 250 {
+		t.Errorf("GetFiringAlerts = %+v, want both alerts oldest first", firing)
+	}
+
+	resolvedAt := base.Add(2 * time.Hour)
+	older.State = domain.AlertStateResolved
+	older.ResolvedAt = &resolvedAt
+	if err := store.SaveAlert(ctx, &older); err != nil {
+		t.Fatalf("SaveAlert update: %v", err)
+	}
+
+	firing, err = store.GetFiringAlerts(ctx)
+	if err != nil {
+		t.Fatalf("GetFiringAlerts: %v", err)
+	}
+	if len(firing) != 1 || firing[0].ID != newer.ID || firing[0].ResolvedAt != nil {
+		t.Errorf("GetFiringAlerts after resolving = %+v, want only the newer alert", firing)
+	}
+
+	history, err := store.GetAlertHistory(ctx, base, 10)
+	if err != nil {
+		t.Fatalf("GetAlertHistory: %v", err)
+	}
+	if len(history) != 2 || history[0].ID != newer.ID {
+		t.Fatalf("GetAlertHistory = %+v, want both alerts newest first", history)
+	}
+	if got := history[1]; got.State != domain.AlertStateResolved || got.ResolvedAt == nil || !got.ResolvedAt.Equal(resolvedAt) ||
+		got.Message != "node-a is down" || !got.FiredAt.Equal(older.FiredAt) {
+		t.Errorf("resolved alert = %+v", got)
+	}
+
+	if history, _ := store.GetAlertHistory(ctx, base.Add(30*time.Minute), 10); len(history) != 1 {
+		t.Errorf("GetAlertHistory since 30m = %d alerts, want 1", len(history))
+	}
+	if history, _ := store.GetAlertHistory(ctx, base, 1); len(history) != 1 || history[0].ID != newer.ID {
+		t.Errorf("GetAlertHistory limit 1 = %+v, want the newer alert", history)
+	}
+
+	missing := domain.Alert{ID: 999, State: domain.AlertStateResolved}
+	if err := store.SaveAlert(ctx, &missing); err == nil {
+		t.Error("SaveAlert of an unknown ID succeeded, want an error")
+	}
+}
+
+func testSilences(t *testing.T, store domain.Store) {
+	ctx := context.Background()
+
+	later := domain.Silence{Rule: "down", StartsAt: base.Add(time.Hour), EndsAt: base.Add(3 * time.Hour),
+		CreatedBy: "ops", CreatedAt: base, Comment: "switch upgrade"}
+	earlier := domain.Silence{NodeID: "node-a", Labels: map[string]string{"site": "ams"},
+		StartsAt: base, EndsAt: base.Add(2 * time.Hour), CreatedBy: "ops", CreatedAt: base}
+	expired := domain.Silence{NodeID: "node-b", StartsAt: base.Add(-2 * time.Hour), EndsAt: base, CreatedAt: base}
+	for _, silence := range []*domain.Silence{&later, &earlier, &expired} {
+		if err := store.CreateSilence(ctx, silence); err != nil {
+			t.Fatalf("CreateSilence: %v", err)
+		}
+	}
+	if later.ID == 0 || earlier.ID == 0 || later.ID == earlier.ID {
+		t.Fatalf("CreateSilence IDs = %d, %d; want distinct nonzero IDs", later.ID, earlier.ID)
+	}
+
+	silences, err := store.GetSilences(ctx, base)
+	if err != nil {
+		t.Fatalf("GetSilences: %v", err)
+	}
+	if len(silences) != 2 || silences[0].ID != earlier.ID || silences[1].ID != later.ID {
+		t.Fatalf("GetSilences = %+v, want the two unexpired silences by start time", silences)
+	}
+	if got := silences[0]; got.Labels["site"] != "ams" || got.NodeID != "node-a" || !got.EndsAt.Equal(earlier.EndsAt) {
+		t.Errorf("stored silence = %+v", got)
+	}
+	if got := silences[1]; got.Rule != "down" || got.Comment != "switch upgrade" || got.CreatedBy != "ops" || got.Labels != nil {
+		t.Errorf("stored silence = %+v", got)
+	}
+
+	if err := store.DeleteSilence(ctx, earlier.ID); err != nil {
+		t.Fatalf("DeleteSilence: %v", err)
+	}
+	if silences, _ := store.GetSilences(ctx, base); len(silences) != 1 || silences[0].ID != later.ID {
+		t.Errorf("GetSilences after delete = %+v, want only the later silence", silences)
+	}
+	if err := store.DeleteSilence(ctx, earlier.ID); !errors.Is(err, domain.ErrSilenceNotFound) {
+		t.Errorf("DeleteSilence of a deleted silence error = %v, want ErrSilenceNotFound", err)
+	}
+}
+
+func testDatabaseSize(t *testing.T, store domain.Store) {
+	mustCreateNode(t, store, newNode("node-a", base))
+
+	size, err := store.GetDatabaseSize(context.Background())
+	if err != nil {
+		t.Fatalf("GetDatabaseSize: %v", err)
+	}
+	if size <= 0 {
+		t.Errorf("GetDatabaseSize = %d, want a positive size", size)
+	}
+}
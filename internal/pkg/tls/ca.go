@@ -0,0 +1,255 @@
+package tls
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+// nodeURIPrefix identifies node certificates: each carries a URI SAN of the
+// form urn:nodeprobe:node:<node UUID>
+const nodeURIPrefix = "urn:nodeprobe:node:"
+
+const caValidity = 10 * 365 * 24 * time.Hour
+
+// CertOptions controls the key type and lifetime of an issued certificate
+type CertOptions struct {
+	KeyType  string        // defaults to ECDSA P-256
+	Validity time.Duration // defaults to one year, capped at the CA's expiry
+}
+
+// CA is a cluster certificate authority used to sign node certificates
+type CA struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// InitCA creates a new cluster CA in dir as ca.crt and ca.key. It refuses to
+// overwrite an existing CA so node certificates are not orphaned by accident.
+func InitCA(dir string) (string, string, error) {
+	certPath := filepath.Join(dir, "ca.crt")
+	keyPath := filepath.Join(dir, "ca.key")
+
+	if _, err := os.Stat(certPath); err == nil {
+		return "", "", fmt.Errorf("CA certificate %s already exists", certPath)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create CA directory: %w", err)
+	}
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate CA key: %w", err)
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return "", "", err
+	}
+
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization:       []string{"NodeProbe"},
+			OrganizationalUnit: []string{"Cluster CA"},
+			CommonName:         "NodeProbe Cluster CA",
+		},
+		NotBefore:             time.Now().Add(-5 * time.Minute),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+
+	if err := writeCertAndKey(certPath, keyPath, certDER, privateKey); err != nil {
+		return "", "", err
+	}
+
+	return certPath, keyPath, nil
+}
+
+// LoadCA reads a CA certificate and private key from PEM files
+func LoadCA(certPath, keyPath string) (*CA, error) {
+	cert, err := loadCertificate(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CA certificate: %w", err)
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("%s is not a CA certificate", certPath)
+	}
+
+	key, err := loadPrivateKey(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CA key: %w", err)
+	}
+
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// IssueNodeCert signs a certificate for nodeID, usable for both server and
+// client authentication, and writes it with its key. A key already at
+// keyPath is kept if it has the requested type, so renewals keep the
+// fingerprint peers have pinned.
+func (ca *CA) IssueNodeCert(nodeID string, dnsNames []string, ips []net.IP, opts CertOptions, certPath, keyPath string) error {
+	if nodeID == "" {
+		return fmt.Errorf("node ID is required")
+	}
+
+	keyType := opts.KeyType
+	if keyType == "" {
+		keyType = domain.KeyTypeECDSAP256
+	}
+	privateKey, err := renewalKey(keyPath, keyType)
+	if err != nil {
+		return fmt.Errorf("failed to generate node key: %w", err)
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return err
+	}
+
+	validity := opts.Validity
+	if validity <= 0 {
+		validity = domain.CertificateValidity
+	}
+	notAfter := time.Now().Add(validity)
+	if notAfter.After(ca.Cert.NotAfter) {
+		notAfter = ca.Cert.NotAfter
+	}
+
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization:       []string{"NodeProbe"},
+			OrganizationalUnit: []string{"Distributed Network"},
+			CommonName:         nodeID,
+		},
+		NotBefore:             time.Now().Add(-5 * time.Minute),
+		NotAfter:              notAfter,
+		KeyUsage:              keyUsage(privateKey),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              dnsNames,
+		IPAddresses:           ips,
+		URIs:                  []*url.URL{NodeURI(nodeID)},
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, ca.Cert, privateKey.Public(), ca.Key)
+	if err != nil {
+		return fmt.Errorf("failed to sign node certificate: %w", err)
+	}
+
+	return writeCertAndKey(certPath, keyPath, certDER, privateKey)
+}
+
+// NodeURI returns the URI SAN that identifies a node certificate
+func NodeURI(nodeID string) *url.URL {
+	return &url.URL{Scheme: "urn", Opaque: "nodeprobe:node:" + nodeID}
+}
+
+// NodeIDFromCert extracts the node UUID from a node certificate's URI SAN
+func NodeIDFromCert(cert *x509.Certificate) string {
+	for _, uri := range cert.URIs {
+		if s := uri.String(); strings.HasPrefix(s, nodeURIPrefix) {
+			return strings.TrimPrefix(s, nodeURIPrefix)
+		}
+	}
+	return ""
+}
+
+// loadCertificate reads the first certificate from a PEM file
+func loadCertificate(path string) (*x509.Certificate, error) {
+	certPEM, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode certificate PEM")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// loadCertPool reads a PEM bundle into a certificate pool
+func loadCertPool(path string) (*x509.CertPool, error) {
+	caPEM, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+
+	return pool, nil
+}
+
+// randomSerial returns a random 128-bit certificate serial number
+func randomSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serial, nil
+}
+
+// writeCertAndKey writes a DER certificate and its private key as PEM files,
+// with the key readable only by the owner
+func writeCertAndKey(certPath, keyPath string, certDER []byte, privateKey crypto.PrivateKey) error {
+	certOut, err := os.Create(certPath)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate file: %w", err)
+	}
+	defer certOut.Close()
+
+	if err := pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: certDER}); err != nil {
+		return fmt.Errorf("failed to encode certificate: %w", err)
+	}
+
+	keyOut, err := os.OpenFile(keyPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	defer keyOut.Close()
+
+	// Set restrictive permissions on private key, even if the file already existed
+	if err := keyOut.Chmod(0600); err != nil {
+		return fmt.Errorf("failed to set key file permissions: %w", err)
+	}
+
+	privateKeyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	if err := pem.Encode(keyOut, &pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyDER}); err != nil {
+		return fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	return nil
+}
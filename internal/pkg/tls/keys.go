@@ -0,0 +1,92 @@
+package tls
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"nodeprobe/internal/domain"
+)
+
+// generateKey creates a private key of the given type, RSA-2048 by default
+func generateKey(keyType string) (crypto.Signer, error) {
+	switch keyType {
+	case "", domain.KeyTypeRSA:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case domain.KeyTypeECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case domain.KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", keyType)
+	}
+}
+
+// renewalKey returns the private key already at keyPath if it is of keyType,
+// and otherwise generates a new one
+func renewalKey(keyPath string, keyType string) (crypto.Signer, error) {
+	if key, err := loadPrivateKey(keyPath); err == nil && publicKeyType(key.Public()) == keyType {
+		return key, nil
+	}
+	return generateKey(keyType)
+}
+
+// loadPrivateKey reads a PKCS #8 private key from a PEM file
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	keyPEM, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode key PEM")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("key does not support signing")
+	}
+	return signer, nil
+}
+
+// keyUsage returns the key usage bits appropriate for a leaf certificate's
+// key: only RSA keys are used for key encipherment
+func keyUsage(key crypto.Signer) x509.KeyUsage {
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		return x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature
+	}
+	return x509.KeyUsageDigitalSignature
+}
+
+// keyTypeOf returns the key type of a certificate's public key, or an
+// empty string for types nodeprobe does not generate
+func keyTypeOf(cert *x509.Certificate) string {
+	return publicKeyType(cert.PublicKey)
+}
+
+// publicKeyType returns the key type of a public key, or an empty string
+// for types nodeprobe does not generate
+func publicKeyType(publicKey crypto.PublicKey) string {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return domain.KeyTypeRSA
+	case *ecdsa.PublicKey:
+		if key.Curve == elliptic.P256() {
+			return domain.KeyTypeECDSAP256
+		}
+	case ed25519.PublicKey:
+		return domain.KeyTypeEd25519
+	}
+	return ""
+}
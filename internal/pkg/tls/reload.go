@@ -0,0 +1,77 @@
+package tls
+
+import (
+	cryptotls "crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// GetCertificate returns the server certificate for a handshake, reloading
+// it from disk whenever the certificate or key file has changed. If a
+// changed pair cannot be loaded (for example while only one of the files
+// has been replaced) the previous certificate keeps being served.
+func (s *Service) GetCertificate(*cryptotls.ClientHelloInfo) (*cryptotls.Certificate, error) {
+	return s.currentCertificate()
+}
+
+// CertificateExpiry returns when the certificate currently served expires
+func (s *Service) CertificateExpiry() (time.Time, error) {
+	cert, err := s.currentCertificate()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.Leaf.NotAfter, nil
+}
+
+func (s *Service) currentCertificate() (*cryptotls.Certificate, error) {
+	certInfo, certErr := os.Stat(s.certPath)
+	keyInfo, keyErr := os.Stat(s.keyPath)
+
+	s.certMu.Lock()
+	defer s.certMu.Unlock()
+
+	if certErr == nil && keyErr == nil &&
+		(s.cert == nil || !certInfo.ModTime().Equal(s.certModTime) || !keyInfo.ModTime().Equal(s.keyModTime)) {
+		cert, err := loadKeyPair(s.certPath, s.keyPath)
+		switch {
+		case err == nil:
+			if s.cert != nil {
+				log.Printf("Reloaded TLS certificate from %s, valid until %s",
+					s.certPath, cert.Leaf.NotAfter.UTC().Format(time.RFC3339))
+			}
+			s.cert = cert
+			s.certModTime = certInfo.ModTime()
+			s.keyModTime = keyInfo.ModTime()
+		case s.cert == nil:
+			return nil, err
+		default:
+			log.Printf("Failed to reload TLS certificate, keeping the current one: %v", err)
+		}
+	}
+
+	if s.cert == nil {
+		return nil, fmt.Errorf("no TLS certificate available at %s", s.certPath)
+	}
+	return s.cert, nil
+}
+
+// loadKeyPair loads a certificate and key and parses the leaf certificate
+func loadKeyPair(certPath, keyPath string) (*cryptotls.Certificate, error) {
+	cert, err := cryptotls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	if cert.Leaf == nil {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		cert.Leaf = leaf
+	}
+
+	return &cert, nil
+}
@@ -2,30 +2,114 @@ package tls
 
 import (
 	"crypto/rand"
-	"crypto/rsa"
+	"crypto/sha256"
+	cryptotls "crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
-	"encoding/pem"
+	"encoding/hex"
 	"fmt"
-	"math/big"
+	"log"
 	"net"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
+
+	"nodeprobe/internal/domain"
 )
 
+// renewBefore is how long before expiry a certificate is replaced; short-lived
+// certificates are replaced once a third of their lifetime remains instead
+const renewBefore = 30 * 24 * time.Hour
+
+// renewalDue reports whether cert is within its renewal window at now
+func renewalDue(cert *x509.Certificate, now time.Time) bool {
+	window := min(renewBefore, cert.NotAfter.Sub(cert.NotBefore)/3)
+	return !cert.NotAfter.After(now.Add(window))
+}
+
 type Service struct {
 	certDir  string
 	certPath string
 	keyPath  string
+	nodeID   string
+	config   domain.TLSConfig
+
+	// The certificate being served, reloaded when the files change
+	certMu      sync.Mutex
+	cert        *cryptotls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
 }
 
-func NewService(certDir string) *Service {
-	return &Service{
+func NewService(certDir string, nodeID string, config *domain.TLSConfig) *Service {
+	s := &Service{
 		certDir:  certDir,
 		certPath: filepath.Join(certDir, "server.crt"),
 		keyPath:  filepath.Join(certDir, "server.key"),
+		nodeID:   nodeID,
+	}
+
+	if config != nil {
+		s.config = *config
+	}
+	if s.config.CACert == "" {
+		s.config.CACert = filepath.Join(certDir, "ca.crt")
+	}
+	if s.config.CAKey == "" {
+		s.config.CAKey = filepath.Join(certDir, "ca.key")
 	}
+	if s.config.CertFile != "" {
+		s.certPath = s.config.CertFile
+	}
+	if s.config.KeyFile != "" {
+		s.keyPath = s.config.KeyFile
+	}
+
+	return s
+}
+
+// EnsureCertificate makes sure a usable server certificate exists: a
+// self-signed one by default, one signed by the cluster CA with mutual TLS,
+// or the operator's own certificate when cert_file is configured
+func (s *Service) EnsureCertificate() error {
+	if s.config.CertFile != "" {
+		return s.validateProvidedCertificate()
+	}
+
+	if !s.config.MutualTLS {
+		return s.GenerateSelfSignedCert()
+	}
+
+	if err := os.MkdirAll(s.certDir, 0755); err != nil {
+		return fmt.Errorf("failed to create certificate directory: %w", err)
+	}
+
+	pool, err := loadCertPool(s.config.CACert)
+	if err != nil {
+		return fmt.Errorf("mutual TLS requires the cluster CA certificate (create one with 'nodeprobe ca init'): %w", err)
+	}
+
+	if s.certificateExists() && s.certificateValid() && s.certificateIssuedBy(pool) {
+		return nil
+	}
+
+	// Without the CA key the operator must issue the certificate with 'nodeprobe ca sign'
+	ca, err := LoadCA(s.config.CACert, s.config.CAKey)
+	if err != nil {
+		return fmt.Errorf("node certificate is missing, expiring or not issued by the cluster CA, and no CA key is available to issue one: %w", err)
+	}
+
+	dnsNames, ips, err := s.certificateAddresses()
+	if err != nil {
+		return err
+	}
+
+	if err := ca.IssueNodeCert(s.nodeID, dnsNames, ips, s.certOptions(), s.certPath, s.keyPath); err != nil {
+		return fmt.Errorf("failed to issue node certificate: %w", err)
+	}
+
+	return nil
 }
 
 func (s *Service) GenerateSelfSignedCert() error {
@@ -39,73 +123,115 @@ func (s *Service) GenerateSelfSignedCert() error {
 		return nil // Certificate already exists and is valid
 	}
 
-	// Generate private key
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	// Keep the existing key when renewing so pinned fingerprints still match
+	keyType := s.config.KeyType
+	if keyType == "" {
+		keyType = domain.KeyTypeRSA
+	}
+	privateKey, err := renewalKey(s.keyPath, keyType)
 	if err != nil {
 		return fmt.Errorf("failed to generate private key: %w", err)
 	}
 
+	serial, err := randomSerial()
+	if err != nil {
+		return err
+	}
+
+	// Add local network addresses and configured extra names to the certificate
+	dnsNames, ips, err := s.certificateAddresses()
+	if err != nil {
+		return err
+	}
+
 	// Create certificate template
 	template := x509.Certificate{
-		SerialNumber: big.NewInt(1),
+		SerialNumber: serial,
 		Subject: pkix.Name{
 			Organization:       []string{"NodeProbe"},
 			OrganizationalUnit: []string{"Distributed Network"},
-			Country:            []string{"US"},
-			Province:           []string{""},
-			Locality:           []string{""},
+			CommonName:         s.nodeID,
 		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().Add(365 * 24 * time.Hour), // Valid for 1 year
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		NotBefore:             time.Now().Add(-5 * time.Minute),
+		NotAfter:              time.Now().Add(s.certOptions().Validity),
+		KeyUsage:              keyUsage(privateKey),
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
-	}
-
-	// Add local network addresses to certificate
-	if err := s.addNetworkAddresses(&template); err != nil {
-		return fmt.Errorf("failed to add network addresses: %w", err)
+		DNSNames:              dnsNames,
+		IPAddresses:           ips,
 	}
 
 	// Create certificate
-	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, privateKey.Public(), privateKey)
 	if err != nil {
 		return fmt.Errorf("failed to create certificate: %w", err)
 	}
 
-	// Save certificate to file
-	certOut, err := os.Create(s.certPath)
+	// Save certificate and private key to files
+	return writeCertAndKey(s.certPath, s.keyPath, certDER, privateKey)
+}
+
+// validateProvidedCertificate checks an operator-provided certificate at
+// startup and on each renewal check; it is never overwritten
+func (s *Service) validateProvidedCertificate() error {
+	if s.config.KeyFile == "" {
+		return fmt.Errorf("key_file is required with cert_file")
+	}
+
+	pair, err := loadKeyPair(s.certPath, s.keyPath)
 	if err != nil {
-		return fmt.Errorf("failed to create certificate file: %w", err)
+		return fmt.Errorf("certificate %s and key %s are not a usable pair: %w", s.certPath, s.keyPath, err)
 	}
-	defer certOut.Close()
 
-	if err := pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: certDER}); err != nil {
-		return fmt.Errorf("failed to encode certificate: %w", err)
+	cert := pair.Leaf
+	if time.Now().After(cert.NotAfter) {
+		return fmt.Errorf("certificate %s expired on %s", s.certPath, cert.NotAfter.UTC().Format(time.RFC3339))
+	}
+	if renewalDue(cert, time.Now()) {
+		log.Printf("WARNING: certificate %s expires on %s and must be replaced by the operator",
+			s.certPath, cert.NotAfter.UTC().Format(time.RFC3339))
 	}
 
-	// Save private key to file
-	keyOut, err := os.Create(s.keyPath)
-	if err != nil {
-		return fmt.Errorf("failed to create key file: %w", err)
+	if s.config.MutualTLS {
+		pool, err := loadCertPool(s.config.CACert)
+		if err != nil {
+			return fmt.Errorf("mutual TLS requires the cluster CA certificate: %w", err)
+		}
+		if !s.certificateIssuedBy(pool) {
+			return fmt.Errorf("certificate %s is not a node certificate for %s issued by the cluster CA", s.certPath, s.nodeID)
+		}
 	}
-	defer keyOut.Close()
 
-	// Set restrictive permissions on private key
-	if err := keyOut.Chmod(0600); err != nil {
-		return fmt.Errorf("failed to set key file permissions: %w", err)
+	return nil
+}
+
+// certOptions returns the key type and validity configured for new certificates
+func (s *Service) certOptions() CertOptions {
+	validity := domain.CertificateValidity
+	if s.config.ValidityDays > 0 {
+		validity = time.Duration(s.config.ValidityDays) * 24 * time.Hour
 	}
+	return CertOptions{KeyType: s.config.KeyType, Validity: validity}
+}
 
-	privateKeyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+// certificateAddresses returns the SANs for a generated certificate: the
+// local addresses plus any configured extra names
+func (s *Service) certificateAddresses() ([]string, []net.IP, error) {
+	dnsNames, ips, err := localAddresses()
 	if err != nil {
-		return fmt.Errorf("failed to marshal private key: %w", err)
+		return nil, nil, fmt.Errorf("failed to get network addresses: %w", err)
 	}
 
-	if err := pem.Encode(keyOut, &pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyDER}); err != nil {
-		return fmt.Errorf("failed to encode private key: %w", err)
+	dnsNames = append(dnsNames, s.config.ExtraDNSNames...)
+	for _, value := range s.config.ExtraIPs {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("invalid extra IP address %q", value)
+		}
+		ips = append(ips, ip)
 	}
 
-	return nil
+	return dnsNames, ips, nil
 }
 
 func (s *Service) GetCertPath() (string, string, error) {
@@ -123,44 +249,71 @@ func (s *Service) certificateExists() bool {
 	return certErr == nil && keyErr == nil
 }
 
+// certificateValid reports whether the existing certificate can be kept: the
+// key matches, it is not yet due for renewal, and it has the configured key
+// type and extra SANs
 func (s *Service) certificateValid() bool {
-	// Load certificate
-	certPEM, err := os.ReadFile(s.certPath)
+	pair, err := loadKeyPair(s.certPath, s.keyPath)
 	if err != nil {
+		log.Printf("Existing certificate will be replaced: %v", err)
+		return false
+	}
+	cert := pair.Leaf
+
+	// Check if certificate is still valid and not yet due for renewal
+	if renewalDue(cert, time.Now()) {
 		return false
 	}
 
-	block, _ := pem.Decode(certPEM)
-	if block == nil {
+	if s.config.KeyType != "" && keyTypeOf(cert) != s.config.KeyType {
+		log.Printf("Existing certificate will be replaced: key type is not %s", s.config.KeyType)
 		return false
 	}
 
-	cert, err := x509.ParseCertificate(block.Bytes)
+	for _, name := range append(append([]string{}, s.config.ExtraDNSNames...), s.config.ExtraIPs...) {
+		if err := cert.VerifyHostname(name); err != nil {
+			log.Printf("Existing certificate will be replaced: %s is not among its names", name)
+			return false
+		}
+	}
+
+	return true
+}
+
+// certificateIssuedBy reports whether the node certificate chains to the
+// cluster CA, permits client authentication and carries this node's identity
+func (s *Service) certificateIssuedBy(pool *x509.CertPool) bool {
+	cert, err := loadCertificate(s.certPath)
 	if err != nil {
 		return false
 	}
 
-	// Check if certificate is still valid (not expired and valid for at least 30 days)
-	now := time.Now()
-	return cert.NotAfter.After(now.Add(30 * 24 * time.Hour))
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:     pool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return false
+	}
+
+	return NodeIDFromCert(cert) == s.nodeID
 }
 
-func (s *Service) addNetworkAddresses(template *x509.Certificate) error {
+// localAddresses returns the hostnames and IP addresses this node is reachable on
+func localAddresses() ([]string, []net.IP, error) {
 	// Add localhost addresses
-	template.IPAddresses = append(template.IPAddresses, net.IPv4(127, 0, 0, 1))
-	template.IPAddresses = append(template.IPAddresses, net.IPv6loopback)
-	template.DNSNames = append(template.DNSNames, "localhost")
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	dnsNames := []string{"localhost"}
 
 	// Get hostname
 	hostname, err := os.Hostname()
 	if err == nil && hostname != "" {
-		template.DNSNames = append(template.DNSNames, hostname)
+		dnsNames = append(dnsNames, hostname)
 	}
 
 	// Get local network interfaces
 	interfaces, err := net.Interfaces()
 	if err != nil {
-		return fmt.Errorf("failed to get network interfaces: %w", err)
+		return nil, nil, fmt.Errorf("failed to get network interfaces: %w", err)
 	}
 
 	for _, iface := range interfaces {
@@ -179,25 +332,158 @@ func (s *Service) addNetworkAddresses(template *x509.Certificate) error {
 			case *net.IPNet:
 				// Only add non-loopback addresses
 				if !v.IP.IsLoopback() {
-					template.IPAddresses = append(template.IPAddresses, v.IP)
+					ips = append(ips, v.IP)
 				}
 			case *net.IPAddr:
 				if !v.IP.IsLoopback() {
-					template.IPAddresses = append(template.IPAddresses, v.IP)
+					ips = append(ips, v.IP)
 				}
 			}
 		}
 	}
 
-	return nil
+	return dnsNames, ips, nil
+}
+
+// MutualTLSEnabled reports whether nodes authenticate each other with the cluster CA
+func (s *Service) MutualTLSEnabled() bool {
+	return s.config.MutualTLS
+}
+
+// ServerTLSConfig returns the TLS configuration for the HTTPS server. With
+// mutual TLS, client certificates are verified against the cluster CA when
+// presented; inter-node endpoints then require one via VerifyPeer.
+func (s *Service) ServerTLSConfig() (*cryptotls.Config, error) {
+	config := &cryptotls.Config{
+		MinVersion:     cryptotls.VersionTLS12,
+		GetCertificate: s.GetCertificate,
+	}
+
+	if !s.config.MutualTLS {
+		return config, nil
+	}
+
+	pool, err := loadCertPool(s.config.CACert)
+	if err != nil {
+		return nil, err
+	}
+
+	config.ClientCAs = pool
+	config.ClientAuth = cryptotls.VerifyClientCertIfGiven
+	return config, nil
+}
+
+// ClientTLSConfig returns the TLS configuration for requests to other
+// nodes. When verify is not nil it is passed the peer's identity once the
+// certificate has been checked, before any request is sent.
+func (s *Service) ClientTLSConfig(verify domain.PeerVerifier) (*cryptotls.Config, error) {
+	if !s.config.MutualTLS {
+		return &cryptotls.Config{
+			InsecureSkipVerify: true, // Accept self-signed certificates
+			VerifyConnection: func(state cryptotls.ConnectionState) error {
+				return verifyIdentity(state.PeerCertificates, "", verify)
+			},
+		}, nil
+	}
+
+	pool, err := loadCertPool(s.config.CACert)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cryptotls.Config{
+		MinVersion: cryptotls.VersionTLS12,
+		// Peers are addressed by FQDN or IP interchangeably, so they are
+		// authenticated by their node identity in VerifyConnection rather
+		// than by hostname
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state cryptotls.ConnectionState) error {
+			nodeID, err := verifyNodeCertificate(state.PeerCertificates, pool, x509.ExtKeyUsageServerAuth)
+			if err != nil {
+				return err
+			}
+			return verifyIdentity(state.PeerCertificates, nodeID, verify)
+		},
+		// Checked per handshake so a reissued certificate is picked up
+		GetClientCertificate: func(*cryptotls.CertificateRequestInfo) (*cryptotls.Certificate, error) {
+			return s.currentCertificate()
+		},
+	}, nil
+}
+
+// verifyIdentity passes the identity of a peer's leaf certificate, issued
+// to nodeID when it was verified against the cluster CA, to verify
+func verifyIdentity(chain []*x509.Certificate, nodeID string, verify domain.PeerVerifier) error {
+	if verify == nil {
+		return nil
+	}
+	if len(chain) == 0 {
+		return fmt.Errorf("peer presented no certificate")
+	}
+	certSum := sha256.Sum256(chain[0].Raw)
+	return verify(domain.PeerIdentity{
+		Fingerprint:            Fingerprint(chain[0]),
+		CertificateFingerprint: hex.EncodeToString(certSum[:]),
+		NodeID:                 nodeID,
+	})
+}
+
+// Fingerprint returns the hex SHA-256 of a certificate's public key
+// (SubjectPublicKeyInfo), which peers pin
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyPeer returns the node ID of a client whose certificate was verified
+// against the cluster CA during the handshake
+func (s *Service) VerifyPeer(state *cryptotls.ConnectionState) (string, error) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", fmt.Errorf("no verified client certificate")
+	}
+
+	nodeID := NodeIDFromCert(state.VerifiedChains[0][0])
+	if nodeID == "" {
+		return "", fmt.Errorf("client certificate has no node identity")
+	}
+
+	return nodeID, nil
+}
+
+// verifyNodeCertificate checks a presented chain against the cluster CA and
+// returns the node ID from the leaf certificate
+func verifyNodeCertificate(chain []*x509.Certificate, pool *x509.CertPool, usage x509.ExtKeyUsage) (string, error) {
+	if len(chain) == 0 {
+		return "", fmt.Errorf("peer presented no certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	if _, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}); err != nil {
+		return "", fmt.Errorf("peer certificate not issued by the cluster CA: %w", err)
+	}
+
+	nodeID := NodeIDFromCert(chain[0])
+	if nodeID == "" {
+		return "", fmt.Errorf("peer certificate has no node identity")
+	}
+
+	return nodeID, nil
 }
 
-// RenewCertificate forces renewal of the certificate
+// RenewCertificate forces renewal of the certificate with a new key
 func (s *Service) RenewCertificate() error {
 	// Remove existing certificate files
 	os.Remove(s.certPath)
 	os.Remove(s.keyPath)
 
 	// Generate new certificate
-	return s.GenerateSelfSignedCert()
+	return s.EnsureCertificate()
 }
@@ -0,0 +1,278 @@
+package tls
+
+import (
+	"bytes"
+	cryptotls "crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nodeprobe/internal/domain"
+)
+
+func TestShortLivedCertificateNotReissued(t *testing.T) {
+	dir := t.TempDir()
+	s := NewService(dir, "node-1", &domain.TLSConfig{KeyType: domain.KeyTypeECDSAP256, ValidityDays: 7})
+
+	if err := s.EnsureCertificate(); err != nil {
+		t.Fatalf("EnsureCertificate: %v", err)
+	}
+	issued, err := os.ReadFile(s.certPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	// The hourly renewal check keeps a fresh certificate
+	if err := s.EnsureCertificate(); err != nil {
+		t.Fatalf("EnsureCertificate: %v", err)
+	}
+	current, err := os.ReadFile(s.certPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(issued, current) {
+		t.Error("fresh 7-day certificate was reissued")
+	}
+}
+
+func TestRenewalDue(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		lifetime time.Duration
+		age      time.Duration
+		want     bool
+	}{
+		{"fresh yearly", 365 * 24 * time.Hour, 0, false},
+		{"yearly within 30 days", 365 * 24 * time.Hour, 336 * 24 * time.Hour, true},
+		{"fresh weekly", 7 * 24 * time.Hour, 0, false},
+		{"weekly half used", 7 * 24 * time.Hour, 84 * time.Hour, false},
+		{"weekly within a third", 7 * 24 * time.Hour, 5 * 24 * time.Hour, true},
+	}
+	for _, tt := range tests {
+		cert := &x509.Certificate{NotBefore: now.Add(-tt.age), NotAfter: now.Add(tt.lifetime - tt.age)}
+		if got := renewalDue(cert, now); got != tt.want {
+			t.Errorf("%s: renewalDue = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenewalKeepsKey(t *testing.T) {
+	dir := t.TempDir()
+	config := &domain.TLSConfig{KeyType: domain.KeyTypeECDSAP256}
+	s := NewService(dir, "node-1", config)
+	if err := s.EnsureCertificate(); err != nil {
+		t.Fatalf("EnsureCertificate: %v", err)
+	}
+	first, err := loadCertificate(s.certPath)
+	if err != nil {
+		t.Fatalf("loadCertificate: %v", err)
+	}
+
+	// A new name forces a new certificate, which keeps the pinned key
+	config.ExtraDNSNames = []string{"probe.example.com"}
+	s = NewService(dir, "node-1", config)
+	if err := s.EnsureCertificate(); err != nil {
+		t.Fatalf("EnsureCertificate: %v", err)
+	}
+	renewed, err := loadCertificate(s.certPath)
+	if err != nil {
+		t.Fatalf("loadCertificate: %v", err)
+	}
+	if renewed.SerialNumber.Cmp(first.SerialNumber) == 0 {
+		t.Fatal("certificate was not reissued for a new name")
+	}
+	if Fingerprint(renewed) != Fingerprint(first) {
+		t.Error("renewed certificate has a new fingerprint")
+	}
+
+	// A different key type needs a new key
+	config.KeyType = domain.KeyTypeEd25519
+	s = NewService(dir, "node-1", config)
+	if err := s.EnsureCertificate(); err != nil {
+		t.Fatalf("EnsureCertificate: %v", err)
+	}
+	rekeyed, err := loadCertificate(s.certPath)
+	if err != nil {
+		t.Fatalf("loadCertificate: %v", err)
+	}
+	if Fingerprint(rekeyed) == Fingerprint(first) {
+		t.Error("certificate with a new key type kept the old fingerprint")
+	}
+}
+
+// newClusterNode returns the TLS service of a node with a certificate
+// issued by the cluster CA in caDir
+func newClusterNode(t *testing.T, caDir string, nodeID string) *Service {
+	t.Helper()
+
+	s := NewService(t.TempDir(), nodeID, &domain.TLSConfig{
+		MutualTLS: true,
+		CACert:    filepath.Join(caDir, "ca.crt"),
+		CAKey:     filepath.Join(caDir, "ca.key"),
+	})
+	if err := s.EnsureCertificate(); err != nil {
+		t.Fatalf("EnsureCertificate: %v", err)
+	}
+	return s
+}
+
+func TestIssueNodeCert(t *testing.T) {
+	caDir := t.TempDir()
+	if _, _, err := InitCA(caDir); err != nil {
+		t.Fatalf("InitCA: %v", err)
+	}
+	if _, _, err := InitCA(caDir); err == nil {
+		t.Error("InitCA overwrote an existing CA")
+	}
+	ca, err := LoadCA(filepath.Join(caDir, "ca.crt"), filepath.Join(caDir, "ca.key"))
+	if err != nil {
+		t.Fatalf("LoadCA: %v", err)
+	}
+
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "node.crt"), filepath.Join(dir, "node.key")
+	if err := ca.IssueNodeCert("node-a", []string{"node-a.example.com"}, nil, CertOptions{}, certPath, keyPath); err != nil {
+		t.Fatalf("IssueNodeCert: %v", err)
+	}
+	cert, err := loadCertificate(certPath)
+	if err != nil {
+		t.Fatalf("loadCertificate: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	for _, usage := range []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth} {
+		if nodeID, err := verifyNodeCertificate([]*x509.Certificate{cert}, pool, usage); err != nil || nodeID != "node-a" {
+			t.Errorf("verifyNodeCertificate(usage %v) = %q, %v, want node-a", usage, nodeID, err)
+		}
+	}
+	if got := NodeIDFromCert(cert); got != "node-a" {
+		t.Errorf("NodeIDFromCert = %q, want node-a", got)
+	}
+	if keyTypeOf(cert) != domain.KeyTypeECDSAP256 {
+		t.Errorf("key type = %q, want the ECDSA P-256 default", keyTypeOf(cert))
+	}
+
+	// Reissuing keeps the key
+	if err := ca.IssueNodeCert("node-a", nil, nil, CertOptions{}, certPath, keyPath); err != nil {
+		t.Fatalf("IssueNodeCert: %v", err)
+	}
+	reissued, err := loadCertificate(certPath)
+	if err != nil {
+		t.Fatalf("loadCertificate: %v", err)
+	}
+	if Fingerprint(reissued) != Fingerprint(cert) {
+		t.Error("reissued certificate has a new key")
+	}
+
+	if err := ca.IssueNodeCert("", nil, nil, CertOptions{}, certPath, keyPath); err == nil {
+		t.Error("IssueNodeCert accepted an empty node ID")
+	}
+}
+
+func TestNodeURIRoundTrip(t *testing.T) {
+	for _, nodeID := range []string{"0b5e2a8c-3f0e-4c56-9a57-3b1f5a0f6f51", "seed-probe.example.com-10.0.0.2"} {
+		cert := &x509.Certificate{URIs: []*url.URL{{Scheme: "https", Host: "example.com"}, NodeURI(nodeID)}}
+		if got := NodeIDFromCert(cert); got != nodeID {
+			t.Errorf("NodeIDFromCert(NodeURI(%q)) = %q", nodeID, got)
+		}
+	}
+	if got := NodeIDFromCert(&x509.Certificate{}); got != "" {
+		t.Errorf("NodeIDFromCert without a URI SAN = %q, want empty", got)
+	}
+}
+
+func TestClientTLSConfigReportsPeerNodeID(t *testing.T) {
+	caDir := t.TempDir()
+	if _, _, err := InitCA(caDir); err != nil {
+		t.Fatalf("InitCA: %v", err)
+	}
+	client := newClusterNode(t, caDir, "node-a")
+	peer := newClusterNode(t, caDir, "node-b")
+
+	// A node with a certificate from another cluster's CA
+	otherCA := t.TempDir()
+	if _, _, err := InitCA(otherCA); err != nil {
+		t.Fatalf("InitCA: %v", err)
+	}
+	stranger := newClusterNode(t, otherCA, "node-b")
+
+	serve := func(s *Service) (*httptest.Server, *atomic.Int32) {
+		var requests atomic.Int32
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+		}))
+		config, err := s.ServerTLSConfig()
+		if err != nil {
+			t.Fatalf("ServerTLSConfig: %v", err)
+		}
+		cert, err := s.currentCertificate()
+		if err != nil {
+			t.Fatalf("currentCertificate: %v", err)
+		}
+		// Only the client's checks of the server are under test
+		config.Certificates = []cryptotls.Certificate{*cert}
+		config.ClientAuth = cryptotls.NoClientCert
+		server.TLS = config
+		server.StartTLS()
+		t.Cleanup(server.Close)
+		return server, &requests
+	}
+
+	get := func(url string, verify domain.PeerVerifier) error {
+		config, err := client.ClientTLSConfig(verify)
+		if err != nil {
+			t.Fatalf("ClientTLSConfig: %v", err)
+		}
+		httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		defer httpClient.CloseIdleConnections()
+		resp, err := httpClient.Get(url)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	server, requests := serve(peer)
+	var seen domain.PeerIdentity
+	if err := get(server.URL, func(identity domain.PeerIdentity) error {
+		seen = identity
+		return nil
+	}); err != nil {
+		t.Fatalf("GET from a cluster peer: %v", err)
+	}
+	if seen.NodeID != "node-b" || seen.Fingerprint == "" {
+		t.Errorf("peer identity = %+v, want node-b with a fingerprint", seen)
+	}
+
+	// Refusing the node ID fails the handshake before the request is sent
+	err := get(server.URL, func(identity domain.PeerIdentity) error {
+		if identity.NodeID != "node-c" {
+			return domain.ErrPeerIdentityMismatch
+		}
+		return nil
+	})
+	if !errors.Is(err, domain.ErrPeerIdentityMismatch) {
+		t.Errorf("GET with the wrong node ID = %v, want ErrPeerIdentityMismatch", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("peer received %d requests, want only the accepted one", n)
+	}
+
+	strangerServer, strangerRequests := serve(stranger)
+	if err := get(strangerServer.URL, nil); err == nil {
+		t.Error("GET from a node of another cluster succeeded")
+	}
+	if n := strangerRequests.Load(); n != 0 {
+		t.Errorf("node of another cluster received %d requests", n)
+	}
+}
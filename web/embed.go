@@ -0,0 +1,14 @@
+// Package web holds the page templates and static assets served by the dashboard
+package web
+
+import "embed"
+
+// Static contains the pages, scripts and stylesheets served under /static/
+//
+//go:embed static
+var Static embed.FS
+
+// Templates contains the HTML templates rendered by the reporting service
+//
+//go:embed templates
+var Templates embed.FS
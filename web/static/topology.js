@@ -0,0 +1,228 @@
+// NodeProbe topology view: a small force-directed layout rendered into SVG.
+(function () {
+    'use strict';
+
+    const SVG_NS = 'http://www.w3.org/2000/svg';
+    const WIDTH = 1000;
+    const HEIGHT = 640;
+    const REFRESH_MS = 30000;
+
+    const svg = document.getElementById('graph');
+    const edgeLayer = document.getElementById('edges');
+    const vertexLayer = document.getElementById('vertices');
+    const details = document.getElementById('details');
+    const generatedAt = document.getElementById('generated-at');
+    const pollToggle = document.getElementById('layer-poll');
+    const discoveryToggle = document.getElementById('layer-discovery');
+
+    // Positions survive refreshes so the layout doesn't jump around
+    const positions = new Map();
+    let graph = { nodes: [], edges: [] };
+    let dragging = null;
+
+    function shortID(id) {
+        return id.length > 12 ? id.slice(0, 8) + '…' : id;
+    }
+
+    function position(node, index, count) {
+        if (!positions.has(node.id)) {
+            const angle = (2 * Math.PI * index) / Math.max(count, 1);
+            const radius = node.is_local ? 0 : Math.min(WIDTH, HEIGHT) / 3;
+            positions.set(node.id, {
+                x: WIDTH / 2 + radius * Math.cos(angle),
+                y: HEIGHT / 2 + radius * Math.sin(angle),
+                vx: 0,
+                vy: 0,
+            });
+        }
+        return positions.get(node.id);
+    }
+
+    function visibleEdges() {
+        return graph.edges.filter(function (edge) {
+            if (edge.kind === 'poll') {
+                return pollToggle.checked;
+            }
+            return discoveryToggle.checked;
+        });
+    }
+
+    // One relaxation pass of a simple spring-electrical model
+    function step() {
+        const nodes = graph.nodes.map(function (n) { return positions.get(n.id); });
+
+        for (let i = 0; i < nodes.length; i++) {
+            for (let j = i + 1; j < nodes.length; j++) {
+                const a = nodes[i];
+                const b = nodes[j];
+                let dx = a.x - b.x;
+                let dy = a.y - b.y;
+                const dist2 = Math.max(dx * dx + dy * dy, 100);
+                const force = 8000 / dist2;
+                const dist = Math.sqrt(dist2);
+                dx /= dist;
+                dy /= dist;
+                a.vx += dx * force;
+                a.vy += dy * force;
+                b.vx -= dx * force;
+                b.vy -= dy * force;
+            }
+        }
+
+        graph.edges.forEach(function (edge) {
+            const a = positions.get(edge.source);
+            const b = positions.get(edge.target);
+            if (!a || !b) {
+                return;
+            }
+            const dx = b.x - a.x;
+            const dy = b.y - a.y;
+            const dist = Math.max(Math.sqrt(dx * dx + dy * dy), 1);
+            const force = (dist - 180) * 0.01;
+            a.vx += (dx / dist) * force;
+            a.vy += (dy / dist) * force;
+            b.vx -= (dx / dist) * force;
+            b.vy -= (dy / dist) * force;
+        });
+
+        graph.nodes.forEach(function (node) {
+            const p = positions.get(node.id);
+            if (dragging === node.id) {
+                p.vx = 0;
+                p.vy = 0;
+                return;
+            }
+            p.vx += (WIDTH / 2 - p.x) * 0.002;
+            p.vy += (HEIGHT / 2 - p.y) * 0.002;
+            p.x = Math.min(WIDTH - 30, Math.max(30, p.x + p.vx));
+            p.y = Math.min(HEIGHT - 30, Math.max(30, p.y + p.vy));
+            p.vx *= 0.6;
+            p.vy *= 0.6;
+        });
+    }
+
+    function element(name, attrs) {
+        const el = document.createElementNS(SVG_NS, name);
+        Object.keys(attrs).forEach(function (key) {
+            el.setAttribute(key, attrs[key]);
+        });
+        return el;
+    }
+
+    function describeEdge(edge) {
+        if (edge.kind === 'discovery') {
+            return edge.source + '\n  discovered\n' + edge.target;
+        }
+        return edge.source + ' → ' + edge.target + '\n' +
+            'health:        ' + edge.health + '\n' +
+            'success rate:  ' + edge.success_rate.toFixed(1) + '% (' +
+            (edge.successes || 0) + '/' + edge.polls + ')\n' +
+            'avg response:  ' + (edge.avg_response_ms || 0).toFixed(1) + 'ms\n' +
+            'last response: ' + (edge.last_response_ms || 0) + 'ms';
+    }
+
+    function describeNode(node) {
+        return node.id + (node.is_local ? ' (this node)' : '') + '\n' +
+            'fqdn:          ' + node.fqdn + '\n' +
+            'ip:            ' + node.ip + '\n' +
+            'status:        ' + (node.is_active ? 'active' : 'inactive') + '\n' +
+            'discovered by: ' + (node.discovered_by || '-');
+    }
+
+    function render() {
+        edgeLayer.replaceChildren();
+        vertexLayer.replaceChildren();
+
+        visibleEdges().forEach(function (edge) {
+            const a = positions.get(edge.source);
+            const b = positions.get(edge.target);
+            if (!a || !b) {
+                return;
+            }
+            const line = element('line', {
+                x1: a.x, y1: a.y, x2: b.x, y2: b.y,
+                'class': 'edge ' + (edge.kind === 'poll' ? edge.health : 'discovery'),
+                'marker-end': 'url(#arrow)',
+            });
+            line.addEventListener('mouseenter', function () {
+                details.textContent = describeEdge(edge);
+            });
+            edgeLayer.appendChild(line);
+        });
+
+        graph.nodes.forEach(function (node) {
+            const p = positions.get(node.id);
+            let cls = 'vertex';
+            if (node.is_local) {
+                cls += ' local';
+            } else if (!node.is_active) {
+                cls += ' inactive';
+            }
+            const group = element('g', { 'class': cls, transform: 'translate(' + p.x + ',' + p.y + ')' });
+            group.appendChild(element('circle', { r: node.is_local ? 14 : 11 }));
+            const label = element('text', { x: 16, y: 4 });
+            label.textContent = node.fqdn && node.fqdn !== 'unknown' ? node.fqdn : shortID(node.id);
+            group.appendChild(label);
+            group.addEventListener('mouseenter', function () {
+                details.textContent = describeNode(node);
+            });
+            group.addEventListener('mousedown', function (event) {
+                dragging = node.id;
+                event.preventDefault();
+            });
+            vertexLayer.appendChild(group);
+        });
+    }
+
+    function tick() {
+        step();
+        render();
+        window.requestAnimationFrame(tick);
+    }
+
+    function svgPoint(event) {
+        const point = svg.createSVGPoint();
+        point.x = event.clientX;
+        point.y = event.clientY;
+        return point.matrixTransform(svg.getScreenCTM().inverse());
+    }
+
+    svg.addEventListener('mousemove', function (event) {
+        if (!dragging) {
+            return;
+        }
+        const p = positions.get(dragging);
+        const point = svgPoint(event);
+        p.x = point.x;
+        p.y = point.y;
+    });
+
+    window.addEventListener('mouseup', function () {
+        dragging = null;
+    });
+
+    function load() {
+        fetch('/api/v1/topology', { headers: { 'Accept': 'application/json' } })
+            .then(function (response) {
+                if (!response.ok) {
+                    throw new Error('HTTP ' + response.status);
+                }
+                return response.json();
+            })
+            .then(function (data) {
+                graph = data;
+                graph.nodes.forEach(function (node, index) {
+                    position(node, index, graph.nodes.length);
+                });
+                generatedAt.textContent = 'Generated ' + new Date(data.generated_at).toLocaleString() +
+                    ' · poll window ' + data.window;
+            })
+            .catch(function (err) {
+                details.textContent = 'Failed to load topology: ' + err.message;
+            });
+    }
+
+    load();
+    window.setInterval(load, REFRESH_MS);
+    window.requestAnimationFrame(tick);
+})();